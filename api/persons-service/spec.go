@@ -0,0 +1,14 @@
+package api
+
+import _ "embed"
+
+// Spec is the OpenAPI description of the persons service API, served by the service and used
+// to validate its requests and responses.
+//
+//go:embed openapi.yaml
+var Spec []byte
+
+// GraphQLSchema is the GraphQL schema of the persons service API, served at /graphql.
+//
+//go:embed schema.graphql
+var GraphQLSchema string
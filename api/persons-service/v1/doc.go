@@ -0,0 +1,4 @@
+// Package personsv1 has the protobuf messages and the gRPC service of the persons service.
+package personsv1
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative persons.proto
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/manager"
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/person"
+	"io"
+	"os"
+	"runtime"
+)
+
+// Set at build time with -ldflags "-X main.version=... -X main.commit=...".
+var (
+	version = "dev"
+	commit  = "unknown"
+)
+
+func validateConfig(_ context.Context, r root, args []string) error {
+	if len(args) != 1 || args[0] != "validate" {
+		return fmt.Errorf("%w: expected config validate", errUsage)
+	}
+
+	if err := r.LoadConfig(); err != nil {
+		return err
+	}
+	if err := r.Config().Validate(); err != nil {
+		return fmt.Errorf("%w: %w", manager.ErrConfig, err)
+	}
+
+	fmt.Println("config is valid")
+	return nil
+}
+
+func seed(ctx context.Context, r root, args []string) error {
+	fs := flag.NewFlagSet("seed", flag.ContinueOnError)
+	count := fs.Int("count", 10, "number of persons to create")
+	if err := fs.Parse(args); err != nil || *count < 0 {
+		return fmt.Errorf("%w: seed [--count n]", errUsage)
+	}
+
+	storage, err := openStorage(ctx, r)
+	if err != nil {
+		return err
+	}
+	defer r.Close(ctx)
+
+	n, err := person.Seed(ctx, storage, *count)
+	fmt.Fprintf(os.Stderr, "created %d persons\n", n)
+	return err
+}
+
+func export(ctx context.Context, r root, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	output := fs.String("output", "-", "output file, - for stdout")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("%w: export [--output file]", errUsage)
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "-" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	storage, err := openStorage(ctx, r)
+	if err != nil {
+		return err
+	}
+	defer r.Close(ctx)
+
+	n, err := person.Export(ctx, storage, w)
+	fmt.Fprintf(os.Stderr, "exported %d persons\n", n)
+	return err
+}
+
+func importPersons(ctx context.Context, r root, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	input := fs.String("input", "-", "input file, - for stdin")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("%w: import [--input file]", errUsage)
+	}
+
+	var in io.Reader = os.Stdin
+	if *input != "-" {
+		f, err := os.Open(*input)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	storage, err := openStorage(ctx, r)
+	if err != nil {
+		return err
+	}
+	defer r.Close(ctx)
+
+	n, err := person.Import(ctx, storage, in)
+	fmt.Fprintf(os.Stderr, "imported %d persons\n", n)
+	return err
+}
+
+func printVersion(_ context.Context, _ root, _ []string) error {
+	fmt.Printf("persons-service %s (commit %s, %s)\n", version, commit, runtime.Version())
+	return nil
+}
+
+func openStorage(ctx context.Context, r root) (manager.PersonStorage, error) {
+	if err := r.LoadConfig(); err != nil {
+		return nil, err
+	}
+	return r.OpenStorage(ctx)
+}
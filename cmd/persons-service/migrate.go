@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/manager"
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/migrator"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+func migrate(ctx context.Context, r root, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: migrate needs one of up, down, status, create", errUsage)
+	}
+
+	if err := r.LoadConfig(); err != nil {
+		return err
+	}
+
+	if args[0] == "create" {
+		if len(args) < 2 {
+			return fmt.Errorf("%w: migrate create needs a migration name", errUsage)
+		}
+		return migrator.Create(r.Config().PostgreSQL.MigrationsDir, args[1])
+	}
+
+	if err := r.Connect(ctx); err != nil {
+		return err
+	}
+	defer r.DB().Close()
+
+	m, err := migrator.NewMigrator(r.DB().DB)
+	if err != nil {
+		return fmt.Errorf("%w: %w", manager.ErrStorage, err)
+	}
+
+	switch args[0] {
+	case "up":
+		return m.Up(ctx)
+	case "down":
+		return m.Down(ctx)
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tSTATE\tAPPLIED AT\tSOURCE")
+		for _, s := range statuses {
+			appliedAt := "-"
+			if !s.AppliedAt.IsZero() {
+				appliedAt = s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", s.Source.Version, s.State, appliedAt, s.Source.Path)
+		}
+		return w.Flush()
+	default:
+		return fmt.Errorf("%w: unknown migrate command %q", errUsage, args[0])
+	}
+}
@@ -2,31 +2,133 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/config"
 	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/manager"
+	"github.com/jmoiron/sqlx"
+	"github.com/rs/zerolog"
 	"os"
 	"os/signal"
 	"syscall"
 )
 
+const (
+	exitOK          = 0
+	exitFailure     = 1
+	exitUsage       = 2
+	exitConfig      = 3
+	exitUnavailable = 4
+)
+
+const usage = `usage: persons-service [--config path] [--log-level level] <command> [args]
+
+commands:
+  serve                       start the HTTP server (default)
+  migrate up|down|status      apply, roll back or show migrations
+  migrate create <name>       create a new SQL migration
+  config validate             check the config file and environment
+  seed [--count n]            create persons with generated data
+  export [--output file]      write all persons as JSON lines
+  import [--input file]       create persons from JSON lines
+  version                     print version information
+`
+
+var errUsage = errors.New("invalid usage")
+
 type root interface {
+	LoadConfig() error
+	Connect(ctx context.Context) error
+	Config() *config.Config
+	DB() *sqlx.DB
+	OpenStorage(ctx context.Context) (manager.PersonStorage, error)
+	Close(ctx context.Context) error
 	Register(ctx context.Context) error
 	Resolve(ctx context.Context, shutdown chan os.Signal) os.Signal
-	Release(ctx context.Context, signal os.Signal)
+	Release(ctx context.Context, signal os.Signal) error
+}
+
+type command func(ctx context.Context, r root, args []string) error
+
+var commands = map[string]command{
+	"serve":   serve,
+	"migrate": migrate,
+	"config":  validateConfig,
+	"seed":    seed,
+	"export":  export,
+	"import":  importPersons,
+	"version": printVersion,
 }
 
 func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	fs := flag.NewFlagSet("persons-service", flag.ContinueOnError)
+	fs.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	cfgPath := fs.String("config", config.DefaultPath, "path to the config file")
+	logLevel := fs.String("log-level", "", "log level overriding the config: trace, debug, info, warn, error")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	if *logLevel != "" {
+		if _, err := zerolog.ParseLevel(*logLevel); err != nil {
+			fmt.Fprintf(os.Stderr, "unknown log level %q\n", *logLevel)
+			return exitUsage
+		}
+	}
+
+	name, cmdArgs := "serve", fs.Args()
+	if len(cmdArgs) > 0 {
+		name, cmdArgs = cmdArgs[0], cmdArgs[1:]
+	}
+
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", name, usage)
+		return exitUsage
+	}
+
+	r := manager.NewRoot(*cfgPath, *logLevel)
+	err := cmd(context.Background(), r, cmdArgs)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+
+	return exitCode(err)
+}
+
+func exitCode(err error) int {
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, errUsage):
+		return exitUsage
+	case errors.Is(err, manager.ErrConfig):
+		return exitConfig
+	case errors.Is(err, manager.ErrStorage):
+		return exitUnavailable
+	default:
+		return exitFailure
+	}
+}
+
+func serve(ctx context.Context, r root, _ []string) error {
 	shutdown := make(chan os.Signal, 1)
 	signal.Notify(shutdown, syscall.SIGINT, syscall.SIGTERM)
 
-	var r root
-	r = manager.NewRoot()
-
-	err := r.Register(context.Background())
+	err := r.Register(ctx)
 	if err != nil {
-		os.Exit(1)
+		return err
 	}
 
-	s := r.Resolve(context.Background(), shutdown)
+	s := r.Resolve(ctx, shutdown)
 
-	r.Release(context.Background(), s)
+	return r.Release(ctx, s)
 }
@@ -0,0 +1,194 @@
+package attachment
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/pgutil"
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+	"time"
+)
+
+var attachmentsBucket = []byte("attachments")
+
+// boltRepository stores attachment metadata in the bbolt file of the person storage, one JSON
+// document per key.
+type boltRepository struct {
+	db *bolt.DB
+}
+
+// NewBoltRepository creates the attachment bucket in db. The caller keeps owning db.
+func NewBoltRepository(db *bolt.DB) (*boltRepository, error) {
+	err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(attachmentsBucket)
+		return err
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create attachment bucket")
+	}
+
+	return &boltRepository{db: db}, nil
+}
+
+func boltKey(id int) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(id))
+	return key
+}
+
+// boltGet returns the attachment stored under id if it belongs to the person.
+func boltGet(b *bolt.Bucket, personID, id int) (Attachment, bool, error) {
+	value := b.Get(boltKey(id))
+	if value == nil {
+		return Attachment{}, false, nil
+	}
+
+	a := Attachment{}
+	if err := json.Unmarshal(value, &a); err != nil {
+		return Attachment{}, false, errors.Wrap(err, "failed to decode attachment")
+	}
+	if a.PersonID != personID {
+		return Attachment{}, false, nil
+	}
+	a.ID = &id
+	return a, true, nil
+}
+
+func (r *boltRepository) CreateAttachment(ctx context.Context, attachment Attachment) (int, error) {
+	if err := pgutil.CheckContext(ctx); err != nil {
+		return 0, err
+	}
+
+	var id int
+	err := r.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(attachmentsBucket)
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		id = int(seq)
+		now := time.Now().UTC()
+		attachment.ID, attachment.CreatedAt = nil, &now
+		value, err := json.Marshal(attachment)
+		if err != nil {
+			return errors.Wrap(err, "failed to encode attachment")
+		}
+		return b.Put(boltKey(id), value)
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to create attachment")
+	}
+
+	return id, nil
+}
+
+func (r *boltRepository) GetAttachments(ctx context.Context, personID int) ([]Attachment, error) {
+	if err := pgutil.CheckContext(ctx); err != nil {
+		return []Attachment{}, err
+	}
+
+	res := make([]Attachment, 0)
+	err := r.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(attachmentsBucket).ForEach(func(key, value []byte) error {
+			a := Attachment{}
+			if err := json.Unmarshal(value, &a); err != nil {
+				return errors.Wrap(err, "failed to decode attachment")
+			}
+			if a.PersonID != personID {
+				return nil
+			}
+			id := int(binary.BigEndian.Uint64(key))
+			a.ID = &id
+			res = append(res, a)
+			return nil
+		})
+	})
+	if err != nil {
+		return []Attachment{}, errors.Wrap(err, "failed to get attachments")
+	}
+
+	return res, nil
+}
+
+func (r *boltRepository) GetAttachment(ctx context.Context, personID, id int) (Attachment, error) {
+	if err := pgutil.CheckContext(ctx); err != nil {
+		return Attachment{}, err
+	}
+
+	var res Attachment
+	err := r.db.View(func(tx *bolt.Tx) error {
+		var err error
+		res, _, err = boltGet(tx.Bucket(attachmentsBucket), personID, id)
+		return err
+	})
+	if err != nil {
+		return Attachment{}, errors.Wrap(err, "failed to get attachment")
+	}
+
+	return res, nil
+}
+
+func (r *boltRepository) DeleteAttachment(ctx context.Context, personID, id int) (bool, error) {
+	if err := pgutil.CheckContext(ctx); err != nil {
+		return false, err
+	}
+
+	isDeleted := false
+	err := r.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(attachmentsBucket)
+		_, found, err := boltGet(b, personID, id)
+		if err != nil || !found {
+			return err
+		}
+		isDeleted = true
+		return b.Delete(boltKey(id))
+	})
+	if err != nil {
+		return false, errors.Wrap(err, "failed to delete attachment")
+	}
+
+	return isDeleted, nil
+}
+
+// MoveAttachments gives the attachments of the person fromID to the person toID.
+func (r *boltRepository) MoveAttachments(ctx context.Context, fromID, toID int) error {
+	if err := pgutil.CheckContext(ctx); err != nil {
+		return err
+	}
+
+	err := r.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(attachmentsBucket)
+		moved := make(map[string][]byte)
+		err := b.ForEach(func(key, value []byte) error {
+			a := Attachment{}
+			if err := json.Unmarshal(value, &a); err != nil {
+				return errors.Wrap(err, "failed to decode attachment")
+			}
+			if a.PersonID != fromID {
+				return nil
+			}
+			a.PersonID = toID
+			value, err := json.Marshal(a)
+			if err != nil {
+				return errors.Wrap(err, "failed to encode attachment")
+			}
+			moved[string(key)] = value
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for key, value := range moved {
+			if err = b.Put([]byte(key), value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to move attachments")
+	}
+
+	return nil
+}
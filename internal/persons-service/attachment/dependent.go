@@ -0,0 +1,44 @@
+package attachment
+
+import (
+	"context"
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/blob"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+)
+
+// dependent keeps the attachments of persons, and their content in the blob store, in step
+// with the persons: they move to the kept person of a merge and go with a deleted one.
+type dependent struct {
+	storage storage
+	blobs   blob.Store
+}
+
+func NewDependent(storage storage, blobs blob.Store) *dependent {
+	return &dependent{storage: storage, blobs: blobs}
+}
+
+func (d *dependent) MovePersonRows(ctx context.Context, fromID, toID int) error {
+	return d.storage.MoveAttachments(ctx, fromID, toID)
+}
+
+// DeletePersonRows deletes the attachments of the person and their content. It runs before
+// the person is deleted, as afterwards the database has dropped the rows naming the blobs.
+func (d *dependent) DeletePersonRows(ctx context.Context, personID int) error {
+	attachments, err := d.storage.GetAttachments(ctx, personID)
+	if err != nil {
+		return err
+	}
+
+	logger := zerolog.Ctx(ctx).With().Int("person_id", personID).Logger()
+	for _, a := range attachments {
+		isDeleted, err := d.storage.DeleteAttachment(ctx, personID, *a.ID)
+		if err != nil {
+			return errors.Wrap(err, "failed to delete attachment")
+		}
+		if isDeleted {
+			deleteBlobs(ctx, logger, d.blobs, a)
+		}
+	}
+	return nil
+}
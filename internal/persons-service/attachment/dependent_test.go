@@ -0,0 +1,57 @@
+package attachment
+
+import (
+	"context"
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/blob"
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/person"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func Test_Dependent(t *testing.T) {
+	ctx := context.Background()
+	blobs, err := blob.NewFSStore(t.TempDir())
+	require.NoError(t, err)
+	attachments := NewMemoryRepository()
+	persons := person.NewDependentStorage(person.NewMemoryRepository(), NewDependent(attachments, blobs))
+
+	id, err := persons.CreatePerson(ctx, person.Person{Name: getPointerOnString("kept")})
+	require.NoError(t, err)
+	sourceID, err := persons.CreatePerson(ctx, person.Person{Name: getPointerOnString("merged")})
+	require.NoError(t, err)
+
+	a := Attachment{
+		PersonID:     sourceID,
+		Kind:         KindPhoto,
+		FileName:     "test.png",
+		ContentType:  "image/png",
+		Size:         4,
+		BlobKey:      "persons/2/attachments/0123",
+		ThumbnailKey: getPointerOnString("persons/2/attachments/0123" + thumbnailSuffix),
+	}
+	for _, key := range []string{a.BlobKey, *a.ThumbnailKey} {
+		require.NoError(t, blobs.Put(ctx, key, strings.NewReader("test"), 4, "image/png"))
+	}
+	attachmentID, err := attachments.CreateAttachment(ctx, a)
+	require.NoError(t, err)
+
+	_, err = persons.MergePersons(ctx, id, sourceID)
+	require.NoError(t, err)
+
+	got, err := attachments.GetAttachment(ctx, id, attachmentID)
+	require.NoError(t, err)
+	require.Equal(t, id, got.PersonID)
+
+	isDeleted, err := persons.DeletePerson(ctx, id)
+	require.NoError(t, err)
+	require.True(t, isDeleted)
+
+	got, err = attachments.GetAttachment(ctx, id, attachmentID)
+	require.NoError(t, err)
+	require.Nil(t, got.ID)
+	for _, key := range []string{a.BlobKey, *a.ThumbnailKey} {
+		_, err = blobs.Open(ctx, key)
+		require.ErrorIs(t, err, blob.ErrNotFound)
+	}
+}
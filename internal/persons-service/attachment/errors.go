@@ -0,0 +1,9 @@
+package attachment
+
+import (
+	"github.com/pkg/errors"
+)
+
+// ErrPersonNotFound is returned by storages that know the persons when the person to attach
+// a file to does not exist.
+var ErrPersonNotFound = errors.New("person not found")
@@ -0,0 +1,583 @@
+package attachment
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/blob"
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/config"
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/person"
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/tracing"
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+//go:generate mockgen -source=handler.go  -destination=handler_mocks.go -self_package=github.com/Erlendum/rsoi-lab-01/internal/persons-service/attachment -package=attachment
+
+const (
+	// maxFormOverhead is what a multipart body may add to the file: boundaries, part headers
+	// and the kind field.
+	maxFormOverhead = 64 << 10
+	maxFileNameLen  = 255
+	thumbnailSuffix = ".thumbnail.png"
+)
+
+type storage interface {
+	CreateAttachment(ctx context.Context, attachment Attachment) (int, error)
+	GetAttachments(ctx context.Context, personID int) ([]Attachment, error)
+	GetAttachment(ctx context.Context, personID, id int) (Attachment, error)
+	DeleteAttachment(ctx context.Context, personID, id int) (bool, error)
+	MoveAttachments(ctx context.Context, fromID, toID int) error
+}
+
+// personStorage is the part of the person storage the handler checks persons in.
+type personStorage interface {
+	GetPerson(ctx context.Context, id int, fields ...string) (person.Person, error)
+}
+
+type attachmentResponse struct {
+	ID           int        `json:"id"`
+	PersonID     int        `json:"person_id"`
+	Kind         string     `json:"kind"`
+	FileName     string     `json:"file_name"`
+	ContentType  string     `json:"content_type"`
+	Size         int64      `json:"size"`
+	URL          string     `json:"url"`
+	ThumbnailURL *string    `json:"thumbnail_url,omitempty"`
+	CreatedAt    *time.Time `json:"created_at,omitempty"`
+}
+
+func newAttachmentResponse(a Attachment) attachmentResponse {
+	url := attachmentURL(a.PersonID, *a.ID)
+	resp := attachmentResponse{
+		ID:          *a.ID,
+		PersonID:    a.PersonID,
+		Kind:        a.Kind,
+		FileName:    a.FileName,
+		ContentType: a.ContentType,
+		Size:        a.Size,
+		URL:         url,
+		CreatedAt:   a.CreatedAt,
+	}
+	if a.ThumbnailKey != nil {
+		thumbnailURL := url + "/thumbnail"
+		resp.ThumbnailURL = &thumbnailURL
+	}
+	return resp
+}
+
+func attachmentURL(personID, id int) string {
+	return "/api/v1/persons/" + strconv.Itoa(personID) + "/attachments/" + strconv.Itoa(id)
+}
+
+// uploadError is a rejected upload with the status and message to respond with.
+type uploadError struct {
+	status  int
+	message string
+}
+
+func (e *uploadError) Error() string {
+	return e.message
+}
+
+// upload is a file received in a multipart body, spooled to a temporary file so it can be
+// sniffed, decoded and stored without holding it in memory.
+type upload struct {
+	file     *os.File
+	fileName string
+	kind     string
+	size     int64
+}
+
+func (u *upload) Close() error {
+	err := u.file.Close()
+	if removeErr := os.Remove(u.file.Name()); err == nil {
+		err = removeErr
+	}
+	return err
+}
+
+type handler struct {
+	storage       storage
+	persons       personStorage
+	blobs         blob.Store
+	maxSize       int64
+	thumbnailSize int
+}
+
+func NewHandler(storage storage, persons personStorage, blobs blob.Store, cfg *config.Attachments) *handler {
+	return &handler{
+		storage:       storage,
+		persons:       persons,
+		blobs:         blobs,
+		maxSize:       cfg.MaxSize,
+		thumbnailSize: cfg.ThumbnailSize,
+	}
+}
+
+func (h *handler) Register(echo *echo.Echo) {
+	api := echo.Group("/api/v1")
+
+	api.GET("/persons/:id/attachments", tracing.Handler("attachment.handler.GetAttachments", h.GetAttachments))
+	api.POST("/persons/:id/attachments", tracing.Handler("attachment.handler.CreateAttachment", h.CreateAttachment))
+	api.GET("/persons/:id/attachments/:attachment_id", tracing.Handler("attachment.handler.GetAttachment", h.GetAttachment))
+	api.GET("/persons/:id/attachments/:attachment_id/thumbnail", tracing.Handler("attachment.handler.GetThumbnail", h.GetThumbnail))
+	api.DELETE("/persons/:id/attachments/:attachment_id", tracing.Handler("attachment.handler.DeleteAttachment", h.DeleteAttachment))
+}
+
+// CreateAttachment stores the file of a multipart/form-data body under the person. The
+// content type is sniffed from the content, the one sent by the client is not trusted. Images
+// get a thumbnail; a photo must be an image and replaces the previous photo of the person.
+func (h *handler) CreateAttachment(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		zerolog.Ctx(c.Request().Context()).Warn().Str("id", c.Param("id")).Msg("wrong person id")
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"errors": "wrong id",
+		})
+	}
+
+	logger := zerolog.Ctx(c.Request().Context()).With().Int("person_id", id).Logger()
+
+	if found, err := person.Exists(c, h.persons, id); err != nil || !found {
+		return err
+	}
+
+	u, err := h.readUpload(c)
+	var uerr *uploadError
+	if errors.As(err, &uerr) {
+		logger.Warn().Err(err).Msg("wrong upload")
+		return c.JSON(uerr.status, echo.Map{
+			"errors": uerr.message,
+		})
+	}
+	if err != nil {
+		logger.Error().Err(err).Msg("reading upload error")
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"errors": "creating attachment error",
+		})
+	}
+	defer func() {
+		if err := u.Close(); err != nil {
+			logger.Warn().Err(err).Msg("removing upload file error")
+		}
+	}()
+
+	mtype, err := mimetype.DetectReader(u.file)
+	if err == nil {
+		_, err = u.file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		logger.Error().Err(err).Msg("sniffing content type error")
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"errors": "creating attachment error",
+		})
+	}
+	isImage := imageTypes[mtype.String()]
+
+	if u.kind == KindPhoto && !isImage {
+		logger.Info().Str("content_type", mtype.String()).Msg("photo is not an image")
+		return c.JSON(http.StatusUnsupportedMediaType, echo.Map{
+			"errors": "photo must be a GIF, JPEG, PNG or WebP image",
+		})
+	}
+
+	var thumbnail []byte
+	if isImage {
+		thumbnail, err = makeThumbnail(u.file, h.thumbnailSize)
+		if err != nil && u.kind == KindPhoto {
+			logger.Info().Err(err).Msg("photo can not be decoded")
+			return c.JSON(http.StatusBadRequest, echo.Map{
+				"errors": "photo can not be decoded",
+			})
+		}
+		if err != nil {
+			logger.Info().Err(err).Msg("making thumbnail error")
+		}
+		if _, err = u.file.Seek(0, io.SeekStart); err != nil {
+			logger.Error().Err(err).Msg("rewinding upload error")
+			return c.JSON(http.StatusInternalServerError, echo.Map{
+				"errors": "creating attachment error",
+			})
+		}
+	}
+
+	a, err := h.storeBlobs(c.Request().Context(), id, u, mtype.String(), thumbnail)
+	if err != nil {
+		logger.Error().Err(err).Msg("storing attachment content error")
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"errors": "creating attachment error",
+		})
+	}
+
+	attachmentID, err := h.storage.CreateAttachment(c.Request().Context(), a)
+	if err != nil {
+		deleteBlobs(c.Request().Context(), logger, h.blobs, a)
+	}
+	if errors.Is(err, ErrPersonNotFound) {
+		logger.Info().Msg("person not found")
+		return c.JSON(http.StatusNotFound, echo.Map{
+			"errors": "person not found",
+		})
+	}
+	if err != nil {
+		logger.Error().Err(err).Msg("creating attachment error")
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"errors": "creating attachment error",
+		})
+	}
+
+	if a.Kind == KindPhoto {
+		if err = h.deletePreviousPhotos(c.Request().Context(), logger, id, attachmentID); err != nil {
+			logger.Error().Err(err).Msg("deleting previous photo error")
+			return c.JSON(http.StatusInternalServerError, echo.Map{
+				"errors": "creating attachment error",
+			})
+		}
+	}
+
+	c.Response().Header().Set("Location", attachmentURL(id, attachmentID))
+
+	return c.NoContent(http.StatusCreated)
+}
+
+// readUpload reads the multipart body: the file field with the content and the optional kind
+// field, in any order. Other fields are skipped.
+func (h *handler) readUpload(c echo.Context) (*upload, error) {
+	req := c.Request()
+	req.Body = http.MaxBytesReader(c.Response(), req.Body, h.maxSize+maxFormOverhead)
+
+	reader, err := req.MultipartReader()
+	if err != nil {
+		return nil, &uploadError{status: http.StatusBadRequest, message: "multipart/form-data body expected"}
+	}
+
+	u := &upload{kind: KindDocument}
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return h.failUpload(u, h.readError(err))
+		}
+
+		switch part.FormName() {
+		case "kind":
+			value, err := io.ReadAll(io.LimitReader(part, 32))
+			if err != nil {
+				return h.failUpload(u, h.readError(err))
+			}
+			u.kind = string(value)
+			if u.kind != KindDocument && u.kind != KindPhoto {
+				return h.failUpload(u, &uploadError{status: http.StatusBadRequest, message: "kind must be document or photo"})
+			}
+		case "file":
+			if u.file != nil {
+				return h.failUpload(u, &uploadError{status: http.StatusBadRequest, message: "only one file can be uploaded"})
+			}
+			u.fileName = part.FileName()
+			if u.fileName == "" {
+				return h.failUpload(u, &uploadError{status: http.StatusBadRequest, message: "file name is required"})
+			}
+			if len(u.fileName) > maxFileNameLen || !utf8.ValidString(u.fileName) {
+				return h.failUpload(u, &uploadError{status: http.StatusBadRequest, message: fmt.Sprintf("file name must be valid UTF-8 of at most %d bytes", maxFileNameLen)})
+			}
+
+			if u.file, err = os.CreateTemp("", "attachment-*"); err != nil {
+				return nil, err
+			}
+			u.size, err = io.Copy(u.file, io.LimitReader(part, h.maxSize+1))
+			if err != nil {
+				return h.failUpload(u, h.readError(err))
+			}
+			if u.size > h.maxSize {
+				return h.failUpload(u, h.tooLarge())
+			}
+			if _, err = u.file.Seek(0, io.SeekStart); err != nil {
+				return h.failUpload(u, err)
+			}
+		}
+	}
+
+	if u.file == nil {
+		return nil, &uploadError{status: http.StatusBadRequest, message: "file is required"}
+	}
+	return u, nil
+}
+
+func (h *handler) failUpload(u *upload, err error) (*upload, error) {
+	if u.file != nil {
+		_ = u.Close()
+	}
+	return nil, err
+}
+
+// readError tells a body over the limit from a malformed one.
+func (h *handler) readError(err error) error {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return h.tooLarge()
+	}
+	return &uploadError{status: http.StatusBadRequest, message: "malformed multipart body"}
+}
+
+func (h *handler) tooLarge() error {
+	return &uploadError{
+		status:  http.StatusRequestEntityTooLarge,
+		message: fmt.Sprintf("file must be at most %d bytes", h.maxSize),
+	}
+}
+
+// storeBlobs puts the uploaded file and its thumbnail into the blob store under fresh keys,
+// so a stored attachment is never overwritten.
+func (h *handler) storeBlobs(ctx context.Context, personID int, u *upload, contentType string, thumbnail []byte) (Attachment, error) {
+	key, err := newBlobKey(personID)
+	if err != nil {
+		return Attachment{}, err
+	}
+
+	a := Attachment{
+		PersonID:    personID,
+		Kind:        u.kind,
+		FileName:    u.fileName,
+		ContentType: contentType,
+		Size:        u.size,
+		BlobKey:     key,
+	}
+	if err = h.blobs.Put(ctx, key, u.file, u.size, contentType); err != nil {
+		return Attachment{}, err
+	}
+
+	if thumbnail != nil {
+		thumbnailKey := key + thumbnailSuffix
+		err = h.blobs.Put(ctx, thumbnailKey, bytes.NewReader(thumbnail), int64(len(thumbnail)), "image/png")
+		if err != nil {
+			_ = h.blobs.Delete(ctx, key)
+			return Attachment{}, err
+		}
+		a.ThumbnailKey = &thumbnailKey
+	}
+
+	return a, nil
+}
+
+func newBlobKey(personID int) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "persons/" + strconv.Itoa(personID) + "/attachments/" + hex.EncodeToString(b), nil
+}
+
+// deleteBlobs removes the content of a deleted attachment. A failure only leaves an orphaned
+// object in the store, so it is logged and not returned.
+func deleteBlobs(ctx context.Context, logger zerolog.Logger, blobs blob.Store, a Attachment) {
+	keys := []string{a.BlobKey}
+	if a.ThumbnailKey != nil {
+		keys = append(keys, *a.ThumbnailKey)
+	}
+	for _, key := range keys {
+		if err := blobs.Delete(ctx, key); err != nil {
+			logger.Warn().Err(err).Str("blob_key", key).Msg("deleting attachment content error")
+		}
+	}
+}
+
+func (h *handler) deletePreviousPhotos(ctx context.Context, logger zerolog.Logger, personID, photoID int) error {
+	attachments, err := h.storage.GetAttachments(ctx, personID)
+	if err != nil {
+		return err
+	}
+
+	for _, a := range attachments {
+		if a.Kind != KindPhoto || *a.ID == photoID {
+			continue
+		}
+		isDeleted, err := h.storage.DeleteAttachment(ctx, personID, *a.ID)
+		if err != nil {
+			return err
+		}
+		if isDeleted {
+			deleteBlobs(ctx, logger, h.blobs, a)
+		}
+	}
+	return nil
+}
+
+func (h *handler) GetAttachments(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		zerolog.Ctx(c.Request().Context()).Warn().Str("id", c.Param("id")).Msg("wrong person id")
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"errors": "wrong id",
+		})
+	}
+
+	logger := zerolog.Ctx(c.Request().Context()).With().Int("person_id", id).Logger()
+
+	if found, err := person.Exists(c, h.persons, id); err != nil || !found {
+		return err
+	}
+
+	attachments, err := h.storage.GetAttachments(c.Request().Context(), id)
+	if err != nil {
+		logger.Error().Err(err).Msg("getting attachments error")
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"errors": "getting attachments error",
+		})
+	}
+
+	resp := make([]attachmentResponse, len(attachments), len(attachments))
+	for i, a := range attachments {
+		resp[i] = newAttachmentResponse(a)
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// GetAttachment streams the content of an attachment as a download. Range requests are
+// served, so large files can be fetched in parts and resumed.
+func (h *handler) GetAttachment(c echo.Context) error {
+	a, found, err := h.attachmentExists(c)
+	if err != nil || !found {
+		return err
+	}
+
+	disposition := mime.FormatMediaType("attachment", map[string]string{"filename": a.FileName})
+	if disposition == "" {
+		disposition = "attachment"
+	}
+
+	return h.serveBlob(c, a, a.BlobKey, a.ContentType, disposition)
+}
+
+// GetThumbnail streams the PNG thumbnail of an image attachment.
+func (h *handler) GetThumbnail(c echo.Context) error {
+	a, found, err := h.attachmentExists(c)
+	if err != nil || !found {
+		return err
+	}
+
+	if a.ThumbnailKey == nil {
+		zerolog.Ctx(c.Request().Context()).Info().Int("attachment_id", *a.ID).Msg("attachment has no thumbnail")
+		return c.JSON(http.StatusNotFound, echo.Map{
+			"errors": "thumbnail not found",
+		})
+	}
+
+	return h.serveBlob(c, a, *a.ThumbnailKey, "image/png", "inline")
+}
+
+func (h *handler) serveBlob(c echo.Context, a Attachment, key, contentType, disposition string) error {
+	logger := zerolog.Ctx(c.Request().Context()).With().Int("person_id", a.PersonID).Int("attachment_id", *a.ID).Logger()
+
+	obj, err := h.blobs.Open(c.Request().Context(), key)
+	if errors.Is(err, blob.ErrNotFound) {
+		logger.Error().Str("blob_key", key).Msg("attachment content is missing")
+		return c.JSON(http.StatusNotFound, echo.Map{
+			"errors": "attachment content not found",
+		})
+	}
+	if err != nil {
+		logger.Error().Err(err).Msg("opening attachment content error")
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"errors": "getting attachment error",
+		})
+	}
+	defer obj.Close()
+
+	header := c.Response().Header()
+	header.Set("Content-Type", contentType)
+	header.Set("Content-Disposition", disposition)
+	header.Set("X-Content-Type-Options", "nosniff")
+	// Blob keys are never reused, so the key identifies the content.
+	header.Set("ETag", `"`+path.Base(key)+`"`)
+
+	var modTime time.Time
+	if a.CreatedAt != nil {
+		modTime = *a.CreatedAt
+	}
+	http.ServeContent(c.Response(), c.Request(), "", modTime, obj)
+
+	return nil
+}
+
+func (h *handler) DeleteAttachment(c echo.Context) error {
+	a, found, err := h.attachmentExists(c)
+	if err != nil || !found {
+		return err
+	}
+
+	logger := zerolog.Ctx(c.Request().Context()).With().Int("person_id", a.PersonID).Int("attachment_id", *a.ID).Logger()
+
+	isDeleted, err := h.storage.DeleteAttachment(c.Request().Context(), a.PersonID, *a.ID)
+	if err != nil {
+		logger.Error().Err(err).Msg("deleting attachment error")
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"errors": "deleting attachment error",
+		})
+	}
+
+	if !isDeleted {
+		logger.Info().Msg("attachment not found")
+		return c.JSON(http.StatusNotFound, echo.Map{
+			"errors": "attachment not found",
+		})
+	}
+
+	deleteBlobs(c.Request().Context(), logger, h.blobs, a)
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// attachmentExists parses the path, writes the error response and returns false if the
+// attachment can not be read or does not exist.
+func (h *handler) attachmentExists(c echo.Context) (Attachment, bool, error) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		zerolog.Ctx(c.Request().Context()).Warn().Str("id", c.Param("id")).Msg("wrong person id")
+		return Attachment{}, false, c.JSON(http.StatusBadRequest, echo.Map{
+			"errors": "wrong id",
+		})
+	}
+
+	attachmentID, err := strconv.Atoi(c.Param("attachment_id"))
+	if err != nil {
+		zerolog.Ctx(c.Request().Context()).Warn().Str("attachment_id", c.Param("attachment_id")).Msg("wrong attachment id")
+		return Attachment{}, false, c.JSON(http.StatusBadRequest, echo.Map{
+			"errors": "wrong attachment id",
+		})
+	}
+
+	logger := zerolog.Ctx(c.Request().Context()).With().Int("person_id", id).Int("attachment_id", attachmentID).Logger()
+
+	a, err := h.storage.GetAttachment(c.Request().Context(), id, attachmentID)
+	if err != nil {
+		logger.Error().Err(err).Msg("getting attachment error")
+		return Attachment{}, false, c.JSON(http.StatusInternalServerError, echo.Map{
+			"errors": "getting attachment error",
+		})
+	}
+
+	if a.ID == nil {
+		logger.Info().Msg("attachment not found")
+		return Attachment{}, false, c.JSON(http.StatusNotFound, echo.Map{
+			"errors": "attachment not found",
+		})
+	}
+
+	return a, true, nil
+}
@@ -0,0 +1,153 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: handler.go
+
+// Package attachment is a generated GoMock package.
+package attachment
+
+import (
+	context "context"
+	reflect "reflect"
+
+	person "github.com/Erlendum/rsoi-lab-01/internal/persons-service/person"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// Mockstorage is a mock of storage interface.
+type Mockstorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockstorageMockRecorder
+}
+
+// MockstorageMockRecorder is the mock recorder for Mockstorage.
+type MockstorageMockRecorder struct {
+	mock *Mockstorage
+}
+
+// NewMockstorage creates a new mock instance.
+func NewMockstorage(ctrl *gomock.Controller) *Mockstorage {
+	mock := &Mockstorage{ctrl: ctrl}
+	mock.recorder = &MockstorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *Mockstorage) EXPECT() *MockstorageMockRecorder {
+	return m.recorder
+}
+
+// CreateAttachment mocks base method.
+func (m *Mockstorage) CreateAttachment(ctx context.Context, attachment Attachment) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateAttachment", ctx, attachment)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateAttachment indicates an expected call of CreateAttachment.
+func (mr *MockstorageMockRecorder) CreateAttachment(ctx, attachment interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAttachment", reflect.TypeOf((*Mockstorage)(nil).CreateAttachment), ctx, attachment)
+}
+
+// DeleteAttachment mocks base method.
+func (m *Mockstorage) DeleteAttachment(ctx context.Context, personID, id int) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteAttachment", ctx, personID, id)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteAttachment indicates an expected call of DeleteAttachment.
+func (mr *MockstorageMockRecorder) DeleteAttachment(ctx, personID, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteAttachment", reflect.TypeOf((*Mockstorage)(nil).DeleteAttachment), ctx, personID, id)
+}
+
+// GetAttachment mocks base method.
+func (m *Mockstorage) GetAttachment(ctx context.Context, personID, id int) (Attachment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAttachment", ctx, personID, id)
+	ret0, _ := ret[0].(Attachment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAttachment indicates an expected call of GetAttachment.
+func (mr *MockstorageMockRecorder) GetAttachment(ctx, personID, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAttachment", reflect.TypeOf((*Mockstorage)(nil).GetAttachment), ctx, personID, id)
+}
+
+// GetAttachments mocks base method.
+func (m *Mockstorage) GetAttachments(ctx context.Context, personID int) ([]Attachment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAttachments", ctx, personID)
+	ret0, _ := ret[0].([]Attachment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAttachments indicates an expected call of GetAttachments.
+func (mr *MockstorageMockRecorder) GetAttachments(ctx, personID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAttachments", reflect.TypeOf((*Mockstorage)(nil).GetAttachments), ctx, personID)
+}
+
+// MoveAttachments mocks base method.
+func (m *Mockstorage) MoveAttachments(ctx context.Context, fromID, toID int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MoveAttachments", ctx, fromID, toID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MoveAttachments indicates an expected call of MoveAttachments.
+func (mr *MockstorageMockRecorder) MoveAttachments(ctx, fromID, toID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MoveAttachments", reflect.TypeOf((*Mockstorage)(nil).MoveAttachments), ctx, fromID, toID)
+}
+
+// MockpersonStorage is a mock of personStorage interface.
+type MockpersonStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockpersonStorageMockRecorder
+}
+
+// MockpersonStorageMockRecorder is the mock recorder for MockpersonStorage.
+type MockpersonStorageMockRecorder struct {
+	mock *MockpersonStorage
+}
+
+// NewMockpersonStorage creates a new mock instance.
+func NewMockpersonStorage(ctrl *gomock.Controller) *MockpersonStorage {
+	mock := &MockpersonStorage{ctrl: ctrl}
+	mock.recorder = &MockpersonStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockpersonStorage) EXPECT() *MockpersonStorageMockRecorder {
+	return m.recorder
+}
+
+// GetPerson mocks base method.
+func (m *MockpersonStorage) GetPerson(ctx context.Context, id int, fields ...string) (person.Person, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx, id}
+	for _, a := range fields {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetPerson", varargs...)
+	ret0, _ := ret[0].(person.Person)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPerson indicates an expected call of GetPerson.
+func (mr *MockpersonStorageMockRecorder) GetPerson(ctx, id interface{}, fields ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx, id}, fields...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPerson", reflect.TypeOf((*MockpersonStorage)(nil).GetPerson), varargs...)
+}
@@ -0,0 +1,592 @@
+package attachment
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/blob"
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/config"
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/person"
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type handlerTestFields struct {
+	storage *Mockstorage
+	persons *MockpersonStorage
+	blobs   blob.Store
+}
+
+func createHandlerTestFields(t *testing.T, ctrl *gomock.Controller) *handlerTestFields {
+	blobs, err := blob.NewFSStore(t.TempDir())
+	require.NoError(t, err)
+
+	return &handlerTestFields{
+		storage: NewMockstorage(ctrl),
+		persons: NewMockpersonStorage(ctrl),
+		blobs:   blobs,
+	}
+}
+
+func newTestHandler(fields *handlerTestFields) *handler {
+	return NewHandler(fields.storage, fields.persons, fields.blobs, &config.Attachments{
+		MaxSize:       1024,
+		ThumbnailSize: 8,
+	})
+}
+
+func getPointerOnString(s string) *string {
+	return &s
+}
+
+func getPointerOnInt(i int) *int {
+	return &i
+}
+
+func pngImage(t *testing.T, width, height int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		img.Set(x, x%height, color.RGBA{R: 255, A: 255})
+	}
+	buf := &bytes.Buffer{}
+	require.NoError(t, png.Encode(buf, img))
+	return buf.Bytes()
+}
+
+// multipartBody builds an upload form; an empty fileName leaves the file field out.
+func multipartBody(t *testing.T, kind, fileName string, content []byte) (io.Reader, string) {
+	body := &bytes.Buffer{}
+	w := multipart.NewWriter(body)
+	if fileName != "" {
+		part, err := w.CreateFormFile("file", fileName)
+		require.NoError(t, err)
+		_, err = part.Write(content)
+		require.NoError(t, err)
+	}
+	if kind != "" {
+		require.NoError(t, w.WriteField("kind", kind))
+	}
+	require.NoError(t, w.Close())
+	return body, w.FormDataContentType()
+}
+
+func storedPerson(fields *handlerTestFields) {
+	fields.persons.EXPECT().GetPerson(gomock.Any(), 1).Return(person.Person{ID: getPointerOnInt(1)}, nil)
+}
+
+func Test_CreateAttachment(t *testing.T) {
+	type fields struct {
+		id                     string
+		kind                   string
+		fileName               string
+		content                []byte
+		contentType            string
+		expectedHTTPCode       int
+		expectedLocationHeader string
+	}
+
+	e := echo.New()
+
+	tests := []struct {
+		name    string
+		fields  fields
+		Prepare func(t *testing.T, fields *handlerTestFields)
+	}{
+		{
+			name: "http-code 400: wrong id",
+			fields: fields{
+				id:               "test",
+				fileName:         "test.txt",
+				content:          []byte("test"),
+				expectedHTTPCode: http.StatusBadRequest,
+			},
+			Prepare: func(t *testing.T, fields *handlerTestFields) {},
+		},
+		{
+			name: "http-code 404: person not found",
+			fields: fields{
+				id:               "1",
+				fileName:         "test.txt",
+				content:          []byte("test"),
+				expectedHTTPCode: http.StatusNotFound,
+			},
+			Prepare: func(t *testing.T, fields *handlerTestFields) {
+				fields.persons.EXPECT().GetPerson(gomock.Any(), 1).Return(person.Person{}, nil)
+			},
+		},
+		{
+			name: "http-code 400: not a multipart body",
+			fields: fields{
+				id:               "1",
+				contentType:      "application/json",
+				expectedHTTPCode: http.StatusBadRequest,
+			},
+			Prepare: func(t *testing.T, fields *handlerTestFields) {
+				storedPerson(fields)
+			},
+		},
+		{
+			name: "http-code 400: no file",
+			fields: fields{
+				id:               "1",
+				kind:             KindDocument,
+				expectedHTTPCode: http.StatusBadRequest,
+			},
+			Prepare: func(t *testing.T, fields *handlerTestFields) {
+				storedPerson(fields)
+			},
+		},
+		{
+			name: "http-code 400: wrong kind",
+			fields: fields{
+				id:               "1",
+				kind:             "avatar",
+				fileName:         "test.txt",
+				content:          []byte("test"),
+				expectedHTTPCode: http.StatusBadRequest,
+			},
+			Prepare: func(t *testing.T, fields *handlerTestFields) {
+				storedPerson(fields)
+			},
+		},
+		{
+			name: "http-code 413",
+			fields: fields{
+				id:               "1",
+				fileName:         "test.txt",
+				content:          bytes.Repeat([]byte("a"), 1025),
+				expectedHTTPCode: http.StatusRequestEntityTooLarge,
+			},
+			Prepare: func(t *testing.T, fields *handlerTestFields) {
+				storedPerson(fields)
+			},
+		},
+		{
+			name: "http-code 415: photo is not an image",
+			fields: fields{
+				id:               "1",
+				kind:             KindPhoto,
+				fileName:         "photo.png",
+				content:          []byte("test"),
+				expectedHTTPCode: http.StatusUnsupportedMediaType,
+			},
+			Prepare: func(t *testing.T, fields *handlerTestFields) {
+				storedPerson(fields)
+			},
+		},
+		{
+			name: "http-code 500: storage error removes the content",
+			fields: fields{
+				id:               "1",
+				fileName:         "test.txt",
+				content:          []byte("test"),
+				expectedHTTPCode: http.StatusInternalServerError,
+			},
+			Prepare: func(t *testing.T, fields *handlerTestFields) {
+				storedPerson(fields)
+				fields.storage.EXPECT().CreateAttachment(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, a Attachment) (int, error) {
+						t.Cleanup(func() {
+							_, err := fields.blobs.Open(context.Background(), a.BlobKey)
+							require.ErrorIs(t, err, blob.ErrNotFound)
+						})
+						return 0, errors.New("")
+					})
+			},
+		},
+		{
+			name: "http-code 201: document with sniffed content type",
+			fields: fields{
+				id:                     "1",
+				fileName:               "test.png",
+				content:                []byte("plain text"),
+				expectedHTTPCode:       http.StatusCreated,
+				expectedLocationHeader: "/api/v1/persons/1/attachments/2",
+			},
+			Prepare: func(t *testing.T, fields *handlerTestFields) {
+				storedPerson(fields)
+				fields.storage.EXPECT().CreateAttachment(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, a Attachment) (int, error) {
+						require.Equal(t, 1, a.PersonID)
+						require.Equal(t, KindDocument, a.Kind)
+						require.Equal(t, "test.png", a.FileName)
+						require.Equal(t, "text/plain; charset=utf-8", a.ContentType)
+						require.Equal(t, int64(10), a.Size)
+						require.Nil(t, a.ThumbnailKey)
+
+						obj, err := fields.blobs.Open(context.Background(), a.BlobKey)
+						require.NoError(t, err)
+						defer obj.Close()
+						content, err := io.ReadAll(obj)
+						require.NoError(t, err)
+						require.Equal(t, "plain text", string(content))
+						return 2, nil
+					})
+			},
+		},
+		{
+			name: "http-code 201: photo with thumbnail replaces the previous photo",
+			fields: fields{
+				id:                     "1",
+				kind:                   KindPhoto,
+				fileName:               "photo.bin",
+				content:                pngImage(t, 32, 16),
+				expectedHTTPCode:       http.StatusCreated,
+				expectedLocationHeader: "/api/v1/persons/1/attachments/3",
+			},
+			Prepare: func(t *testing.T, fields *handlerTestFields) {
+				storedPerson(fields)
+
+				previous := Attachment{ID: getPointerOnInt(1), PersonID: 1, Kind: KindPhoto, BlobKey: "persons/1/attachments/previous"}
+				require.NoError(t, fields.blobs.Put(context.Background(), previous.BlobKey, strings.NewReader("old"), 3, "image/png"))
+
+				var created Attachment
+				fields.storage.EXPECT().CreateAttachment(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, a Attachment) (int, error) {
+						require.Equal(t, KindPhoto, a.Kind)
+						require.Equal(t, "image/png", a.ContentType)
+						require.NotNil(t, a.ThumbnailKey)
+
+						obj, err := fields.blobs.Open(context.Background(), *a.ThumbnailKey)
+						require.NoError(t, err)
+						defer obj.Close()
+						thumbnail, err := png.Decode(obj)
+						require.NoError(t, err)
+						require.Equal(t, image.Rect(0, 0, 8, 4), thumbnail.Bounds())
+
+						created = a
+						created.ID = getPointerOnInt(3)
+						return 3, nil
+					})
+				fields.storage.EXPECT().GetAttachments(gomock.Any(), 1).DoAndReturn(func(context.Context, int) ([]Attachment, error) {
+					return []Attachment{
+						previous,
+						{ID: getPointerOnInt(2), PersonID: 1, Kind: KindDocument, BlobKey: "persons/1/attachments/document"},
+						created,
+					}, nil
+				})
+				fields.storage.EXPECT().DeleteAttachment(gomock.Any(), 1, 1).Return(true, nil)
+				t.Cleanup(func() {
+					_, err := fields.blobs.Open(context.Background(), previous.BlobKey)
+					require.ErrorIs(t, err, blob.ErrNotFound)
+				})
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			testFields := createHandlerTestFields(t, ctrl)
+			tt.Prepare(t, testFields)
+
+			h := newTestHandler(testFields)
+
+			body, contentType := multipartBody(t, tt.fields.kind, tt.fields.fileName, tt.fields.content)
+			if tt.fields.contentType != "" {
+				contentType = tt.fields.contentType
+			}
+			req := httptest.NewRequest(http.MethodPost, "/test", body)
+			req.Header.Set("Content-Type", contentType)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetParamNames("id")
+			c.SetParamValues(tt.fields.id)
+
+			err := h.CreateAttachment(c)
+
+			require.NoError(t, err)
+			require.Equal(t, tt.fields.expectedHTTPCode, rec.Code)
+			require.Equal(t, tt.fields.expectedLocationHeader, rec.Header().Get("Location"))
+		})
+	}
+}
+
+func Test_GetAttachments(t *testing.T) {
+	e := echo.New()
+	ctrl := gomock.NewController(t)
+
+	testFields := createHandlerTestFields(t, ctrl)
+	storedPerson(testFields)
+	testFields.storage.EXPECT().GetAttachments(gomock.Any(), 1).Return([]Attachment{
+		{ID: getPointerOnInt(1), PersonID: 1, Kind: KindDocument, FileName: "cv.pdf", ContentType: "application/pdf", Size: 10},
+		{ID: getPointerOnInt(2), PersonID: 1, Kind: KindPhoto, FileName: "photo.png", ContentType: "image/png", Size: 20,
+			ThumbnailKey: getPointerOnString("thumbnail")},
+	}, nil)
+
+	h := newTestHandler(testFields)
+
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	require.NoError(t, h.GetAttachments(c))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var resp []map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Len(t, resp, 2)
+	require.Equal(t, "/api/v1/persons/1/attachments/1", resp[0]["url"])
+	require.NotContains(t, resp[0], "thumbnail_url")
+	require.NotContains(t, resp[0], "blob_key")
+	require.Equal(t, "/api/v1/persons/1/attachments/2/thumbnail", resp[1]["thumbnail_url"])
+}
+
+func Test_GetAttachment(t *testing.T) {
+	type fields struct {
+		attachmentID     string
+		rangeHeader      string
+		expectedHTTPCode int
+		expectedBody     string
+	}
+
+	e := echo.New()
+	createdAt := time.Date(2026, time.October, 19, 12, 0, 0, 0, time.UTC)
+	stored := Attachment{
+		ID:          getPointerOnInt(1),
+		PersonID:    1,
+		Kind:        KindDocument,
+		FileName:    "отчёт.txt",
+		ContentType: "text/plain; charset=utf-8",
+		Size:        10,
+		BlobKey:     "persons/1/attachments/0123",
+		CreatedAt:   &createdAt,
+	}
+
+	tests := []struct {
+		name    string
+		fields  fields
+		Prepare func(fields *handlerTestFields)
+	}{
+		{
+			name: "http-code 400: wrong attachment id",
+			fields: fields{
+				attachmentID:     "test",
+				expectedHTTPCode: http.StatusBadRequest,
+			},
+			Prepare: func(fields *handlerTestFields) {},
+		},
+		{
+			name: "http-code 404",
+			fields: fields{
+				attachmentID:     "2",
+				expectedHTTPCode: http.StatusNotFound,
+			},
+			Prepare: func(fields *handlerTestFields) {
+				fields.storage.EXPECT().GetAttachment(gomock.Any(), 1, 2).Return(Attachment{}, nil)
+			},
+		},
+		{
+			name: "http-code 200",
+			fields: fields{
+				attachmentID:     "1",
+				expectedHTTPCode: http.StatusOK,
+				expectedBody:     "0123456789",
+			},
+			Prepare: func(fields *handlerTestFields) {
+				fields.storage.EXPECT().GetAttachment(gomock.Any(), 1, 1).Return(stored, nil)
+			},
+		},
+		{
+			name: "http-code 206: range",
+			fields: fields{
+				attachmentID:     "1",
+				rangeHeader:      "bytes=2-5",
+				expectedHTTPCode: http.StatusPartialContent,
+				expectedBody:     "2345",
+			},
+			Prepare: func(fields *handlerTestFields) {
+				fields.storage.EXPECT().GetAttachment(gomock.Any(), 1, 1).Return(stored, nil)
+			},
+		},
+		{
+			name: "http-code 416: range out of the content",
+			fields: fields{
+				attachmentID:     "1",
+				rangeHeader:      "bytes=20-",
+				expectedHTTPCode: http.StatusRequestedRangeNotSatisfiable,
+			},
+			Prepare: func(fields *handlerTestFields) {
+				fields.storage.EXPECT().GetAttachment(gomock.Any(), 1, 1).Return(stored, nil)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			testFields := createHandlerTestFields(t, ctrl)
+			require.NoError(t, testFields.blobs.Put(context.Background(), stored.BlobKey, strings.NewReader("0123456789"), 10, ""))
+			tt.Prepare(testFields)
+
+			h := newTestHandler(testFields)
+
+			req := httptest.NewRequest(http.MethodGet, "/test", nil)
+			if tt.fields.rangeHeader != "" {
+				req.Header.Set("Range", tt.fields.rangeHeader)
+			}
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetParamNames("id", "attachment_id")
+			c.SetParamValues("1", tt.fields.attachmentID)
+
+			err := h.GetAttachment(c)
+
+			require.NoError(t, err)
+			require.Equal(t, tt.fields.expectedHTTPCode, rec.Code)
+			if tt.fields.expectedBody != "" {
+				require.Equal(t, tt.fields.expectedBody, rec.Body.String())
+				require.Equal(t, "text/plain; charset=utf-8", rec.Header().Get("Content-Type"))
+				require.Equal(t, "attachment; filename*=utf-8''%D0%BE%D1%82%D1%87%D1%91%D1%82.txt", rec.Header().Get("Content-Disposition"))
+				require.Equal(t, "nosniff", rec.Header().Get("X-Content-Type-Options"))
+				require.Equal(t, "bytes", rec.Header().Get("Accept-Ranges"))
+			}
+		})
+	}
+}
+
+func Test_GetThumbnail(t *testing.T) {
+	e := echo.New()
+
+	tests := []struct {
+		name             string
+		attachment       Attachment
+		expectedHTTPCode int
+	}{
+		{
+			name:             "http-code 404: no thumbnail",
+			attachment:       Attachment{ID: getPointerOnInt(1), PersonID: 1, BlobKey: "persons/1/attachments/0123"},
+			expectedHTTPCode: http.StatusNotFound,
+		},
+		{
+			name: "http-code 200",
+			attachment: Attachment{ID: getPointerOnInt(1), PersonID: 1, BlobKey: "persons/1/attachments/0123",
+				ThumbnailKey: getPointerOnString("persons/1/attachments/0123" + thumbnailSuffix)},
+			expectedHTTPCode: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			testFields := createHandlerTestFields(t, ctrl)
+			thumbnail := pngImage(t, 4, 4)
+			require.NoError(t, testFields.blobs.Put(context.Background(), "persons/1/attachments/0123"+thumbnailSuffix,
+				bytes.NewReader(thumbnail), int64(len(thumbnail)), "image/png"))
+			testFields.storage.EXPECT().GetAttachment(gomock.Any(), 1, 1).Return(tt.attachment, nil)
+
+			h := newTestHandler(testFields)
+
+			req := httptest.NewRequest(http.MethodGet, "/test", nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetParamNames("id", "attachment_id")
+			c.SetParamValues("1", "1")
+
+			require.NoError(t, h.GetThumbnail(c))
+			require.Equal(t, tt.expectedHTTPCode, rec.Code)
+			if tt.expectedHTTPCode == http.StatusOK {
+				require.Equal(t, "image/png", rec.Header().Get("Content-Type"))
+				require.Equal(t, thumbnail, rec.Body.Bytes())
+			}
+		})
+	}
+}
+
+func Test_DeleteAttachment(t *testing.T) {
+	e := echo.New()
+
+	tests := []struct {
+		name             string
+		Prepare          func(fields *handlerTestFields)
+		expectedHTTPCode int
+	}{
+		{
+			name: "http-code 404",
+			Prepare: func(fields *handlerTestFields) {
+				fields.storage.EXPECT().GetAttachment(gomock.Any(), 1, 1).Return(Attachment{}, nil)
+			},
+			expectedHTTPCode: http.StatusNotFound,
+		},
+		{
+			name: "http-code 500",
+			Prepare: func(fields *handlerTestFields) {
+				fields.storage.EXPECT().GetAttachment(gomock.Any(), 1, 1).
+					Return(Attachment{ID: getPointerOnInt(1), PersonID: 1, BlobKey: "persons/1/attachments/0123"}, nil)
+				fields.storage.EXPECT().DeleteAttachment(gomock.Any(), 1, 1).Return(false, errors.New(""))
+			},
+			expectedHTTPCode: http.StatusInternalServerError,
+		},
+		{
+			name: "http-code 204",
+			Prepare: func(fields *handlerTestFields) {
+				fields.storage.EXPECT().GetAttachment(gomock.Any(), 1, 1).
+					Return(Attachment{ID: getPointerOnInt(1), PersonID: 1, BlobKey: "persons/1/attachments/0123"}, nil)
+				fields.storage.EXPECT().DeleteAttachment(gomock.Any(), 1, 1).Return(true, nil)
+			},
+			expectedHTTPCode: http.StatusNoContent,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			testFields := createHandlerTestFields(t, ctrl)
+			require.NoError(t, testFields.blobs.Put(context.Background(), "persons/1/attachments/0123", strings.NewReader("test"), 4, ""))
+			tt.Prepare(testFields)
+
+			h := newTestHandler(testFields)
+
+			req := httptest.NewRequest(http.MethodDelete, "/test", nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetParamNames("id", "attachment_id")
+			c.SetParamValues("1", "1")
+
+			require.NoError(t, h.DeleteAttachment(c))
+			require.Equal(t, tt.expectedHTTPCode, rec.Code)
+
+			_, err := testFields.blobs.Open(context.Background(), "persons/1/attachments/0123")
+			if tt.expectedHTTPCode == http.StatusNoContent {
+				require.ErrorIs(t, err, blob.ErrNotFound)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func Test_thumbnailBounds(t *testing.T) {
+	tests := []struct {
+		width, height, expectedWidth, expectedHeight int
+	}{
+		{width: 100, height: 50, expectedWidth: 10, expectedHeight: 5},
+		{width: 50, height: 100, expectedWidth: 5, expectedHeight: 10},
+		{width: 1000, height: 1, expectedWidth: 10, expectedHeight: 1},
+		{width: 8, height: 6, expectedWidth: 8, expectedHeight: 6},
+	}
+
+	for _, tt := range tests {
+		width, height := thumbnailBounds(tt.width, tt.height, 10)
+		require.Equal(t, tt.expectedWidth, width)
+		require.Equal(t, tt.expectedHeight, height)
+	}
+}
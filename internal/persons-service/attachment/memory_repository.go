@@ -0,0 +1,118 @@
+package attachment
+
+import (
+	"context"
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/pgutil"
+	"sort"
+	"sync"
+	"time"
+)
+
+// memoryRepository keeps attachment metadata in process memory. It is meant for tests and
+// local runs without a database; the data is lost on restart.
+type memoryRepository struct {
+	mu          sync.RWMutex
+	attachments map[int]Attachment
+	lastID      int
+}
+
+func NewMemoryRepository() *memoryRepository {
+	return &memoryRepository{
+		attachments: make(map[int]Attachment),
+	}
+}
+
+func (r *memoryRepository) CreateAttachment(ctx context.Context, attachment Attachment) (int, error) {
+	if err := pgutil.CheckContext(ctx); err != nil {
+		return 0, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lastID++
+	id := r.lastID
+	now := time.Now().UTC()
+	attachment = cloneAttachment(attachment)
+	attachment.ID, attachment.CreatedAt = &id, &now
+	r.attachments[id] = attachment
+
+	return id, nil
+}
+
+func (r *memoryRepository) GetAttachments(ctx context.Context, personID int) ([]Attachment, error) {
+	if err := pgutil.CheckContext(ctx); err != nil {
+		return []Attachment{}, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	res := make([]Attachment, 0)
+	for _, a := range r.attachments {
+		if a.PersonID == personID {
+			res = append(res, cloneAttachment(a))
+		}
+	}
+	sortByID(res)
+
+	return res, nil
+}
+
+func (r *memoryRepository) GetAttachment(ctx context.Context, personID, id int) (Attachment, error) {
+	if err := pgutil.CheckContext(ctx); err != nil {
+		return Attachment{}, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	a, ok := r.attachments[id]
+	if !ok || a.PersonID != personID {
+		return Attachment{}, nil
+	}
+
+	return cloneAttachment(a), nil
+}
+
+func (r *memoryRepository) DeleteAttachment(ctx context.Context, personID, id int) (bool, error) {
+	if err := pgutil.CheckContext(ctx); err != nil {
+		return false, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	a, ok := r.attachments[id]
+	if !ok || a.PersonID != personID {
+		return false, nil
+	}
+	delete(r.attachments, id)
+
+	return true, nil
+}
+
+// MoveAttachments gives the attachments of the person fromID to the person toID.
+func (r *memoryRepository) MoveAttachments(ctx context.Context, fromID, toID int) error {
+	if err := pgutil.CheckContext(ctx); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, a := range r.attachments {
+		if a.PersonID == fromID {
+			a.PersonID = toID
+			r.attachments[id] = a
+		}
+	}
+
+	return nil
+}
+
+func sortByID(attachments []Attachment) {
+	sort.Slice(attachments, func(i, j int) bool {
+		return *attachments[i].ID < *attachments[j].ID
+	})
+}
@@ -0,0 +1,42 @@
+package attachment
+
+import (
+	"time"
+)
+
+const (
+	// KindDocument is any file attached to a person.
+	KindDocument = "document"
+	// KindPhoto is the image of a person. A person has at most one photo, uploading a new one
+	// replaces it.
+	KindPhoto = "photo"
+)
+
+// Attachment describes a file attached to a person. The content is kept in a blob store under
+// BlobKey, the metadata in the storage.
+type Attachment struct {
+	ID           *int       `db:"id" json:"id,omitempty"`
+	PersonID     int        `db:"person_id" json:"person_id"`
+	Kind         string     `db:"kind" json:"kind"`
+	FileName     string     `db:"file_name" json:"file_name"`
+	ContentType  string     `db:"content_type" json:"content_type"`
+	Size         int64      `db:"size" json:"size"`
+	BlobKey      string     `db:"blob_key" json:"blob_key"`
+	ThumbnailKey *string    `db:"thumbnail_key" json:"thumbnail_key,omitempty"`
+	CreatedAt    *time.Time `db:"created_at" json:"created_at,omitempty"`
+}
+
+func cloneAttachment(a Attachment) Attachment {
+	a.ID = clonePointer(a.ID)
+	a.ThumbnailKey = clonePointer(a.ThumbnailKey)
+	a.CreatedAt = clonePointer(a.CreatedAt)
+	return a
+}
+
+func clonePointer[T any](v *T) *T {
+	if v == nil {
+		return nil
+	}
+	c := *v
+	return &c
+}
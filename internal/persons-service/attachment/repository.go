@@ -0,0 +1,160 @@
+package attachment
+
+import (
+	"context"
+	"database/sql"
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/pgutil"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"time"
+)
+
+const (
+	defaultTimeout = 5 * time.Second
+)
+
+var attachmentColumns = []string{"id", "person_id", "kind", "file_name", "content_type", "size", "blob_key", "thumbnail_key", "created_at"}
+
+var queryTracer = pgutil.NewQueryTracer("github.com/Erlendum/rsoi-lab-01/internal/persons-service/attachment", "attachment.repository")
+
+type repository struct {
+	conns pgutil.ConnRouter
+}
+
+func NewRepository(conn *sqlx.DB) *repository {
+	return &repository{conns: pgutil.SingleConn{Conn: conn}}
+}
+
+// NewReplicatedRepository creates a repository that reads and writes through conns.
+func NewReplicatedRepository(conns pgutil.ConnRouter) *repository {
+	return &repository{conns: conns}
+}
+
+func (r *repository) CreateAttachment(ctx context.Context, attachment Attachment) (int, error) {
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+	builder := psql.Insert("attachments").
+		Columns("person_id", "kind", "file_name", "content_type", "size", "blob_key", "thumbnail_key").
+		Values(attachment.PersonID, attachment.Kind, attachment.FileName, attachment.ContentType, attachment.Size,
+			attachment.BlobKey, attachment.ThumbnailKey)
+	query, args, err := builder.Suffix("RETURNING id").ToSql()
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to build query")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	ctx, span := queryTracer.StartQuerySpan(ctx, "CreateAttachment", query)
+	var id int
+	err = r.conns.Writer(ctx).QueryRowContext(ctx, query, args...).Scan(&id)
+	queryTracer.EndQuerySpan(span, err)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code.Name() == "foreign_key_violation" {
+		return 0, ErrPersonNotFound
+	}
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to execute query")
+	}
+	r.conns.MarkWrite(ctx)
+
+	return id, nil
+}
+
+func (r *repository) GetAttachments(ctx context.Context, personID int) ([]Attachment, error) {
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+	builder := psql.Select(attachmentColumns...).From("attachments").Where(sq.Eq{"person_id": personID}).OrderBy("id")
+	query, args, err := builder.ToSql()
+	if err != nil {
+		return []Attachment{}, errors.Wrap(err, "failed to build query")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	res := make([]Attachment, 0)
+
+	ctx, span := queryTracer.StartQuerySpan(ctx, "GetAttachments", query)
+	err = r.conns.Reader(ctx).SelectContext(ctx, &res, query, args...)
+	queryTracer.EndQuerySpan(span, err)
+	if err != nil {
+		return []Attachment{}, errors.Wrap(err, "failed to execute query")
+	}
+
+	return res, nil
+}
+
+func (r *repository) GetAttachment(ctx context.Context, personID, id int) (Attachment, error) {
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+	builder := psql.Select(attachmentColumns...).From("attachments").Where(sq.Eq{"id": id, "person_id": personID})
+	query, args, err := builder.ToSql()
+	if err != nil {
+		return Attachment{}, errors.Wrap(err, "failed to build query")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	res := Attachment{}
+
+	ctx, span := queryTracer.StartQuerySpan(ctx, "GetAttachment", query)
+	err = r.conns.Reader(ctx).GetContext(ctx, &res, query, args...)
+	queryTracer.EndQuerySpan(span, err)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Attachment{}, nil
+	}
+	if err != nil {
+		return Attachment{}, errors.Wrap(err, "failed to execute query")
+	}
+
+	return res, nil
+}
+
+func (r *repository) DeleteAttachment(ctx context.Context, personID, id int) (bool, error) {
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+	query, args, err := psql.Delete("attachments").Where(sq.Eq{"id": id, "person_id": personID}).ToSql()
+	if err != nil {
+		return false, errors.Wrap(err, "failed to build query")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	ctx, span := queryTracer.StartQuerySpan(ctx, "DeleteAttachment", query)
+	res, err := r.conns.Writer(ctx).ExecContext(ctx, query, args...)
+	queryTracer.EndQuerySpan(span, err)
+	if res == nil || err != nil {
+		return false, errors.Wrap(err, "failed to execute query")
+	}
+
+	countAffectedRows, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get count of affected rows")
+	}
+	r.conns.MarkWrite(ctx)
+
+	return countAffectedRows == 1, nil
+}
+
+// MoveAttachments gives the attachments of the person fromID to the person toID.
+func (r *repository) MoveAttachments(ctx context.Context, fromID, toID int) error {
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+	query, args, err := psql.Update("attachments").Set("person_id", toID).Where(sq.Eq{"person_id": fromID}).ToSql()
+	if err != nil {
+		return errors.Wrap(err, "failed to build query")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	ctx, span := queryTracer.StartQuerySpan(ctx, "MoveAttachments", query)
+	_, err = r.conns.Writer(ctx).ExecContext(ctx, query, args...)
+	queryTracer.EndQuerySpan(span, err)
+	if err != nil {
+		return errors.Wrap(err, "failed to execute query")
+	}
+	r.conns.MarkWrite(ctx)
+
+	return nil
+}
@@ -0,0 +1,76 @@
+//go:build integration
+
+package attachment
+
+import (
+	"context"
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/pgtest"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+	"os"
+	"testing"
+)
+
+// testDB is shared by the integration tests. It points to POSTGRESQL_TEST_DSN if set, or
+// to an ephemeral PostgreSQL started for the test run.
+var testDB *sqlx.DB
+
+func TestMain(m *testing.M) {
+	os.Exit(pgtest.Run(m, func(db *sqlx.DB) {
+		testDB = db
+	}))
+}
+
+func newTestRepository(t *testing.T) (*repository, func() int) {
+	_, err := testDB.Exec("TRUNCATE persons RESTART IDENTITY CASCADE")
+	require.NoError(t, err)
+
+	newPerson := func() int {
+		var id int
+		err := testDB.QueryRow("INSERT INTO persons (name) VALUES ('test') RETURNING id").Scan(&id)
+		require.NoError(t, err)
+		return id
+	}
+	return NewRepository(testDB), newPerson
+}
+
+func Test_Repository(t *testing.T) {
+	testStorageConformance(t, func(t *testing.T) (storage, func() int) {
+		return newTestRepository(t)
+	})
+}
+
+func Test_Repository_AttachmentOfMissingPerson(t *testing.T) {
+	r, _ := newTestRepository(t)
+
+	_, err := r.CreateAttachment(context.Background(), Attachment{
+		PersonID:    1,
+		Kind:        KindDocument,
+		FileName:    "test.txt",
+		ContentType: "text/plain; charset=utf-8",
+		BlobKey:     "persons/1/attachments/test",
+	})
+	require.ErrorIs(t, err, ErrPersonNotFound)
+}
+
+func Test_Repository_DeletePersonCascadesAttachments(t *testing.T) {
+	ctx := context.Background()
+	r, newPerson := newTestRepository(t)
+
+	personID := newPerson()
+	_, err := r.CreateAttachment(ctx, Attachment{
+		PersonID:    personID,
+		Kind:        KindDocument,
+		FileName:    "test.txt",
+		ContentType: "text/plain; charset=utf-8",
+		BlobKey:     "persons/1/attachments/test",
+	})
+	require.NoError(t, err)
+
+	_, err = testDB.Exec("DELETE FROM persons WHERE id = $1", personID)
+	require.NoError(t, err)
+
+	attachments, err := r.GetAttachments(ctx, personID)
+	require.NoError(t, err)
+	require.Empty(t, attachments)
+}
@@ -0,0 +1,166 @@
+package attachment
+
+import (
+	"context"
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/person"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// testStorageConformance checks the behaviour every storage backend must share. newStorage
+// must return an empty storage and a function that creates a person to attach files to.
+func testStorageConformance(t *testing.T, newStorage func(t *testing.T) (storage, func() int)) {
+	ctx := context.Background()
+
+	newAttachment := func(personID int, kind string) Attachment {
+		return Attachment{
+			PersonID:    personID,
+			Kind:        kind,
+			FileName:    "test.png",
+			ContentType: "image/png",
+			Size:        42,
+			BlobKey:     "persons/" + strconv.Itoa(personID) + "/attachments/" + kind,
+		}
+	}
+
+	t.Run("create and get", func(t *testing.T) {
+		s, newPerson := newStorage(t)
+		personID := newPerson()
+
+		a := newAttachment(personID, KindPhoto)
+		a.ThumbnailKey = getPointerOnString(a.BlobKey + thumbnailSuffix)
+		id, err := s.CreateAttachment(ctx, a)
+		require.NoError(t, err)
+
+		got, err := s.GetAttachment(ctx, personID, id)
+		require.NoError(t, err)
+		require.Equal(t, id, *got.ID)
+		require.NotNil(t, got.CreatedAt)
+		got.ID, got.CreatedAt = nil, nil
+		require.Equal(t, a, got)
+
+		got, err = s.GetAttachment(ctx, personID, id+1)
+		require.NoError(t, err)
+		require.Nil(t, got.ID)
+	})
+
+	t.Run("attachments of another person", func(t *testing.T) {
+		s, newPerson := newStorage(t)
+		personID, otherID := newPerson(), newPerson()
+
+		id, err := s.CreateAttachment(ctx, newAttachment(personID, KindDocument))
+		require.NoError(t, err)
+
+		got, err := s.GetAttachment(ctx, otherID, id)
+		require.NoError(t, err)
+		require.Nil(t, got.ID)
+
+		isDeleted, err := s.DeleteAttachment(ctx, otherID, id)
+		require.NoError(t, err)
+		require.False(t, isDeleted)
+
+		attachments, err := s.GetAttachments(ctx, otherID)
+		require.NoError(t, err)
+		require.Empty(t, attachments)
+	})
+
+	t.Run("list in creation order", func(t *testing.T) {
+		s, newPerson := newStorage(t)
+		personID := newPerson()
+
+		first, err := s.CreateAttachment(ctx, newAttachment(personID, KindDocument))
+		require.NoError(t, err)
+		second, err := s.CreateAttachment(ctx, newAttachment(personID, KindPhoto))
+		require.NoError(t, err)
+
+		attachments, err := s.GetAttachments(ctx, personID)
+		require.NoError(t, err)
+		require.Len(t, attachments, 2)
+		require.Equal(t, first, *attachments[0].ID)
+		require.Equal(t, second, *attachments[1].ID)
+		require.Equal(t, KindPhoto, attachments[1].Kind)
+		require.Nil(t, attachments[0].ThumbnailKey)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		s, newPerson := newStorage(t)
+		personID := newPerson()
+
+		id, err := s.CreateAttachment(ctx, newAttachment(personID, KindDocument))
+		require.NoError(t, err)
+
+		isDeleted, err := s.DeleteAttachment(ctx, personID, id)
+		require.NoError(t, err)
+		require.True(t, isDeleted)
+
+		isDeleted, err = s.DeleteAttachment(ctx, personID, id)
+		require.NoError(t, err)
+		require.False(t, isDeleted)
+
+		attachments, err := s.GetAttachments(ctx, personID)
+		require.NoError(t, err)
+		require.Empty(t, attachments)
+	})
+
+	t.Run("move", func(t *testing.T) {
+		s, newPerson := newStorage(t)
+		sourceID, targetID := newPerson(), newPerson()
+
+		id, err := s.CreateAttachment(ctx, newAttachment(sourceID, KindDocument))
+		require.NoError(t, err)
+
+		require.NoError(t, s.MoveAttachments(ctx, sourceID, targetID))
+
+		attachments, err := s.GetAttachments(ctx, sourceID)
+		require.NoError(t, err)
+		require.Empty(t, attachments)
+		got, err := s.GetAttachment(ctx, targetID, id)
+		require.NoError(t, err)
+		require.Equal(t, targetID, got.PersonID)
+		require.Equal(t, "test.png", got.FileName)
+	})
+
+	t.Run("cancelled context", func(t *testing.T) {
+		s, newPerson := newStorage(t)
+		personID := newPerson()
+
+		cancelledCtx, cancel := context.WithCancel(ctx)
+		cancel()
+
+		_, err := s.CreateAttachment(cancelledCtx, newAttachment(personID, KindDocument))
+		require.Error(t, err)
+		_, err = s.GetAttachments(cancelledCtx, personID)
+		require.Error(t, err)
+	})
+}
+
+// newPersonCounter stands in for the person storage of backends that do not check persons.
+func newPersonCounter() func() int {
+	id := 0
+	return func() int {
+		id++
+		return id
+	}
+}
+
+func Test_MemoryRepository(t *testing.T) {
+	testStorageConformance(t, func(t *testing.T) (storage, func() int) {
+		return NewMemoryRepository(), newPersonCounter()
+	})
+}
+
+func Test_BoltRepository(t *testing.T) {
+	testStorageConformance(t, func(t *testing.T) (storage, func() int) {
+		persons, err := person.NewBoltRepository(filepath.Join(t.TempDir(), "persons.db"))
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			require.NoError(t, persons.Close())
+		})
+
+		r, err := NewBoltRepository(persons.DB())
+		require.NoError(t, err)
+		return r, newPersonCounter()
+	})
+}
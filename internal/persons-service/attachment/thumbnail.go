@@ -0,0 +1,67 @@
+package attachment
+
+import (
+	"bytes"
+	"github.com/pkg/errors"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+)
+
+// maxImagePixels bounds the images decoded for thumbnails. A small file can declare huge
+// dimensions, so they are checked before the pixels are allocated.
+const maxImagePixels = 40_000_000
+
+// imageTypes are the sniffed content types thumbnails can be made of. Photos must have one of
+// them.
+var imageTypes = map[string]bool{
+	"image/gif":  true,
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/webp": true,
+}
+
+// makeThumbnail scales the image read from r to fit into a size by size square and encodes it
+// as PNG. Images that already fit are not enlarged.
+func makeThumbnail(r io.ReadSeeker, size int) ([]byte, error) {
+	cfg, _, err := image.DecodeConfig(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode image config")
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > maxImagePixels {
+		return nil, errors.Errorf("image of %dx%d pixels is not supported", cfg.Width, cfg.Height)
+	}
+
+	if _, err = r.Seek(0, io.SeekStart); err != nil {
+		return nil, errors.Wrap(err, "failed to rewind image")
+	}
+	src, _, err := image.Decode(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode image")
+	}
+
+	width, height := thumbnailBounds(src.Bounds().Dx(), src.Bounds().Dy(), size)
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, src.Bounds(), draw.Over, nil)
+
+	buf := &bytes.Buffer{}
+	if err = png.Encode(buf, dst); err != nil {
+		return nil, errors.Wrap(err, "failed to encode thumbnail")
+	}
+	return buf.Bytes(), nil
+}
+
+// thumbnailBounds keeps the aspect ratio; neither side gets shorter than a pixel.
+func thumbnailBounds(width, height, size int) (int, int) {
+	if width <= size && height <= size {
+		return width, height
+	}
+	if width >= height {
+		return size, max(1, height*size/width)
+	}
+	return max(1, width*size/height), size
+}
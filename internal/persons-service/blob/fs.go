@@ -0,0 +1,119 @@
+package blob
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// fsStore keeps objects as files under a root directory.
+type fsStore struct {
+	root string
+}
+
+// NewFSStore creates a store in the directory root, creating it if needed.
+func NewFSStore(root string) (*fsStore, error) {
+	if err := os.MkdirAll(root, 0o750); err != nil {
+		return nil, errors.Wrap(err, "failed to create blob directory")
+	}
+	return &fsStore{root: root}, nil
+}
+
+func (s *fsStore) path(key string) (string, error) {
+	if err := validateKey(key); err != nil {
+		return "", err
+	}
+	return filepath.Join(s.root, filepath.FromSlash(key)), nil
+}
+
+// Put writes to a temporary file first, so a failed upload never replaces a stored object
+// and readers never see a partial one.
+func (s *fsStore) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return errors.Wrap(err, "failed to create blob directory")
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return errors.Wrap(err, "failed to create blob file")
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	written, err := io.Copy(tmp, &contextReader{ctx: ctx, r: r})
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to write blob file")
+	}
+	if written != size {
+		return errors.Errorf("blob size is %d, expected %d", written, size)
+	}
+
+	return errors.Wrap(os.Rename(tmp.Name(), path), "failed to move blob file")
+}
+
+func (s *fsStore) Open(ctx context.Context, key string) (Object, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open blob file")
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, errors.Wrap(err, "failed to stat blob file")
+	}
+
+	return &fileObject{File: f, size: info.Size()}, nil
+}
+
+func (s *fsStore) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return errors.Wrap(err, "failed to remove blob file")
+	}
+	return nil
+}
+
+type fileObject struct {
+	*os.File
+	size int64
+}
+
+func (o *fileObject) Size() int64 {
+	return o.size
+}
+
+// contextReader stops a copy once ctx is done, e.g. when the client of an upload goes away.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
@@ -0,0 +1,42 @@
+package blob
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"io"
+	"strings"
+)
+
+// ErrNotFound is returned by Open when the store has no object for the key.
+var ErrNotFound = errors.New("blob not found")
+
+// Store keeps the contents of attachments by key. Keys are slash-separated paths without
+// empty, "." or ".." segments.
+type Store interface {
+	// Put stores size bytes read from r under key, replacing any object stored there.
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Open returns the object stored under key for streaming, or ErrNotFound.
+	Open(ctx context.Context, key string) (Object, error)
+	// Delete removes the object stored under key; deleting a missing object is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// Object is an opened stored object. Seeking is cheap, so ranges of large objects can be
+// served without reading what precedes them.
+type Object interface {
+	io.ReadSeekCloser
+	Size() int64
+}
+
+// validateKey rejects keys that could escape the store root of a file system store.
+func validateKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") {
+		return errors.Errorf("invalid blob key %q", key)
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || segment == "." || segment == ".." || strings.ContainsRune(segment, '\\') {
+			return errors.Errorf("invalid blob key %q", key)
+		}
+	}
+	return nil
+}
@@ -1,32 +1,154 @@
 package config
 
 import (
-	"fmt"
+	"github.com/pkg/errors"
 	"gopkg.in/yaml.v3"
 	"os"
+	"strings"
 	"time"
 )
 
+const DefaultPath = "./configs/persons-service/config.yml"
+
+const (
+	StorageDriverPostgreSQL = "postgresql"
+	StorageDriverMemory     = "memory"
+	StorageDriverBolt       = "bolt"
+)
+
+const (
+	BlobStoreFS = "fs"
+	BlobStoreS3 = "s3"
+)
+
 type Server struct {
 	Address         string        `yaml:"address"`
 	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
 }
 
 type PostgreSQL struct {
-	DSN string `env:"POSTGRESQL_DSN"`
+	DSN                  string        `env:"POSTGRESQL_DSN"`
+	ReplicaDSNs          []string      `yaml:"replica_dsns" env:"POSTGRESQL_REPLICA_DSNS"`
+	ReplicaCheckInterval time.Duration `yaml:"replica_check_interval"`
+	ReplicaMaxLag        time.Duration `yaml:"replica_max_lag"`
+	ReadYourWritesWindow time.Duration `yaml:"read_your_writes_window"`
+	AutoMigrate          bool          `yaml:"auto_migrate"`
+	MigrationsDir        string        `yaml:"migrations_dir"`
+}
+
+type Storage struct {
+	Driver string `yaml:"driver"`
+	Path   string `yaml:"path"`
+}
+
+type Cache struct {
+	Enabled bool          `yaml:"enabled"`
+	Size    int           `yaml:"size"`
+	TTL     time.Duration `yaml:"ttl"`
+}
+
+// Persons holds the rules for person records. Each unique constraint is a set of json field
+// names, e.g. [name, address], whose values may not repeat case-insensitively. A zero
+// DuplicateThreshold uses the default of 0.8.
+type Persons struct {
+	UniqueConstraints  [][]string `yaml:"unique_constraints"`
+	DuplicateThreshold float64    `yaml:"duplicate_threshold"`
+	Attributes         Attributes `yaml:"attributes"`
+	// V1Deprecation announces the retirement of the v1 person routes in favour of v2.
+	V1Deprecation Deprecation `yaml:"v1_deprecation"`
+}
+
+// Deprecation announces the retirement of an API version in the Deprecation and Sunset headers
+// of its responses: Deprecated is when it has been deprecated, Sunset when it will stop being
+// served. A zero time leaves its header out.
+type Deprecation struct {
+	Deprecated time.Time `yaml:"deprecated"`
+	Sunset     time.Time `yaml:"sunset"`
+}
+
+// DefaultTenantHeader is the request header the tenant is read from when none is configured.
+const DefaultTenantHeader = "X-Tenant-ID"
+
+// Attributes configures the custom attributes of persons. Schemas maps a tenant, read from the
+// TenantHeader request header, to the JSON Schema file its attributes must conform to; the
+// "default" schema applies to the other tenants.
+type Attributes struct {
+	TenantHeader string            `yaml:"tenant_header"`
+	Schemas      map[string]string `yaml:"schemas"`
+}
+
+// Header returns TenantHeader, or DefaultTenantHeader when it is not set.
+func (a Attributes) Header() string {
+	if a.TenantHeader == "" {
+		return DefaultTenantHeader
+	}
+	return a.TenantHeader
+}
+
+// Attachments configures the files attached to persons. Their contents are kept in the blob
+// store, on the file system under Path or in an S3 bucket; the metadata is kept in the storage.
+// MaxSize is the upload limit in bytes, ThumbnailSize the longest side of image thumbnails in
+// pixels.
+type Attachments struct {
+	Store         string `yaml:"store"`
+	Path          string `yaml:"path"`
+	MaxSize       int64  `yaml:"max_size"`
+	ThumbnailSize int    `yaml:"thumbnail_size"`
+	S3            S3     `yaml:"s3"`
+}
+
+// S3 points to a bucket of an S3-compatible service, e.g. MinIO running locally.
+type S3 struct {
+	Endpoint        string `yaml:"endpoint"`
+	Region          string `yaml:"region"`
+	Bucket          string `yaml:"bucket"`
+	AccessKeyID     string `env:"S3_ACCESS_KEY_ID"`
+	SecretAccessKey string `env:"S3_SECRET_ACCESS_KEY"`
+}
+
+// OpenAPI configures the validation against the OpenAPI spec of the service. Invalid requests
+// are rejected; invalid responses are only logged, as they have already been sent.
+type OpenAPI struct {
+	ValidateRequests  bool `yaml:"validate_requests"`
+	ValidateResponses bool `yaml:"validate_responses"`
+}
+
+type Tracing struct {
+	Exporter    string  `yaml:"exporter"`
+	Endpoint    string  `yaml:"endpoint"`
+	Insecure    bool    `yaml:"insecure"`
+	File        string  `yaml:"file"`
+	ServiceName string  `yaml:"service_name"`
+	SampleRatio float64 `yaml:"sample_ratio"`
+}
+
+type Log struct {
+	Level  string `yaml:"level"`
+	Format string `yaml:"format"`
 }
 
 type Config struct {
-	Server     Server     `yaml:"server"`
-	PostgreSQL PostgreSQL `yaml:postgresql`
+	Server      Server      `yaml:"server"`
+	Admin       Server      `yaml:"admin"`
+	GRPC        Server      `yaml:"grpc"`
+	Storage     Storage     `yaml:"storage"`
+	PostgreSQL  PostgreSQL  `yaml:"postgresql"`
+	Cache       Cache       `yaml:"cache"`
+	Persons     Persons     `yaml:"persons"`
+	Attachments Attachments `yaml:"attachments"`
+	OpenAPI     OpenAPI     `yaml:"openapi"`
+	Tracing     Tracing     `yaml:"tracing"`
+	Log         Log         `yaml:"log"`
 }
 
-func New() (*Config, error) {
+func New(path string) (*Config, error) {
 	cfg := &Config{}
 
 	cfg.PostgreSQL.DSN = os.Getenv("POSTGRESQL_DSN")
+	cfg.Attachments.S3.AccessKeyID = os.Getenv("S3_ACCESS_KEY_ID")
+	cfg.Attachments.S3.SecretAccessKey = os.Getenv("S3_SECRET_ACCESS_KEY")
 
-	yamlFile, err := os.ReadFile(fmt.Sprint("./configs/persons-service/config.yml"))
+	yamlFile, err := os.ReadFile(path)
 	if err != nil {
 		return cfg, err
 	}
@@ -34,5 +156,84 @@ func New() (*Config, error) {
 	if err != nil {
 		return nil, err
 	}
+
+	if replicas := os.Getenv("POSTGRESQL_REPLICA_DSNS"); replicas != "" {
+		cfg.PostgreSQL.ReplicaDSNs = strings.Split(replicas, ",")
+	}
 	return cfg, err
 }
+
+func (c *Config) Validate() error {
+	if c.Server.Address == "" {
+		return errors.New("server.address is required")
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		return errors.New("server.shutdown_timeout must be positive")
+	}
+	if c.GRPC.Address != "" && c.GRPC.ShutdownTimeout <= 0 {
+		return errors.New("grpc.shutdown_timeout must be positive")
+	}
+	switch c.Storage.Driver {
+	case "", StorageDriverPostgreSQL:
+		if c.PostgreSQL.DSN == "" {
+			return errors.New("POSTGRESQL_DSN is required")
+		}
+		if len(c.PostgreSQL.ReplicaDSNs) > 0 && c.PostgreSQL.ReplicaCheckInterval <= 0 {
+			return errors.New("postgresql.replica_check_interval must be positive")
+		}
+	case StorageDriverMemory:
+	case StorageDriverBolt:
+		if c.Storage.Path == "" {
+			return errors.New("storage.path is required for the bolt driver")
+		}
+	default:
+		return errors.Errorf("unknown storage.driver %q", c.Storage.Driver)
+	}
+	if c.Cache.Enabled && c.Cache.Size <= 0 {
+		return errors.New("cache.size must be positive")
+	}
+	for _, constraint := range c.Persons.UniqueConstraints {
+		if len(constraint) == 0 {
+			return errors.New("persons.unique_constraints must not contain empty constraints")
+		}
+	}
+	if c.Persons.DuplicateThreshold < 0 || c.Persons.DuplicateThreshold > 1 {
+		return errors.New("persons.duplicate_threshold must be between 0 and 1")
+	}
+	for tenant, path := range c.Persons.Attributes.Schemas {
+		if path == "" {
+			return errors.Errorf("persons.attributes.schemas.%s must not be empty", tenant)
+		}
+	}
+	if d := c.Persons.V1Deprecation; !d.Deprecated.IsZero() && !d.Sunset.IsZero() && d.Sunset.Before(d.Deprecated) {
+		return errors.New("persons.v1_deprecation.sunset must not be before deprecated")
+	}
+	switch c.Attachments.Store {
+	case BlobStoreFS:
+		if c.Attachments.Path == "" {
+			return errors.New("attachments.path is required for the fs store")
+		}
+	case BlobStoreS3:
+		if c.Attachments.S3.Endpoint == "" || c.Attachments.S3.Bucket == "" {
+			return errors.New("attachments.s3.endpoint and attachments.s3.bucket are required for the s3 store")
+		}
+		if c.Attachments.S3.AccessKeyID == "" || c.Attachments.S3.SecretAccessKey == "" {
+			return errors.New("S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY are required for the s3 store")
+		}
+	default:
+		return errors.Errorf("unknown attachments.store %q", c.Attachments.Store)
+	}
+	if c.Attachments.MaxSize <= 0 {
+		return errors.New("attachments.max_size must be positive")
+	}
+	if c.Attachments.ThumbnailSize <= 0 {
+		return errors.New("attachments.thumbnail_size must be positive")
+	}
+	if c.Tracing.Exporter == "file" && c.Tracing.File == "" {
+		return errors.New("tracing.file is required for the file exporter")
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		return errors.New("tracing.sample_ratio must be between 0 and 1")
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package grpc
+
+import (
+	"context"
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/config"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	gogrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"net"
+	"runtime/debug"
+)
+
+type personHandler interface {
+	Register(s gogrpc.ServiceRegistrar)
+}
+
+type server struct {
+	grpc           *gogrpc.Server
+	health         *health.Server
+	listener       net.Listener
+	cfg            *config.Server
+	personsHandler personHandler
+	opts           []gogrpc.ServerOption
+}
+
+// NewServer creates the gRPC server of the persons API. The options add the interceptors, like
+// the middlewares of the http server.
+func NewServer(cfg *config.Server, personsHandler personHandler, opts ...gogrpc.ServerOption) *server {
+	return &server{
+		cfg:            cfg,
+		personsHandler: personsHandler,
+		opts:           opts,
+	}
+}
+
+// Init registers the services and binds the address, so a taken port fails the start.
+func (s *server) Init() error {
+	opts := append([]gogrpc.ServerOption{
+		gogrpc.ChainUnaryInterceptor(recoverUnary),
+		gogrpc.ChainStreamInterceptor(recoverStream),
+	}, s.opts...)
+	s.grpc = gogrpc.NewServer(opts...)
+
+	s.personsHandler.Register(s.grpc)
+
+	// The overall status and the status of every registered service start as serving.
+	s.health = health.NewServer()
+	for name := range s.grpc.GetServiceInfo() {
+		s.health.SetServingStatus(name, healthpb.HealthCheckResponse_SERVING)
+	}
+	healthpb.RegisterHealthServer(s.grpc, s.health)
+	reflection.Register(s.grpc)
+
+	listener, err := net.Listen("tcp", s.cfg.Address)
+	if err != nil {
+		return err
+	}
+	s.listener = listener
+
+	return nil
+}
+
+func (s *server) Run() error {
+	log.Info().Str("address", s.listener.Addr().String()).Msg("grpc server has been started")
+	return s.grpc.Serve(s.listener)
+}
+
+// Stop reports the services as not serving and lets the calls in flight finish within the
+// shutdown timeout, then closes the remaining ones.
+func (s *server) Stop(ctx context.Context) error {
+	s.health.Shutdown()
+
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.ShutdownTimeout)
+	defer cancel()
+
+	stopped := make(chan struct{})
+	go func() {
+		s.grpc.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		log.Warn().Msg("could not stop grpc server gracefully")
+		s.grpc.Stop()
+	}
+	return nil
+}
+
+// recoverUnary turns a panic of a call into an INTERNAL status, like the recover middleware of
+// the http server.
+func recoverUnary(ctx context.Context, req interface{}, info *gogrpc.UnaryServerInfo, handler gogrpc.UnaryHandler) (resp interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = recovered(ctx, r)
+		}
+	}()
+	return handler(ctx, req)
+}
+
+func recoverStream(srv interface{}, ss gogrpc.ServerStream, info *gogrpc.StreamServerInfo, handler gogrpc.StreamHandler) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = recovered(ss.Context(), r)
+		}
+	}()
+	return handler(srv, ss)
+}
+
+func recovered(ctx context.Context, r interface{}) error {
+	zerolog.Ctx(ctx).Error().Interface("panic", r).Bytes("stack", debug.Stack()).Msg("grpc call panicked")
+	return status.Error(codes.Internal, "internal error")
+}
@@ -0,0 +1,72 @@
+package grpc
+
+import (
+	"context"
+	personsv1 "github.com/Erlendum/rsoi-lab-01/api/persons-service/v1"
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/config"
+	"github.com/stretchr/testify/require"
+	gogrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"testing"
+	"time"
+)
+
+type panickingPersonHandler struct {
+	personsv1.UnimplementedPersonServiceServer
+}
+
+func (h *panickingPersonHandler) Register(s gogrpc.ServiceRegistrar) {
+	personsv1.RegisterPersonServiceServer(s, h)
+}
+
+func (h *panickingPersonHandler) GetPerson(context.Context, *personsv1.GetPersonRequest) (*personsv1.Person, error) {
+	panic("test")
+}
+
+func Test_Server(t *testing.T) {
+	ctx := context.Background()
+	s := NewServer(&config.Server{Address: "127.0.0.1:0", ShutdownTimeout: time.Second}, &panickingPersonHandler{})
+	require.NoError(t, s.Init())
+
+	done := make(chan error, 1)
+	go func() {
+		done <- s.Run()
+	}()
+
+	conn, err := gogrpc.NewClient(s.listener.Addr().String(), gogrpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	health := healthpb.NewHealthClient(conn)
+	for _, service := range []string{"", personsv1.PersonService_ServiceDesc.ServiceName} {
+		resp, err := health.Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+		require.NoError(t, err)
+		require.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status)
+	}
+
+	reflectionCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	reflection, err := reflectionpb.NewServerReflectionClient(conn).ServerReflectionInfo(reflectionCtx)
+	require.NoError(t, err)
+	require.NoError(t, reflection.Send(&reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{},
+	}))
+	resp, err := reflection.Recv()
+	require.NoError(t, err)
+	var services []string
+	for _, service := range resp.GetListServicesResponse().GetService() {
+		services = append(services, service.Name)
+	}
+	require.Contains(t, services, personsv1.PersonService_ServiceDesc.ServiceName)
+	cancel()
+
+	_, err = personsv1.NewPersonServiceClient(conn).GetPerson(ctx, &personsv1.GetPersonRequest{Id: 1})
+	require.Equal(t, codes.Internal, status.Code(err))
+
+	require.NoError(t, s.Stop(ctx))
+	require.NoError(t, <-done)
+}
@@ -0,0 +1,13 @@
+package health
+
+import (
+	"context"
+	"github.com/jmoiron/sqlx"
+)
+
+// NewPostgreSQLChecker checks that the database answers a ping.
+func NewPostgreSQLChecker(db *sqlx.DB) Checker {
+	return CheckerFunc(func(ctx context.Context) error {
+		return db.PingContext(ctx)
+	})
+}
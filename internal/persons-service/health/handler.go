@@ -0,0 +1,132 @@
+package health
+
+import (
+	"context"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultCheckTimeout = 2 * time.Second
+
+	statusOK   = "ok"
+	statusFail = "fail"
+)
+
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc adapts a plain function to the Checker interface.
+type CheckerFunc func(ctx context.Context) error
+
+func (f CheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+type namedChecker struct {
+	name    string
+	checker Checker
+}
+
+type handler struct {
+	mu           sync.RWMutex
+	checkers     []namedChecker
+	shuttingDown atomic.Bool
+}
+
+func NewHandler() *handler {
+	return &handler{}
+}
+
+// AddChecker registers a dependency check used by the readiness endpoint.
+func (h *handler) AddChecker(name string, checker Checker) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.checkers = append(h.checkers, namedChecker{name: name, checker: checker})
+}
+
+// Shutdown makes the readiness endpoint fail, so the orchestrator stops routing traffic
+// to the instance while in-flight requests are drained.
+func (h *handler) Shutdown() {
+	h.shuttingDown.Store(true)
+}
+
+func (h *handler) Register(echo *echo.Echo) {
+	echo.GET("/healthz", h.Liveness)
+	echo.GET("/readyz", h.Readiness)
+}
+
+func (h *handler) Liveness(c echo.Context) error {
+	return c.JSON(http.StatusOK, echo.Map{
+		"status": statusOK,
+	})
+}
+
+type checkResult struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+type readinessResponse struct {
+	Status string                 `json:"status"`
+	Checks map[string]checkResult `json:"checks"`
+}
+
+func (h *handler) Readiness(c echo.Context) error {
+	if h.shuttingDown.Load() {
+		return c.JSON(http.StatusServiceUnavailable, readinessResponse{
+			Status: statusFail,
+			Checks: map[string]checkResult{
+				"shutdown": {Status: statusFail, Error: "shutdown in progress"},
+			},
+		})
+	}
+
+	h.mu.RLock()
+	checkers := h.checkers
+	h.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), defaultCheckTimeout)
+	defer cancel()
+
+	results := make([]checkResult, len(checkers))
+	wg := sync.WaitGroup{}
+	for i, nc := range checkers {
+		wg.Add(1)
+		go func(i int, checker Checker) {
+			defer wg.Done()
+			start := time.Now()
+			err := checker.Check(ctx)
+			results[i] = checkResult{
+				Status:    statusOK,
+				LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+			}
+			if err != nil {
+				results[i].Status = statusFail
+				results[i].Error = err.Error()
+			}
+		}(i, nc.checker)
+	}
+	wg.Wait()
+
+	resp := readinessResponse{
+		Status: statusOK,
+		Checks: make(map[string]checkResult, len(checkers)),
+	}
+	for i, nc := range checkers {
+		resp.Checks[nc.name] = results[i]
+		if results[i].Status != statusOK {
+			resp.Status = statusFail
+		}
+	}
+
+	if resp.Status != statusOK {
+		return c.JSON(http.StatusServiceUnavailable, resp)
+	}
+	return c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,92 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_Readiness(t *testing.T) {
+	type fields struct {
+		checkers         map[string]Checker
+		shutdown         bool
+		expectedHTTPCode int
+		expectedStatuses map[string]string
+	}
+
+	okChecker := CheckerFunc(func(ctx context.Context) error { return nil })
+	failChecker := CheckerFunc(func(ctx context.Context) error { return errors.New("unavailable") })
+
+	e := echo.New()
+
+	tests := []struct {
+		name   string
+		fields fields
+	}{
+		{
+			name: "http-code 200: no checkers",
+			fields: fields{
+				expectedHTTPCode: http.StatusOK,
+				expectedStatuses: map[string]string{},
+			},
+		},
+		{
+			name: "http-code 200: all checks pass",
+			fields: fields{
+				checkers:         map[string]Checker{"a": okChecker, "b": okChecker},
+				expectedHTTPCode: http.StatusOK,
+				expectedStatuses: map[string]string{"a": statusOK, "b": statusOK},
+			},
+		},
+		{
+			name: "http-code 503: one check fails",
+			fields: fields{
+				checkers:         map[string]Checker{"a": okChecker, "b": failChecker},
+				expectedHTTPCode: http.StatusServiceUnavailable,
+				expectedStatuses: map[string]string{"a": statusOK, "b": statusFail},
+			},
+		},
+		{
+			name: "http-code 503: shutdown",
+			fields: fields{
+				checkers:         map[string]Checker{"a": okChecker},
+				shutdown:         true,
+				expectedHTTPCode: http.StatusServiceUnavailable,
+				expectedStatuses: map[string]string{"shutdown": statusFail},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHandler()
+			for name, checker := range tt.fields.checkers {
+				h.AddChecker(name, checker)
+			}
+			if tt.fields.shutdown {
+				h.Shutdown()
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			err := h.Readiness(c)
+
+			require.NoError(t, err)
+			require.Equal(t, tt.fields.expectedHTTPCode, rec.Code)
+
+			resp := readinessResponse{}
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			require.Len(t, resp.Checks, len(tt.fields.expectedStatuses))
+			for name, status := range tt.fields.expectedStatuses {
+				require.Equal(t, status, resp.Checks[name].Status)
+			}
+		})
+	}
+}
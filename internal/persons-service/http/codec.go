@@ -0,0 +1,333 @@
+package http
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	"github.com/vmihailenco/msgpack/v5"
+	"io"
+	"mime"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MIMETextCSV is the media type of CSV lists.
+const MIMETextCSV = "text/csv"
+
+// Encoder writes values in the representation of a media type.
+type Encoder interface {
+	MediaType() string
+	Encode(w io.Writer, v interface{}) error
+}
+
+// Codec writes and reads values in the representation of a media type.
+type Codec interface {
+	Encoder
+	Decode(r io.Reader, v interface{}) error
+}
+
+// Codecs is a registry of the representations the API negotiates, in the order of preference
+// of the server.
+type Codecs struct {
+	codecs []Codec
+	// listEncoders only represent lists, e.g. CSV tables.
+	listEncoders []Encoder
+}
+
+func NewCodecs(codecs []Codec, listEncoders ...Encoder) *Codecs {
+	return &Codecs{codecs: codecs, listEncoders: listEncoders}
+}
+
+// DefaultCodecs are the representations of the API: JSON, XML and MessagePack, and CSV for
+// lists.
+var DefaultCodecs = NewCodecs([]Codec{JSONCodec{}, XMLCodec{}, MsgpackCodec{}}, CSVEncoder{})
+
+// Encoder returns the encoder of the representation accept prefers, or false if there is none.
+// An empty accept takes the first codec.
+func (r *Codecs) Encoder(accept string) (Encoder, bool) {
+	encoders := make([]Encoder, len(r.codecs))
+	for i, codec := range r.codecs {
+		encoders[i] = codec
+	}
+	return negotiate(accept, encoders)
+}
+
+// ListEncoder is Encoder for lists, which the list encoders can represent too.
+func (r *Codecs) ListEncoder(accept string) (Encoder, bool) {
+	encoders := make([]Encoder, 0, len(r.codecs)+len(r.listEncoders))
+	for _, codec := range r.codecs {
+		encoders = append(encoders, codec)
+	}
+	return negotiate(accept, append(encoders, r.listEncoders...))
+}
+
+// Decoder returns the codec of the media type of contentType, or false if there is none. An
+// empty contentType is read as JSON.
+func (r *Codecs) Decoder(contentType string) (Codec, bool) {
+	mediaType := echo.MIMEApplicationJSON
+	if contentType != "" {
+		var err error
+		if mediaType, _, err = mime.ParseMediaType(contentType); err != nil {
+			return nil, false
+		}
+	}
+
+	for _, codec := range r.codecs {
+		if codec.MediaType() == mediaType {
+			return codec, true
+		}
+	}
+	return nil, false
+}
+
+// mediaRange is a part of an Accept header.
+type mediaRange struct {
+	mediaType string
+	quality   float64
+}
+
+// negotiate returns the first encoder of the media range of accept with the highest quality
+// that matches any.
+func negotiate(accept string, encoders []Encoder) (Encoder, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return encoders[0], true
+	}
+
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(part)
+		if err != nil {
+			continue
+		}
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			if quality, err = strconv.ParseFloat(q, 64); err != nil {
+				continue
+			}
+		}
+		if quality > 0 {
+			ranges = append(ranges, mediaRange{mediaType: mediaType, quality: quality})
+		}
+	}
+	slices.SortStableFunc(ranges, func(a, b mediaRange) int {
+		switch {
+		case a.quality > b.quality:
+			return -1
+		case a.quality < b.quality:
+			return 1
+		}
+		return 0
+	})
+
+	for _, r := range ranges {
+		for _, encoder := range encoders {
+			if matchesRange(encoder.MediaType(), r.mediaType) {
+				return encoder, true
+			}
+		}
+	}
+	return nil, false
+}
+
+func matchesRange(mediaType, mediaRange string) bool {
+	if mediaRange == "*/*" || mediaRange == mediaType {
+		return true
+	}
+	prefix, ok := strings.CutSuffix(mediaRange, "/*")
+	return ok && strings.HasPrefix(mediaType, prefix+"/")
+}
+
+// JSONCodec represents values as JSON like echo does.
+type JSONCodec struct{}
+
+func (JSONCodec) MediaType() string {
+	return echo.MIMEApplicationJSON
+}
+
+func (JSONCodec) Encode(w io.Writer, v interface{}) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+func (JSONCodec) Decode(r io.Reader, v interface{}) error {
+	return json.NewDecoder(r).Decode(v)
+}
+
+// XMLCodec represents values as XML with their xml struct tags. Slices are wrapped in a list
+// element, as a document has one root.
+type XMLCodec struct{}
+
+func (XMLCodec) MediaType() string {
+	return echo.MIMEApplicationXML
+}
+
+// xmlListElement is the root element of a list.
+var xmlListElement = xml.StartElement{Name: xml.Name{Local: "list"}}
+
+func (XMLCodec) Encode(w io.Writer, v interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice {
+		return enc.Encode(v)
+	}
+
+	if err := enc.EncodeToken(xmlListElement); err != nil {
+		return err
+	}
+	for i := 0; i < rv.Len(); i++ {
+		if err := enc.Encode(rv.Index(i).Interface()); err != nil {
+			return err
+		}
+	}
+	if err := enc.EncodeToken(xmlListElement.End()); err != nil {
+		return err
+	}
+	return enc.Flush()
+}
+
+func (XMLCodec) Decode(r io.Reader, v interface{}) error {
+	return xml.NewDecoder(r).Decode(v)
+}
+
+// MsgpackCodec represents values as MessagePack with the names of their json struct tags, so
+// they have the fields of the JSON representation.
+type MsgpackCodec struct{}
+
+func (MsgpackCodec) MediaType() string {
+	return echo.MIMEApplicationMsgpack
+}
+
+func (MsgpackCodec) Encode(w io.Writer, v interface{}) error {
+	enc := msgpack.NewEncoder(w)
+	enc.SetCustomStructTag("json")
+	return enc.Encode(v)
+}
+
+func (MsgpackCodec) Decode(r io.Reader, v interface{}) error {
+	dec := msgpack.NewDecoder(r)
+	dec.SetCustomStructTag("json")
+	return dec.Decode(v)
+}
+
+// CSVEncoder represents a slice of structs as a table with a header row of the names of their
+// json struct tags. Lists, maps and nested structs are written as JSON in their cells, unknown
+// values as empty cells.
+type CSVEncoder struct{}
+
+func (CSVEncoder) MediaType() string {
+	return MIMETextCSV
+}
+
+func (CSVEncoder) Encode(w io.Writer, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice {
+		return errors.Errorf("csv encodes slices, not %T", v)
+	}
+
+	cw := csv.NewWriter(w)
+	var columns []csvColumn
+	for i := 0; i < rv.Len(); i++ {
+		item := reflect.Indirect(unwrapInterface(rv.Index(i)))
+		if item.Kind() != reflect.Struct {
+			return errors.Errorf("csv encodes slices of structs, not of %s", item.Type())
+		}
+		if columns == nil {
+			columns = csvColumns(item.Type())
+			header := make([]string, len(columns))
+			for j, column := range columns {
+				header[j] = column.name
+			}
+			if err := cw.Write(header); err != nil {
+				return err
+			}
+		}
+
+		row := make([]string, len(columns))
+		for j, column := range columns {
+			cell, err := csvCell(item.Field(column.index))
+			if err != nil {
+				return err
+			}
+			row[j] = cell
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+type csvColumn struct {
+	name  string
+	index int
+}
+
+func csvColumns(t reflect.Type) []csvColumn {
+	var columns []csvColumn
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		columns = append(columns, csvColumn{name: name, index: i})
+	}
+	return columns
+}
+
+func unwrapInterface(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Interface && !v.IsNil() {
+		v = v.Elem()
+	}
+	return v
+}
+
+func csvCell(v reflect.Value) (string, error) {
+	v = unwrapInterface(v)
+	if (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && v.IsNil() {
+		return "", nil
+	}
+	v = reflect.Indirect(v)
+
+	if t, ok := v.Interface().(time.Time); ok {
+		return t.Format(time.RFC3339Nano), nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64), nil
+	case reflect.Slice, reflect.Map:
+		if v.IsNil() {
+			return "", nil
+		}
+	}
+
+	data, err := json.Marshal(v.Interface())
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,155 @@
+package http
+
+import (
+	"bytes"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func Test_Codecs_Negotiation(t *testing.T) {
+	tests := []struct {
+		name              string
+		accept            string
+		list              bool
+		expectedMediaType string
+	}{
+		{
+			name:              "no accept",
+			expectedMediaType: echo.MIMEApplicationJSON,
+		},
+		{
+			name:              "any",
+			accept:            "*/*",
+			expectedMediaType: echo.MIMEApplicationJSON,
+		},
+		{
+			name:              "exact with parameters",
+			accept:            "application/xml; charset=utf-8",
+			expectedMediaType: echo.MIMEApplicationXML,
+		},
+		{
+			name:              "quality",
+			accept:            "application/json;q=0.5, application/msgpack",
+			expectedMediaType: echo.MIMEApplicationMsgpack,
+		},
+		{
+			name:              "wildcard subtype",
+			accept:            "text/html, application/*;q=0.8",
+			expectedMediaType: echo.MIMEApplicationJSON,
+		},
+		{
+			name:              "csv list",
+			accept:            "text/csv",
+			list:              true,
+			expectedMediaType: MIMETextCSV,
+		},
+		{
+			name:   "csv single",
+			accept: "text/csv",
+		},
+		{
+			name:   "unsupported",
+			accept: "text/html, application/json;q=0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var enc Encoder
+			var ok bool
+			if tt.list {
+				enc, ok = DefaultCodecs.ListEncoder(tt.accept)
+			} else {
+				enc, ok = DefaultCodecs.Encoder(tt.accept)
+			}
+
+			if tt.expectedMediaType == "" {
+				require.False(t, ok)
+				return
+			}
+			require.True(t, ok)
+			require.Equal(t, tt.expectedMediaType, enc.MediaType())
+		})
+	}
+}
+
+func Test_Codecs_Decoder(t *testing.T) {
+	codec, ok := DefaultCodecs.Decoder("")
+	require.True(t, ok)
+	require.Equal(t, echo.MIMEApplicationJSON, codec.MediaType())
+
+	codec, ok = DefaultCodecs.Decoder("application/xml; charset=utf-8")
+	require.True(t, ok)
+	require.Equal(t, echo.MIMEApplicationXML, codec.MediaType())
+
+	_, ok = DefaultCodecs.Decoder(MIMETextCSV)
+	require.False(t, ok)
+}
+
+type codecTestItem struct {
+	ID        int               `json:"id" xml:"id"`
+	Name      *string           `json:"name,omitempty" xml:"name,omitempty"`
+	Tags      []string          `json:"tags" xml:"tags>tag"`
+	Labels    map[string]string `json:"labels" xml:"-"`
+	CreatedAt *time.Time        `json:"created_at" xml:"created_at"`
+	Internal  string            `json:"-" xml:"-"`
+}
+
+func Test_Codecs_Encode(t *testing.T) {
+	name := "Ivan, \"the\" first"
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	items := []interface{}{
+		codecTestItem{ID: 1, Name: &name, Tags: []string{"a", "b"}, Labels: map[string]string{"k": "v"}, CreatedAt: &createdAt},
+		&codecTestItem{ID: 2},
+	}
+
+	tests := []struct {
+		name         string
+		encoder      Encoder
+		expectedBody string
+	}{
+		{
+			name:    "csv",
+			encoder: CSVEncoder{},
+			expectedBody: "id,name,tags,labels,created_at\n" +
+				"1,\"Ivan, \"\"the\"\" first\",\"[\"\"a\"\",\"\"b\"\"]\",\"{\"\"k\"\":\"\"v\"\"}\",2024-01-02T03:04:05Z\n" +
+				"2,,,,\n",
+		},
+		{
+			name:    "xml",
+			encoder: XMLCodec{},
+			expectedBody: `<?xml version="1.0" encoding="UTF-8"?>` + "\n" +
+				`<list><codecTestItem><id>1</id><name>Ivan, &#34;the&#34; first</name><tags><tag>a</tag><tag>b</tag></tags>` +
+				`<created_at>2024-01-02T03:04:05Z</created_at></codecTestItem>` +
+				`<codecTestItem><id>2</id><tags></tags></codecTestItem></list>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := &bytes.Buffer{}
+			require.NoError(t, tt.encoder.Encode(body, items))
+			require.Equal(t, tt.expectedBody, body.String())
+		})
+	}
+
+	require.Error(t, CSVEncoder{}.Encode(&bytes.Buffer{}, items[0]))
+}
+
+func Test_MsgpackCodec(t *testing.T) {
+	name := "Ivan"
+	body := &bytes.Buffer{}
+	require.NoError(t, MsgpackCodec{}.Encode(body, codecTestItem{ID: 1, Name: &name, Tags: []string{"a"}, Internal: "secret"}))
+
+	decoded := map[string]interface{}{}
+	require.NoError(t, MsgpackCodec{}.Decode(body, &decoded))
+	require.Equal(t, map[string]interface{}{
+		"id":         int8(1),
+		"name":       "Ivan",
+		"tags":       []interface{}{"a"},
+		"labels":     nil,
+		"created_at": nil,
+	}, decoded)
+}
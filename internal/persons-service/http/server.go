@@ -2,12 +2,14 @@ package http
 
 import (
 	"context"
+	"errors"
 	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/config"
 	"github.com/Erlendum/rsoi-lab-01/pkg/validation"
 	"github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	"github.com/rs/zerolog/log"
+	nethttp "net/http"
 )
 
 type personHandler interface {
@@ -19,17 +21,63 @@ type personHandler interface {
 	GetPersons(c echo.Context) error
 }
 
+type organizationHandler interface {
+	Register(echo *echo.Echo)
+	CreateOrganization(c echo.Context) error
+	UpdateOrganization(c echo.Context) error
+	DeleteOrganization(c echo.Context) error
+	GetOrganization(c echo.Context) error
+	GetOrganizations(c echo.Context) error
+}
+
+type attachmentHandler interface {
+	Register(echo *echo.Echo)
+	CreateAttachment(c echo.Context) error
+	DeleteAttachment(c echo.Context) error
+	GetAttachment(c echo.Context) error
+	GetAttachments(c echo.Context) error
+}
+
+type graphqlHandler interface {
+	Register(echo *echo.Echo)
+	GraphQL(c echo.Context) error
+}
+
+type docsHandler interface {
+	Register(echo *echo.Echo)
+	GetSpec(c echo.Context) error
+	GetDocs(c echo.Context) error
+}
+
+type healthHandler interface {
+	Register(echo *echo.Echo)
+	Liveness(c echo.Context) error
+	Readiness(c echo.Context) error
+}
+
 type server struct {
-	echo           *echo.Echo
-	cfg            *config.Server
-	personsHandler personHandler
+	echo                 *echo.Echo
+	cfg                  *config.Server
+	personsHandler       personHandler
+	organizationsHandler organizationHandler
+	attachmentsHandler   attachmentHandler
+	graphqlHandler       graphqlHandler
+	docsHandler          docsHandler
+	healthHandler        healthHandler
+	middlewares          []echo.MiddlewareFunc
 }
 
-func NewServer(cfg *config.Server, personsHandler personHandler) *server {
+func NewServer(cfg *config.Server, personsHandler personHandler, organizationsHandler organizationHandler, attachmentsHandler attachmentHandler, graphqlHandler graphqlHandler, docsHandler docsHandler, healthHandler healthHandler, middlewares ...echo.MiddlewareFunc) *server {
 	return &server{
-		echo:           echo.New(),
-		personsHandler: personsHandler,
-		cfg:            cfg,
+		echo:                 echo.New(),
+		personsHandler:       personsHandler,
+		organizationsHandler: organizationsHandler,
+		attachmentsHandler:   attachmentsHandler,
+		graphqlHandler:       graphqlHandler,
+		docsHandler:          docsHandler,
+		healthHandler:        healthHandler,
+		middlewares:          middlewares,
+		cfg:                  cfg,
 	}
 }
 
@@ -39,22 +87,34 @@ func (s *server) Init() error {
 	s.echo.HidePort = true
 
 	s.echo.Use(
+		middleware.Recover(),
 		middleware.CORSWithConfig(middleware.CORSConfig{
 			AllowOrigins:                             []string{"*"},
 			UnsafeWildcardOriginWithAllowCredentials: true,
 			AllowCredentials:                         true,
 		}),
+		middleware.RequestID(),
 	)
+	s.echo.Use(s.middlewares...)
 
 	s.echo.Validator = validation.MustRegisterCustomValidator(validator.New())
 
+	s.healthHandler.Register(s.echo)
+	s.docsHandler.Register(s.echo)
 	s.personsHandler.Register(s.echo)
+	s.organizationsHandler.Register(s.echo)
+	s.attachmentsHandler.Register(s.echo)
+	s.graphqlHandler.Register(s.echo)
 	return nil
 }
 
 func (s *server) Run() error {
 	log.Info().Msg("server has been started")
-	return s.echo.StartServer(s.echo.Server)
+	err := s.echo.StartServer(s.echo.Server)
+	if errors.Is(err, nethttp.ErrServerClosed) {
+		return nil
+	}
+	return err
 }
 
 func (s *server) Stop(ctx context.Context) error {
@@ -0,0 +1,75 @@
+package logging
+
+import (
+	"context"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"time"
+)
+
+// NewGRPCUnaryInterceptor is the gRPC counterpart of NewHTTPMiddleware for unary calls: it puts
+// a call-scoped logger carrying the request id and method into the context and writes an
+// access log entry.
+func NewGRPCUnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		logger := callLogger(ctx, info.FullMethod)
+
+		resp, err := handler(logger.WithContext(ctx), req)
+		logCall(logger, err, start)
+		return resp, err
+	}
+}
+
+// NewGRPCStreamInterceptor is NewGRPCUnaryInterceptor for streaming calls.
+func NewGRPCStreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		logger := callLogger(ss.Context(), info.FullMethod)
+
+		err := handler(srv, &contextStream{ServerStream: ss, ctx: logger.WithContext(ss.Context())})
+		logCall(logger, err, start)
+		return err
+	}
+}
+
+func callLogger(ctx context.Context, method string) zerolog.Logger {
+	logCtx := log.Logger.With().Str("method", method)
+	if ids := metadata.ValueFromIncomingContext(ctx, "x-request-id"); len(ids) > 0 {
+		logCtx = logCtx.Str("request_id", ids[0])
+	}
+	return logCtx.Logger()
+}
+
+func logCall(logger zerolog.Logger, err error, start time.Time) {
+	code := status.Code(err)
+
+	var event *zerolog.Event
+	switch code {
+	case codes.OK:
+		event = logger.Info()
+	case codes.Unknown, codes.Internal, codes.DataLoss, codes.Unimplemented, codes.Unavailable:
+		event = logger.Error()
+	default:
+		event = logger.Warn()
+	}
+	event.
+		Err(err).
+		Str("code", code.String()).
+		Dur("latency", time.Since(start)).
+		Msg("call handled")
+}
+
+// contextStream replaces the context of a server stream.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,48 @@
+package logging
+
+import (
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/config"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"io"
+	"os"
+)
+
+const (
+	FormatJSON    = "json"
+	FormatConsole = "console"
+)
+
+// Setup configures the global logger. A non-empty levelOverride, e.g. from the command line,
+// wins over the level from the config.
+func Setup(cfg *config.Log, levelOverride string) error {
+	levelName := cfg.Level
+	if levelOverride != "" {
+		levelName = levelOverride
+	}
+	level := zerolog.InfoLevel
+	if levelName != "" {
+		var err error
+		level, err = zerolog.ParseLevel(levelName)
+		if err != nil {
+			return errors.Wrapf(err, "unknown log level %q", levelName)
+		}
+	}
+
+	var w io.Writer
+	switch cfg.Format {
+	case "", FormatJSON:
+		w = os.Stderr
+	case FormatConsole:
+		w = zerolog.ConsoleWriter{Out: os.Stderr}
+	default:
+		return errors.Errorf("unknown log format %q", cfg.Format)
+	}
+
+	zerolog.SetGlobalLevel(level)
+	log.Logger = zerolog.New(w).With().Timestamp().Caller().Logger()
+	zerolog.DefaultContextLogger = &log.Logger
+
+	return nil
+}
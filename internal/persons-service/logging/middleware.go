@@ -0,0 +1,66 @@
+package logging
+
+import (
+	"errors"
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"net/http"
+	"time"
+)
+
+// NewHTTPMiddleware returns an echo middleware that puts a request-scoped logger carrying the
+// request id, method and route into the request context and writes an access log entry.
+// It must run after the request id middleware.
+func NewHTTPMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			req := c.Request()
+
+			route := c.Path()
+			if route == "" {
+				route = req.URL.Path
+			}
+
+			logger := log.Logger.With().
+				Str("request_id", c.Response().Header().Get(echo.HeaderXRequestID)).
+				Str("method", req.Method).
+				Str("route", route).
+				Logger()
+			c.SetRequest(req.WithContext(logger.WithContext(req.Context())))
+
+			err := next(c)
+
+			status := c.Response().Status
+			if err != nil {
+				var httpErr *echo.HTTPError
+				if errors.As(err, &httpErr) {
+					status = httpErr.Code
+				} else {
+					status = http.StatusInternalServerError
+				}
+			}
+
+			var event *zerolog.Event
+			switch {
+			case status >= http.StatusInternalServerError:
+				event = logger.Error()
+			case status >= http.StatusBadRequest:
+				event = logger.Warn()
+			default:
+				event = logger.Info()
+			}
+			event.
+				Err(err).
+				Str("path", req.URL.Path).
+				Str("remote_ip", c.RealIP()).
+				Int("status", status).
+				Int64("bytes_out", c.Response().Size).
+				Dur("latency", time.Since(start)).
+				Msg("request handled")
+
+			return err
+		}
+	}
+}
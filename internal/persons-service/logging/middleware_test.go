@@ -0,0 +1,50 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_HTTPMiddleware(t *testing.T) {
+	buf := &bytes.Buffer{}
+	defaultLogger := log.Logger
+	log.Logger = zerolog.New(buf)
+	defer func() { log.Logger = defaultLogger }()
+
+	e := echo.New()
+	e.Use(middleware.RequestID(), NewHTTPMiddleware())
+	e.GET("/persons/:id", func(c echo.Context) error {
+		zerolog.Ctx(c.Request().Context()).Info().Msg("from handler")
+		return c.NoContent(http.StatusNotFound)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/persons/1", nil)
+	req.Header.Set(echo.HeaderXRequestID, "test-request-id")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	require.Equal(t, "test-request-id", rec.Header().Get(echo.HeaderXRequestID))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+
+	handlerEntry := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &handlerEntry))
+	require.Equal(t, "test-request-id", handlerEntry["request_id"])
+	require.Equal(t, "/persons/:id", handlerEntry["route"])
+
+	accessEntry := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &accessEntry))
+	require.Equal(t, "warn", accessEntry["level"])
+	require.Equal(t, float64(http.StatusNotFound), accessEntry["status"])
+	require.Contains(t, accessEntry, "latency")
+}
@@ -0,0 +1,98 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"github.com/rs/zerolog/log"
+	"os"
+	"sync"
+)
+
+type component struct {
+	name string
+	run  func() error
+	stop func(ctx context.Context) error
+}
+
+// lifecycle runs components in the order they were added and stops them in reverse order,
+// so a component is always stopped before the ones it depends on.
+type lifecycle struct {
+	components []component
+	errorChan  chan error
+	wg         sync.WaitGroup
+	runErr     error
+}
+
+func newLifecycle() *lifecycle {
+	return &lifecycle{}
+}
+
+// Add appends a component. run blocks while the component works and may be nil for passive
+// components such as a connection pool; stop may be nil if there is nothing to release.
+func (l *lifecycle) Add(name string, run func() error, stop func(ctx context.Context) error) {
+	l.components = append(l.components, component{name: name, run: run, stop: stop})
+}
+
+// Run starts all runnable components and blocks until a signal is received or one of them
+// fails. In the latter case it returns a nil signal and the failure is reported by Stop.
+func (l *lifecycle) Run(shutdown <-chan os.Signal) os.Signal {
+	l.errorChan = make(chan error, len(l.components))
+	for _, c := range l.components {
+		if c.run == nil {
+			continue
+		}
+		l.wg.Add(1)
+		go func(c component) {
+			defer l.wg.Done()
+			log.Info().Str("component", c.name).Msg("component started")
+			if err := c.run(); err != nil {
+				l.errorChan <- errors.Join(errors.New(c.name+" failed"), err)
+			}
+		}(c)
+	}
+
+	select {
+	case err := <-l.errorChan:
+		log.Err(err).Msg("fatal error occurred")
+		l.runErr = err
+		return nil
+	case sig := <-shutdown:
+		return sig
+	}
+}
+
+// Stop stops all components in reverse order, waits for the runnable ones to return and
+// reports the failure that triggered the shutdown together with errors from stopping.
+func (l *lifecycle) Stop(ctx context.Context) error {
+	errs := []error{l.runErr}
+	for i := len(l.components) - 1; i >= 0; i-- {
+		c := l.components[i]
+		if c.stop == nil {
+			continue
+		}
+		if err := c.stop(ctx); err != nil {
+			log.Err(err).Str("component", c.name).Msg("could not stop component")
+			errs = append(errs, err)
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		l.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return errors.Join(append(errs, errors.New("components did not stop in time"))...)
+	}
+
+	if l.errorChan != nil {
+		close(l.errorChan)
+		for err := range l.errorChan {
+			log.Err(err).Msg("error occurred during shutdown")
+		}
+	}
+
+	return errors.Join(errs...)
+}
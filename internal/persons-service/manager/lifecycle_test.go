@@ -0,0 +1,123 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/require"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+type testComponent struct {
+	name    string
+	stopped chan struct{}
+	runErr  error
+	stopErr error
+	order   *[]string
+}
+
+func newTestComponent(name string, order *[]string) *testComponent {
+	return &testComponent{name: name, stopped: make(chan struct{}), order: order}
+}
+
+func (c *testComponent) Run() error {
+	if c.runErr != nil {
+		return c.runErr
+	}
+	<-c.stopped
+	return nil
+}
+
+func (c *testComponent) Stop(ctx context.Context) error {
+	*c.order = append(*c.order, c.name)
+	close(c.stopped)
+	return c.stopErr
+}
+
+func Test_Lifecycle(t *testing.T) {
+	tests := []struct {
+		name          string
+		signal        os.Signal
+		runErr        error
+		stopErr       error
+		expectedError bool
+	}{
+		{
+			name:   "stopped by signal",
+			signal: syscall.SIGTERM,
+		},
+		{
+			name:          "stopped by fatal error",
+			runErr:        errors.New("listen error"),
+			expectedError: true,
+		},
+		{
+			name:          "stop error is reported",
+			signal:        syscall.SIGINT,
+			stopErr:       errors.New("close error"),
+			expectedError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := make([]string, 0)
+			l := newLifecycle()
+
+			passiveStopped := false
+			l.Add("passive", nil, func(ctx context.Context) error {
+				order = append(order, "passive")
+				passiveStopped = true
+				return nil
+			})
+
+			first := newTestComponent("first", &order)
+			first.stopErr = tt.stopErr
+			l.Add(first.name, first.Run, first.Stop)
+
+			second := newTestComponent("second", &order)
+			second.runErr = tt.runErr
+			l.Add(second.name, second.Run, second.Stop)
+
+			shutdown := make(chan os.Signal, 1)
+			if tt.signal != nil {
+				shutdown <- tt.signal
+			}
+
+			sig := l.Run(shutdown)
+			require.Equal(t, tt.signal, sig)
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+
+			err := l.Stop(ctx)
+			require.Equal(t, tt.expectedError, err != nil)
+			if tt.runErr != nil {
+				require.ErrorIs(t, err, tt.runErr)
+			}
+			if tt.stopErr != nil {
+				require.ErrorIs(t, err, tt.stopErr)
+			}
+			require.Equal(t, []string{"second", "first", "passive"}, order)
+			require.True(t, passiveStopped)
+		})
+	}
+}
+
+func Test_LifecycleStopTimeout(t *testing.T) {
+	l := newLifecycle()
+	l.Add("stuck", func() error {
+		select {}
+	}, nil)
+
+	shutdown := make(chan os.Signal, 1)
+	shutdown <- syscall.SIGTERM
+	l.Run(shutdown)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	require.Error(t, l.Stop(ctx))
+}
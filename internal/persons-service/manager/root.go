@@ -2,16 +2,94 @@ package manager
 
 import (
 	"context"
+	"fmt"
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/attachment"
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/blob"
 	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/config"
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/grpc"
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/health"
 	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/http"
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/logging"
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/metrics"
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/migrator"
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/openapi"
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/organization"
 	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/person"
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/replicas"
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/tracing"
+	"github.com/Erlendum/rsoi-lab-01/pkg/cache"
 	"github.com/jmoiron/sqlx"
+	"github.com/labstack/echo/v4"
 	_ "github.com/lib/pq"
-	"github.com/rs/zerolog"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/rs/zerolog/log"
+	bolt "go.etcd.io/bbolt"
+	gogrpc "google.golang.org/grpc"
 	"os"
 )
 
+var (
+	ErrConfig  = errors.New("config error")
+	ErrStorage = errors.New("storage error")
+)
+
+// PersonStorage is the set of person operations shared by all storage backends.
+type PersonStorage interface {
+	CreatePerson(ctx context.Context, person person.Person) (int, error)
+	UpdatePerson(ctx context.Context, id int, person *person.Person) error
+	DeletePerson(ctx context.Context, id int) (bool, error)
+	GetPersons(ctx context.Context, page person.Page, fields ...string) ([]person.Person, error)
+	GetPerson(ctx context.Context, id int, fields ...string) (person.Person, error)
+	GetPersonsByIDs(ctx context.Context, ids []int) ([]person.Person, error)
+	FindPersons(ctx context.Context, match person.Person, page person.Page, fields ...string) ([]person.Person, error)
+	CountPersons(ctx context.Context, match person.Person) (int, error)
+	FindDuplicateCandidates(ctx context.Context, p person.Person, limit int) ([]person.Person, error)
+	MergePersons(ctx context.Context, id, sourceID int) (person.Person, error)
+	PersonsChanged(ctx context.Context, ids []int)
+	GetPersonAlias(ctx context.Context, aliasID int) (int, error)
+	CreateRelation(ctx context.Context, relation person.Relation) (int, error)
+	GetRelations(ctx context.Context, personID int) ([]person.Relation, error)
+	GetRelationsByPersonIDs(ctx context.Context, personIDs []int) ([]person.Relation, error)
+	DeleteRelation(ctx context.Context, personID, relationID int) (bool, error)
+	GetRelatives(ctx context.Context, personID, maxDepth int, types []string) ([]person.Relative, error)
+}
+
+// personBackend is a person storage backend, which checks the unique constraints in the
+// transaction it writes a person in.
+type personBackend interface {
+	PersonStorage
+	// SetUniqueConstraints makes CreatePerson and UpdatePerson fail with a person.ConflictError
+	// when the person would share the fields of a constraint, e.g. [name, address], with another
+	// one. It fails for an unknown field and then leaves the constraints as they were.
+	SetUniqueConstraints(fields [][]string) error
+}
+
+// OrganizationStorage is the set of organization operations shared by all storage backends.
+type OrganizationStorage interface {
+	CreateOrganization(ctx context.Context, org organization.Organization) (int, error)
+	UpdateOrganization(ctx context.Context, id int, org *organization.Organization) ([]int, error)
+	DeleteOrganization(ctx context.Context, id int) (bool, []int, error)
+	GetOrganizations(ctx context.Context) ([]organization.Organization, error)
+	GetOrganization(ctx context.Context, id int) (organization.Organization, error)
+	CreateEmployment(ctx context.Context, employment organization.Employment) (int, error)
+	DeleteEmployment(ctx context.Context, personID, employmentID int) (bool, error)
+	GetPersonEmployments(ctx context.Context, personID int) ([]organization.Employment, error)
+	GetOrganizationEmployments(ctx context.Context, organizationID int) ([]organization.Employment, error)
+	person.Dependent
+}
+
+// AttachmentStorage is the set of attachment metadata operations shared by all storage
+// backends.
+type AttachmentStorage interface {
+	CreateAttachment(ctx context.Context, a attachment.Attachment) (int, error)
+	GetAttachments(ctx context.Context, personID int) ([]attachment.Attachment, error)
+	GetAttachment(ctx context.Context, personID, id int) (attachment.Attachment, error)
+	DeleteAttachment(ctx context.Context, personID, id int) (bool, error)
+	MoveAttachments(ctx context.Context, fromID, toID int) error
+}
+
 type server interface {
 	Init() error
 	Run() error
@@ -19,66 +97,403 @@ type server interface {
 }
 
 type root struct {
-	errorChan chan error
-	server    server
+	lifecycle *lifecycle
 	cfg       *config.Config
+	cfgPath   string
+	logLevel  string
+	psqldb    *sqlx.DB
+	replicas  map[string]*sqlx.DB
+	router    *replicas.Router
+	boltdb    *bolt.DB
+	// personWork is the memory person storage the memory organization storage keeps the work
+	// field of persons in.
+	personWork organization.PersonWork
 }
 
-func NewRoot() *root {
-	return &root{}
+// NewRoot creates the root; an empty logLevel means the level from the config is used.
+func NewRoot(cfgPath string, logLevel string) *root {
+	return &root{
+		lifecycle: newLifecycle(),
+		cfgPath:   cfgPath,
+		logLevel:  logLevel,
+	}
+}
+
+// LoadConfig reads the config and sets up logging. It is the first step of every command.
+func (r *root) LoadConfig() error {
+	cfg, err := config.New(r.cfgPath)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrConfig, err)
+	}
+	r.cfg = cfg
+
+	if err = logging.Setup(&cfg.Log, r.logLevel); err != nil {
+		return fmt.Errorf("%w: %w", ErrConfig, err)
+	}
+
+	return nil
+}
+
+// Connect opens the PostgreSQL connection. LoadConfig must be called before.
+func (r *root) Connect(ctx context.Context) error {
+	psqldb, err := sqlx.ConnectContext(ctx, "postgres", r.cfg.PostgreSQL.DSN)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrStorage, err)
+	}
+	r.psqldb = psqldb
+
+	return nil
+}
+
+// OpenStorage opens the person storage selected by the config and registers its release in
+// the lifecycle. LoadConfig must be called before.
+func (r *root) OpenStorage(ctx context.Context) (PersonStorage, error) {
+	storage, err := r.openPersonBackend(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err = storage.SetUniqueConstraints(r.cfg.Persons.UniqueConstraints); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrConfig, err)
+	}
+
+	return storage, nil
+}
+
+func (r *root) openPersonBackend(ctx context.Context) (personBackend, error) {
+	switch r.cfg.Storage.Driver {
+	case "", config.StorageDriverPostgreSQL:
+		err := r.Connect(ctx)
+		if err != nil {
+			return nil, err
+		}
+		r.lifecycle.Add("postgresql", nil, func(ctx context.Context) error {
+			return r.psqldb.Close()
+		})
+		if len(r.cfg.PostgreSQL.ReplicaDSNs) == 0 {
+			return person.NewRepository(r.psqldb), nil
+		}
+
+		r.replicas = make(map[string]*sqlx.DB, len(r.cfg.PostgreSQL.ReplicaDSNs))
+		for i, dsn := range r.cfg.PostgreSQL.ReplicaDSNs {
+			// Opened lazily: an unavailable replica must not prevent the start, reads go to
+			// the primary until the router has seen the replica healthy.
+			db, err := sqlx.Open("postgres", dsn)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %w", ErrConfig, err)
+			}
+			name := fmt.Sprintf("replica_%d", i)
+			r.replicas[name] = db
+			r.lifecycle.Add(name, nil, func(ctx context.Context) error {
+				return db.Close()
+			})
+		}
+
+		r.router = replicas.NewRouter(r.psqldb, r.replicas,
+			r.cfg.PostgreSQL.ReplicaCheckInterval,
+			r.cfg.PostgreSQL.ReplicaMaxLag,
+			r.cfg.PostgreSQL.ReadYourWritesWindow,
+		)
+		r.lifecycle.Add("replica router", r.router.Run, r.router.Stop)
+		return person.NewReplicatedRepository(r.router), nil
+	case config.StorageDriverMemory:
+		repo := person.NewMemoryRepository()
+		r.personWork = repo
+		return repo, nil
+	case config.StorageDriverBolt:
+		repo, err := person.NewBoltRepository(r.cfg.Storage.Path)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrStorage, err)
+		}
+		r.lifecycle.Add("bolt", nil, func(ctx context.Context) error {
+			return repo.Close()
+		})
+		r.boltdb = repo.DB()
+		return repo, nil
+	default:
+		return nil, fmt.Errorf("%w: unknown storage driver %q", ErrConfig, r.cfg.Storage.Driver)
+	}
+}
+
+// openOrganizationStorage opens the organization storage on the connections OpenStorage has
+// opened for the persons, so both live in the same database.
+func (r *root) openOrganizationStorage() (OrganizationStorage, error) {
+	switch {
+	case r.router != nil:
+		return organization.NewReplicatedRepository(r.router), nil
+	case r.psqldb != nil:
+		return organization.NewRepository(r.psqldb), nil
+	case r.boltdb != nil:
+		repo, err := organization.NewBoltRepository(r.boltdb)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrStorage, err)
+		}
+		return repo, nil
+	default:
+		return organization.NewMemoryRepository(r.personWork), nil
+	}
+}
+
+// openAttachmentStorage opens the attachment metadata storage next to the persons, like
+// openOrganizationStorage.
+func (r *root) openAttachmentStorage() (AttachmentStorage, error) {
+	switch {
+	case r.router != nil:
+		return attachment.NewReplicatedRepository(r.router), nil
+	case r.psqldb != nil:
+		return attachment.NewRepository(r.psqldb), nil
+	case r.boltdb != nil:
+		repo, err := attachment.NewBoltRepository(r.boltdb)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrStorage, err)
+		}
+		return repo, nil
+	default:
+		return attachment.NewMemoryRepository(), nil
+	}
+}
+
+// openBlobStore opens the store for the attachment contents selected by the config.
+func (r *root) openBlobStore() (blob.Store, error) {
+	cfg := &r.cfg.Attachments
+	switch cfg.Store {
+	case config.BlobStoreS3:
+		store, err := blob.NewS3Store(blob.S3Config{
+			Endpoint:        cfg.S3.Endpoint,
+			Region:          cfg.S3.Region,
+			Bucket:          cfg.S3.Bucket,
+			AccessKeyID:     cfg.S3.AccessKeyID,
+			SecretAccessKey: cfg.S3.SecretAccessKey,
+		}, nil)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrConfig, err)
+		}
+		return store, nil
+	default:
+		store, err := blob.NewFSStore(cfg.Path)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrStorage, err)
+		}
+		return store, nil
+	}
+}
+
+// Close releases everything opened by OpenStorage, for commands that do not run the server.
+func (r *root) Close(ctx context.Context) error {
+	return r.lifecycle.Stop(ctx)
+}
+
+func (r *root) Config() *config.Config {
+	return r.cfg
+}
+
+func (r *root) DB() *sqlx.DB {
+	return r.psqldb
 }
 
 func (r *root) Register(ctx context.Context) error {
-	zerolog.SetGlobalLevel(zerolog.InfoLevel)
-	log.Logger = log.With().Caller().Logger()
+	err := r.register(ctx)
+	if err != nil {
+		if stopErr := r.lifecycle.Stop(ctx); stopErr != nil {
+			log.Err(stopErr).Msg("could not release partially registered components")
+		}
+		return err
+	}
 
-	var err error
-	r.cfg, err = config.New()
+	return nil
+}
+
+func (r *root) register(ctx context.Context) error {
+	err := r.LoadConfig()
 	if err != nil {
 		log.Error().Err(err).Msg("config load error")
 		return err
 	}
 
-	psqldb, err := sqlx.Connect("postgres", r.cfg.PostgreSQL.DSN)
+	if err = r.cfg.Validate(); err != nil {
+		log.Error().Err(err).Msg("config validation error")
+		return fmt.Errorf("%w: %w", ErrConfig, err)
+	}
+
+	storage, err := r.OpenStorage(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("storage open error")
+		return err
+	}
+
+	if r.psqldb != nil && r.cfg.PostgreSQL.AutoMigrate {
+		m, err := migrator.NewMigrator(r.psqldb.DB)
+		if err != nil {
+			log.Error().Err(err).Msg("migrator init error")
+			return fmt.Errorf("%w: %w", ErrStorage, err)
+		}
+		if err = m.Up(ctx); err != nil {
+			log.Error().Err(err).Msg("auto migration error")
+			return fmt.Errorf("%w: %w", ErrStorage, err)
+		}
+	}
+
+	tp, err := tracing.NewProvider(ctx, &r.cfg.Tracing)
+	if err != nil {
+		log.Error().Err(err).Msg("tracing init error")
+		return fmt.Errorf("%w: %w", ErrConfig, err)
+	}
+	r.lifecycle.Add("tracing", nil, tp.Shutdown)
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	if r.psqldb != nil {
+		registry.MustRegister(collectors.NewDBStatsCollector(r.psqldb.DB, "persons"))
+	}
+	for name, db := range r.replicas {
+		registry.MustRegister(collectors.NewDBStatsCollector(db.DB, "persons_"+name))
+	}
+
+	if r.cfg.Admin.Address != "" {
+		adminServer := metrics.NewServer(&r.cfg.Admin, registry)
+
+		err = adminServer.Init()
+		if err != nil {
+			log.Error().Err(err).Msg("admin server init error")
+			return err
+		}
+		r.lifecycle.Add("admin server", adminServer.Run, adminServer.Stop)
+	}
+
+	organizationRepo, err := r.openOrganizationStorage()
+	if err != nil {
+		log.Error().Err(err).Msg("organization storage open error")
+		return err
+	}
+	attachmentRepo, err := r.openAttachmentStorage()
 	if err != nil {
-		log.Error().Err(err).Msg("postgresql connection error")
+		log.Error().Err(err).Msg("attachment storage open error")
 		return err
 	}
+	blobStore, err := r.openBlobStore()
+	if err != nil {
+		log.Error().Err(err).Msg("blob store open error")
+		return err
+	}
+
+	var personRepo PersonStorage = person.NewInstrumentedStorage(storage, registry)
+	if r.cfg.Cache.Enabled {
+		cached := person.NewCachedStorage(personRepo, cache.NewLRU(r.cfg.Cache.Size), r.cfg.Cache.TTL, registry)
+		if r.router != nil {
+			cached.SkipWhen(r.router.Sticky)
+		}
+		personRepo = cached
+	}
+	personRepo = person.NewDependentStorage(personRepo, organizationRepo, attachment.NewDependent(attachmentRepo, blobStore))
+
+	// Outermost, so the changes made through any API reach the GraphQL subscriptions.
+	personEvents := person.NewBroker()
+	personRepo = person.NewEventStorage(personRepo, personEvents)
+
+	// OpenStorage has set the unique constraints on the storage, which enforces them.
+	personRules := r.cfg.Persons
+	personRules.UniqueConstraints = nil
+
+	personHandler, err := person.NewHandler(personRepo, &personRules)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrConfig, err)
+	}
+	graphqlHandler, err := person.NewGraphQLHandler(personRepo, personEvents, &personRules)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrConfig, err)
+	}
+
+	organizationHandler := organization.NewHandler(organizationRepo, personRepo)
+	attachmentHandler := attachment.NewHandler(attachmentRepo, personRepo, blobStore, &r.cfg.Attachments)
+
+	healthHandler := health.NewHandler()
+	if r.psqldb != nil {
+		healthHandler.AddChecker("postgresql", health.NewPostgreSQLChecker(r.psqldb))
+	}
 
-	personRepo := person.NewRepository(psqldb)
+	spec, err := openapi.Load()
+	if err != nil {
+		log.Error().Err(err).Msg("openapi spec load error")
+		return err
+	}
+	docsHandler, err := openapi.NewHandler(spec)
+	if err != nil {
+		return err
+	}
+	specMiddleware, err := openapi.NewMiddleware(spec, &r.cfg.OpenAPI)
+	if err != nil {
+		log.Error().Err(err).Msg("openapi validation init error")
+		return err
+	}
 
-	personHandler := person.NewHandler(personRepo)
+	middlewares := []echo.MiddlewareFunc{
+		tracing.NewHTTPMiddleware(),
+		logging.NewHTTPMiddleware(),
+		metrics.NewHTTPMiddleware(registry),
+	}
+	if r.router != nil {
+		middlewares = append(middlewares, r.router.NewHTTPMiddleware())
+	}
+	middlewares = append(middlewares, specMiddleware)
 
-	r.server = http.NewServer(&r.cfg.Server, personHandler)
+	var server server = http.NewServer(&r.cfg.Server, personHandler, organizationHandler, attachmentHandler, graphqlHandler, docsHandler, healthHandler, middlewares...)
 
-	err = r.server.Init()
+	err = server.Init()
 	if err != nil {
 		log.Error().Err(err).Msg("server init error")
 		return err
 	}
+	r.lifecycle.Add("server", server.Run, server.Stop)
+
+	if r.cfg.GRPC.Address != "" {
+		// Shares the storage and the person rules with the http handlers.
+		personService, err := person.NewGRPCHandler(personRepo, &personRules)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrConfig, err)
+		}
+
+		grpcServer := grpc.NewServer(&r.cfg.GRPC, personService,
+			gogrpc.ChainUnaryInterceptor(tracing.NewGRPCUnaryInterceptor(), logging.NewGRPCUnaryInterceptor()),
+			gogrpc.ChainStreamInterceptor(tracing.NewGRPCStreamInterceptor(), logging.NewGRPCStreamInterceptor()),
+		)
+
+		err = grpcServer.Init()
+		if err != nil {
+			log.Error().Err(err).Msg("grpc server init error")
+			return err
+		}
+		r.lifecycle.Add("grpc server", grpcServer.Run, grpcServer.Stop)
+	}
+
+	// Added last, so readiness starts failing before anything else is stopped.
+	r.lifecycle.Add("readiness", nil, func(ctx context.Context) error {
+		healthHandler.Shutdown()
+		return nil
+	})
 
 	return nil
 }
 
+// Resolve runs the registered components until a shutdown signal is received or one of them
+// fails; in the latter case the returned signal is nil.
 func (r *root) Resolve(ctx context.Context, shutdown chan os.Signal) os.Signal {
-	go func() {
-		log.Info().Msg("server started")
-		r.errorChan <- r.server.Run()
-	}()
-	for {
-		select {
-		case err := <-r.errorChan:
-			log.Err(err).Msg("error occurred")
-		case sig := <-shutdown:
-			return sig
-		}
-	}
+	return r.lifecycle.Run(shutdown)
 }
 
-func (r *root) Release(ctx context.Context, signal os.Signal) {
-	log.Info().Msgf("shutdown started with signal : [%d]", signal)
-	defer log.Info().Msg("shutdown completed")
-	if err := r.server.Stop(ctx); err != nil {
-		log.Err(err).Msg("could not stop server")
+// Release stops the components in reverse order within the server shutdown timeout and
+// returns the error that caused the shutdown, if any.
+func (r *root) Release(ctx context.Context, signal os.Signal) error {
+	if signal != nil {
+		log.Info().Msgf("shutdown started with signal : [%s]", signal)
+	} else {
+		log.Info().Msg("shutdown started after fatal error")
 	}
+	defer log.Info().Msg("shutdown completed")
+
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.Server.ShutdownTimeout)
+	defer cancel()
+
+	return r.lifecycle.Stop(ctx)
 }
@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/config"
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog/log"
+	"net/http"
+)
+
+// server is the admin listener exposing /metrics apart from the public API.
+type server struct {
+	echo     *echo.Echo
+	cfg      *config.Server
+	gatherer prometheus.Gatherer
+}
+
+func NewServer(cfg *config.Server, gatherer prometheus.Gatherer) *server {
+	return &server{
+		echo:     echo.New(),
+		cfg:      cfg,
+		gatherer: gatherer,
+	}
+}
+
+func (s *server) Init() error {
+	s.echo.Server.Addr = s.cfg.Address
+	s.echo.HideBanner = true
+	s.echo.HidePort = true
+
+	s.echo.GET("/metrics", echo.WrapHandler(promhttp.HandlerFor(s.gatherer, promhttp.HandlerOpts{})))
+	return nil
+}
+
+func (s *server) Run() error {
+	log.Info().Msg("admin server has been started")
+	err := s.echo.StartServer(s.echo.Server)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+func (s *server) Stop(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.ShutdownTimeout)
+	defer cancel()
+	if err := s.echo.Shutdown(ctx); err != nil {
+		log.Err(err).Msg("could not stop admin server gracefully")
+		return s.echo.Close()
+	}
+	return nil
+}
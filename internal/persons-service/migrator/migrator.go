@@ -0,0 +1,74 @@
+package migrator
+
+import (
+	"context"
+	"database/sql"
+	migrations "github.com/Erlendum/rsoi-lab-01/migrations/persons-service"
+	"github.com/pkg/errors"
+	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/lock"
+	"github.com/rs/zerolog/log"
+)
+
+type migrator struct {
+	provider *goose.Provider
+}
+
+// NewMigrator creates a migrator over the embedded migrations. Every operation holds a
+// PostgreSQL advisory lock, so several replicas starting at once apply migrations only once.
+func NewMigrator(db *sql.DB) (*migrator, error) {
+	locker, err := lock.NewPostgresSessionLocker()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create session locker")
+	}
+
+	provider, err := goose.NewProvider(goose.DialectPostgres, db, migrations.FS, goose.WithSessionLocker(locker))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create migrations provider")
+	}
+
+	return &migrator{provider: provider}, nil
+}
+
+func (m *migrator) Up(ctx context.Context) error {
+	results, err := m.provider.Up(ctx)
+	for _, res := range results {
+		log.Info().Msgf("migration applied: %s", res)
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to apply migrations")
+	}
+
+	return nil
+}
+
+func (m *migrator) Down(ctx context.Context) error {
+	res, err := m.provider.Down(ctx)
+	if res != nil {
+		log.Info().Msgf("migration rolled back: %s", res)
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to roll back migration")
+	}
+
+	return nil
+}
+
+func (m *migrator) Status(ctx context.Context) ([]*goose.MigrationStatus, error) {
+	statuses, err := m.provider.Status(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get migrations status")
+	}
+
+	return statuses, nil
+}
+
+// Create writes a new empty SQL migration into dir. The embedded set is fixed at build time,
+// so the new file is picked up only after the binary is rebuilt.
+func Create(dir, name string) error {
+	if err := goose.Create(nil, dir, name, "sql"); err != nil {
+		return errors.Wrap(err, "failed to create migration")
+	}
+
+	return nil
+}
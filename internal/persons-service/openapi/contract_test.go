@@ -0,0 +1,341 @@
+package openapi
+
+import (
+	"bytes"
+	"context"
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/attachment"
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/blob"
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/config"
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/health"
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/organization"
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/person"
+	"github.com/Erlendum/rsoi-lab-01/pkg/validation"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"image"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var specPathParam = regexp.MustCompile(`\{([a-z_]+)\}`)
+
+// specCustomMethod matches the custom methods of the spec, like /persons/{id}:merge, which
+// are routed on /persons/:id/merge.
+var specCustomMethod = regexp.MustCompile(`\{([a-z_]+)\}:([a-z]+)$`)
+
+// newContractServer registers the API handlers on memory storages, like the service does,
+// behind a validator that fails the test on every response the spec does not describe.
+func newContractServer(t *testing.T, spec *openapi3.T) *echo.Echo {
+	e := echo.New()
+	e.Validator = validation.MustRegisterCustomValidator(validator.New())
+
+	persons := person.NewMemoryRepository()
+	require.NoError(t, persons.SetUniqueConstraints([][]string{{"email"}}))
+	personHandler, err := person.NewHandler(persons, &config.Persons{
+		DuplicateThreshold: 0.8,
+		Attributes: config.Attributes{
+			Schemas: map[string]string{"default": "../../../configs/persons-service/attributes/default.schema.json"},
+		},
+	})
+	require.NoError(t, err)
+
+	blobs, err := blob.NewFSStore(t.TempDir())
+	require.NoError(t, err)
+
+	docsHandler, err := NewHandler(spec)
+	require.NoError(t, err)
+
+	v, err := newSpecValidator(spec)
+	require.NoError(t, err)
+	v.validateResponses = true
+	v.onResponseError = func(c echo.Context, err error) {
+		t.Errorf("%s %s: response does not match the spec: %v", c.Request().Method, c.Request().URL, err)
+	}
+	e.Use(v.middleware)
+
+	health.NewHandler().Register(e)
+	docsHandler.Register(e)
+	personHandler.Register(e)
+	organization.NewHandler(organization.NewMemoryRepository(persons), persons).Register(e)
+	attachment.NewHandler(attachment.NewMemoryRepository(), persons, blobs, &config.Attachments{
+		MaxSize:       1 << 20,
+		ThumbnailSize: 16,
+	}).Register(e)
+
+	return e
+}
+
+func Test_RoutesMatchSpec(t *testing.T) {
+	spec, err := Load()
+	require.NoError(t, err)
+	e := newContractServer(t, spec)
+
+	var specRoutes []string
+	for path, item := range spec.Paths.Map() {
+		routed := specCustomMethod.ReplaceAllString(path, "{$1}/$2")
+		routed = specPathParam.ReplaceAllString(routed, ":$1")
+		for method := range item.Operations() {
+			specRoutes = append(specRoutes, method+" "+routed)
+		}
+	}
+
+	var registeredRoutes []string
+	for _, route := range e.Routes() {
+		if strings.HasPrefix(route.Path, "/api/") {
+			registeredRoutes = append(registeredRoutes, route.Method+" "+route.Path)
+		}
+	}
+
+	sort.Strings(specRoutes)
+	sort.Strings(registeredRoutes)
+	require.Equal(t, specRoutes, registeredRoutes)
+}
+
+// contractStep is a request of the contract scenario. Steps run in order on one server, so
+// later steps refer to what earlier ones created.
+type contractStep struct {
+	method      string
+	path        string
+	body        string
+	contentType string
+	header      http.Header
+	// invalidRequest marks requests the spec rejects, sent to check how the handlers answer
+	// them.
+	invalidRequest bool
+	expectedStatus int
+}
+
+func jsonStep(method, path, body string, expectedStatus int) contractStep {
+	return contractStep{method: method, path: path, body: body, contentType: echo.MIMEApplicationJSON, expectedStatus: expectedStatus}
+}
+
+func Test_Contract(t *testing.T) {
+	spec, err := Load()
+	require.NoError(t, err)
+	e := newContractServer(t, spec)
+
+	v, err := newSpecValidator(spec)
+	require.NoError(t, err)
+
+	photo := &bytes.Buffer{}
+	require.NoError(t, png.Encode(photo, image.NewGray(image.Rect(0, 0, 32, 32))))
+	photoBody, photoType := contractUpload(t, "photo", "photo.png", photo.Bytes())
+	documentBody, documentType := contractUpload(t, "", "cv.txt", []byte("curriculum vitae"))
+	largeBody, largeType := contractUpload(t, "", "large.txt", bytes.Repeat([]byte("a"), 1<<20+1))
+
+	steps := []contractStep{
+		jsonStep(http.MethodPost, "/api/v1/persons", `{"name": "Ivan", "age": 30, "address": "Moscow", "work": "Acme",
+			"email": "ivan@example.com", "phone_numbers": ["+79991234567"], "address_parts": {"city": "Moscow"},
+			"tags": ["vip"], "attributes": {"region": "eu"}}`, http.StatusCreated),
+		{method: http.MethodPost, path: "/api/v1/persons", body: `{"name": "Ivan", "email": "ivan@example.com"}`,
+			contentType: echo.MIMEApplicationJSON, header: http.Header{"X-Tenant-Id": {"acme"}}, expectedStatus: http.StatusConflict},
+		jsonStep(http.MethodPost, "/api/v1/persons", `{"name": "Ivan", "attributes": {"Region": "eu"}}`, http.StatusBadRequest),
+		{method: http.MethodPost, path: "/api/v1/persons", body: `{}`, contentType: echo.MIMEApplicationJSON,
+			invalidRequest: true, expectedStatus: http.StatusBadRequest},
+		{method: http.MethodPost, path: "/api/v1/persons", body: `{`, contentType: echo.MIMEApplicationJSON,
+			invalidRequest: true, expectedStatus: http.StatusBadRequest},
+		jsonStep(http.MethodGet, "/api/v1/persons", "", http.StatusOK),
+		jsonStep(http.MethodGet, "/api/v1/persons?tag=vip&attr.region=eu", "", http.StatusOK),
+		jsonStep(http.MethodGet, "/api/v1/persons?limit=1&offset=1", "", http.StatusOK),
+		{method: http.MethodGet, path: "/api/v1/persons?limit=0", invalidRequest: true, expectedStatus: http.StatusBadRequest},
+		{method: http.MethodGet, path: "/api/v1/persons/1?fields=name,password", invalidRequest: true, expectedStatus: http.StatusBadRequest},
+		jsonStep(http.MethodGet, "/api/v1/persons/1", "", http.StatusOK),
+		jsonStep(http.MethodGet, "/api/v1/persons/100", "", http.StatusNotFound),
+		{method: http.MethodGet, path: "/api/v1/persons/test", invalidRequest: true, expectedStatus: http.StatusBadRequest},
+		jsonStep(http.MethodPatch, "/api/v1/persons/1", `{"name": "Ivan Petrov"}`, http.StatusOK),
+		jsonStep(http.MethodPatch, "/api/v1/persons/100", `{"name": "Ivan Petrov"}`, http.StatusNotFound),
+		{method: http.MethodPatch, path: "/api/v1/persons/1", body: `{"name": "Ivan", "age": 200}`, contentType: echo.MIMEApplicationJSON,
+			invalidRequest: true, expectedStatus: http.StatusBadRequest},
+
+		jsonStep(http.MethodPost, "/api/v1/persons", `{"name": "Ivan Petrov", "address": "Moscow"}`, http.StatusCreated),
+		jsonStep(http.MethodGet, "/api/v1/persons/1/duplicates", "", http.StatusOK),
+		jsonStep(http.MethodGet, "/api/v1/persons/100/duplicates", "", http.StatusNotFound),
+		jsonStep(http.MethodPost, "/api/v1/persons/1:merge", `{"source_id": 2}`, http.StatusOK),
+		jsonStep(http.MethodPost, "/api/v1/persons/1:merge", `{"source_id": 100}`, http.StatusNotFound),
+		jsonStep(http.MethodPost, "/api/v1/persons/1:merge", `{"source_id": 1}`, http.StatusBadRequest),
+		jsonStep(http.MethodGet, "/api/v1/persons/2", "", http.StatusMovedPermanently),
+
+		jsonStep(http.MethodPost, "/api/v1/persons", `{"name": "Petr"}`, http.StatusCreated),
+		jsonStep(http.MethodPost, "/api/v1/persons/1/relations", `{"related_person_id": 3, "type": "sibling"}`, http.StatusCreated),
+		jsonStep(http.MethodPost, "/api/v1/persons/1/relations", `{"related_person_id": 3, "type": "sibling"}`, http.StatusConflict),
+		jsonStep(http.MethodPost, "/api/v1/persons/1/relations", `{"related_person_id": 100, "type": "sibling"}`, http.StatusNotFound),
+		{method: http.MethodPost, path: "/api/v1/persons/1/relations", body: `{"related_person_id": 3, "type": "friend"}`,
+			contentType: echo.MIMEApplicationJSON, invalidRequest: true, expectedStatus: http.StatusBadRequest},
+		jsonStep(http.MethodGet, "/api/v1/persons/1/relations", "", http.StatusOK),
+		jsonStep(http.MethodGet, "/api/v1/persons/100/relations", "", http.StatusNotFound),
+		jsonStep(http.MethodGet, "/api/v1/persons/1/relatives?depth=2&type=sibling", "", http.StatusOK),
+		jsonStep(http.MethodGet, "/api/v1/persons/100/relatives", "", http.StatusNotFound),
+		{method: http.MethodGet, path: "/api/v1/persons/1/relatives?depth=9", invalidRequest: true, expectedStatus: http.StatusBadRequest},
+		jsonStep(http.MethodDelete, "/api/v1/persons/1/relations/1", "", http.StatusNoContent),
+		jsonStep(http.MethodDelete, "/api/v1/persons/1/relations/1", "", http.StatusNotFound),
+
+		jsonStep(http.MethodPost, "/api/v1/organizations", `{"name": "Acme"}`, http.StatusCreated),
+		jsonStep(http.MethodPost, "/api/v1/organizations", `{"name": "Acme"}`, http.StatusConflict),
+		{method: http.MethodPost, path: "/api/v1/organizations", body: `{}`, contentType: echo.MIMEApplicationJSON,
+			invalidRequest: true, expectedStatus: http.StatusBadRequest},
+		jsonStep(http.MethodGet, "/api/v1/organizations", "", http.StatusOK),
+		jsonStep(http.MethodGet, "/api/v1/organizations/1", "", http.StatusOK),
+		jsonStep(http.MethodGet, "/api/v1/organizations/100", "", http.StatusNotFound),
+		jsonStep(http.MethodPatch, "/api/v1/organizations/1", `{"name": "Acme Corp"}`, http.StatusOK),
+		jsonStep(http.MethodPatch, "/api/v1/organizations/100", `{"name": "Acme Corp"}`, http.StatusNotFound),
+		jsonStep(http.MethodPost, "/api/v1/organizations", `{"name": "Initech"}`, http.StatusCreated),
+		jsonStep(http.MethodPatch, "/api/v1/organizations/2", `{"name": "Acme Corp"}`, http.StatusConflict),
+		jsonStep(http.MethodPost, "/api/v1/persons/1/employments", `{"organization_id": 1, "role": "developer", "start_date": "2020-01-01"}`, http.StatusCreated),
+		jsonStep(http.MethodPost, "/api/v1/persons/1/employments", `{"organization_id": 100}`, http.StatusNotFound),
+		jsonStep(http.MethodPost, "/api/v1/persons/1/employments", `{"organization_id": 1, "start_date": "2020-01-01", "end_date": "2019-01-01"}`, http.StatusBadRequest),
+		jsonStep(http.MethodGet, "/api/v1/persons/1/employments", "", http.StatusOK),
+		jsonStep(http.MethodGet, "/api/v1/persons/100/employments", "", http.StatusNotFound),
+		jsonStep(http.MethodGet, "/api/v1/organizations/1/persons?current=true", "", http.StatusOK),
+		{method: http.MethodGet, path: "/api/v1/organizations/1/persons?current=maybe", invalidRequest: true, expectedStatus: http.StatusBadRequest},
+		jsonStep(http.MethodGet, "/api/v1/organizations/100/persons", "", http.StatusNotFound),
+		jsonStep(http.MethodDelete, "/api/v1/persons/1/employments/1", "", http.StatusNoContent),
+		jsonStep(http.MethodDelete, "/api/v1/persons/1/employments/1", "", http.StatusNotFound),
+		jsonStep(http.MethodDelete, "/api/v1/organizations/2", "", http.StatusNoContent),
+		jsonStep(http.MethodDelete, "/api/v1/organizations/2", "", http.StatusNotFound),
+
+		{method: http.MethodPost, path: "/api/v1/persons/1/attachments", body: photoBody, contentType: photoType, expectedStatus: http.StatusCreated},
+		{method: http.MethodPost, path: "/api/v1/persons/1/attachments", body: documentBody, contentType: documentType, expectedStatus: http.StatusCreated},
+		{method: http.MethodPost, path: "/api/v1/persons/100/attachments", body: documentBody, contentType: documentType, expectedStatus: http.StatusNotFound},
+		{method: http.MethodPost, path: "/api/v1/persons/1/attachments", body: largeBody, contentType: largeType, expectedStatus: http.StatusRequestEntityTooLarge},
+		{method: http.MethodPost, path: "/api/v1/persons/1/attachments", body: strings.Replace(documentBody, `name="file"`, `name="photo"`, 1),
+			contentType: documentType, invalidRequest: true, expectedStatus: http.StatusBadRequest},
+		jsonStep(http.MethodGet, "/api/v1/persons/1/attachments", "", http.StatusOK),
+		jsonStep(http.MethodGet, "/api/v1/persons/100/attachments", "", http.StatusNotFound),
+		jsonStep(http.MethodGet, "/api/v1/persons/1/attachments/2", "", http.StatusOK),
+		{method: http.MethodGet, path: "/api/v1/persons/1/attachments/2", header: http.Header{"Range": {"bytes=0-3"}}, expectedStatus: http.StatusPartialContent},
+		{method: http.MethodGet, path: "/api/v1/persons/1/attachments/2", header: http.Header{"Range": {"bytes=100-"}}, expectedStatus: http.StatusRequestedRangeNotSatisfiable},
+		jsonStep(http.MethodGet, "/api/v1/persons/1/attachments/100", "", http.StatusNotFound),
+		jsonStep(http.MethodGet, "/api/v1/persons/1/attachments/1/thumbnail", "", http.StatusOK),
+		jsonStep(http.MethodGet, "/api/v1/persons/1/attachments/2/thumbnail", "", http.StatusNotFound),
+		jsonStep(http.MethodDelete, "/api/v1/persons/1/attachments/2", "", http.StatusNoContent),
+		jsonStep(http.MethodDelete, "/api/v1/persons/1/attachments/2", "", http.StatusNotFound),
+
+		jsonStep(http.MethodDelete, "/api/v1/persons/3", "", http.StatusNoContent),
+		jsonStep(http.MethodDelete, "/api/v1/persons/3", "", http.StatusNotFound),
+	}
+	steps = append(steps, versionSteps("/api/v2", 4, 2, nil)...)
+	steps = append(steps, versionSteps("/api", 6, 3, http.Header{"Accept": {"application/json; version=2"}})...)
+	steps = append(steps, contractStep{method: http.MethodGet, path: "/api/persons/1",
+		header: http.Header{"Accept": {"application/json; version=3"}}, expectedStatus: http.StatusNotAcceptable})
+
+	succeeded := make(map[*openapi3.Operation]bool)
+	for _, step := range steps {
+		req := httptest.NewRequest(step.method, step.path, strings.NewReader(step.body))
+		for name, values := range step.header {
+			req.Header[name] = values
+		}
+		if step.contentType != "" && step.body != "" {
+			req.Header.Set(echo.HeaderContentType, step.contentType)
+		}
+
+		route, pathParams, err := v.router.FindRoute(req)
+		require.NoError(t, err, "%s %s is not in the spec", step.method, step.path)
+
+		err = openapi3filter.ValidateRequest(context.Background(), &openapi3filter.RequestValidationInput{
+			Request:    req.Clone(context.Background()),
+			PathParams: pathParams,
+			Route:      route,
+			Options:    &openapi3filter.Options{AuthenticationFunc: openapi3filter.NoopAuthenticationFunc, SkipSettingDefaults: true},
+		})
+		if step.invalidRequest {
+			require.Error(t, err, "%s %s should not match the spec", step.method, step.path)
+		} else {
+			require.NoError(t, err, "%s %s should match the spec", step.method, step.path)
+		}
+		req.Body = io.NopCloser(strings.NewReader(step.body))
+
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		require.Equal(t, step.expectedStatus, rec.Code, "%s %s: %s", step.method, step.path, rec.Body.String())
+
+		if rec.Code < http.StatusBadRequest {
+			succeeded[route.Operation] = true
+		}
+	}
+
+	for path, item := range spec.Paths.Map() {
+		for method, operation := range item.Operations() {
+			require.True(t, succeeded[operation], "%s %s has no successful request in the contract steps", method, path)
+		}
+	}
+}
+
+// versionSteps exercise the person operations under prefix once the v1 steps have run, with
+// id the next person id and relationID the next relation id.
+func versionSteps(prefix string, id, relationID int, header http.Header) []contractStep {
+	step := func(method, path, body string, expectedStatus int) contractStep {
+		s := jsonStep(method, prefix+path, body, expectedStatus)
+		s.header = header
+		return s
+	}
+	person := "/persons/" + strconv.Itoa(id)
+
+	return []contractStep{
+		step(http.MethodPost, "/persons", `{"name": "Anna", "email": "anna`+strconv.Itoa(id)+`@example.com"}`, http.StatusCreated),
+		step(http.MethodPost, "/persons", `{"name": "Anna", "email": "anna`+strconv.Itoa(id)+`@example.com"}`, http.StatusConflict),
+		step(http.MethodPost, "/persons", `{"name": "Anna", "attributes": {"Region": "eu"}}`, http.StatusBadRequest),
+		step(http.MethodGet, "/persons", "", http.StatusOK),
+		step(http.MethodGet, person, "", http.StatusOK),
+		step(http.MethodGet, person+"?fields=name,address_parts", "", http.StatusOK),
+		step(http.MethodGet, "/persons?fields=name&limit=1", "", http.StatusOK),
+		step(http.MethodGet, "/persons/2", "", http.StatusMovedPermanently),
+		step(http.MethodGet, "/persons/100", "", http.StatusNotFound),
+		step(http.MethodPatch, person, `{"name": "Anna Ivanova"}`, http.StatusOK),
+		step(http.MethodPost, "/persons", `{"name": "Anna Ivanova"}`, http.StatusCreated),
+		step(http.MethodGet, person+"/duplicates", "", http.StatusOK),
+		step(http.MethodPost, person+":merge", `{"source_id": `+strconv.Itoa(id+1)+`}`, http.StatusOK),
+		step(http.MethodPost, person+":merge", `{"source_id": 100}`, http.StatusNotFound),
+		step(http.MethodPost, person+"/relations", `{"related_person_id": 1, "type": "sibling"}`, http.StatusCreated),
+		step(http.MethodGet, person+"/relations", "", http.StatusOK),
+		step(http.MethodGet, person+"/relatives", "", http.StatusOK),
+		step(http.MethodDelete, person+"/relations/"+strconv.Itoa(relationID), "", http.StatusNoContent),
+		step(http.MethodDelete, person+"/relations/"+strconv.Itoa(relationID), "", http.StatusNotFound),
+		step(http.MethodDelete, person, "", http.StatusNoContent),
+		step(http.MethodDelete, person, "", http.StatusNotFound),
+	}
+}
+
+func contractUpload(t *testing.T, kind, fileName string, content []byte) (string, string) {
+	body := &bytes.Buffer{}
+	w := multipart.NewWriter(body)
+	if kind != "" {
+		require.NoError(t, w.WriteField("kind", kind))
+	}
+	part, err := w.CreateFormFile("file", fileName)
+	require.NoError(t, err)
+	_, err = part.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return body.String(), w.FormDataContentType()
+}
+
+func Test_GetSpec(t *testing.T) {
+	spec, err := Load()
+	require.NoError(t, err)
+	e := newContractServer(t, spec)
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	served, err := openapi3.NewLoader().LoadFromData(rec.Body.Bytes())
+	require.NoError(t, err)
+	require.Equal(t, spec.Info.Title, served.Info.Title)
+	require.Equal(t, len(spec.Paths.Map()), len(served.Paths.Map()))
+
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/docs", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), `url: "/openapi.json"`)
+}
@@ -0,0 +1,54 @@
+package openapi
+
+import (
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+	"net/http"
+)
+
+// swaggerUIPage renders the spec with Swagger UI loaded from a CDN, so the assets do not have
+// to be shipped with the service.
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Persons service API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js" crossorigin></script>
+  <script>
+    window.ui = SwaggerUIBundle({url: "/openapi.json", dom_id: "#swagger-ui"});
+  </script>
+</body>
+</html>
+`
+
+type handler struct {
+	spec []byte
+}
+
+// NewHandler creates the handler serving spec. The spec is encoded once, it does not change
+// while the service runs.
+func NewHandler(spec *openapi3.T) (*handler, error) {
+	encoded, err := spec.MarshalJSON()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode openapi spec")
+	}
+	return &handler{spec: encoded}, nil
+}
+
+func (h *handler) Register(echo *echo.Echo) {
+	echo.GET("/openapi.json", h.GetSpec)
+	echo.GET("/docs", h.GetDocs)
+}
+
+func (h *handler) GetSpec(c echo.Context) error {
+	return c.Blob(http.StatusOK, echo.MIMEApplicationJSON, h.spec)
+}
+
+func (h *handler) GetDocs(c echo.Context) error {
+	return c.HTML(http.StatusOK, swaggerUIPage)
+}
@@ -0,0 +1,261 @@
+package openapi
+
+import (
+	"bytes"
+	"errors"
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/config"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// specValidator checks the requests and responses of the operations described in the spec.
+// Requests to paths the spec does not describe, e.g. health checks, pass through unchecked.
+type specValidator struct {
+	router            routers.Router
+	validateRequests  bool
+	validateResponses bool
+	// onResponseError is called with the mismatch of a response that has already been sent.
+	onResponseError func(c echo.Context, err error)
+}
+
+func newSpecValidator(spec *openapi3.T) (*specValidator, error) {
+	// The servers of the spec name the local address; operations are matched by path only, so
+	// the service validates requests under any host it is deployed to.
+	routed := *spec
+	routed.Servers = nil
+	router, err := gorillamux.NewRouter(&routed)
+	if err != nil {
+		return nil, err
+	}
+
+	return &specValidator{
+		router: router,
+		onResponseError: func(c echo.Context, err error) {
+			zerolog.Ctx(c.Request().Context()).Error().Err(err).Msg("response does not match openapi spec")
+		},
+	}, nil
+}
+
+// NewMiddleware validates requests and responses against spec as configured. Invalid requests
+// are rejected before they reach the handlers; invalid responses are logged, they have been
+// sent by then.
+func NewMiddleware(spec *openapi3.T, cfg *config.OpenAPI) (echo.MiddlewareFunc, error) {
+	v, err := newSpecValidator(spec)
+	if err != nil {
+		return nil, err
+	}
+	v.validateRequests = cfg.ValidateRequests
+	v.validateResponses = cfg.ValidateResponses
+
+	return v.middleware, nil
+}
+
+func (v *specValidator) middleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if !v.validateRequests && !v.validateResponses {
+			return next(c)
+		}
+
+		req := c.Request()
+		route, pathParams, err := v.router.FindRoute(req)
+		if err != nil {
+			return next(c)
+		}
+
+		input := &openapi3filter.RequestValidationInput{
+			Request:    req,
+			PathParams: pathParams,
+			Route:      route,
+			Options: &openapi3filter.Options{
+				ExcludeRequestBody:  !validatesBody(req.Header.Get(echo.HeaderContentType)),
+				MultiError:          true,
+				AuthenticationFunc:  openapi3filter.NoopAuthenticationFunc,
+				SkipSettingDefaults: true,
+			},
+		}
+
+		if v.validateRequests {
+			if err = openapi3filter.ValidateRequest(req.Context(), input); err != nil {
+				zerolog.Ctx(req.Context()).Warn().Err(err).Msg("request does not match openapi spec")
+				status, resp := requestErrorResponse(err)
+				if answersProblems(route.Operation) {
+					resp = problem(status, resp)
+					c.Response().Header().Set(echo.HeaderContentType, mimeApplicationProblemJSON)
+				}
+				return c.JSON(status, resp)
+			}
+		}
+
+		if !v.validateResponses {
+			return next(c)
+		}
+
+		capture := &responseCapture{ResponseWriter: c.Response().Writer}
+		c.Response().Writer = capture
+		err = next(c)
+		c.Response().Writer = capture.ResponseWriter
+		if err != nil {
+			return err
+		}
+
+		// Server errors are not part of the contract.
+		if capture.status >= http.StatusInternalServerError {
+			return nil
+		}
+
+		respInput := &openapi3filter.ResponseValidationInput{
+			RequestValidationInput: input,
+			Status:                 capture.status,
+			Header:                 c.Response().Header(),
+			Options: &openapi3filter.Options{
+				ExcludeResponseBody:   !capture.capturing,
+				IncludeResponseStatus: true,
+				MultiError:            true,
+			},
+		}
+		respInput.SetBodyBytes(capture.body.Bytes())
+		if err = openapi3filter.ValidateResponse(req.Context(), respInput); err != nil {
+			v.onResponseError(c, err)
+		}
+		return nil
+	}
+}
+
+// requestErrorResponse answers an invalid request body like the handlers answer a validation
+// error, with a message for each invalid field. Other mismatches are described as a whole.
+func requestErrorResponse(err error) (int, echo.Map) {
+	fields := make(map[string]string)
+	var messages []string
+	for _, e := range unpackErrors(err) {
+		var requestErr *openapi3filter.RequestError
+		if !errors.As(e, &requestErr) {
+			messages = append(messages, e.Error())
+			continue
+		}
+
+		schemaErrs := unpackErrors(requestErr.Err)
+		if requestErr.RequestBody == nil || len(schemaErrs) == 0 {
+			messages = append(messages, requestErr.Error())
+			continue
+		}
+		for _, schemaErr := range schemaErrs {
+			var serr *openapi3.SchemaError
+			if !errors.As(schemaErr, &serr) {
+				messages = append(messages, requestErr.Error())
+				continue
+			}
+			field := strings.Join(serr.JSONPointer(), ".")
+			if field == "" {
+				messages = append(messages, "body "+serr.Reason)
+				continue
+			}
+			fields[field] = serr.Reason
+		}
+	}
+
+	if len(messages) > 0 {
+		return http.StatusBadRequest, echo.Map{
+			"errors": strings.Join(messages, "; "),
+		}
+	}
+	return http.StatusBadRequest, echo.Map{
+		"errors": "validation error",
+		"fields": fields,
+	}
+}
+
+// mimeApplicationProblemJSON is the media type of RFC 9457 problem details.
+const mimeApplicationProblemJSON = "application/problem+json"
+
+// answersProblems tells whether operation answers invalid requests with problem details, as
+// the operations of v2 of the persons API do.
+func answersProblems(operation *openapi3.Operation) bool {
+	resp := operation.Responses.Status(http.StatusBadRequest)
+	return resp != nil && resp.Value != nil && resp.Value.Content.Get(mimeApplicationProblemJSON) != nil
+}
+
+// problem converts an error response to problem details, keeping the messages of the invalid
+// fields.
+func problem(status int, resp echo.Map) echo.Map {
+	res := echo.Map{
+		"type":   "about:blank",
+		"title":  http.StatusText(status),
+		"status": status,
+	}
+	res["detail"] = resp["errors"]
+	if fields, ok := resp["fields"].(map[string]string); ok {
+		res["errors"] = fields
+	}
+	return res
+}
+
+// unpackErrors flattens the multi errors the validation collects. Only multi errors are
+// unpacked, the request errors wrapping them are kept to tell what was invalid.
+func unpackErrors(err error) []error {
+	multi, ok := err.(openapi3.MultiError)
+	if !ok {
+		if err == nil {
+			return nil
+		}
+		return []error{err}
+	}
+
+	var res []error
+	for _, e := range multi {
+		res = append(res, unpackErrors(e)...)
+	}
+	return res
+}
+
+// validatesBody tells whether a request body of contentType is checked against the spec.
+// Uploads are streamed by the handlers instead of being buffered here, and the handlers check
+// the other representations than JSON, e.g. XML, once they have decoded them.
+func validatesBody(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err != nil || mediaType == echo.MIMEApplicationJSON
+}
+
+// responseCapture passes the response through and keeps a copy of JSON bodies for the
+// validation. Other bodies, e.g. attachment downloads, may be large and are not kept.
+type responseCapture struct {
+	http.ResponseWriter
+	status    int
+	capturing bool
+	body      bytes.Buffer
+}
+
+func (w *responseCapture) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+		mediaType, _, _ := mime.ParseMediaType(w.Header().Get(echo.HeaderContentType))
+		w.capturing = mediaType == echo.MIMEApplicationJSON
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseCapture) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.capturing {
+		w.body.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseCapture) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *responseCapture) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,99 @@
+package openapi
+
+import (
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/config"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_Middleware(t *testing.T) {
+	tests := []struct {
+		name             string
+		method           string
+		path             string
+		body             string
+		cfg              config.OpenAPI
+		expectedHTTPCode int
+		expectedBody     string
+	}{
+		{
+			name:             "http-code 400: invalid body fields",
+			method:           http.MethodPost,
+			path:             "/api/v1/persons",
+			body:             `{"name": "test", "age": 200}`,
+			cfg:              config.OpenAPI{ValidateRequests: true},
+			expectedHTTPCode: http.StatusBadRequest,
+			expectedBody:     `{"errors": "validation error", "fields": {"age": "number must be at most 150"}}`,
+		},
+		{
+			name:             "http-code 400: invalid body fields in v2",
+			method:           http.MethodPost,
+			path:             "/api/v2/persons",
+			body:             `{"name": "test", "age": 200}`,
+			cfg:              config.OpenAPI{ValidateRequests: true},
+			expectedHTTPCode: http.StatusBadRequest,
+			expectedBody: `{"type": "about:blank", "title": "Bad Request", "status": 400, "detail": "validation error",
+				"errors": {"age": "number must be at most 150"}}`,
+		},
+		{
+			name:             "http-code 400: invalid path parameter",
+			method:           http.MethodGet,
+			path:             "/api/v1/persons/test",
+			cfg:              config.OpenAPI{ValidateRequests: true},
+			expectedHTTPCode: http.StatusBadRequest,
+		},
+		{
+			name:             "http-code 200: validation disabled",
+			method:           http.MethodGet,
+			path:             "/api/v1/persons/test",
+			expectedHTTPCode: http.StatusOK,
+		},
+		{
+			name:             "http-code 200: valid request",
+			method:           http.MethodPost,
+			path:             "/api/v1/persons",
+			body:             `{"name": "test", "age": 20}`,
+			cfg:              config.OpenAPI{ValidateRequests: true},
+			expectedHTTPCode: http.StatusOK,
+		},
+		{
+			name:             "http-code 200: path not in spec",
+			method:           http.MethodGet,
+			path:             "/manage/health",
+			cfg:              config.OpenAPI{ValidateRequests: true},
+			expectedHTTPCode: http.StatusOK,
+		},
+	}
+
+	spec, err := Load()
+	require.NoError(t, err)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mw, err := NewMiddleware(spec, &tt.cfg)
+			require.NoError(t, err)
+
+			e := echo.New()
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.body != "" {
+				req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			}
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			err = mw(func(c echo.Context) error {
+				return c.NoContent(http.StatusOK)
+			})(c)
+
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedHTTPCode, rec.Code)
+			if tt.expectedBody != "" {
+				require.JSONEq(t, tt.expectedBody, rec.Body.String())
+			}
+		})
+	}
+}
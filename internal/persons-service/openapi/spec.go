@@ -0,0 +1,21 @@
+package openapi
+
+import (
+	"context"
+	api "github.com/Erlendum/rsoi-lab-01/api/persons-service"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/pkg/errors"
+)
+
+// Load parses the OpenAPI spec embedded in the binary and checks that it is well-formed.
+func Load() (*openapi3.T, error) {
+	loader := openapi3.NewLoader()
+	spec, err := loader.LoadFromData(api.Spec)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse openapi spec")
+	}
+	if err = spec.Validate(context.Background()); err != nil {
+		return nil, errors.Wrap(err, "invalid openapi spec")
+	}
+	return spec, nil
+}
@@ -0,0 +1,455 @@
+package organization
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/person"
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/pgutil"
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+	"time"
+)
+
+var (
+	organizationsBucket = []byte("organizations")
+	employmentsBucket   = []byte("employments")
+)
+
+// boltRepository stores organizations in the bbolt file of the person storage, one JSON
+// document per key.
+type boltRepository struct {
+	db *bolt.DB
+}
+
+// NewBoltRepository creates the organization buckets in db. The caller keeps owning db.
+func NewBoltRepository(db *bolt.DB) (*boltRepository, error) {
+	err := db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{organizationsBucket, employmentsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create organization buckets")
+	}
+
+	return &boltRepository{db: db}, nil
+}
+
+func boltKey(id int) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(id))
+	return key
+}
+
+func boltID(key []byte) *int {
+	id := int(binary.BigEndian.Uint64(key))
+	return &id
+}
+
+func boltOrganizations(tx *bolt.Tx) ([]Organization, error) {
+	res := make([]Organization, 0)
+	err := tx.Bucket(organizationsBucket).ForEach(func(key, value []byte) error {
+		o := Organization{}
+		if err := json.Unmarshal(value, &o); err != nil {
+			return errors.Wrap(err, "failed to decode organization")
+		}
+		o.ID = boltID(key)
+		res = append(res, o)
+		return nil
+	})
+	return res, err
+}
+
+func boltEmployments(tx *bolt.Tx, match func(Employment) bool) ([]Employment, error) {
+	res := make([]Employment, 0)
+	err := tx.Bucket(employmentsBucket).ForEach(func(key, value []byte) error {
+		e := Employment{}
+		if err := json.Unmarshal(value, &e); err != nil {
+			return errors.Wrap(err, "failed to decode employment")
+		}
+		e.ID = boltID(key)
+		if match(e) {
+			res = append(res, e)
+		}
+		return nil
+	})
+	return res, err
+}
+
+// boltPut stores v under id. The ID field of v must be nil, the key holds it.
+func boltPut(b *bolt.Bucket, id int, v interface{}) error {
+	value, err := json.Marshal(v)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode value")
+	}
+	return b.Put(boltKey(id), value)
+}
+
+// boltUpdateWork sets the work of the persons to their current employment in tx and returns
+// those whose work changed. The persons share the file, so they change in the same
+// transaction as the employments.
+func boltUpdateWork(tx *bolt.Tx, personIDs []int) ([]int, error) {
+	now := time.Now()
+	changed := make([]int, 0)
+	for _, personID := range personIDs {
+		history, err := boltEmployments(tx, func(e Employment) bool { return e.PersonID == personID })
+		if err != nil {
+			return nil, err
+		}
+
+		var decodeErr error
+		work := currentWork(history, func(organizationID int) *string {
+			o := Organization{}
+			if value := tx.Bucket(organizationsBucket).Get(boltKey(organizationID)); value != nil {
+				decodeErr = json.Unmarshal(value, &o)
+			}
+			return o.Name
+		}, now)
+		if decodeErr != nil {
+			return nil, errors.Wrap(decodeErr, "failed to decode organization")
+		}
+
+		ok, err := person.BoltSetWork(tx, personID, work)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to set person work")
+		}
+		if ok {
+			changed = append(changed, personID)
+		}
+	}
+	return changed, nil
+}
+
+// boltNameTaken reports whether an organization other than the one with id has name.
+func boltNameTaken(tx *bolt.Tx, name string, id int) (bool, error) {
+	organizations, err := boltOrganizations(tx)
+	if err != nil {
+		return false, err
+	}
+	for _, o := range organizations {
+		if *o.Name == name && *o.ID != id {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *boltRepository) CreateOrganization(ctx context.Context, organization Organization) (int, error) {
+	if err := pgutil.CheckContext(ctx); err != nil {
+		return 0, err
+	}
+	if organization.Name == nil {
+		return 0, errors.New("name is required")
+	}
+
+	var id int
+	err := r.db.Update(func(tx *bolt.Tx) error {
+		taken, err := boltNameTaken(tx, *organization.Name, 0)
+		if err != nil {
+			return err
+		}
+		if taken {
+			return ErrExists
+		}
+
+		b := tx.Bucket(organizationsBucket)
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		id = int(seq)
+		now := time.Now().UTC()
+		organization.ID = nil
+		organization.CreatedAt, organization.UpdatedAt = &now, &now
+		return boltPut(b, id, organization)
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to create organization")
+	}
+
+	return id, nil
+}
+
+func (r *boltRepository) UpdateOrganization(ctx context.Context, id int, organization *Organization) ([]int, error) {
+	if err := pgutil.CheckContext(ctx); err != nil {
+		return nil, err
+	}
+	if organization.Name == nil {
+		return nil, nil
+	}
+
+	var updated Organization
+	var changed []int
+	err := r.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(organizationsBucket)
+		value := b.Get(boltKey(id))
+		if value == nil {
+			return ErrNotFound
+		}
+		taken, err := boltNameTaken(tx, *organization.Name, id)
+		if err != nil {
+			return err
+		}
+		if taken {
+			return ErrExists
+		}
+
+		if err = json.Unmarshal(value, &updated); err != nil {
+			return errors.Wrap(err, "failed to decode organization")
+		}
+		now := time.Now().UTC()
+		updated.Name = clonePointer(organization.Name)
+		updated.UpdatedAt = &now
+		if err = boltPut(b, id, updated); err != nil {
+			return err
+		}
+		updated.ID = &id
+
+		employments, err := boltEmployments(tx, func(e Employment) bool { return e.OrganizationID == id })
+		if err != nil {
+			return err
+		}
+		changed, err = boltUpdateWork(tx, currentEmployees(employments, now))
+		return err
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to update organization")
+	}
+
+	*organization = updated
+	return changed, nil
+}
+
+func (r *boltRepository) DeleteOrganization(ctx context.Context, id int) (bool, []int, error) {
+	if err := pgutil.CheckContext(ctx); err != nil {
+		return false, nil, err
+	}
+
+	isDeleted := false
+	var changed []int
+	err := r.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(organizationsBucket)
+		key := boltKey(id)
+		if b.Get(key) == nil {
+			return nil
+		}
+		isDeleted = true
+		if err := b.Delete(key); err != nil {
+			return err
+		}
+
+		employments, err := boltEmployments(tx, func(e Employment) bool { return e.OrganizationID == id })
+		if err != nil {
+			return err
+		}
+		for _, e := range employments {
+			if err = tx.Bucket(employmentsBucket).Delete(boltKey(*e.ID)); err != nil {
+				return err
+			}
+		}
+		changed, err = boltUpdateWork(tx, currentEmployees(employments, time.Now()))
+		return err
+	})
+	if err != nil {
+		return false, nil, errors.Wrap(err, "failed to delete organization")
+	}
+
+	return isDeleted, changed, nil
+}
+
+func (r *boltRepository) GetOrganizations(ctx context.Context) ([]Organization, error) {
+	if err := pgutil.CheckContext(ctx); err != nil {
+		return []Organization{}, err
+	}
+
+	var res []Organization
+	err := r.db.View(func(tx *bolt.Tx) error {
+		var err error
+		res, err = boltOrganizations(tx)
+		return err
+	})
+	if err != nil {
+		return []Organization{}, errors.Wrap(err, "failed to get organizations")
+	}
+
+	return res, nil
+}
+
+func (r *boltRepository) GetOrganization(ctx context.Context, id int) (Organization, error) {
+	if err := pgutil.CheckContext(ctx); err != nil {
+		return Organization{}, err
+	}
+
+	res := Organization{}
+	err := r.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(organizationsBucket).Get(boltKey(id))
+		if value == nil {
+			return nil
+		}
+		if err := json.Unmarshal(value, &res); err != nil {
+			return errors.Wrap(err, "failed to decode organization")
+		}
+		res.ID = &id
+		return nil
+	})
+	if err != nil {
+		return Organization{}, errors.Wrap(err, "failed to get organization")
+	}
+
+	return res, nil
+}
+
+func (r *boltRepository) CreateEmployment(ctx context.Context, employment Employment) (int, error) {
+	if err := pgutil.CheckContext(ctx); err != nil {
+		return 0, err
+	}
+
+	var id int
+	err := r.db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket(organizationsBucket).Get(boltKey(employment.OrganizationID)) == nil {
+			return ErrNotFound
+		}
+
+		b := tx.Bucket(employmentsBucket)
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		id = int(seq)
+		now := time.Now().UTC()
+		employment.ID, employment.CreatedAt = nil, &now
+		if err = boltPut(b, id, employment); err != nil {
+			return err
+		}
+		if employment.IsCurrent(now) {
+			_, err = boltUpdateWork(tx, []int{employment.PersonID})
+		}
+		return err
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to create employment")
+	}
+
+	return id, nil
+}
+
+func (r *boltRepository) DeleteEmployment(ctx context.Context, personID, employmentID int) (bool, error) {
+	if err := pgutil.CheckContext(ctx); err != nil {
+		return false, err
+	}
+
+	isDeleted := false
+	err := r.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(employmentsBucket)
+		key := boltKey(employmentID)
+		value := b.Get(key)
+		if value == nil {
+			return nil
+		}
+
+		e := Employment{}
+		if err := json.Unmarshal(value, &e); err != nil {
+			return errors.Wrap(err, "failed to decode employment")
+		}
+		if e.PersonID != personID {
+			return nil
+		}
+		isDeleted = true
+		if err := b.Delete(key); err != nil {
+			return err
+		}
+		if e.IsCurrent(time.Now()) {
+			_, err := boltUpdateWork(tx, []int{personID})
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return false, errors.Wrap(err, "failed to delete employment")
+	}
+
+	return isDeleted, nil
+}
+
+// MovePersonRows gives the employments of the person fromID to the person toID.
+func (r *boltRepository) MovePersonRows(ctx context.Context, fromID, toID int) error {
+	if err := pgutil.CheckContext(ctx); err != nil {
+		return err
+	}
+
+	err := r.db.Update(func(tx *bolt.Tx) error {
+		employments, err := boltEmployments(tx, func(e Employment) bool { return e.PersonID == fromID })
+		if err != nil {
+			return err
+		}
+		for _, e := range employments {
+			id := *e.ID
+			e.ID, e.PersonID = nil, toID
+			if err = boltPut(tx.Bucket(employmentsBucket), id, e); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to move employments")
+	}
+
+	return nil
+}
+
+// DeletePersonRows deletes the employments of the person.
+func (r *boltRepository) DeletePersonRows(ctx context.Context, personID int) error {
+	if err := pgutil.CheckContext(ctx); err != nil {
+		return err
+	}
+
+	err := r.db.Update(func(tx *bolt.Tx) error {
+		employments, err := boltEmployments(tx, func(e Employment) bool { return e.PersonID == personID })
+		if err != nil {
+			return err
+		}
+		for _, e := range employments {
+			if err = tx.Bucket(employmentsBucket).Delete(boltKey(*e.ID)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to delete employments")
+	}
+
+	return nil
+}
+
+func (r *boltRepository) GetPersonEmployments(ctx context.Context, personID int) ([]Employment, error) {
+	return r.getEmployments(ctx, func(e Employment) bool { return e.PersonID == personID }, sortHistory)
+}
+
+func (r *boltRepository) GetOrganizationEmployments(ctx context.Context, organizationID int) ([]Employment, error) {
+	return r.getEmployments(ctx, func(e Employment) bool { return e.OrganizationID == organizationID }, sortByID)
+}
+
+func (r *boltRepository) getEmployments(ctx context.Context, match func(Employment) bool, order func([]Employment)) ([]Employment, error) {
+	if err := pgutil.CheckContext(ctx); err != nil {
+		return []Employment{}, err
+	}
+
+	var res []Employment
+	err := r.db.View(func(tx *bolt.Tx) error {
+		var err error
+		res, err = boltEmployments(tx, match)
+		return err
+	})
+	if err != nil {
+		return []Employment{}, errors.Wrap(err, "failed to get employments")
+	}
+	order(res)
+
+	return res, nil
+}
@@ -0,0 +1,16 @@
+package organization
+
+import (
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrNotFound is returned by storages when the organization to update or employ at does
+	// not exist.
+	ErrNotFound = errors.New("organization not found")
+	// ErrExists is returned by storages when another organization already has the name.
+	ErrExists = errors.New("organization already exists")
+	// ErrPersonNotFound is returned by storages that know the persons when the employed person
+	// does not exist.
+	ErrPersonNotFound = errors.New("person not found")
+)
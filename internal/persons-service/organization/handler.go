@@ -0,0 +1,554 @@
+package organization
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/person"
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/tracing"
+	"github.com/Erlendum/rsoi-lab-01/pkg/validation"
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+//go:generate mockgen -source=handler.go  -destination=handler_mocks.go -self_package=github.com/Erlendum/rsoi-lab-01/internal/persons-service/organization -package=organization
+
+type storage interface {
+	CreateOrganization(ctx context.Context, organization Organization) (int, error)
+	UpdateOrganization(ctx context.Context, id int, organization *Organization) ([]int, error)
+	DeleteOrganization(ctx context.Context, id int) (bool, []int, error)
+	GetOrganizations(ctx context.Context) ([]Organization, error)
+	GetOrganization(ctx context.Context, id int) (Organization, error)
+	CreateEmployment(ctx context.Context, employment Employment) (int, error)
+	DeleteEmployment(ctx context.Context, personID, employmentID int) (bool, error)
+	GetPersonEmployments(ctx context.Context, personID int) ([]Employment, error)
+	GetOrganizationEmployments(ctx context.Context, organizationID int) ([]Employment, error)
+}
+
+// personStorage is the part of the person storage the handler reads persons from and reports
+// the persons whose work field the storage changed to.
+type personStorage interface {
+	GetPerson(ctx context.Context, id int, fields ...string) (person.Person, error)
+	GetPersonsByIDs(ctx context.Context, ids []int) ([]person.Person, error)
+	PersonsChanged(ctx context.Context, ids []int)
+}
+
+type organizationRequest struct {
+	Name *string `json:"name" validate:"required,max=255"`
+}
+
+type organizationResponse struct {
+	ID        int        `json:"id"`
+	Name      string     `json:"name"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+func newOrganizationResponse(o Organization) organizationResponse {
+	return organizationResponse{
+		ID:        valueOrZero(o.ID),
+		Name:      valueOrZero(o.Name),
+		CreatedAt: o.CreatedAt,
+		UpdatedAt: o.UpdatedAt,
+	}
+}
+
+type employmentRequest struct {
+	OrganizationID *int    `json:"organization_id" validate:"required"`
+	Role           *string `json:"role" validate:"omitempty,max=255"`
+	StartDate      *string `json:"start_date" validate:"omitempty,datetime=2006-01-02"`
+	EndDate        *string `json:"end_date" validate:"omitempty,datetime=2006-01-02"`
+}
+
+// toEmployment converts a validated request, so the dates are known to be well-formed.
+func (r *employmentRequest) toEmployment(personID int) Employment {
+	e := Employment{
+		PersonID:       personID,
+		OrganizationID: *r.OrganizationID,
+		Role:           r.Role,
+	}
+	if r.StartDate != nil {
+		startDate, _ := time.Parse(validation.DateLayout, *r.StartDate)
+		e.StartDate = &startDate
+	}
+	if r.EndDate != nil {
+		endDate, _ := time.Parse(validation.DateLayout, *r.EndDate)
+		e.EndDate = &endDate
+	}
+	return e
+}
+
+type employmentResponse struct {
+	ID             int     `json:"id"`
+	PersonID       int     `json:"person_id"`
+	OrganizationID int     `json:"organization_id"`
+	Role           *string `json:"role,omitempty"`
+	StartDate      *string `json:"start_date,omitempty"`
+	EndDate        *string `json:"end_date,omitempty"`
+	Current        bool    `json:"current"`
+}
+
+func newEmploymentResponse(e Employment, now time.Time) employmentResponse {
+	return employmentResponse{
+		ID:             valueOrZero(e.ID),
+		PersonID:       e.PersonID,
+		OrganizationID: e.OrganizationID,
+		Role:           e.Role,
+		StartDate:      formatDate(e.StartDate),
+		EndDate:        formatDate(e.EndDate),
+		Current:        e.IsCurrent(now),
+	}
+}
+
+func formatDate(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	date := t.Format(validation.DateLayout)
+	return &date
+}
+
+// validationErrorResponse lists the messages of a validation error in the language asked for
+// by the client.
+func validationErrorResponse(c echo.Context, err error) echo.Map {
+	resp := echo.Map{"errors": "validation error"}
+
+	var verr *validation.Error
+	if errors.As(err, &verr) {
+		resp["fields"] = verr.Translate(c.Request().Header.Get("Accept-Language"))
+	}
+	return resp
+}
+
+type handler struct {
+	storage storage
+	persons personStorage
+}
+
+func NewHandler(storage storage, persons personStorage) *handler {
+	return &handler{
+		storage: storage,
+		persons: persons,
+	}
+}
+
+func (h *handler) Register(echo *echo.Echo) {
+	api := echo.Group("/api/v1")
+
+	api.GET("/organizations/:id", tracing.Handler("organization.handler.GetOrganization", h.GetOrganization))
+	api.GET("/organizations", tracing.Handler("organization.handler.GetOrganizations", h.GetOrganizations))
+	api.POST("/organizations", tracing.Handler("organization.handler.CreateOrganization", h.CreateOrganization))
+	api.PATCH("/organizations/:id", tracing.Handler("organization.handler.UpdateOrganization", h.UpdateOrganization))
+	api.DELETE("/organizations/:id", tracing.Handler("organization.handler.DeleteOrganization", h.DeleteOrganization))
+	api.GET("/organizations/:id/persons", tracing.Handler("organization.handler.GetOrganizationPersons", h.GetOrganizationPersons))
+	api.GET("/persons/:id/employments", tracing.Handler("organization.handler.GetEmployments", h.GetEmployments))
+	api.POST("/persons/:id/employments", tracing.Handler("organization.handler.CreateEmployment", h.CreateEmployment))
+	api.DELETE("/persons/:id/employments/:employment_id", tracing.Handler("organization.handler.DeleteEmployment", h.DeleteEmployment))
+}
+
+func (h *handler) CreateOrganization(c echo.Context) error {
+	logger := zerolog.Ctx(c.Request().Context())
+
+	req := &organizationRequest{}
+	if err := json.NewDecoder(c.Request().Body).Decode(req); err != nil {
+		logger.Warn().Err(err).Msg("unmarshalling error")
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"errors": "unmarshalling error",
+		})
+	}
+
+	if err := c.Validate(req); err != nil {
+		logger.Warn().Err(err).Msg("validation error")
+		return c.JSON(http.StatusBadRequest, validationErrorResponse(c, err))
+	}
+
+	id, err := h.storage.CreateOrganization(c.Request().Context(), Organization{Name: req.Name})
+	if errors.Is(err, ErrExists) {
+		logger.Info().Str("name", *req.Name).Msg("organization already exists")
+		return c.JSON(http.StatusConflict, echo.Map{
+			"errors": "organization already exists",
+		})
+	}
+	if err != nil {
+		logger.Error().Err(err).Msg("creating organization error")
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"errors": "creating organization error",
+		})
+	}
+
+	c.Response().Header().Set("Location", "/api/v1/organizations/"+strconv.Itoa(id))
+
+	return c.NoContent(http.StatusCreated)
+}
+
+// UpdateOrganization renames an organization. The work field of the persons currently employed
+// there follows the new name.
+func (h *handler) UpdateOrganization(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		zerolog.Ctx(c.Request().Context()).Warn().Str("id", c.Param("id")).Msg("wrong organization id")
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"errors": "wrong id",
+		})
+	}
+
+	logger := zerolog.Ctx(c.Request().Context()).With().Int("organization_id", id).Logger()
+
+	req := &organizationRequest{}
+	if err = json.NewDecoder(c.Request().Body).Decode(req); err != nil {
+		logger.Warn().Err(err).Msg("unmarshalling error")
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"errors": "unmarshalling error",
+		})
+	}
+
+	if err = c.Validate(req); err != nil {
+		logger.Warn().Err(err).Msg("validation error")
+		return c.JSON(http.StatusBadRequest, validationErrorResponse(c, err))
+	}
+
+	o := Organization{Name: req.Name}
+	changed, err := h.storage.UpdateOrganization(c.Request().Context(), id, &o)
+	if errors.Is(err, ErrNotFound) {
+		logger.Info().Msg("organization not found")
+		return c.JSON(http.StatusNotFound, echo.Map{
+			"errors": "organization not found",
+		})
+	}
+	if errors.Is(err, ErrExists) {
+		logger.Info().Str("name", *req.Name).Msg("organization already exists")
+		return c.JSON(http.StatusConflict, echo.Map{
+			"errors": "organization already exists",
+		})
+	}
+	if err != nil {
+		logger.Error().Err(err).Msg("updating organization error")
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"errors": "updating organization error",
+		})
+	}
+
+	h.persons.PersonsChanged(c.Request().Context(), changed)
+
+	return c.JSON(http.StatusOK, newOrganizationResponse(o))
+}
+
+// DeleteOrganization deletes an organization with its employments. The storage clears the work
+// field of the persons currently employed there.
+func (h *handler) DeleteOrganization(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		zerolog.Ctx(c.Request().Context()).Warn().Str("id", c.Param("id")).Msg("wrong organization id")
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"errors": "wrong id",
+		})
+	}
+
+	logger := zerolog.Ctx(c.Request().Context()).With().Int("organization_id", id).Logger()
+
+	isDeleted, changed, err := h.storage.DeleteOrganization(c.Request().Context(), id)
+	if err != nil {
+		logger.Error().Err(err).Msg("deleting organization error")
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"errors": "deleting organization error",
+		})
+	}
+	h.persons.PersonsChanged(c.Request().Context(), changed)
+
+	if !isDeleted {
+		logger.Info().Msg("organization not found")
+		return c.JSON(http.StatusNotFound, echo.Map{
+			"errors": "organization not found",
+		})
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *handler) GetOrganization(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		zerolog.Ctx(c.Request().Context()).Warn().Str("id", c.Param("id")).Msg("wrong organization id")
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"errors": "wrong id",
+		})
+	}
+
+	o, found, err := h.organizationExists(c, id)
+	if err != nil || !found {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, newOrganizationResponse(o))
+}
+
+func (h *handler) GetOrganizations(c echo.Context) error {
+	logger := zerolog.Ctx(c.Request().Context())
+
+	organizations, err := h.storage.GetOrganizations(c.Request().Context())
+	if err != nil {
+		logger.Error().Err(err).Msg("getting organizations error")
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"errors": "getting organizations error",
+		})
+	}
+
+	resp := make([]organizationResponse, len(organizations), len(organizations))
+	for i, o := range organizations {
+		resp[i] = newOrganizationResponse(o)
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// GetOrganizationPersons lists the persons employed at an organization with their role and
+// dates, only the current employments if the current query parameter is true.
+func (h *handler) GetOrganizationPersons(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		zerolog.Ctx(c.Request().Context()).Warn().Str("id", c.Param("id")).Msg("wrong organization id")
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"errors": "wrong id",
+		})
+	}
+
+	logger := zerolog.Ctx(c.Request().Context()).With().Int("organization_id", id).Logger()
+
+	currentOnly := false
+	if param := c.QueryParam("current"); param != "" {
+		currentOnly, err = strconv.ParseBool(param)
+		if err != nil {
+			logger.Warn().Str("current", param).Msg("wrong current flag")
+			return c.JSON(http.StatusBadRequest, echo.Map{
+				"errors": "current must be a boolean",
+			})
+		}
+	}
+
+	if _, found, err := h.organizationExists(c, id); err != nil || !found {
+		return err
+	}
+
+	employments, err := h.storage.GetOrganizationEmployments(c.Request().Context(), id)
+	if err != nil {
+		logger.Error().Err(err).Msg("getting employments error")
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"errors": "getting organization persons error",
+		})
+	}
+
+	type organizationPersonResponse struct {
+		PersonID   int                `json:"person_id"`
+		Name       string             `json:"name"`
+		Employment employmentResponse `json:"employment"`
+	}
+
+	now := time.Now()
+	if currentOnly {
+		employments = slices.DeleteFunc(employments, func(e Employment) bool { return !e.IsCurrent(now) })
+	}
+
+	ids := make([]int, len(employments))
+	for i, e := range employments {
+		ids[i] = e.PersonID
+	}
+	persons, err := h.persons.GetPersonsByIDs(c.Request().Context(), ids)
+	if err != nil {
+		logger.Error().Err(err).Msg("getting persons error")
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"errors": "getting organization persons error",
+		})
+	}
+	byID := make(map[int]person.Person, len(persons))
+	for _, p := range persons {
+		byID[*p.ID] = p
+	}
+
+	resp := make([]organizationPersonResponse, 0, len(employments))
+	for _, e := range employments {
+		p, ok := byID[e.PersonID]
+		// Storages without foreign keys can keep the employments of a deleted person.
+		if !ok {
+			continue
+		}
+
+		resp = append(resp, organizationPersonResponse{
+			PersonID:   *p.ID,
+			Name:       valueOrZero(p.Name),
+			Employment: newEmploymentResponse(e, now),
+		})
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// CreateEmployment adds an employment to the history of a person. A current employment also
+// sets the work field of the person to the organization name.
+func (h *handler) CreateEmployment(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		zerolog.Ctx(c.Request().Context()).Warn().Str("id", c.Param("id")).Msg("wrong person id")
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"errors": "wrong id",
+		})
+	}
+
+	logger := zerolog.Ctx(c.Request().Context()).With().Int("person_id", id).Logger()
+
+	req := &employmentRequest{}
+	if err = json.NewDecoder(c.Request().Body).Decode(req); err != nil {
+		logger.Warn().Err(err).Msg("unmarshalling error")
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"errors": "unmarshalling error",
+		})
+	}
+
+	if err = c.Validate(req); err != nil {
+		logger.Warn().Err(err).Msg("validation error")
+		return c.JSON(http.StatusBadRequest, validationErrorResponse(c, err))
+	}
+
+	e := req.toEmployment(id)
+	if e.StartDate != nil && e.EndDate != nil && e.EndDate.Before(*e.StartDate) {
+		logger.Warn().Msg("employment ends before it starts")
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"errors": "end_date must not be before start_date",
+		})
+	}
+
+	if found, err := person.Exists(c, h.persons, id); err != nil || !found {
+		return err
+	}
+
+	if _, found, err := h.organizationExists(c, e.OrganizationID); err != nil || !found {
+		return err
+	}
+
+	employmentID, err := h.storage.CreateEmployment(c.Request().Context(), e)
+	if errors.Is(err, ErrNotFound) {
+		logger.Info().Int("organization_id", e.OrganizationID).Msg("organization not found")
+		return c.JSON(http.StatusNotFound, echo.Map{
+			"errors": "organization not found",
+		})
+	}
+	if errors.Is(err, ErrPersonNotFound) {
+		logger.Info().Msg("person not found")
+		return c.JSON(http.StatusNotFound, echo.Map{
+			"errors": "person not found",
+		})
+	}
+	if err != nil {
+		logger.Error().Err(err).Msg("creating employment error")
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"errors": "creating employment error",
+		})
+	}
+
+	if e.IsCurrent(time.Now()) {
+		h.persons.PersonsChanged(c.Request().Context(), []int{id})
+	}
+
+	c.Response().Header().Set("Location", "/api/v1/persons/"+strconv.Itoa(id)+"/employments/"+strconv.Itoa(employmentID))
+
+	return c.NoContent(http.StatusCreated)
+}
+
+// GetEmployments lists the employment history of a person, latest first.
+func (h *handler) GetEmployments(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		zerolog.Ctx(c.Request().Context()).Warn().Str("id", c.Param("id")).Msg("wrong person id")
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"errors": "wrong id",
+		})
+	}
+
+	logger := zerolog.Ctx(c.Request().Context()).With().Int("person_id", id).Logger()
+
+	if found, err := person.Exists(c, h.persons, id); err != nil || !found {
+		return err
+	}
+
+	employments, err := h.storage.GetPersonEmployments(c.Request().Context(), id)
+	if err != nil {
+		logger.Error().Err(err).Msg("getting employments error")
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"errors": "getting employments error",
+		})
+	}
+
+	now := time.Now()
+	resp := make([]employmentResponse, len(employments), len(employments))
+	for i, e := range employments {
+		resp[i] = newEmploymentResponse(e, now)
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// DeleteEmployment removes an employment from the history of a person. The storage recomputes
+// the work field of the person if the employment was current.
+func (h *handler) DeleteEmployment(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		zerolog.Ctx(c.Request().Context()).Warn().Str("id", c.Param("id")).Msg("wrong person id")
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"errors": "wrong id",
+		})
+	}
+
+	employmentID, err := strconv.Atoi(c.Param("employment_id"))
+	if err != nil {
+		zerolog.Ctx(c.Request().Context()).Warn().Str("employment_id", c.Param("employment_id")).Msg("wrong employment id")
+		return c.JSON(http.StatusBadRequest, echo.Map{
+			"errors": "wrong employment id",
+		})
+	}
+
+	logger := zerolog.Ctx(c.Request().Context()).With().Int("person_id", id).Int("employment_id", employmentID).Logger()
+
+	isDeleted, err := h.storage.DeleteEmployment(c.Request().Context(), id, employmentID)
+	if err != nil {
+		logger.Error().Err(err).Msg("deleting employment error")
+		return c.JSON(http.StatusInternalServerError, echo.Map{
+			"errors": "deleting employment error",
+		})
+	}
+
+	if !isDeleted {
+		logger.Info().Msg("employment not found")
+		return c.JSON(http.StatusNotFound, echo.Map{
+			"errors": "employment not found",
+		})
+	}
+	h.persons.PersonsChanged(c.Request().Context(), []int{id})
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// organizationExists writes the error response and returns false if the organization with id
+// can not be read or does not exist.
+func (h *handler) organizationExists(c echo.Context, id int) (Organization, bool, error) {
+	logger := zerolog.Ctx(c.Request().Context()).With().Int("organization_id", id).Logger()
+
+	o, err := h.storage.GetOrganization(c.Request().Context(), id)
+	if err != nil {
+		logger.Error().Err(err).Msg("getting organization error")
+		return Organization{}, false, c.JSON(http.StatusInternalServerError, echo.Map{
+			"errors": "getting organization error",
+		})
+	}
+
+	if o.ID == nil {
+		logger.Info().Msg("organization not found")
+		return Organization{}, false, c.JSON(http.StatusNotFound, echo.Map{
+			"errors": "organization not found",
+		})
+	}
+
+	return o, true, nil
+}
@@ -0,0 +1,242 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: handler.go
+
+// Package organization is a generated GoMock package.
+package organization
+
+import (
+	context "context"
+	reflect "reflect"
+
+	person "github.com/Erlendum/rsoi-lab-01/internal/persons-service/person"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// Mockstorage is a mock of storage interface.
+type Mockstorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockstorageMockRecorder
+}
+
+// MockstorageMockRecorder is the mock recorder for Mockstorage.
+type MockstorageMockRecorder struct {
+	mock *Mockstorage
+}
+
+// NewMockstorage creates a new mock instance.
+func NewMockstorage(ctrl *gomock.Controller) *Mockstorage {
+	mock := &Mockstorage{ctrl: ctrl}
+	mock.recorder = &MockstorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *Mockstorage) EXPECT() *MockstorageMockRecorder {
+	return m.recorder
+}
+
+// CreateEmployment mocks base method.
+func (m *Mockstorage) CreateEmployment(ctx context.Context, employment Employment) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateEmployment", ctx, employment)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateEmployment indicates an expected call of CreateEmployment.
+func (mr *MockstorageMockRecorder) CreateEmployment(ctx, employment interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateEmployment", reflect.TypeOf((*Mockstorage)(nil).CreateEmployment), ctx, employment)
+}
+
+// CreateOrganization mocks base method.
+func (m *Mockstorage) CreateOrganization(ctx context.Context, organization Organization) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateOrganization", ctx, organization)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateOrganization indicates an expected call of CreateOrganization.
+func (mr *MockstorageMockRecorder) CreateOrganization(ctx, organization interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateOrganization", reflect.TypeOf((*Mockstorage)(nil).CreateOrganization), ctx, organization)
+}
+
+// DeleteEmployment mocks base method.
+func (m *Mockstorage) DeleteEmployment(ctx context.Context, personID, employmentID int) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteEmployment", ctx, personID, employmentID)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteEmployment indicates an expected call of DeleteEmployment.
+func (mr *MockstorageMockRecorder) DeleteEmployment(ctx, personID, employmentID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteEmployment", reflect.TypeOf((*Mockstorage)(nil).DeleteEmployment), ctx, personID, employmentID)
+}
+
+// DeleteOrganization mocks base method.
+func (m *Mockstorage) DeleteOrganization(ctx context.Context, id int) (bool, []int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteOrganization", ctx, id)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].([]int)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// DeleteOrganization indicates an expected call of DeleteOrganization.
+func (mr *MockstorageMockRecorder) DeleteOrganization(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteOrganization", reflect.TypeOf((*Mockstorage)(nil).DeleteOrganization), ctx, id)
+}
+
+// GetOrganization mocks base method.
+func (m *Mockstorage) GetOrganization(ctx context.Context, id int) (Organization, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOrganization", ctx, id)
+	ret0, _ := ret[0].(Organization)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOrganization indicates an expected call of GetOrganization.
+func (mr *MockstorageMockRecorder) GetOrganization(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrganization", reflect.TypeOf((*Mockstorage)(nil).GetOrganization), ctx, id)
+}
+
+// GetOrganizationEmployments mocks base method.
+func (m *Mockstorage) GetOrganizationEmployments(ctx context.Context, organizationID int) ([]Employment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOrganizationEmployments", ctx, organizationID)
+	ret0, _ := ret[0].([]Employment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOrganizationEmployments indicates an expected call of GetOrganizationEmployments.
+func (mr *MockstorageMockRecorder) GetOrganizationEmployments(ctx, organizationID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrganizationEmployments", reflect.TypeOf((*Mockstorage)(nil).GetOrganizationEmployments), ctx, organizationID)
+}
+
+// GetOrganizations mocks base method.
+func (m *Mockstorage) GetOrganizations(ctx context.Context) ([]Organization, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOrganizations", ctx)
+	ret0, _ := ret[0].([]Organization)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOrganizations indicates an expected call of GetOrganizations.
+func (mr *MockstorageMockRecorder) GetOrganizations(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrganizations", reflect.TypeOf((*Mockstorage)(nil).GetOrganizations), ctx)
+}
+
+// GetPersonEmployments mocks base method.
+func (m *Mockstorage) GetPersonEmployments(ctx context.Context, personID int) ([]Employment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPersonEmployments", ctx, personID)
+	ret0, _ := ret[0].([]Employment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPersonEmployments indicates an expected call of GetPersonEmployments.
+func (mr *MockstorageMockRecorder) GetPersonEmployments(ctx, personID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPersonEmployments", reflect.TypeOf((*Mockstorage)(nil).GetPersonEmployments), ctx, personID)
+}
+
+// UpdateOrganization mocks base method.
+func (m *Mockstorage) UpdateOrganization(ctx context.Context, id int, organization *Organization) ([]int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateOrganization", ctx, id, organization)
+	ret0, _ := ret[0].([]int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateOrganization indicates an expected call of UpdateOrganization.
+func (mr *MockstorageMockRecorder) UpdateOrganization(ctx, id, organization interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateOrganization", reflect.TypeOf((*Mockstorage)(nil).UpdateOrganization), ctx, id, organization)
+}
+
+// MockpersonStorage is a mock of personStorage interface.
+type MockpersonStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockpersonStorageMockRecorder
+}
+
+// MockpersonStorageMockRecorder is the mock recorder for MockpersonStorage.
+type MockpersonStorageMockRecorder struct {
+	mock *MockpersonStorage
+}
+
+// NewMockpersonStorage creates a new mock instance.
+func NewMockpersonStorage(ctrl *gomock.Controller) *MockpersonStorage {
+	mock := &MockpersonStorage{ctrl: ctrl}
+	mock.recorder = &MockpersonStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockpersonStorage) EXPECT() *MockpersonStorageMockRecorder {
+	return m.recorder
+}
+
+// GetPerson mocks base method.
+func (m *MockpersonStorage) GetPerson(ctx context.Context, id int, fields ...string) (person.Person, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx, id}
+	for _, a := range fields {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetPerson", varargs...)
+	ret0, _ := ret[0].(person.Person)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPerson indicates an expected call of GetPerson.
+func (mr *MockpersonStorageMockRecorder) GetPerson(ctx, id interface{}, fields ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx, id}, fields...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPerson", reflect.TypeOf((*MockpersonStorage)(nil).GetPerson), varargs...)
+}
+
+// GetPersonsByIDs mocks base method.
+func (m *MockpersonStorage) GetPersonsByIDs(ctx context.Context, ids []int) ([]person.Person, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPersonsByIDs", ctx, ids)
+	ret0, _ := ret[0].([]person.Person)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPersonsByIDs indicates an expected call of GetPersonsByIDs.
+func (mr *MockpersonStorageMockRecorder) GetPersonsByIDs(ctx, ids interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPersonsByIDs", reflect.TypeOf((*MockpersonStorage)(nil).GetPersonsByIDs), ctx, ids)
+}
+
+// PersonsChanged mocks base method.
+func (m *MockpersonStorage) PersonsChanged(ctx context.Context, ids []int) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "PersonsChanged", ctx, ids)
+}
+
+// PersonsChanged indicates an expected call of PersonsChanged.
+func (mr *MockpersonStorageMockRecorder) PersonsChanged(ctx, ids interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PersonsChanged", reflect.TypeOf((*MockpersonStorage)(nil).PersonsChanged), ctx, ids)
+}
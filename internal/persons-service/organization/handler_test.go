@@ -0,0 +1,453 @@
+package organization
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/person"
+	"github.com/Erlendum/rsoi-lab-01/pkg/validation"
+	"github.com/go-playground/validator/v10"
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type handlerTestFields struct {
+	storage *Mockstorage
+	persons *MockpersonStorage
+}
+
+func createHandlerTestFields(ctrl *gomock.Controller) *handlerTestFields {
+	return &handlerTestFields{
+		storage: NewMockstorage(ctrl),
+		persons: NewMockpersonStorage(ctrl),
+	}
+}
+
+func getPointerOnString(s string) *string {
+	return &s
+}
+
+func getPointerOnInt(i int) *int {
+	return &i
+}
+
+func Test_CreateOrganization(t *testing.T) {
+	type fields struct {
+		reqBody                string
+		expectedHTTPCode       int
+		expectedLocationHeader string
+	}
+
+	e := echo.New()
+	e.Validator = validation.MustRegisterCustomValidator(validator.New())
+
+	tests := []struct {
+		name    string
+		fields  fields
+		Prepare func(fields *handlerTestFields)
+	}{
+		{
+			name: "http-code 400: wrong body",
+			fields: fields{
+				expectedHTTPCode: http.StatusBadRequest,
+				reqBody:          ``,
+			},
+			Prepare: func(fields *handlerTestFields) {},
+		},
+		{
+			name: "http-code 400: empty body",
+			fields: fields{
+				expectedHTTPCode: http.StatusBadRequest,
+				reqBody:          `{}`,
+			},
+			Prepare: func(fields *handlerTestFields) {},
+		},
+		{
+			name: "http-code 409",
+			fields: fields{
+				expectedHTTPCode: http.StatusConflict,
+				reqBody:          `{"name": "test"}`,
+			},
+			Prepare: func(fields *handlerTestFields) {
+				fields.storage.EXPECT().CreateOrganization(gomock.Any(), gomock.Any()).Return(0, ErrExists)
+			},
+		},
+		{
+			name: "http-code 500: storage error",
+			fields: fields{
+				expectedHTTPCode: http.StatusInternalServerError,
+				reqBody:          `{"name": "test"}`,
+			},
+			Prepare: func(fields *handlerTestFields) {
+				fields.storage.EXPECT().CreateOrganization(gomock.Any(), gomock.Any()).Return(0, errors.New(""))
+			},
+		},
+		{
+			name: "http-code 201",
+			fields: fields{
+				expectedHTTPCode:       http.StatusCreated,
+				reqBody:                `{"name": "test"}`,
+				expectedLocationHeader: `/api/v1/organizations/1`,
+			},
+			Prepare: func(fields *handlerTestFields) {
+				fields.storage.EXPECT().CreateOrganization(gomock.Any(), Organization{Name: getPointerOnString("test")}).Return(1, nil)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			testFields := createHandlerTestFields(ctrl)
+			tt.Prepare(testFields)
+
+			h := NewHandler(testFields.storage, testFields.persons)
+
+			req := httptest.NewRequest(http.MethodPost, "/test", strings.NewReader(tt.fields.reqBody))
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			err := h.CreateOrganization(c)
+
+			require.NoError(t, err)
+			require.Equal(t, tt.fields.expectedHTTPCode, rec.Code)
+			require.Equal(t, tt.fields.expectedLocationHeader, rec.Header().Get("Location"))
+		})
+	}
+}
+
+func Test_UpdateOrganization(t *testing.T) {
+	type fields struct {
+		id               string
+		reqBody          string
+		expectedHTTPCode int
+	}
+
+	e := echo.New()
+	e.Validator = validation.MustRegisterCustomValidator(validator.New())
+
+	tests := []struct {
+		name    string
+		fields  fields
+		Prepare func(fields *handlerTestFields)
+	}{
+		{
+			name: "http-code 400: wrong id",
+			fields: fields{
+				expectedHTTPCode: http.StatusBadRequest,
+				id:               "test",
+				reqBody:          `{"name": "test"}`,
+			},
+			Prepare: func(fields *handlerTestFields) {},
+		},
+		{
+			name: "http-code 404",
+			fields: fields{
+				expectedHTTPCode: http.StatusNotFound,
+				id:               "1",
+				reqBody:          `{"name": "test"}`,
+			},
+			Prepare: func(fields *handlerTestFields) {
+				fields.storage.EXPECT().UpdateOrganization(gomock.Any(), 1, gomock.Any()).Return(nil, ErrNotFound)
+			},
+		},
+		{
+			name: "http-code 409",
+			fields: fields{
+				expectedHTTPCode: http.StatusConflict,
+				id:               "1",
+				reqBody:          `{"name": "test"}`,
+			},
+			Prepare: func(fields *handlerTestFields) {
+				fields.storage.EXPECT().UpdateOrganization(gomock.Any(), 1, gomock.Any()).Return(nil, ErrExists)
+			},
+		},
+		{
+			name: "http-code 200: changed employees are reported",
+			fields: fields{
+				expectedHTTPCode: http.StatusOK,
+				id:               "1",
+				reqBody:          `{"name": "renamed"}`,
+			},
+			Prepare: func(fields *handlerTestFields) {
+				fields.storage.EXPECT().UpdateOrganization(gomock.Any(), 1, gomock.Any()).
+					DoAndReturn(func(_ any, _ int, o *Organization) ([]int, error) {
+						o.ID = getPointerOnInt(1)
+						return []int{2}, nil
+					})
+				fields.persons.EXPECT().PersonsChanged(gomock.Any(), []int{2})
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			testFields := createHandlerTestFields(ctrl)
+			tt.Prepare(testFields)
+
+			h := NewHandler(testFields.storage, testFields.persons)
+
+			req := httptest.NewRequest(http.MethodPatch, "/test", strings.NewReader(tt.fields.reqBody))
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetParamNames("id")
+			c.SetParamValues(tt.fields.id)
+
+			err := h.UpdateOrganization(c)
+
+			require.NoError(t, err)
+			require.Equal(t, tt.fields.expectedHTTPCode, rec.Code)
+		})
+	}
+}
+
+func Test_CreateEmployment(t *testing.T) {
+	type fields struct {
+		id                     string
+		reqBody                string
+		expectedHTTPCode       int
+		expectedLocationHeader string
+	}
+
+	e := echo.New()
+	e.Validator = validation.MustRegisterCustomValidator(validator.New())
+
+	existingPerson := person.Person{ID: getPointerOnInt(1), Name: getPointerOnString("test")}
+	existingOrganization := Organization{ID: getPointerOnInt(2), Name: getPointerOnString("org")}
+
+	tests := []struct {
+		name    string
+		fields  fields
+		Prepare func(fields *handlerTestFields)
+	}{
+		{
+			name: "http-code 400: missing organization id",
+			fields: fields{
+				expectedHTTPCode: http.StatusBadRequest,
+				id:               "1",
+				reqBody:          `{"role": "engineer"}`,
+			},
+			Prepare: func(fields *handlerTestFields) {},
+		},
+		{
+			name: "http-code 400: invalid date",
+			fields: fields{
+				expectedHTTPCode: http.StatusBadRequest,
+				id:               "1",
+				reqBody:          `{"organization_id": 2, "start_date": "01.01.2020"}`,
+			},
+			Prepare: func(fields *handlerTestFields) {},
+		},
+		{
+			name: "http-code 400: ends before it starts",
+			fields: fields{
+				expectedHTTPCode: http.StatusBadRequest,
+				id:               "1",
+				reqBody:          `{"organization_id": 2, "start_date": "2020-01-01", "end_date": "2019-01-01"}`,
+			},
+			Prepare: func(fields *handlerTestFields) {},
+		},
+		{
+			name: "http-code 404: person not found",
+			fields: fields{
+				expectedHTTPCode: http.StatusNotFound,
+				id:               "1",
+				reqBody:          `{"organization_id": 2}`,
+			},
+			Prepare: func(fields *handlerTestFields) {
+				fields.persons.EXPECT().GetPerson(gomock.Any(), 1).Return(person.Person{}, nil)
+			},
+		},
+		{
+			name: "http-code 404: organization not found",
+			fields: fields{
+				expectedHTTPCode: http.StatusNotFound,
+				id:               "1",
+				reqBody:          `{"organization_id": 2}`,
+			},
+			Prepare: func(fields *handlerTestFields) {
+				fields.persons.EXPECT().GetPerson(gomock.Any(), 1).Return(existingPerson, nil)
+				fields.storage.EXPECT().GetOrganization(gomock.Any(), 2).Return(Organization{}, nil)
+			},
+		},
+		{
+			name: "http-code 201: past employment keeps work",
+			fields: fields{
+				expectedHTTPCode:       http.StatusCreated,
+				id:                     "1",
+				reqBody:                `{"organization_id": 2, "role": "intern", "start_date": "2010-01-01", "end_date": "2012-01-01"}`,
+				expectedLocationHeader: `/api/v1/persons/1/employments/3`,
+			},
+			Prepare: func(fields *handlerTestFields) {
+				startDate := time.Date(2010, time.January, 1, 0, 0, 0, 0, time.UTC)
+				endDate := time.Date(2012, time.January, 1, 0, 0, 0, 0, time.UTC)
+				fields.persons.EXPECT().GetPerson(gomock.Any(), 1).Return(existingPerson, nil)
+				fields.storage.EXPECT().GetOrganization(gomock.Any(), 2).Return(existingOrganization, nil)
+				fields.storage.EXPECT().CreateEmployment(gomock.Any(), Employment{
+					PersonID:       1,
+					OrganizationID: 2,
+					Role:           getPointerOnString("intern"),
+					StartDate:      &startDate,
+					EndDate:        &endDate,
+				}).Return(3, nil)
+			},
+		},
+		{
+			name: "http-code 201: current employment sets work",
+			fields: fields{
+				expectedHTTPCode:       http.StatusCreated,
+				id:                     "1",
+				reqBody:                `{"organization_id": 2}`,
+				expectedLocationHeader: `/api/v1/persons/1/employments/3`,
+			},
+			Prepare: func(fields *handlerTestFields) {
+				fields.persons.EXPECT().GetPerson(gomock.Any(), 1).Return(existingPerson, nil)
+				fields.storage.EXPECT().GetOrganization(gomock.Any(), 2).Return(existingOrganization, nil)
+				fields.storage.EXPECT().CreateEmployment(gomock.Any(), gomock.Any()).Return(3, nil)
+				fields.persons.EXPECT().PersonsChanged(gomock.Any(), []int{1})
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			testFields := createHandlerTestFields(ctrl)
+			tt.Prepare(testFields)
+
+			h := NewHandler(testFields.storage, testFields.persons)
+
+			req := httptest.NewRequest(http.MethodPost, "/test", strings.NewReader(tt.fields.reqBody))
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetParamNames("id")
+			c.SetParamValues(tt.fields.id)
+
+			err := h.CreateEmployment(c)
+
+			require.NoError(t, err)
+			require.Equal(t, tt.fields.expectedHTTPCode, rec.Code)
+			require.Equal(t, tt.fields.expectedLocationHeader, rec.Header().Get("Location"))
+		})
+	}
+}
+
+func Test_GetOrganizationPersons(t *testing.T) {
+	type fields struct {
+		id               string
+		query            string
+		expectedHTTPCode int
+		expectedPersons  []int
+	}
+
+	e := echo.New()
+
+	ended := time.Now().AddDate(-1, 0, 0)
+	employments := []Employment{
+		{ID: getPointerOnInt(1), PersonID: 1, OrganizationID: 1, EndDate: &ended},
+		{ID: getPointerOnInt(2), PersonID: 2, OrganizationID: 1, Role: getPointerOnString("engineer")},
+		{ID: getPointerOnInt(3), PersonID: 3, OrganizationID: 1},
+	}
+
+	tests := []struct {
+		name    string
+		fields  fields
+		Prepare func(fields *handlerTestFields)
+	}{
+		{
+			name: "http-code 400: wrong current flag",
+			fields: fields{
+				expectedHTTPCode: http.StatusBadRequest,
+				id:               "1",
+				query:            "current=maybe",
+			},
+			Prepare: func(fields *handlerTestFields) {},
+		},
+		{
+			name: "http-code 404",
+			fields: fields{
+				expectedHTTPCode: http.StatusNotFound,
+				id:               "1",
+			},
+			Prepare: func(fields *handlerTestFields) {
+				fields.storage.EXPECT().GetOrganization(gomock.Any(), 1).Return(Organization{}, nil)
+			},
+		},
+		{
+			name: "http-code 200: deleted persons are skipped",
+			fields: fields{
+				expectedHTTPCode: http.StatusOK,
+				id:               "1",
+				expectedPersons:  []int{1, 2},
+			},
+			Prepare: func(fields *handlerTestFields) {
+				fields.storage.EXPECT().GetOrganization(gomock.Any(), 1).Return(Organization{ID: getPointerOnInt(1)}, nil)
+				fields.storage.EXPECT().GetOrganizationEmployments(gomock.Any(), 1).Return(employments, nil)
+				fields.persons.EXPECT().GetPersonsByIDs(gomock.Any(), []int{1, 2, 3}).Return([]person.Person{
+					{ID: getPointerOnInt(1), Name: getPointerOnString("first")},
+					{ID: getPointerOnInt(2), Name: getPointerOnString("second")},
+				}, nil)
+			},
+		},
+		{
+			name: "http-code 200: current only",
+			fields: fields{
+				expectedHTTPCode: http.StatusOK,
+				id:               "1",
+				query:            "current=true",
+				expectedPersons:  []int{2},
+			},
+			Prepare: func(fields *handlerTestFields) {
+				fields.storage.EXPECT().GetOrganization(gomock.Any(), 1).Return(Organization{ID: getPointerOnInt(1)}, nil)
+				fields.storage.EXPECT().GetOrganizationEmployments(gomock.Any(), 1).Return(employments, nil)
+				fields.persons.EXPECT().GetPersonsByIDs(gomock.Any(), []int{2, 3}).Return([]person.Person{
+					{ID: getPointerOnInt(2), Name: getPointerOnString("second")},
+				}, nil)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			testFields := createHandlerTestFields(ctrl)
+			tt.Prepare(testFields)
+
+			h := NewHandler(testFields.storage, testFields.persons)
+
+			req := httptest.NewRequest(http.MethodGet, "/test?"+tt.fields.query, nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetParamNames("id")
+			c.SetParamValues(tt.fields.id)
+
+			err := h.GetOrganizationPersons(c)
+
+			require.NoError(t, err)
+			require.Equal(t, tt.fields.expectedHTTPCode, rec.Code)
+			if tt.fields.expectedHTTPCode != http.StatusOK {
+				return
+			}
+
+			var resp []struct {
+				PersonID int `json:"person_id"`
+			}
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			ids := make([]int, len(resp))
+			for i, r := range resp {
+				ids[i] = r.PersonID
+			}
+			require.Equal(t, tt.fields.expectedPersons, ids)
+		})
+	}
+}
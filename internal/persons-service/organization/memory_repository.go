@@ -0,0 +1,319 @@
+package organization
+
+import (
+	"context"
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/pgutil"
+	"github.com/pkg/errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// PersonWork sets the work field of persons, for storages that share no database with them.
+type PersonWork interface {
+	// SetWork sets the work of the person, or clears it for nil, and reports whether it changed.
+	SetWork(ctx context.Context, id int, work *string) (bool, error)
+}
+
+// memoryRepository keeps organizations in process memory. It is meant for tests and local runs
+// without a database; the data is lost on restart.
+type memoryRepository struct {
+	mu               sync.RWMutex
+	persons          PersonWork
+	organizations    map[int]Organization
+	employments      map[int]Employment
+	lastID           int
+	lastEmploymentID int
+}
+
+// NewMemoryRepository creates a repository that keeps the work of persons through persons.
+func NewMemoryRepository(persons PersonWork) *memoryRepository {
+	return &memoryRepository{
+		persons:       persons,
+		organizations: make(map[int]Organization),
+		employments:   make(map[int]Employment),
+	}
+}
+
+// nameTaken reports whether an organization other than the one with id has name.
+func (r *memoryRepository) nameTaken(name string, id int) bool {
+	for _, o := range r.organizations {
+		if *o.Name == name && *o.ID != id {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *memoryRepository) CreateOrganization(ctx context.Context, organization Organization) (int, error) {
+	if err := pgutil.CheckContext(ctx); err != nil {
+		return 0, err
+	}
+	if organization.Name == nil {
+		return 0, errors.New("name is required")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.nameTaken(*organization.Name, 0) {
+		return 0, ErrExists
+	}
+
+	r.lastID++
+	id := r.lastID
+	now := time.Now().UTC()
+	organization = cloneOrganization(organization)
+	organization.ID = &id
+	organization.CreatedAt, organization.UpdatedAt = &now, &now
+	r.organizations[id] = organization
+
+	return id, nil
+}
+
+func (r *memoryRepository) UpdateOrganization(ctx context.Context, id int, organization *Organization) ([]int, error) {
+	if err := pgutil.CheckContext(ctx); err != nil {
+		return nil, err
+	}
+	if organization.Name == nil {
+		return nil, nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.organizations[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if r.nameTaken(*organization.Name, id) {
+		return nil, ErrExists
+	}
+
+	now := time.Now().UTC()
+	stored.Name = clonePointer(organization.Name)
+	stored.UpdatedAt = &now
+	r.organizations[id] = stored
+	*organization = cloneOrganization(stored)
+
+	return r.updateWork(ctx, currentEmployees(r.filterEmployments(func(e Employment) bool { return e.OrganizationID == id }), now))
+}
+
+func (r *memoryRepository) DeleteOrganization(ctx context.Context, id int) (bool, []int, error) {
+	if err := pgutil.CheckContext(ctx); err != nil {
+		return false, nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.organizations[id]; !ok {
+		return false, nil, nil
+	}
+	employments := r.filterEmployments(func(e Employment) bool { return e.OrganizationID == id })
+	delete(r.organizations, id)
+	for _, e := range employments {
+		delete(r.employments, *e.ID)
+	}
+
+	changed, err := r.updateWork(ctx, currentEmployees(employments, time.Now()))
+	return true, changed, err
+}
+
+func (r *memoryRepository) GetOrganizations(ctx context.Context) ([]Organization, error) {
+	if err := pgutil.CheckContext(ctx); err != nil {
+		return []Organization{}, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	res := make([]Organization, 0, len(r.organizations))
+	for _, o := range r.organizations {
+		res = append(res, cloneOrganization(o))
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return *res[i].ID < *res[j].ID
+	})
+
+	return res, nil
+}
+
+func (r *memoryRepository) GetOrganization(ctx context.Context, id int) (Organization, error) {
+	if err := pgutil.CheckContext(ctx); err != nil {
+		return Organization{}, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	o, ok := r.organizations[id]
+	if !ok {
+		return Organization{}, nil
+	}
+
+	return cloneOrganization(o), nil
+}
+
+func (r *memoryRepository) CreateEmployment(ctx context.Context, employment Employment) (int, error) {
+	if err := pgutil.CheckContext(ctx); err != nil {
+		return 0, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.organizations[employment.OrganizationID]; !ok {
+		return 0, ErrNotFound
+	}
+
+	r.lastEmploymentID++
+	id := r.lastEmploymentID
+	now := time.Now().UTC()
+	employment = cloneEmployment(employment)
+	employment.ID, employment.CreatedAt = &id, &now
+	r.employments[id] = employment
+
+	if employment.IsCurrent(now) {
+		if _, err := r.updateWork(ctx, []int{employment.PersonID}); err != nil {
+			return 0, err
+		}
+	}
+
+	return id, nil
+}
+
+func (r *memoryRepository) DeleteEmployment(ctx context.Context, personID, employmentID int) (bool, error) {
+	if err := pgutil.CheckContext(ctx); err != nil {
+		return false, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e, ok := r.employments[employmentID]
+	if !ok || e.PersonID != personID {
+		return false, nil
+	}
+	delete(r.employments, employmentID)
+
+	if e.IsCurrent(time.Now()) {
+		if _, err := r.updateWork(ctx, []int{personID}); err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// MovePersonRows gives the employments of the person fromID to the person toID.
+func (r *memoryRepository) MovePersonRows(ctx context.Context, fromID, toID int) error {
+	if err := pgutil.CheckContext(ctx); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, e := range r.employments {
+		if e.PersonID == fromID {
+			e.PersonID = toID
+			r.employments[id] = e
+		}
+	}
+
+	return nil
+}
+
+// DeletePersonRows deletes the employments of the person.
+func (r *memoryRepository) DeletePersonRows(ctx context.Context, personID int) error {
+	if err := pgutil.CheckContext(ctx); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, e := range r.employments {
+		if e.PersonID == personID {
+			delete(r.employments, id)
+		}
+	}
+
+	return nil
+}
+
+func (r *memoryRepository) GetPersonEmployments(ctx context.Context, personID int) ([]Employment, error) {
+	return r.getEmployments(ctx, func(e Employment) bool { return e.PersonID == personID }, sortHistory)
+}
+
+func (r *memoryRepository) GetOrganizationEmployments(ctx context.Context, organizationID int) ([]Employment, error) {
+	return r.getEmployments(ctx, func(e Employment) bool { return e.OrganizationID == organizationID }, sortByID)
+}
+
+// filterEmployments returns the employments match accepts. r.mu must be held.
+func (r *memoryRepository) filterEmployments(match func(Employment) bool) []Employment {
+	res := make([]Employment, 0)
+	for _, e := range r.employments {
+		if match(e) {
+			res = append(res, cloneEmployment(e))
+		}
+	}
+	return res
+}
+
+// updateWork sets the work of the persons to their current employment and returns those whose
+// work changed. r.mu must be held.
+func (r *memoryRepository) updateWork(ctx context.Context, personIDs []int) ([]int, error) {
+	now := time.Now()
+	changed := make([]int, 0)
+	for _, personID := range personIDs {
+		history := r.filterEmployments(func(e Employment) bool { return e.PersonID == personID })
+		work := currentWork(history, func(organizationID int) *string {
+			return clonePointer(r.organizations[organizationID].Name)
+		}, now)
+		ok, err := r.persons.SetWork(ctx, personID, work)
+		if err != nil {
+			return changed, errors.Wrap(err, "failed to set person work")
+		}
+		if ok {
+			changed = append(changed, personID)
+		}
+	}
+	return changed, nil
+}
+
+func (r *memoryRepository) getEmployments(ctx context.Context, match func(Employment) bool, order func([]Employment)) ([]Employment, error) {
+	if err := pgutil.CheckContext(ctx); err != nil {
+		return []Employment{}, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	res := r.filterEmployments(match)
+	order(res)
+
+	return res, nil
+}
+
+// sortHistory orders employments the latest first, those without a start date last, like the
+// database does.
+func sortHistory(employments []Employment) {
+	sort.Slice(employments, func(i, j int) bool {
+		a, b := employments[i].StartDate, employments[j].StartDate
+		switch {
+		case a != nil && b != nil && !a.Equal(*b):
+			return a.After(*b)
+		case (a == nil) != (b == nil):
+			return a != nil
+		}
+		return *employments[i].ID > *employments[j].ID
+	})
+}
+
+func sortByID(employments []Employment) {
+	sort.Slice(employments, func(i, j int) bool {
+		return *employments[i].ID < *employments[j].ID
+	})
+}
@@ -0,0 +1,93 @@
+package organization
+
+import (
+	"slices"
+	"time"
+)
+
+// Organization is an employer of persons. Nil fields are NULL in the storage or, in partial
+// updates, fields that are left unchanged.
+type Organization struct {
+	ID        *int       `db:"id" json:"id,omitempty"`
+	Name      *string    `db:"name" json:"name"`
+	CreatedAt *time.Time `db:"created_at" json:"created_at,omitempty"`
+	UpdatedAt *time.Time `db:"updated_at" json:"updated_at,omitempty"`
+}
+
+// Employment is a period a person worked at an organization. An employment without an end
+// date is current.
+type Employment struct {
+	ID             *int       `db:"id" json:"id,omitempty"`
+	PersonID       int        `db:"person_id" json:"person_id"`
+	OrganizationID int        `db:"organization_id" json:"organization_id"`
+	Role           *string    `db:"role" json:"role,omitempty"`
+	StartDate      *time.Time `db:"start_date" json:"start_date,omitempty"`
+	EndDate        *time.Time `db:"end_date" json:"end_date,omitempty"`
+	CreatedAt      *time.Time `db:"created_at" json:"created_at,omitempty"`
+}
+
+// IsCurrent reports whether the employment has not ended by now.
+func (e Employment) IsCurrent(now time.Time) bool {
+	return e.EndDate == nil || e.EndDate.After(now)
+}
+
+// currentEmployees returns the persons of employments that are current by now, sorted and
+// without repeats.
+func currentEmployees(employments []Employment, now time.Time) []int {
+	res := make([]int, 0)
+	for _, e := range employments {
+		if e.IsCurrent(now) {
+			res = append(res, e.PersonID)
+		}
+	}
+	slices.Sort(res)
+	return slices.Compact(res)
+}
+
+// currentWork returns the name of the organization of the latest current employment in
+// history, the work field of its person, or nil if none is current. history is sorted by
+// sortHistory and name looks the organization names up.
+func currentWork(history []Employment, name func(organizationID int) *string, now time.Time) *string {
+	sortHistory(history)
+	for _, e := range history {
+		if e.IsCurrent(now) {
+			return name(e.OrganizationID)
+		}
+	}
+	return nil
+}
+
+func cloneOrganization(o Organization) Organization {
+	return Organization{
+		ID:        clonePointer(o.ID),
+		Name:      clonePointer(o.Name),
+		CreatedAt: clonePointer(o.CreatedAt),
+		UpdatedAt: clonePointer(o.UpdatedAt),
+	}
+}
+
+func cloneEmployment(e Employment) Employment {
+	e.ID = clonePointer(e.ID)
+	e.Role = clonePointer(e.Role)
+	e.StartDate = clonePointer(e.StartDate)
+	e.EndDate = clonePointer(e.EndDate)
+	e.CreatedAt = clonePointer(e.CreatedAt)
+	return e
+}
+
+func clonePointer[T any](v *T) *T {
+	if v == nil {
+		return nil
+	}
+	c := *v
+	return &c
+}
+
+// valueOrZero returns the value v points to, or the zero value for nullable columns.
+func valueOrZero[T any](v *T) T {
+	if v == nil {
+		var zero T
+		return zero
+	}
+	return *v
+}
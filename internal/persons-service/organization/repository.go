@@ -0,0 +1,444 @@
+package organization
+
+import (
+	"context"
+	"database/sql"
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/pgutil"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"strings"
+	"time"
+)
+
+const (
+	defaultTimeout = 5 * time.Second
+)
+
+// currentWorkQuery is the name of the organization of the latest current employment of the
+// person p, in the order GetPersonEmployments lists the history in, or NULL.
+const currentWorkQuery = `(SELECT o.name FROM employments e JOIN organizations o ON o.id = e.organization_id
+	WHERE e.person_id = p.id AND (e.end_date IS NULL OR e.end_date > now())
+	ORDER BY e.start_date DESC NULLS LAST, e.id DESC LIMIT 1)`
+
+// isCurrent matches the employments that have not ended, like Employment.IsCurrent.
+const isCurrent = "(end_date IS NULL OR end_date > now())"
+
+var (
+	organizationColumns = []string{"id", "name", "created_at", "updated_at"}
+	employmentColumns   = []string{"id", "person_id", "organization_id", "role", "start_date", "end_date", "created_at"}
+)
+
+var queryTracer = pgutil.NewQueryTracer("github.com/Erlendum/rsoi-lab-01/internal/persons-service/organization", "organization.repository")
+
+type repository struct {
+	conns pgutil.ConnRouter
+}
+
+func NewRepository(conn *sqlx.DB) *repository {
+	return &repository{conns: pgutil.SingleConn{Conn: conn}}
+}
+
+// NewReplicatedRepository creates a repository that reads and writes through conns.
+func NewReplicatedRepository(conns pgutil.ConnRouter) *repository {
+	return &repository{conns: conns}
+}
+
+// mapError translates the constraint violations the handler reacts to.
+func mapError(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return err
+	}
+
+	switch {
+	case pqErr.Code.Name() == "unique_violation":
+		return ErrExists
+	case pqErr.Code.Name() == "foreign_key_violation" && strings.Contains(pqErr.Constraint, "person_id"):
+		return ErrPersonNotFound
+	case pqErr.Code.Name() == "foreign_key_violation":
+		return ErrNotFound
+	}
+	return err
+}
+
+func (r *repository) CreateOrganization(ctx context.Context, organization Organization) (int, error) {
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+	builder := psql.Insert("organizations").Columns("name").Values(organization.Name)
+	query, args, err := builder.Suffix("RETURNING id").ToSql()
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to build query")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	ctx, span := queryTracer.StartQuerySpan(ctx, "CreateOrganization", query)
+	var id int
+	err = r.conns.Writer(ctx).QueryRowContext(ctx, query, args...).Scan(&id)
+	queryTracer.EndQuerySpan(span, err)
+	if err = mapError(err); errors.Is(err, ErrExists) {
+		return 0, err
+	}
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to execute query")
+	}
+	r.conns.MarkWrite(ctx)
+
+	return id, nil
+}
+
+// UpdateOrganization renames the organization and sets the work of its current employees to the
+// new name in one transaction. It returns the persons whose work changed.
+func (r *repository) UpdateOrganization(ctx context.Context, id int, organization *Organization) ([]int, error) {
+	if organization.Name == nil {
+		return nil, nil
+	}
+
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+	builder := psql.Update("organizations").
+		Set("name", organization.Name).
+		Set("updated_at", sq.Expr("now()")).
+		Where(sq.Eq{"id": id}).
+		Suffix("RETURNING " + strings.Join(organizationColumns, ", "))
+	query, args, err := builder.ToSql()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build query")
+	}
+
+	updated := Organization{}
+	var changed []int
+	err = r.withTx(ctx, func(ctx context.Context, tx *sqlx.Tx) error {
+		spanCtx, span := queryTracer.StartQuerySpan(ctx, "UpdateOrganization", query)
+		err := tx.QueryRowxContext(spanCtx, query, args...).StructScan(&updated)
+		queryTracer.EndQuerySpan(span, err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		if err = mapError(err); errors.Is(err, ErrExists) {
+			return err
+		}
+		if err != nil {
+			return errors.Wrap(err, "failed to execute query")
+		}
+
+		changed, err = updateWork(ctx, tx, "UpdateOrganization", sq.Expr(
+			"p.id IN (SELECT person_id FROM employments WHERE organization_id = ? AND "+isCurrent+")", id))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	*organization = updated
+
+	return changed, nil
+}
+
+// DeleteOrganization deletes the organization with its employments and clears the work of its
+// current employees in one transaction. It returns the persons whose work changed.
+func (r *repository) DeleteOrganization(ctx context.Context, id int) (bool, []int, error) {
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+	query, args, err := psql.Select("DISTINCT person_id").From("employments").
+		Where(sq.Eq{"organization_id": id}).Where(isCurrent).ToSql()
+	if err != nil {
+		return false, nil, errors.Wrap(err, "failed to build query")
+	}
+
+	isDeleted := false
+	var changed []int
+	err = r.withTx(ctx, func(ctx context.Context, tx *sqlx.Tx) error {
+		employees := make([]int, 0)
+		spanCtx, span := queryTracer.StartQuerySpan(ctx, "DeleteOrganization", query)
+		err := tx.SelectContext(spanCtx, &employees, query, args...)
+		queryTracer.EndQuerySpan(span, err)
+		if err != nil {
+			return errors.Wrap(err, "failed to execute query")
+		}
+
+		countAffectedRows, err := txExec(ctx, tx, "DeleteOrganization", psql.Delete("organizations").Where(sq.Eq{"id": id}))
+		if err != nil || countAffectedRows == 0 {
+			return err
+		}
+		isDeleted = true
+
+		changed, err = updateWork(ctx, tx, "DeleteOrganization", sq.Eq{"p.id": employees})
+		return err
+	})
+	if err != nil {
+		return false, nil, err
+	}
+
+	return isDeleted, changed, nil
+}
+
+func (r *repository) GetOrganizations(ctx context.Context) ([]Organization, error) {
+	res := make([]Organization, 0)
+	err := r.selectRows(ctx, "GetOrganizations", &res, sq.Select(organizationColumns...).From("organizations").OrderBy("id"))
+	if err != nil {
+		return []Organization{}, err
+	}
+
+	return res, nil
+}
+
+func (r *repository) GetOrganization(ctx context.Context, id int) (Organization, error) {
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	builder := psql.Select(organizationColumns...).From("organizations").Where(sq.Eq{"id": id})
+
+	query, args, err := builder.ToSql()
+	if err != nil {
+		return Organization{}, errors.Wrap(err, "failed to build query")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	res := Organization{}
+
+	ctx, span := queryTracer.StartQuerySpan(ctx, "GetOrganization", query)
+	err = r.conns.Reader(ctx).GetContext(ctx, &res, query, args...)
+	queryTracer.EndQuerySpan(span, err)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Organization{}, nil
+	}
+	if err != nil {
+		return Organization{}, errors.Wrap(err, "failed to execute query")
+	}
+
+	return res, nil
+}
+
+// CreateEmployment adds the employment and, if it is current, sets the work of the person in
+// the same transaction.
+func (r *repository) CreateEmployment(ctx context.Context, employment Employment) (int, error) {
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+	builder := psql.Insert("employments").
+		Columns("person_id", "organization_id", "role", "start_date", "end_date").
+		Values(employment.PersonID, employment.OrganizationID, employment.Role, employment.StartDate, employment.EndDate)
+	query, args, err := builder.Suffix("RETURNING id, " + isCurrent).ToSql()
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to build query")
+	}
+
+	var id int
+	err = r.withTx(ctx, func(ctx context.Context, tx *sqlx.Tx) error {
+		var current bool
+		spanCtx, span := queryTracer.StartQuerySpan(ctx, "CreateEmployment", query)
+		err := tx.QueryRowContext(spanCtx, query, args...).Scan(&id, &current)
+		queryTracer.EndQuerySpan(span, err)
+		if err = mapError(err); errors.Is(err, ErrNotFound) || errors.Is(err, ErrPersonNotFound) {
+			return err
+		}
+		if err != nil {
+			return errors.Wrap(err, "failed to execute query")
+		}
+
+		if current {
+			_, err = updateWork(ctx, tx, "CreateEmployment", sq.Eq{"p.id": employment.PersonID})
+		}
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// DeleteEmployment deletes the employment and, if it was current, recomputes the work of the
+// person in the same transaction.
+func (r *repository) DeleteEmployment(ctx context.Context, personID, employmentID int) (bool, error) {
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+	query, args, err := psql.Delete("employments").Where(sq.Eq{"id": employmentID, "person_id": personID}).
+		Suffix("RETURNING " + isCurrent).ToSql()
+	if err != nil {
+		return false, errors.Wrap(err, "failed to build query")
+	}
+
+	isDeleted := false
+	err = r.withTx(ctx, func(ctx context.Context, tx *sqlx.Tx) error {
+		var current bool
+		spanCtx, span := queryTracer.StartQuerySpan(ctx, "DeleteEmployment", query)
+		err := tx.QueryRowContext(spanCtx, query, args...).Scan(&current)
+		queryTracer.EndQuerySpan(span, err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrap(err, "failed to execute query")
+		}
+		isDeleted = true
+
+		if current {
+			_, err = updateWork(ctx, tx, "DeleteEmployment", sq.Eq{"p.id": personID})
+		}
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return isDeleted, nil
+}
+
+func (r *repository) GetPersonEmployments(ctx context.Context, personID int) ([]Employment, error) {
+	res := make([]Employment, 0)
+	err := r.selectRows(ctx, "GetPersonEmployments", &res, sq.Select(employmentColumns...).From("employments").
+		Where(sq.Eq{"person_id": personID}).
+		OrderBy("start_date DESC NULLS LAST", "id DESC"))
+	if err != nil {
+		return []Employment{}, err
+	}
+
+	return res, nil
+}
+
+func (r *repository) GetOrganizationEmployments(ctx context.Context, organizationID int) ([]Employment, error) {
+	res := make([]Employment, 0)
+	err := r.selectRows(ctx, "GetOrganizationEmployments", &res, sq.Select(employmentColumns...).From("employments").
+		Where(sq.Eq{"organization_id": organizationID}).
+		OrderBy("id"))
+	if err != nil {
+		return []Employment{}, err
+	}
+
+	return res, nil
+}
+
+// MovePersonRows gives the employments of the person fromID to the person toID.
+func (r *repository) MovePersonRows(ctx context.Context, fromID, toID int) error {
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+	_, err := r.exec(ctx, "MovePersonRows", psql.Update("employments").Set("person_id", toID).Where(sq.Eq{"person_id": fromID}))
+	return err
+}
+
+// DeletePersonRows deletes the employments of the person.
+func (r *repository) DeletePersonRows(ctx context.Context, personID int) error {
+	_, err := r.delete(ctx, "DeletePersonRows", sq.Delete("employments").Where(sq.Eq{"person_id": personID}))
+	return err
+}
+
+func (r *repository) selectRows(ctx context.Context, method string, dst interface{}, builder sq.SelectBuilder) error {
+	query, args, err := builder.PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return errors.Wrap(err, "failed to build query")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	ctx, span := queryTracer.StartQuerySpan(ctx, method, query)
+	err = r.conns.Reader(ctx).SelectContext(ctx, dst, query, args...)
+	queryTracer.EndQuerySpan(span, err)
+	if err != nil {
+		return errors.Wrap(err, "failed to execute query")
+	}
+
+	return nil
+}
+
+// withTx runs fn in a transaction on the writer and commits it if fn succeeds.
+func (r *repository) withTx(ctx context.Context, fn func(ctx context.Context, tx *sqlx.Tx) error) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	tx, err := r.conns.Writer(ctx).BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to begin transaction")
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err = fn(ctx, tx); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return errors.Wrap(err, "failed to commit transaction")
+	}
+	r.conns.MarkWrite(ctx)
+
+	return nil
+}
+
+// updateWork sets the work of the persons where matches to their current employment in tx and
+// returns those whose work changed.
+func updateWork(ctx context.Context, tx *sqlx.Tx, method string, where sq.Sqlizer) ([]int, error) {
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+	query, args, err := psql.Update("persons p").
+		Set("work", sq.Expr(currentWorkQuery)).
+		Set("updated_at", sq.Expr("now()")).
+		Where(where).
+		Where("p.work IS DISTINCT FROM " + currentWorkQuery).
+		Suffix("RETURNING p.id").ToSql()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build query")
+	}
+
+	changed := make([]int, 0)
+	ctx, span := queryTracer.StartQuerySpan(ctx, method, query)
+	err = tx.SelectContext(ctx, &changed, query, args...)
+	queryTracer.EndQuerySpan(span, err)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to execute query")
+	}
+
+	return changed, nil
+}
+
+// txExec runs the statement in tx and returns the count of affected rows.
+func txExec(ctx context.Context, tx *sqlx.Tx, method string, builder sq.Sqlizer) (int64, error) {
+	query, args, err := builder.ToSql()
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to build query")
+	}
+
+	ctx, span := queryTracer.StartQuerySpan(ctx, method, query)
+	res, err := tx.ExecContext(ctx, query, args...)
+	queryTracer.EndQuerySpan(span, err)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to execute query")
+	}
+
+	countAffectedRows, err := res.RowsAffected()
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to get count of affected rows")
+	}
+
+	return countAffectedRows, nil
+}
+
+func (r *repository) delete(ctx context.Context, method string, builder sq.DeleteBuilder) (bool, error) {
+	countAffectedRows, err := r.exec(ctx, method, builder.PlaceholderFormat(sq.Dollar))
+	if err != nil {
+		return false, err
+	}
+
+	return countAffectedRows == 1, nil
+}
+
+func (r *repository) exec(ctx context.Context, method string, builder sq.Sqlizer) (int64, error) {
+	query, args, err := builder.ToSql()
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to build query")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	ctx, span := queryTracer.StartQuerySpan(ctx, method, query)
+	res, err := r.conns.Writer(ctx).ExecContext(ctx, query, args...)
+	queryTracer.EndQuerySpan(span, err)
+	if res == nil || err != nil {
+		return 0, errors.Wrap(err, "failed to execute query")
+	}
+
+	countAffectedRows, err := res.RowsAffected()
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to get count of affected rows")
+	}
+	r.conns.MarkWrite(ctx)
+
+	return countAffectedRows, nil
+}
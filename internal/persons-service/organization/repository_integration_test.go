@@ -0,0 +1,66 @@
+//go:build integration
+
+package organization
+
+import (
+	"context"
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/person"
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/pgtest"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+	"os"
+	"testing"
+)
+
+// testDB is shared by the integration tests. It points to POSTGRESQL_TEST_DSN if set, or
+// to an ephemeral PostgreSQL started for the test run.
+var testDB *sqlx.DB
+
+func TestMain(m *testing.M) {
+	os.Exit(pgtest.Run(m, func(db *sqlx.DB) {
+		testDB = db
+	}))
+}
+
+func newTestRepository(t *testing.T) (*repository, testPersons) {
+	_, err := testDB.Exec("TRUNCATE persons, organizations RESTART IDENTITY CASCADE")
+	require.NoError(t, err)
+
+	return NewRepository(testDB), person.NewRepository(testDB)
+}
+
+func Test_Repository(t *testing.T) {
+	testStorageConformance(t, func(t *testing.T) (storage, testPersons) {
+		return newTestRepository(t)
+	})
+}
+
+func Test_Repository_EmploymentOfMissingPerson(t *testing.T) {
+	ctx := context.Background()
+	r, _ := newTestRepository(t)
+
+	id, err := r.CreateOrganization(ctx, Organization{Name: getPointerOnString("test")})
+	require.NoError(t, err)
+
+	_, err = r.CreateEmployment(ctx, Employment{PersonID: 1, OrganizationID: id})
+	require.ErrorIs(t, err, ErrPersonNotFound)
+}
+
+func Test_Repository_DeletePersonCascadesEmployments(t *testing.T) {
+	ctx := context.Background()
+	r, persons := newTestRepository(t)
+
+	personID, err := persons.CreatePerson(ctx, person.Person{Name: getPointerOnString("test")})
+	require.NoError(t, err)
+	id, err := r.CreateOrganization(ctx, Organization{Name: getPointerOnString("test")})
+	require.NoError(t, err)
+	_, err = r.CreateEmployment(ctx, Employment{PersonID: personID, OrganizationID: id})
+	require.NoError(t, err)
+
+	_, err = testDB.Exec("DELETE FROM persons WHERE id = $1", personID)
+	require.NoError(t, err)
+
+	employments, err := r.GetOrganizationEmployments(ctx, id)
+	require.NoError(t, err)
+	require.Empty(t, employments)
+}
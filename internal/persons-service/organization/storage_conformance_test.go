@@ -0,0 +1,300 @@
+package organization
+
+import (
+	"context"
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/person"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testPersons is the person storage next to the organization storage under test, to create
+// the persons to employ and read their work field.
+type testPersons interface {
+	CreatePerson(ctx context.Context, person person.Person) (int, error)
+	GetPerson(ctx context.Context, id int, fields ...string) (person.Person, error)
+}
+
+// testStorageConformance checks the behaviour every storage backend must share. newStorage
+// must return an empty storage and the person storage it keeps the work field in.
+func testStorageConformance(t *testing.T, newStorage func(t *testing.T) (storage, testPersons)) {
+	ctx := context.Background()
+
+	personCreator := func(t *testing.T, persons testPersons) func() int {
+		return func() int {
+			id, err := persons.CreatePerson(ctx, person.Person{Name: getPointerOnString("test")})
+			require.NoError(t, err)
+			return id
+		}
+	}
+
+	work := func(t *testing.T, persons testPersons, id int) *string {
+		p, err := persons.GetPerson(ctx, id)
+		require.NoError(t, err)
+		return p.Work
+	}
+
+	date := func(year int) *time.Time {
+		d := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+		return &d
+	}
+
+	createOrganization := func(t *testing.T, s storage, name string) int {
+		id, err := s.CreateOrganization(ctx, Organization{Name: getPointerOnString(name)})
+		require.NoError(t, err)
+		return id
+	}
+
+	t.Run("create and get", func(t *testing.T) {
+		s, _ := newStorage(t)
+
+		id := createOrganization(t, s, "test")
+
+		got, err := s.GetOrganization(ctx, id)
+		require.NoError(t, err)
+		require.Equal(t, id, *got.ID)
+		require.Equal(t, "test", *got.Name)
+		require.NotNil(t, got.CreatedAt)
+		require.NotNil(t, got.UpdatedAt)
+
+		got, err = s.GetOrganization(ctx, id+1)
+		require.NoError(t, err)
+		require.Nil(t, got.ID)
+	})
+
+	t.Run("unique name", func(t *testing.T) {
+		s, _ := newStorage(t)
+
+		createOrganization(t, s, "first")
+		id := createOrganization(t, s, "second")
+
+		_, err := s.CreateOrganization(ctx, Organization{Name: getPointerOnString("first")})
+		require.ErrorIs(t, err, ErrExists)
+
+		_, err = s.UpdateOrganization(ctx, id, &Organization{Name: getPointerOnString("first")})
+		require.ErrorIs(t, err, ErrExists)
+	})
+
+	t.Run("update", func(t *testing.T) {
+		s, _ := newStorage(t)
+
+		id := createOrganization(t, s, "test")
+
+		update := Organization{Name: getPointerOnString("renamed")}
+		changed, err := s.UpdateOrganization(ctx, id, &update)
+		require.NoError(t, err)
+		require.Empty(t, changed)
+		require.Equal(t, id, *update.ID)
+		require.Equal(t, "renamed", *update.Name)
+		require.NotNil(t, update.CreatedAt)
+
+		_, err = s.UpdateOrganization(ctx, id+1, &Organization{Name: getPointerOnString("missing")})
+		require.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("list", func(t *testing.T) {
+		s, _ := newStorage(t)
+
+		organizations, err := s.GetOrganizations(ctx)
+		require.NoError(t, err)
+		require.Empty(t, organizations)
+
+		first := createOrganization(t, s, "b")
+		second := createOrganization(t, s, "a")
+
+		organizations, err = s.GetOrganizations(ctx)
+		require.NoError(t, err)
+		require.Len(t, organizations, 2)
+		require.Equal(t, first, *organizations[0].ID)
+		require.Equal(t, second, *organizations[1].ID)
+	})
+
+	t.Run("employments", func(t *testing.T) {
+		s, persons := newStorage(t)
+		newPerson := personCreator(t, persons)
+
+		personID := newPerson()
+		first := createOrganization(t, s, "first")
+		second := createOrganization(t, s, "second")
+
+		_, err := s.CreateEmployment(ctx, Employment{PersonID: personID, OrganizationID: second + 1})
+		require.ErrorIs(t, err, ErrNotFound)
+
+		oldID, err := s.CreateEmployment(ctx, Employment{
+			PersonID:       personID,
+			OrganizationID: first,
+			Role:           getPointerOnString("intern"),
+			StartDate:      date(2010),
+			EndDate:        date(2012),
+		})
+		require.NoError(t, err)
+		undatedID, err := s.CreateEmployment(ctx, Employment{PersonID: personID, OrganizationID: second})
+		require.NoError(t, err)
+		currentID, err := s.CreateEmployment(ctx, Employment{
+			PersonID:       personID,
+			OrganizationID: second,
+			Role:           getPointerOnString("engineer"),
+			StartDate:      date(2012),
+		})
+		require.NoError(t, err)
+
+		history, err := s.GetPersonEmployments(ctx, personID)
+		require.NoError(t, err)
+		require.Len(t, history, 3)
+		require.Equal(t, currentID, *history[0].ID)
+		require.Equal(t, oldID, *history[1].ID)
+		require.Equal(t, undatedID, *history[2].ID)
+		require.Equal(t, "engineer", *history[0].Role)
+		require.True(t, date(2012).Equal(*history[0].StartDate))
+		require.Nil(t, history[0].EndDate)
+		require.True(t, date(2012).Equal(*history[1].EndDate))
+		require.NotNil(t, history[0].CreatedAt)
+
+		employed, err := s.GetOrganizationEmployments(ctx, second)
+		require.NoError(t, err)
+		require.Len(t, employed, 2)
+		require.Equal(t, undatedID, *employed[0].ID)
+		require.Equal(t, currentID, *employed[1].ID)
+
+		isDeleted, err := s.DeleteEmployment(ctx, personID+1, oldID)
+		require.NoError(t, err)
+		require.False(t, isDeleted)
+
+		isDeleted, err = s.DeleteEmployment(ctx, personID, oldID)
+		require.NoError(t, err)
+		require.True(t, isDeleted)
+
+		history, err = s.GetPersonEmployments(ctx, personID)
+		require.NoError(t, err)
+		require.Len(t, history, 2)
+	})
+
+	t.Run("delete cascades employments", func(t *testing.T) {
+		s, persons := newStorage(t)
+		newPerson := personCreator(t, persons)
+
+		personID := newPerson()
+		id := createOrganization(t, s, "test")
+		_, err := s.CreateEmployment(ctx, Employment{PersonID: personID, OrganizationID: id})
+		require.NoError(t, err)
+
+		isDeleted, changed, err := s.DeleteOrganization(ctx, id)
+		require.NoError(t, err)
+		require.True(t, isDeleted)
+		require.Equal(t, []int{personID}, changed)
+		require.Nil(t, work(t, persons, personID))
+
+		isDeleted, _, err = s.DeleteOrganization(ctx, id)
+		require.NoError(t, err)
+		require.False(t, isDeleted)
+
+		history, err := s.GetPersonEmployments(ctx, personID)
+		require.NoError(t, err)
+		require.Empty(t, history)
+	})
+
+	t.Run("work follows current employments", func(t *testing.T) {
+		s, persons := newStorage(t)
+		newPerson := personCreator(t, persons)
+
+		personID, otherID := newPerson(), newPerson()
+		first := createOrganization(t, s, "first")
+		second := createOrganization(t, s, "second")
+
+		_, err := s.CreateEmployment(ctx, Employment{PersonID: personID, OrganizationID: first, StartDate: date(2010), EndDate: date(2012)})
+		require.NoError(t, err)
+		require.Nil(t, work(t, persons, personID))
+
+		olderID, err := s.CreateEmployment(ctx, Employment{PersonID: personID, OrganizationID: first, StartDate: date(2012)})
+		require.NoError(t, err)
+		require.Equal(t, "first", *work(t, persons, personID))
+		latestID, err := s.CreateEmployment(ctx, Employment{PersonID: personID, OrganizationID: second, StartDate: date(2015)})
+		require.NoError(t, err)
+		require.Equal(t, "second", *work(t, persons, personID))
+		_, err = s.CreateEmployment(ctx, Employment{PersonID: otherID, OrganizationID: first})
+		require.NoError(t, err)
+
+		changed, err := s.UpdateOrganization(ctx, first, &Organization{Name: getPointerOnString("renamed")})
+		require.NoError(t, err)
+		require.Equal(t, []int{otherID}, changed)
+		require.Equal(t, "second", *work(t, persons, personID))
+		require.Equal(t, "renamed", *work(t, persons, otherID))
+
+		isDeleted, err := s.DeleteEmployment(ctx, personID, latestID)
+		require.NoError(t, err)
+		require.True(t, isDeleted)
+		require.Equal(t, "renamed", *work(t, persons, personID))
+
+		isDeleted, err = s.DeleteEmployment(ctx, personID, olderID)
+		require.NoError(t, err)
+		require.True(t, isDeleted)
+		require.Nil(t, work(t, persons, personID))
+
+		isDeleted, changed, err = s.DeleteOrganization(ctx, second)
+		require.NoError(t, err)
+		require.True(t, isDeleted)
+		require.Empty(t, changed)
+		require.Equal(t, "renamed", *work(t, persons, otherID))
+	})
+
+	t.Run("move and delete person rows", func(t *testing.T) {
+		s, persons := newStorage(t)
+		newPerson := personCreator(t, persons)
+		rows := s.(person.Dependent)
+
+		sourceID, targetID := newPerson(), newPerson()
+		id := createOrganization(t, s, "test")
+		employmentID, err := s.CreateEmployment(ctx, Employment{PersonID: sourceID, OrganizationID: id})
+		require.NoError(t, err)
+
+		require.NoError(t, rows.MovePersonRows(ctx, sourceID, targetID))
+
+		history, err := s.GetPersonEmployments(ctx, sourceID)
+		require.NoError(t, err)
+		require.Empty(t, history)
+		history, err = s.GetPersonEmployments(ctx, targetID)
+		require.NoError(t, err)
+		require.Len(t, history, 1)
+		require.Equal(t, employmentID, *history[0].ID)
+
+		require.NoError(t, rows.DeletePersonRows(ctx, targetID))
+
+		employed, err := s.GetOrganizationEmployments(ctx, id)
+		require.NoError(t, err)
+		require.Empty(t, employed)
+	})
+
+	t.Run("cancelled context", func(t *testing.T) {
+		s, _ := newStorage(t)
+
+		cancelledCtx, cancel := context.WithCancel(ctx)
+		cancel()
+
+		_, err := s.CreateOrganization(cancelledCtx, Organization{Name: getPointerOnString("test")})
+		require.Error(t, err)
+		_, err = s.GetOrganizations(cancelledCtx)
+		require.Error(t, err)
+	})
+}
+
+func Test_MemoryRepository(t *testing.T) {
+	testStorageConformance(t, func(t *testing.T) (storage, testPersons) {
+		persons := person.NewMemoryRepository()
+		return NewMemoryRepository(persons), persons
+	})
+}
+
+func Test_BoltRepository(t *testing.T) {
+	testStorageConformance(t, func(t *testing.T) (storage, testPersons) {
+		persons, err := person.NewBoltRepository(filepath.Join(t.TempDir(), "persons.db"))
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			require.NoError(t, persons.Close())
+		})
+
+		r, err := NewBoltRepository(persons.DB())
+		require.NoError(t, err)
+		return r, persons
+	})
+}
@@ -0,0 +1,141 @@
+package person
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"encoding/xml"
+	"github.com/pkg/errors"
+	"reflect"
+)
+
+// Attributes are the custom fields of a person, any JSON object. They are stored in a jsonb
+// column and validated against the JSON Schema of the tenant.
+type Attributes map[string]interface{}
+
+// Value stores the attributes as JSON; nil attributes are NULL.
+func (a Attributes) Value() (driver.Value, error) {
+	if a == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(a)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal attributes")
+	}
+	return string(b), nil
+}
+
+func (a *Attributes) Scan(src interface{}) error {
+	var b []byte
+	switch src := src.(type) {
+	case nil:
+		*a = nil
+		return nil
+	case []byte:
+		b = src
+	case string:
+		b = []byte(src)
+	default:
+		return errors.Errorf("unsupported attributes type %T", src)
+	}
+	return errors.Wrap(json.Unmarshal(b, a), "failed to unmarshal attributes")
+}
+
+// MarshalXML writes the attributes as the JSON text of their element, as XML has no types for
+// their values.
+func (a Attributes) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	b, err := json.Marshal(a)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal attributes")
+	}
+	return e.EncodeElement(string(b), start)
+}
+
+func (a *Attributes) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var text string
+	if err := d.DecodeElement(&text, &start); err != nil {
+		return err
+	}
+	return errors.Wrap(json.Unmarshal([]byte(text), a), "failed to unmarshal attributes")
+}
+
+// cloneAttributes returns a deep copy of a.
+func cloneAttributes(a Attributes) Attributes {
+	if a == nil {
+		return nil
+	}
+	return cloneJSON(map[string]interface{}(a)).(map[string]interface{})
+}
+
+func cloneJSON(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		c := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			c[k] = cloneJSON(e)
+		}
+		return c
+	case []interface{}:
+		c := make([]interface{}, len(v))
+		for i, e := range v {
+			c[i] = cloneJSON(e)
+		}
+		return c
+	}
+	return v
+}
+
+// containsAttributes reports whether a contains match the way the jsonb @> operator does:
+// objects contain the keys of match with contained values, arrays contain each element of
+// match, other values are equal.
+func containsAttributes(a, match Attributes) bool {
+	return containsJSON(normalizeJSON(a), normalizeJSON(match))
+}
+
+// normalizeJSON converts v to the values encoding/json decodes, e.g. float64 for all numbers.
+func normalizeJSON(v interface{}) interface{} {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var res interface{}
+	if err = json.Unmarshal(b, &res); err != nil {
+		return v
+	}
+	return res
+}
+
+func containsJSON(v, match interface{}) bool {
+	switch match := match.(type) {
+	case map[string]interface{}:
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for k, m := range match {
+			e, ok := obj[k]
+			if !ok || !containsJSON(e, m) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		arr, ok := v.([]interface{})
+		if !ok {
+			return false
+		}
+		for _, m := range match {
+			found := false
+			for _, e := range arr {
+				if containsJSON(e, m) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(v, match)
+}
@@ -0,0 +1,179 @@
+package person
+
+import (
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/config"
+	"github.com/Erlendum/rsoi-lab-01/pkg/validation"
+	"github.com/go-playground/validator/v10"
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_Attributes_ValueScan(t *testing.T) {
+	v, err := Attributes(nil).Value()
+	require.NoError(t, err)
+	require.Nil(t, v)
+
+	v, err = Attributes{"team": "core", "level": 3}.Value()
+	require.NoError(t, err)
+	require.JSONEq(t, `{"team": "core", "level": 3}`, v.(string))
+
+	var a Attributes
+	require.NoError(t, a.Scan([]byte(`{"team": "core", "skills": ["go"]}`)))
+	require.Equal(t, Attributes{"team": "core", "skills": []interface{}{"go"}}, a)
+
+	require.NoError(t, a.Scan(nil))
+	require.Nil(t, a)
+
+	require.Error(t, a.Scan(1))
+}
+
+func Test_containsAttributes(t *testing.T) {
+	a := Attributes{
+		"team":   "core",
+		"level":  3,
+		"skills": []interface{}{"go", "sql"},
+		"office": map[string]interface{}{"city": "Moscow", "floor": 2},
+	}
+
+	tests := []struct {
+		name     string
+		match    Attributes
+		expected bool
+	}{
+		{name: "empty", match: Attributes{}, expected: true},
+		{name: "string", match: Attributes{"team": "core"}, expected: true},
+		{name: "other string", match: Attributes{"team": "Core"}, expected: false},
+		{name: "number of other type", match: Attributes{"level": float64(3)}, expected: true},
+		{name: "array element", match: Attributes{"skills": []interface{}{"sql"}}, expected: true},
+		{name: "missing array element", match: Attributes{"skills": []interface{}{"java"}}, expected: false},
+		{name: "nested object", match: Attributes{"office": map[string]interface{}{"city": "Moscow"}}, expected: true},
+		{name: "scalar is not an array", match: Attributes{"team": []interface{}{"core"}}, expected: false},
+		{name: "missing key", match: Attributes{"region": "EU"}, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, containsAttributes(a, tt.match))
+		})
+	}
+}
+
+func Test_mergePersons_AttributesAndTags(t *testing.T) {
+	merged := mergePersons(
+		Person{Attributes: Attributes{"team": "core"}, Tags: pq.StringArray{"vip"}},
+		Person{Attributes: Attributes{"team": "sales", "level": 3}, Tags: pq.StringArray{"beta", "vip"}},
+	)
+	require.Equal(t, Attributes{"team": "core", "level": 3}, merged.Attributes)
+	require.Equal(t, pq.StringArray{"vip", "beta"}, merged.Tags)
+
+	require.Nil(t, mergePersons(Person{}, Person{}).Attributes)
+}
+
+func Test_CreatePerson_Attributes(t *testing.T) {
+	schema := filepath.Join(t.TempDir(), "sales.schema.json")
+	require.NoError(t, os.WriteFile(schema, []byte(`{
+		"type": "object",
+		"properties": {"region": {"enum": ["EU", "US"]}},
+		"required": ["region"]
+	}`), 0o600))
+
+	e := echo.New()
+	e.Validator = validation.MustRegisterCustomValidator(validator.New())
+
+	tests := []struct {
+		name                 string
+		tenant               string
+		reqBody              string
+		expectedHTTPCode     int
+		expectedResponseBody string
+		Prepare              func(fields *handlerTestFields)
+	}{
+		{
+			name:             "http-code 400: too many tags",
+			reqBody:          `{"name": "test", "tags": ["1","2","3","4","5","6","7","8","9","10","11","12","13","14","15","16","17","18","19","20","21"]}`,
+			expectedHTTPCode: http.StatusBadRequest,
+			Prepare:          func(fields *handlerTestFields) {},
+		},
+		{
+			name:             "http-code 400: empty tag",
+			reqBody:          `{"name": "test", "tags": [""]}`,
+			expectedHTTPCode: http.StatusBadRequest,
+			Prepare:          func(fields *handlerTestFields) {},
+		},
+		{
+			name:             "http-code 400: attributes break the tenant schema",
+			tenant:           "sales",
+			reqBody:          `{"name": "test", "attributes": {"region": "ASIA"}}`,
+			expectedHTTPCode: http.StatusBadRequest,
+			expectedResponseBody: `{"errors":"validation error","fields":{"attributes.region":"value must be one of \"EU\", \"US\""}}
+`,
+			Prepare: func(fields *handlerTestFields) {},
+		},
+		{
+			name:             "http-code 201: tenant without schema",
+			tenant:           "hr",
+			reqBody:          `{"name": "test", "attributes": {"region": "ASIA"}, "tags": ["vip", "vip"]}`,
+			expectedHTTPCode: http.StatusCreated,
+			Prepare: func(fields *handlerTestFields) {
+				fields.storage.EXPECT().CreatePerson(gomock.Any(), Person{
+					Name:       getPointerOnString("test"),
+					Attributes: Attributes{"region": "ASIA"},
+					Tags:       pq.StringArray{"vip"},
+				}).Return(1, nil)
+				fields.storage.EXPECT().GetPerson(gomock.Any(), 1).Return(Person{ID: getPointerOnInt(1), Name: getPointerOnString("test")}, nil)
+			},
+		},
+		{
+			name:             "http-code 201: attributes conform",
+			tenant:           "sales",
+			reqBody:          `{"name": "test", "attributes": {"region": "EU"}}`,
+			expectedHTTPCode: http.StatusCreated,
+			Prepare: func(fields *handlerTestFields) {
+				fields.storage.EXPECT().CreatePerson(gomock.Any(), gomock.Any()).Return(1, nil)
+				fields.storage.EXPECT().GetPerson(gomock.Any(), 1).Return(Person{ID: getPointerOnInt(1), Name: getPointerOnString("test")}, nil)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			testFields := createHandlerTestFields(ctrl)
+			tt.Prepare(testFields)
+
+			h, err := NewHandler(testFields.storage, &config.Persons{
+				DuplicateThreshold: defaultDuplicateThreshold,
+				Attributes: config.Attributes{
+					TenantHeader: "X-Tenant-ID",
+					Schemas:      map[string]string{"sales": schema},
+				},
+			})
+			require.NoError(t, err)
+
+			req := httptest.NewRequest(http.MethodPost, "/test", strings.NewReader(tt.reqBody))
+			req.Header.Set("X-Tenant-ID", tt.tenant)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			err = h.CreatePerson(c)
+
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedHTTPCode, rec.Code)
+			if tt.expectedResponseBody != "" {
+				body, err := io.ReadAll(rec.Result().Body)
+				require.NoError(t, err)
+				require.Equal(t, tt.expectedResponseBody, string(body))
+			}
+		})
+	}
+}
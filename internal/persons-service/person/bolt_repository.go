@@ -0,0 +1,692 @@
+package person
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/pgutil"
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+	"slices"
+	"time"
+)
+
+var (
+	personsBucket = []byte("persons")
+	// aliasesBucket maps the ids of merged persons to the person they were merged into.
+	aliasesBucket   = []byte("aliases")
+	relationsBucket = []byte("relations")
+)
+
+// boltRepository stores persons in an embedded bbolt file, one JSON document per key.
+type boltRepository struct {
+	db     *bolt.DB
+	unique []uniqueConstraint
+}
+
+func NewBoltRepository(path string) (*boltRepository, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: defaultTimeout})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open bolt database")
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{personsBucket, aliasesBucket, relationsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, errors.Wrap(err, "failed to create buckets")
+	}
+
+	return &boltRepository{db: db}, nil
+}
+
+// DB returns the underlying bbolt database, for storages that share the file.
+func (r *boltRepository) DB() *bolt.DB {
+	return r.db
+}
+
+func (r *boltRepository) Close() error {
+	return r.db.Close()
+}
+
+func boltKey(id int) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(id))
+	return key
+}
+
+func boltDecode(key, value []byte) (Person, error) {
+	p := Person{}
+	if err := json.Unmarshal(value, &p); err != nil {
+		return Person{}, errors.Wrap(err, "failed to decode person")
+	}
+
+	id := int(binary.BigEndian.Uint64(key))
+	p.ID = &id
+	return p, nil
+}
+
+func boltEncode(p Person) ([]byte, error) {
+	p.ID = nil
+	value, err := json.Marshal(p)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode person")
+	}
+	return value, nil
+}
+
+// BoltSetWork sets the work of the person in tx, or clears it for nil, and reports whether it
+// changed. Storages sharing the file call it to change the work in their own transaction. A
+// missing person is ignored.
+func BoltSetWork(tx *bolt.Tx, id int, work *string) (bool, error) {
+	b := tx.Bucket(personsBucket)
+	key := boltKey(id)
+	value := b.Get(key)
+	if value == nil {
+		return false, nil
+	}
+
+	stored, err := boltDecode(key, value)
+	if err != nil {
+		return false, err
+	}
+	if equalPointers(stored.Work, work) {
+		return false, nil
+	}
+
+	now := time.Now().UTC()
+	stored.Work = clonePointer(work)
+	stored.UpdatedAt = &now
+	value, err = boltEncode(stored)
+	if err != nil {
+		return false, err
+	}
+	return true, b.Put(key, value)
+}
+
+// SetUniqueConstraints checks the constraints in the bolt transaction a person is written in.
+func (r *boltRepository) SetUniqueConstraints(fields [][]string) error {
+	constraints, err := newUniqueConstraints(fields)
+	if err != nil {
+		return err
+	}
+	r.unique = constraints
+	return nil
+}
+
+// findConflict checks the constraints for p in the transaction it is written in.
+func (r *boltRepository) findConflict(tx *bolt.Tx, p Person, excludeID int) error {
+	return findConflict(r.unique, p, excludeID, func(match Person) ([]Person, error) {
+		return boltFindPersons(tx, match, Page{}, nil)
+	})
+}
+
+func (r *boltRepository) CreatePerson(ctx context.Context, person Person) (int, error) {
+	if err := pgutil.CheckContext(ctx); err != nil {
+		return 0, err
+	}
+	if person.Name == nil {
+		return 0, errors.New("name is required")
+	}
+
+	now := time.Now().UTC()
+	person.CreatedAt, person.UpdatedAt = &now, &now
+	value, err := boltEncode(person)
+	if err != nil {
+		return 0, err
+	}
+
+	var id int
+	err = r.db.Update(func(tx *bolt.Tx) error {
+		if err := r.findConflict(tx, person, 0); err != nil {
+			return err
+		}
+
+		b := tx.Bucket(personsBucket)
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		id = int(seq)
+		return b.Put(boltKey(id), value)
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to create person")
+	}
+
+	return id, nil
+}
+
+func (r *boltRepository) UpdatePerson(ctx context.Context, id int, person *Person) error {
+	if err := pgutil.CheckContext(ctx); err != nil {
+		return err
+	}
+	if isEmptyUpdate(*person) {
+		return nil
+	}
+
+	var updated Person
+	err := r.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(personsBucket)
+		key := boltKey(id)
+		value := b.Get(key)
+		if value == nil {
+			return ErrNotFound
+		}
+
+		stored, err := boltDecode(key, value)
+		if err != nil {
+			return err
+		}
+
+		updated = applyUpdate(stored, *person)
+		if err = r.findConflict(tx, updated, id); err != nil {
+			return err
+		}
+
+		now := time.Now().UTC()
+		updated.UpdatedAt = &now
+		value, err = boltEncode(updated)
+		if err != nil {
+			return err
+		}
+		return b.Put(key, value)
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to update person")
+	}
+
+	*person = updated
+	return nil
+}
+
+func (r *boltRepository) DeletePerson(ctx context.Context, id int) (bool, error) {
+	if err := pgutil.CheckContext(ctx); err != nil {
+		return false, err
+	}
+
+	isDeleted := false
+	err := r.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(personsBucket)
+		key := boltKey(id)
+		if b.Get(key) == nil {
+			return nil
+		}
+		isDeleted = true
+		if err := b.Delete(key); err != nil {
+			return err
+		}
+		if err := repointAliases(tx, key, nil); err != nil {
+			return err
+		}
+		return deletePersonRelations(tx, id)
+	})
+	if err != nil {
+		return false, errors.Wrap(err, "failed to delete person")
+	}
+
+	return isDeleted, nil
+}
+
+func (r *boltRepository) GetPersons(ctx context.Context, page Page, fields ...string) ([]Person, error) {
+	return r.findPersons(ctx, Person{}, page, fields)
+}
+
+func (r *boltRepository) GetPerson(ctx context.Context, id int, fields ...string) (Person, error) {
+	if err := pgutil.CheckContext(ctx); err != nil {
+		return Person{}, err
+	}
+
+	res := Person{}
+	err := r.db.View(func(tx *bolt.Tx) error {
+		key := boltKey(id)
+		value := tx.Bucket(personsBucket).Get(key)
+		if value == nil {
+			return nil
+		}
+
+		var err error
+		res, err = boltDecode(key, value)
+		return err
+	})
+	if err != nil {
+		return Person{}, errors.Wrap(err, "failed to get person")
+	}
+
+	return projectPerson(res, fields), nil
+}
+
+func (r *boltRepository) GetPersonsByIDs(ctx context.Context, ids []int) ([]Person, error) {
+	if err := pgutil.CheckContext(ctx); err != nil {
+		return []Person{}, err
+	}
+
+	res := make([]Person, 0, len(ids))
+	err := r.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(personsBucket)
+		for _, id := range distinctIDs(ids) {
+			key := boltKey(id)
+			value := b.Get(key)
+			if value == nil {
+				continue
+			}
+
+			p, err := boltDecode(key, value)
+			if err != nil {
+				return err
+			}
+			res = append(res, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return []Person{}, errors.Wrap(err, "failed to get persons")
+	}
+
+	return res, nil
+}
+
+// repointAliases moves the aliases of the person with key from to the person with key to, or
+// deletes them when to is nil.
+func repointAliases(tx *bolt.Tx, from, to []byte) error {
+	b := tx.Bucket(aliasesBucket)
+	aliasKeys := make([][]byte, 0)
+	err := b.ForEach(func(key, value []byte) error {
+		if bytes.Equal(value, from) {
+			aliasKeys = append(aliasKeys, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, key := range aliasKeys {
+		if to == nil {
+			err = b.Delete(key)
+		} else {
+			err = b.Put(key, to)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *boltRepository) FindPersons(ctx context.Context, match Person, page Page, fields ...string) ([]Person, error) {
+	return r.findPersons(ctx, match, page, fields)
+}
+
+func (r *boltRepository) CountPersons(ctx context.Context, match Person) (int, error) {
+	persons, err := r.findPersons(ctx, match, Page{}, nil)
+	return len(persons), err
+}
+
+func (r *boltRepository) FindDuplicateCandidates(ctx context.Context, p Person, limit int) ([]Person, error) {
+	if err := pgutil.CheckContext(ctx); err != nil {
+		return []Person{}, err
+	}
+
+	res := make([]Person, 0)
+	err := r.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(personsBucket).Cursor()
+		for key, value := c.First(); key != nil && len(res) < limit; key, value = c.Next() {
+			candidate, err := boltDecode(key, value)
+			if err != nil {
+				return err
+			}
+			if *candidate.ID != *p.ID && isDuplicateCandidate(p, candidate) {
+				res = append(res, candidate)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return []Person{}, errors.Wrap(err, "failed to get persons")
+	}
+
+	return res, nil
+}
+
+// findPersons returns the page of the persons matching match with the columns of fields. Keys
+// are big endian IDs, so the cursor walks the persons in ID order and stops at the end of the
+// page.
+func (r *boltRepository) findPersons(ctx context.Context, match Person, page Page, fields []string) ([]Person, error) {
+	if err := pgutil.CheckContext(ctx); err != nil {
+		return []Person{}, err
+	}
+
+	var res []Person
+	err := r.db.View(func(tx *bolt.Tx) error {
+		var err error
+		res, err = boltFindPersons(tx, match, page, fields)
+		return err
+	})
+	if err != nil {
+		return []Person{}, errors.Wrap(err, "failed to get persons")
+	}
+
+	return res, nil
+}
+
+// boltFindPersons walks the persons in tx in key order, so by ID, and stops at the end of page.
+func boltFindPersons(tx *bolt.Tx, match Person, page Page, fields []string) ([]Person, error) {
+	res := make([]Person, 0)
+	skipped := 0
+	c := tx.Bucket(personsBucket).Cursor()
+	for key, value := c.First(); key != nil; key, value = c.Next() {
+		if page.Limit > 0 && len(res) == page.Limit {
+			break
+		}
+
+		p, err := boltDecode(key, value)
+		if err != nil {
+			return nil, err
+		}
+		if !matchesPerson(p, match) {
+			continue
+		}
+		if skipped < page.Offset {
+			skipped++
+			continue
+		}
+		res = append(res, projectPerson(p, fields))
+	}
+	return res, nil
+}
+
+func (r *boltRepository) MergePersons(ctx context.Context, id, sourceID int) (Person, error) {
+	if err := pgutil.CheckContext(ctx); err != nil {
+		return Person{}, err
+	}
+
+	var merged Person
+	err := r.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(personsBucket)
+		key, sourceKey := boltKey(id), boltKey(sourceID)
+		value, sourceValue := b.Get(key), b.Get(sourceKey)
+		if value == nil || sourceValue == nil {
+			return ErrNotFound
+		}
+
+		target, err := boltDecode(key, value)
+		if err != nil {
+			return err
+		}
+		source, err := boltDecode(sourceKey, sourceValue)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now().UTC()
+		merged = mergePersons(target, source)
+		merged.UpdatedAt = &now
+		value, err = boltEncode(merged)
+		if err != nil {
+			return err
+		}
+		if err = b.Put(key, value); err != nil {
+			return err
+		}
+
+		if err = b.Delete(sourceKey); err != nil {
+			return err
+		}
+		if err = repointAliases(tx, sourceKey, key); err != nil {
+			return err
+		}
+		if err = tx.Bucket(aliasesBucket).Put(sourceKey, key); err != nil {
+			return err
+		}
+
+		relations, err := boltRelations(tx)
+		if err != nil {
+			return err
+		}
+		rb := tx.Bucket(relationsBucket)
+		for _, relation := range relations {
+			if err = rb.Delete(boltKey(*relation.ID)); err != nil {
+				return err
+			}
+		}
+		for _, relation := range mergeRelations(relations, id, sourceID) {
+			if err = putBoltRelation(rb, relation); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return Person{}, errors.Wrap(err, "failed to merge persons")
+	}
+
+	return merged, nil
+}
+
+// PersonsChanged has nothing to do, the storages changing persons behind it store the changes
+// themselves.
+func (r *boltRepository) PersonsChanged(ctx context.Context, ids []int) {}
+
+func (r *boltRepository) GetPersonAlias(ctx context.Context, aliasID int) (int, error) {
+	if err := pgutil.CheckContext(ctx); err != nil {
+		return 0, err
+	}
+
+	id := 0
+	err := r.db.View(func(tx *bolt.Tx) error {
+		if value := tx.Bucket(aliasesBucket).Get(boltKey(aliasID)); value != nil {
+			id = int(binary.BigEndian.Uint64(value))
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to get person alias")
+	}
+
+	return id, nil
+}
+
+func boltRelations(tx *bolt.Tx) ([]Relation, error) {
+	res := make([]Relation, 0)
+	err := tx.Bucket(relationsBucket).ForEach(func(key, value []byte) error {
+		relation := Relation{}
+		if err := json.Unmarshal(value, &relation); err != nil {
+			return errors.Wrap(err, "failed to decode relation")
+		}
+
+		id := int(binary.BigEndian.Uint64(key))
+		relation.ID = &id
+		res = append(res, relation)
+		return nil
+	})
+	return res, err
+}
+
+func putBoltRelation(b *bolt.Bucket, relation Relation) error {
+	key := boltKey(*relation.ID)
+	relation.ID = nil
+	value, err := json.Marshal(relation)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode relation")
+	}
+	return b.Put(key, value)
+}
+
+func deletePersonRelations(tx *bolt.Tx, id int) error {
+	relations, err := boltRelations(tx)
+	if err != nil {
+		return err
+	}
+
+	b := tx.Bucket(relationsBucket)
+	for _, relation := range relations {
+		if relation.PersonID == id || relation.RelatedPersonID == id {
+			if err = b.Delete(boltKey(*relation.ID)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (r *boltRepository) CreateRelation(ctx context.Context, relation Relation) (int, error) {
+	if err := pgutil.CheckContext(ctx); err != nil {
+		return 0, err
+	}
+	if relation.PersonID == relation.RelatedPersonID {
+		return 0, errors.New("person can not be related to itself")
+	}
+
+	var id int
+	err := r.db.Update(func(tx *bolt.Tx) error {
+		persons := tx.Bucket(personsBucket)
+		if persons.Get(boltKey(relation.PersonID)) == nil || persons.Get(boltKey(relation.RelatedPersonID)) == nil {
+			return ErrNotFound
+		}
+
+		relations, err := boltRelations(tx)
+		if err != nil {
+			return err
+		}
+		for _, stored := range relations {
+			if stored.PersonID == relation.PersonID && stored.RelatedPersonID == relation.RelatedPersonID && stored.Type == relation.Type {
+				return ErrRelationExists
+			}
+		}
+
+		b := tx.Bucket(relationsBucket)
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		id = int(seq)
+		now := time.Now().UTC()
+		relation.ID, relation.CreatedAt = &id, &now
+		return putBoltRelation(b, relation)
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to create relation")
+	}
+
+	return id, nil
+}
+
+func (r *boltRepository) GetRelations(ctx context.Context, personID int) ([]Relation, error) {
+	if err := pgutil.CheckContext(ctx); err != nil {
+		return []Relation{}, err
+	}
+
+	res := make([]Relation, 0)
+	err := r.db.View(func(tx *bolt.Tx) error {
+		relations, err := boltRelations(tx)
+		if err != nil {
+			return err
+		}
+		for _, relation := range relations {
+			if relation.involves(personID) {
+				res = append(res, relation)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return []Relation{}, errors.Wrap(err, "failed to get relations")
+	}
+
+	return res, nil
+}
+
+func (r *boltRepository) GetRelationsByPersonIDs(ctx context.Context, personIDs []int) ([]Relation, error) {
+	if err := pgutil.CheckContext(ctx); err != nil {
+		return []Relation{}, err
+	}
+
+	res := make([]Relation, 0)
+	err := r.db.View(func(tx *bolt.Tx) error {
+		relations, err := boltRelations(tx)
+		if err != nil {
+			return err
+		}
+		for _, relation := range relations {
+			if slices.ContainsFunc(personIDs, relation.involves) {
+				res = append(res, relation)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return []Relation{}, errors.Wrap(err, "failed to get relations")
+	}
+
+	return res, nil
+}
+
+func (r *boltRepository) DeleteRelation(ctx context.Context, personID, relationID int) (bool, error) {
+	if err := pgutil.CheckContext(ctx); err != nil {
+		return false, err
+	}
+
+	isDeleted := false
+	err := r.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(relationsBucket)
+		key := boltKey(relationID)
+		value := b.Get(key)
+		if value == nil {
+			return nil
+		}
+
+		relation := Relation{}
+		if err := json.Unmarshal(value, &relation); err != nil {
+			return errors.Wrap(err, "failed to decode relation")
+		}
+		if !relation.involves(personID) {
+			return nil
+		}
+		isDeleted = true
+		return b.Delete(key)
+	})
+	if err != nil {
+		return false, errors.Wrap(err, "failed to delete relation")
+	}
+
+	return isDeleted, nil
+}
+
+func (r *boltRepository) GetRelatives(ctx context.Context, personID, maxDepth int, types []string) ([]Relative, error) {
+	if err := pgutil.CheckContext(ctx); err != nil {
+		return []Relative{}, err
+	}
+
+	res := make([]Relative, 0)
+	err := r.db.View(func(tx *bolt.Tx) error {
+		relations, err := boltRelations(tx)
+		if err != nil {
+			return err
+		}
+
+		persons := tx.Bucket(personsBucket)
+		for id, depth := range walkRelations(relations, personID, maxDepth, types) {
+			key := boltKey(id)
+			p, err := boltDecode(key, persons.Get(key))
+			if err != nil {
+				return err
+			}
+			res = append(res, Relative{Person: p, Depth: depth})
+		}
+		return nil
+	})
+	if err != nil {
+		return []Relative{}, errors.Wrap(err, "failed to get relatives")
+	}
+	sortRelatives(res)
+
+	return res, nil
+}
@@ -0,0 +1,251 @@
+package person
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/Erlendum/rsoi-lab-01/pkg/cache"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog"
+	"golang.org/x/sync/singleflight"
+	"sync/atomic"
+	"time"
+)
+
+const personsCacheKey = "persons"
+
+func personCacheKey(id int) string {
+	return fmt.Sprintf("person:%d", id)
+}
+
+// cachedStorage is a read-through cache in front of a storage. Concurrent misses for the same
+// key are coalesced into one storage call, and writes invalidate the affected keys.
+type cachedStorage struct {
+	storage storage
+	cache   cache.Cache
+	ttl     time.Duration
+	skip    func(ctx context.Context) bool
+	group   singleflight.Group
+	// generation is bumped by every write, so a read that started before the write does not
+	// put a stale value back into the cache after the invalidation.
+	generation atomic.Uint64
+	requests   *prometheus.CounterVec
+}
+
+func NewCachedStorage(storage storage, cache cache.Cache, ttl time.Duration, reg prometheus.Registerer) *cachedStorage {
+	requests := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "person_cache_requests_total",
+		Help: "Number of person cache lookups by operation and result.",
+	}, []string{"operation", "result"})
+	reg.MustRegister(requests)
+
+	return &cachedStorage{
+		storage:  storage,
+		cache:    cache,
+		ttl:      ttl,
+		requests: requests,
+	}
+}
+
+// SkipWhen sends the reads for which skip reports true straight to the storage, neither
+// reading nor filling the cache. A session reading its own writes from the primary skips it,
+// as the cache may hold what a lagging replica returned before the write.
+func (s *cachedStorage) SkipWhen(skip func(ctx context.Context) bool) {
+	s.skip = skip
+}
+
+func (s *cachedStorage) CreatePerson(ctx context.Context, person Person) (int, error) {
+	id, err := s.storage.CreatePerson(ctx, person)
+	s.invalidate(ctx, personsCacheKey)
+	return id, err
+}
+
+func (s *cachedStorage) UpdatePerson(ctx context.Context, id int, person *Person) error {
+	err := s.storage.UpdatePerson(ctx, id, person)
+	s.invalidate(ctx, personCacheKey(id), personsCacheKey)
+	return err
+}
+
+func (s *cachedStorage) DeletePerson(ctx context.Context, id int) (bool, error) {
+	isDeleted, err := s.storage.DeletePerson(ctx, id)
+	s.invalidate(ctx, personCacheKey(id), personsCacheKey)
+	return isDeleted, err
+}
+
+// GetPersons and GetPerson cache whole persons. Projected reads go to the storage, which loads
+// only the columns of their fields, rather than loading and caching whole persons for them.
+// Only the list of every person is cached; pages go to the storage, as a write would have to
+// drop every cached page.
+func (s *cachedStorage) GetPersons(ctx context.Context, page Page, fields ...string) ([]Person, error) {
+	if page != (Page{}) || len(fields) > 0 || s.skipped(ctx, "GetPersons") {
+		return s.storage.GetPersons(ctx, page, fields...)
+	}
+
+	persons := make([]Person, 0)
+	err := s.get(ctx, "GetPersons", personsCacheKey, &persons, func(ctx context.Context) (interface{}, bool, error) {
+		persons, err := s.storage.GetPersons(ctx, Page{})
+		return persons, err == nil, err
+	})
+	if err != nil {
+		return []Person{}, err
+	}
+	return persons, nil
+}
+
+func (s *cachedStorage) GetPerson(ctx context.Context, id int, fields ...string) (Person, error) {
+	if len(fields) > 0 || s.skipped(ctx, "GetPerson") {
+		return s.storage.GetPerson(ctx, id, fields...)
+	}
+
+	p := Person{}
+	err := s.get(ctx, "GetPerson", personCacheKey(id), &p, func(ctx context.Context) (interface{}, bool, error) {
+		p, err := s.storage.GetPerson(ctx, id)
+		if err != nil {
+			return nil, false, err
+		}
+		// Missing persons are not cached, so a later create is visible at once.
+		return p, p.ID != nil, nil
+	})
+	if err != nil {
+		return Person{}, err
+	}
+	return p, nil
+}
+
+// GetPersonsByIDs is not cached; batched lookups already load each person once per request.
+func (s *cachedStorage) GetPersonsByIDs(ctx context.Context, ids []int) ([]Person, error) {
+	return s.storage.GetPersonsByIDs(ctx, ids)
+}
+
+// FindPersons, CountPersons and FindDuplicateCandidates are not cached, the cache holds single
+// persons and the whole unfiltered list only.
+func (s *cachedStorage) FindPersons(ctx context.Context, match Person, page Page, fields ...string) ([]Person, error) {
+	return s.storage.FindPersons(ctx, match, page, fields...)
+}
+
+func (s *cachedStorage) CountPersons(ctx context.Context, match Person) (int, error) {
+	return s.storage.CountPersons(ctx, match)
+}
+
+func (s *cachedStorage) FindDuplicateCandidates(ctx context.Context, p Person, limit int) ([]Person, error) {
+	return s.storage.FindDuplicateCandidates(ctx, p, limit)
+}
+
+func (s *cachedStorage) MergePersons(ctx context.Context, id, sourceID int) (Person, error) {
+	p, err := s.storage.MergePersons(ctx, id, sourceID)
+	s.invalidate(ctx, personCacheKey(id), personCacheKey(sourceID), personsCacheKey)
+	return p, err
+}
+
+// PersonsChanged drops the persons other storages changed, e.g. the work of the employees of a
+// renamed organization.
+func (s *cachedStorage) PersonsChanged(ctx context.Context, ids []int) {
+	s.storage.PersonsChanged(ctx, ids)
+	keys := []string{personsCacheKey}
+	for _, id := range ids {
+		keys = append(keys, personCacheKey(id))
+	}
+	s.invalidate(ctx, keys...)
+}
+
+func (s *cachedStorage) GetPersonAlias(ctx context.Context, aliasID int) (int, error) {
+	return s.storage.GetPersonAlias(ctx, aliasID)
+}
+
+// Relations are not cached; they change together with the persons they link.
+func (s *cachedStorage) CreateRelation(ctx context.Context, relation Relation) (int, error) {
+	return s.storage.CreateRelation(ctx, relation)
+}
+
+func (s *cachedStorage) GetRelations(ctx context.Context, personID int) ([]Relation, error) {
+	return s.storage.GetRelations(ctx, personID)
+}
+
+func (s *cachedStorage) GetRelationsByPersonIDs(ctx context.Context, personIDs []int) ([]Relation, error) {
+	return s.storage.GetRelationsByPersonIDs(ctx, personIDs)
+}
+
+func (s *cachedStorage) DeleteRelation(ctx context.Context, personID, relationID int) (bool, error) {
+	return s.storage.DeleteRelation(ctx, personID, relationID)
+}
+
+func (s *cachedStorage) GetRelatives(ctx context.Context, personID, maxDepth int, types []string) ([]Relative, error) {
+	return s.storage.GetRelatives(ctx, personID, maxDepth, types)
+}
+
+// skipped reports whether the read bypasses the cache and counts it if so.
+func (s *cachedStorage) skipped(ctx context.Context, operation string) bool {
+	if s.skip == nil || !s.skip(ctx) {
+		return false
+	}
+	s.requests.WithLabelValues(operation, "skip").Inc()
+	return true
+}
+
+// get decodes the cached value of key into dst, or loads it with load and caches it if load
+// reports it as cacheable. Cache failures are logged and treated as misses.
+//
+// A load is shared by every caller missing the key meanwhile, so it runs detached from the
+// cancellation of the caller that started it, under a timeout of its own; each caller stops
+// waiting for it when its own context is done.
+func (s *cachedStorage) get(ctx context.Context, operation, key string, dst interface{}, load func(ctx context.Context) (interface{}, bool, error)) error {
+	logger := zerolog.Ctx(ctx)
+
+	value, ok, err := s.cache.Get(ctx, key)
+	if err != nil {
+		logger.Warn().Err(err).Str("key", key).Msg("cache get error")
+	}
+	if ok {
+		if err = json.Unmarshal(value, dst); err == nil {
+			s.requests.WithLabelValues(operation, "hit").Inc()
+			return nil
+		}
+		logger.Warn().Err(err).Str("key", key).Msg("cache decode error")
+	}
+	s.requests.WithLabelValues(operation, "miss").Inc()
+
+	shared := s.group.DoChan(key, func() (interface{}, error) {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), defaultTimeout)
+		defer cancel()
+
+		generation := s.generation.Load()
+
+		res, cacheable, err := load(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		encoded, err := json.Marshal(res)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to encode cache value")
+		}
+
+		if cacheable && s.generation.Load() == generation {
+			if err = s.cache.Set(ctx, key, encoded, s.ttl); err != nil {
+				logger.Warn().Err(err).Str("key", key).Msg("cache set error")
+			}
+		}
+		return encoded, nil
+	})
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case res := <-shared:
+		if res.Err != nil {
+			return res.Err
+		}
+		return json.Unmarshal(res.Val.([]byte), dst)
+	}
+}
+
+func (s *cachedStorage) invalidate(ctx context.Context, keys ...string) {
+	s.generation.Add(1)
+	for _, key := range keys {
+		s.group.Forget(key)
+	}
+	if err := s.cache.Delete(ctx, keys...); err != nil {
+		zerolog.Ctx(ctx).Warn().Err(err).Strs("keys", keys).Msg("cache invalidation error")
+	}
+}
@@ -0,0 +1,163 @@
+package person
+
+import (
+	"context"
+	"github.com/Erlendum/rsoi-lab-01/pkg/cache"
+	"github.com/golang/mock/gomock"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_CachedStorage(t *testing.T) {
+	testStorageConformance(t, func(t *testing.T) storage {
+		return NewCachedStorage(NewMemoryRepository(), cache.NewLRU(100), time.Minute, prometheus.NewRegistry())
+	})
+}
+
+func Test_CachedStorage_ReadThrough(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	testFields := createHandlerTestFields(ctrl)
+	s := NewCachedStorage(testFields.storage, cache.NewLRU(100), time.Minute, prometheus.NewRegistry())
+
+	p := Person{ID: getPointerOnInt(1), Name: getPointerOnString("test"), Age: getPointerOnInt(1)}
+	testFields.storage.EXPECT().GetPerson(gomock.Any(), 1).Return(p, nil).Times(3)
+	testFields.storage.EXPECT().GetPerson(gomock.Any(), 1, "name").Return(Person{ID: p.ID, Name: p.Name}, nil)
+	testFields.storage.EXPECT().UpdatePerson(gomock.Any(), 1, gomock.Any()).Return(nil)
+	testFields.storage.EXPECT().PersonsChanged(gomock.Any(), []int{1})
+
+	for i := 0; i < 3; i++ {
+		got, err := s.GetPerson(ctx, 1)
+		require.NoError(t, err)
+		require.Equal(t, p, got)
+	}
+	got, err := s.GetPerson(ctx, 1, "name")
+	require.NoError(t, err)
+	require.Equal(t, Person{ID: p.ID, Name: p.Name}, got)
+	require.Equal(t, float64(1), testutil.ToFloat64(s.requests.WithLabelValues("GetPerson", "miss")))
+	require.Equal(t, float64(2), testutil.ToFloat64(s.requests.WithLabelValues("GetPerson", "hit")))
+
+	require.NoError(t, s.UpdatePerson(ctx, 1, &Person{Age: getPointerOnInt(2)}))
+
+	_, err = s.GetPerson(ctx, 1)
+	require.NoError(t, err)
+
+	s.PersonsChanged(ctx, []int{1})
+
+	_, err = s.GetPerson(ctx, 1)
+	require.NoError(t, err)
+}
+
+func Test_CachedStorage_CoalescesMisses(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	testFields := createHandlerTestFields(ctrl)
+	s := NewCachedStorage(testFields.storage, cache.NewLRU(100), time.Minute, prometheus.NewRegistry())
+
+	release := make(chan struct{})
+	testFields.storage.EXPECT().GetPersons(gomock.Any(), Page{}).DoAndReturn(func(context.Context, Page, ...string) ([]Person, error) {
+		<-release
+		return []Person{{ID: getPointerOnInt(1), Name: getPointerOnString("test")}}, nil
+	}).Times(1)
+
+	const readers = 10
+	wg := sync.WaitGroup{}
+	for i := 0; i < readers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			persons, err := s.GetPersons(ctx, Page{})
+			require.NoError(t, err)
+			require.Len(t, persons, 1)
+		}()
+	}
+
+	require.Eventually(t, func() bool {
+		return testutil.ToFloat64(s.requests.WithLabelValues("GetPersons", "miss")) == readers
+	}, time.Second, time.Millisecond)
+	// Give the last reader time to get from the miss into the shared call.
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+}
+
+func Test_CachedStorage_SharedLoadOutlivesCancelledCaller(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	testFields := createHandlerTestFields(ctrl)
+	s := NewCachedStorage(testFields.storage, cache.NewLRU(100), time.Minute, prometheus.NewRegistry())
+
+	started, release := make(chan struct{}), make(chan struct{})
+	p := Person{ID: getPointerOnInt(1), Name: getPointerOnString("test")}
+	testFields.storage.EXPECT().GetPerson(gomock.Any(), 1).DoAndReturn(func(ctx context.Context, id int, fields ...string) (Person, error) {
+		close(started)
+		<-release
+		return p, ctx.Err()
+	}).Times(1)
+
+	firstCtx, cancel := context.WithCancel(context.Background())
+	firstErr := make(chan error)
+	go func() {
+		_, err := s.GetPerson(firstCtx, 1)
+		firstErr <- err
+	}()
+	<-started
+
+	second := make(chan Person)
+	go func() {
+		got, err := s.GetPerson(context.Background(), 1)
+		require.NoError(t, err)
+		second <- got
+	}()
+	require.Eventually(t, func() bool {
+		return testutil.ToFloat64(s.requests.WithLabelValues("GetPerson", "miss")) == 2
+	}, time.Second, time.Millisecond)
+	// Give the second caller time to get from the miss into the shared load.
+	time.Sleep(50 * time.Millisecond)
+
+	cancel()
+	require.ErrorIs(t, <-firstErr, context.Canceled)
+
+	close(release)
+	require.Equal(t, p, <-second)
+
+	got, err := s.GetPerson(context.Background(), 1)
+	require.NoError(t, err)
+	require.Equal(t, p, got)
+}
+
+func Test_CachedStorage_SkipsStickySessions(t *testing.T) {
+	type stickyKey struct{}
+	ctx := context.Background()
+	stickyCtx := context.WithValue(ctx, stickyKey{}, true)
+	ctrl := gomock.NewController(t)
+	testFields := createHandlerTestFields(ctrl)
+	s := NewCachedStorage(testFields.storage, cache.NewLRU(100), time.Minute, prometheus.NewRegistry())
+	s.SkipWhen(func(ctx context.Context) bool { return ctx.Value(stickyKey{}) != nil })
+
+	stale := Person{ID: getPointerOnInt(1), Name: getPointerOnString("stale")}
+	fresh := Person{ID: getPointerOnInt(1), Name: getPointerOnString("fresh")}
+	gomock.InOrder(
+		testFields.storage.EXPECT().GetPerson(gomock.Any(), 1).Return(stale, nil),
+		testFields.storage.EXPECT().GetPerson(gomock.Any(), 1).Return(fresh, nil).Times(2),
+	)
+
+	got, err := s.GetPerson(ctx, 1)
+	require.NoError(t, err)
+	require.Equal(t, stale, got)
+
+	got, err = s.GetPerson(stickyCtx, 1)
+	require.NoError(t, err)
+	require.Equal(t, fresh, got)
+	got, err = s.GetPerson(stickyCtx, 1)
+	require.NoError(t, err)
+	require.Equal(t, fresh, got)
+
+	got, err = s.GetPerson(ctx, 1)
+	require.NoError(t, err)
+	require.Equal(t, stale, got)
+	require.Equal(t, float64(2), testutil.ToFloat64(s.requests.WithLabelValues("GetPerson", "skip")))
+}
@@ -0,0 +1,50 @@
+package person
+
+import (
+	"context"
+	"github.com/pkg/errors"
+)
+
+// Dependent is a storage of rows that belong to persons, like employments or attachments.
+type Dependent interface {
+	// MovePersonRows gives the rows of the person fromID to the person toID.
+	MovePersonRows(ctx context.Context, fromID, toID int) error
+	// DeletePersonRows removes the rows of the person.
+	DeletePersonRows(ctx context.Context, personID int) error
+}
+
+// dependentStorage keeps the rows of dependents in step with the persons of storage, as the
+// memory and bolt storages have no foreign keys to do it. The rows of a deleted person are
+// removed before it, while a dependent can still read them, and the rows of a merged person
+// move to the kept one. PostgreSQL moves them in the merge transaction already, so there the
+// dependents find nothing left to move.
+type dependentStorage struct {
+	storage
+	dependents []Dependent
+}
+
+func NewDependentStorage(storage storage, dependents ...Dependent) *dependentStorage {
+	return &dependentStorage{storage: storage, dependents: dependents}
+}
+
+func (s *dependentStorage) DeletePerson(ctx context.Context, id int) (bool, error) {
+	for _, d := range s.dependents {
+		if err := d.DeletePersonRows(ctx, id); err != nil {
+			return false, errors.Wrap(err, "failed to delete person rows")
+		}
+	}
+	return s.storage.DeletePerson(ctx, id)
+}
+
+func (s *dependentStorage) MergePersons(ctx context.Context, id, sourceID int) (Person, error) {
+	p, err := s.storage.MergePersons(ctx, id, sourceID)
+	if err != nil {
+		return p, err
+	}
+	for _, d := range s.dependents {
+		if err = d.MovePersonRows(ctx, sourceID, id); err != nil {
+			return Person{}, errors.Wrap(err, "failed to move person rows")
+		}
+	}
+	return p, nil
+}
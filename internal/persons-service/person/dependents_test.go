@@ -0,0 +1,51 @@
+package person
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// recordingDependent records the calls it gets.
+type recordingDependent struct {
+	calls []string
+}
+
+func (d *recordingDependent) MovePersonRows(ctx context.Context, fromID, toID int) error {
+	d.calls = append(d.calls, fmt.Sprintf("move %d %d", fromID, toID))
+	return nil
+}
+
+func (d *recordingDependent) DeletePersonRows(ctx context.Context, personID int) error {
+	d.calls = append(d.calls, fmt.Sprintf("delete %d", personID))
+	return nil
+}
+
+func Test_DependentStorage(t *testing.T) {
+	testStorageConformance(t, func(t *testing.T) storage {
+		return NewDependentStorage(NewMemoryRepository(), &recordingDependent{})
+	})
+}
+
+func Test_DependentStorage_MergeAndDelete(t *testing.T) {
+	ctx := context.Background()
+	dependent := &recordingDependent{}
+	s := NewDependentStorage(NewMemoryRepository(), dependent)
+
+	id, err := s.CreatePerson(ctx, Person{Name: getPointerOnString("kept")})
+	require.NoError(t, err)
+	sourceID, err := s.CreatePerson(ctx, Person{Name: getPointerOnString("merged")})
+	require.NoError(t, err)
+
+	_, err = s.MergePersons(ctx, id, sourceID)
+	require.NoError(t, err)
+	_, err = s.MergePersons(ctx, id, sourceID)
+	require.ErrorIs(t, err, ErrNotFound)
+
+	isDeleted, err := s.DeletePerson(ctx, id)
+	require.NoError(t, err)
+	require.True(t, isDeleted)
+
+	require.Equal(t, []string{fmt.Sprintf("move %d %d", sourceID, id), fmt.Sprintf("delete %d", id)}, dependent.calls)
+}
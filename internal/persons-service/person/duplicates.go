@@ -0,0 +1,168 @@
+package person
+
+import (
+	"slices"
+	"sort"
+	"strings"
+)
+
+const (
+	defaultDuplicateThreshold = 0.8
+	// maxDuplicateCandidates bounds the persons scored against one, maxDuplicates the
+	// duplicates returned for it.
+	maxDuplicateCandidates = 500
+	maxDuplicates          = 20
+)
+
+// duplicate is a person that probably is the same human as another one.
+type duplicate struct {
+	person Person
+	score  float64
+}
+
+// findDuplicates returns the persons other than p scored at least threshold against it, the
+// most similar first and at most maxDuplicates of them.
+func findDuplicates(p Person, persons []Person, threshold float64) []duplicate {
+	res := make([]duplicate, 0)
+	for _, candidate := range persons {
+		if *candidate.ID == *p.ID {
+			continue
+		}
+		if score := duplicateScore(p, candidate); score >= threshold {
+			res = append(res, duplicate{person: candidate, score: score})
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].score > res[j].score
+	})
+	return res[:min(len(res), maxDuplicates)]
+}
+
+// isDuplicateCandidate reports whether c shares a blocking key with p: the email, a phone
+// number or a word of the name. The storages only load the candidates of a person to score
+// them, so persons whose names share no word, like "Jon" and "John", are not found.
+func isDuplicateCandidate(p, c Person) bool {
+	if p.Email != nil && c.Email != nil && strings.EqualFold(*p.Email, *c.Email) {
+		return true
+	}
+	for _, phone := range p.PhoneNumbers {
+		if slices.Contains(c.PhoneNumbers, phone) {
+			return true
+		}
+	}
+	if p.Name == nil || c.Name == nil {
+		return false
+	}
+	words := nameWords(*c.Name)
+	return slices.ContainsFunc(nameWords(*p.Name), func(word string) bool {
+		_, found := slices.BinarySearch(words, word)
+		return found
+	})
+}
+
+// nameWords returns the sorted distinct words of name as normalizeText splits them.
+func nameWords(name string) []string {
+	return slices.Compact(strings.Fields(normalizeText(name)))
+}
+
+// duplicateScore rates from 0 to 1 how likely a and b are the same human. A shared email or
+// phone number is a certain match; otherwise the fuzzy similarity of the names, addresses and
+// works and the equality of the birth dates are averaged over the fields both persons have.
+func duplicateScore(a, b Person) float64 {
+	if a.Email != nil && b.Email != nil && strings.EqualFold(*a.Email, *b.Email) {
+		return 1
+	}
+	for _, phone := range a.PhoneNumbers {
+		if slices.Contains(b.PhoneNumbers, phone) {
+			return 1
+		}
+	}
+	if a.Name == nil || b.Name == nil {
+		return 0
+	}
+
+	const (
+		nameWeight      = 3
+		addressWeight   = 2
+		birthDateWeight = 2
+		workWeight      = 1
+	)
+
+	score := nameWeight * similarity(*a.Name, *b.Name)
+	weight := float64(nameWeight)
+	if addressA, addressB := fullAddress(a), fullAddress(b); addressA != "" && addressB != "" {
+		score += addressWeight * similarity(addressA, addressB)
+		weight += addressWeight
+	}
+	if a.BirthDate != nil && b.BirthDate != nil {
+		if a.BirthDate.Equal(*b.BirthDate) {
+			score += birthDateWeight
+		}
+		weight += birthDateWeight
+	}
+	if a.Work != nil && b.Work != nil {
+		score += workWeight * similarity(*a.Work, *b.Work)
+		weight += workWeight
+	}
+	return score / weight
+}
+
+// fullAddress returns the address of p, or its parts joined if only those are set.
+func fullAddress(p Person) string {
+	if p.Address != nil {
+		return *p.Address
+	}
+
+	parts := make([]string, 0, 4)
+	for _, part := range []*string{p.Street, p.City, p.PostalCode, p.Country} {
+		if part != nil {
+			parts = append(parts, *part)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// similarity is 1 minus the Levenshtein distance of the normalized strings divided by the
+// length of the longer one.
+func similarity(a, b string) float64 {
+	ra, rb := []rune(normalizeText(a)), []rune(normalizeText(b))
+	longest := max(len(ra), len(rb))
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(levenshtein(ra, rb))/float64(longest)
+}
+
+// wordSeparators are the characters words are split at. The persons_name_words_idx index splits
+// names at the same ones.
+const wordSeparators = " \t\n.,;:-_'\"()/"
+
+// normalizeText lowercases s, drops punctuation and sorts its words, so "Petrov, Ivan" and
+// "ivan petrov" are equal.
+func normalizeText(s string) string {
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return strings.ContainsRune(wordSeparators, r)
+	})
+	sort.Strings(words)
+	return strings.Join(words, " ")
+}
+
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
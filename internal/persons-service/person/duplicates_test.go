@@ -0,0 +1,102 @@
+package person
+
+import (
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func Test_duplicateScore(t *testing.T) {
+	birthDate := time.Date(1990, time.January, 31, 0, 0, 0, 0, time.UTC)
+	otherBirthDate := time.Date(1991, time.January, 31, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		a, b  Person
+		score float64
+	}{
+		{
+			name:  "same email",
+			a:     Person{Name: getPointerOnString("a"), Email: getPointerOnString("Test@example.com")},
+			b:     Person{Name: getPointerOnString("b"), Email: getPointerOnString("test@example.com")},
+			score: 1,
+		},
+		{
+			name:  "shared phone number",
+			a:     Person{PhoneNumbers: pq.StringArray{"+79991234567"}},
+			b:     Person{PhoneNumbers: pq.StringArray{"+70000000000", "+79991234567"}},
+			score: 1,
+		},
+		{
+			name:  "reordered name",
+			a:     Person{Name: getPointerOnString("Ivan Petrov")},
+			b:     Person{Name: getPointerOnString("petrov, ivan")},
+			score: 1,
+		},
+		{
+			name:  "typo in name",
+			a:     Person{Name: getPointerOnString("Ivan Petrov")},
+			b:     Person{Name: getPointerOnString("Ivan Petrof")},
+			score: 1 - 1.0/11,
+		},
+		{
+			name:  "different birth date",
+			a:     Person{Name: getPointerOnString("Ivan Petrov"), BirthDate: &birthDate},
+			b:     Person{Name: getPointerOnString("Ivan Petrov"), BirthDate: &otherBirthDate},
+			score: 3.0 / 5,
+		},
+		{
+			name:  "address from parts",
+			a:     Person{Name: getPointerOnString("Ivan"), Address: getPointerOnString("Baumanskaya 2, Moscow")},
+			b:     Person{Name: getPointerOnString("Ivan"), Street: getPointerOnString("Baumanskaya 2"), City: getPointerOnString("Moscow")},
+			score: 1,
+		},
+		{
+			name:  "no name",
+			a:     Person{Name: getPointerOnString("Ivan")},
+			b:     Person{},
+			score: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.InDelta(t, tt.score, duplicateScore(tt.a, tt.b), 1e-9)
+			require.InDelta(t, tt.score, duplicateScore(tt.b, tt.a), 1e-9)
+		})
+	}
+}
+
+func Test_findDuplicates(t *testing.T) {
+	persons := []Person{
+		{ID: getPointerOnInt(1), Name: getPointerOnString("Ivan Petrov")},
+		{ID: getPointerOnInt(2), Name: getPointerOnString("Petr Ivanov")},
+		{ID: getPointerOnInt(3), Name: getPointerOnString("Ivan Petrof")},
+		{ID: getPointerOnInt(4), Name: getPointerOnString("ivan petrov")},
+	}
+
+	duplicates := findDuplicates(persons[0], persons, 0.8)
+	require.Len(t, duplicates, 2)
+	require.Equal(t, 4, *duplicates[0].person.ID)
+	require.Equal(t, 3, *duplicates[1].person.ID)
+}
+
+func Test_findDuplicates_Bounded(t *testing.T) {
+	persons := make([]Person, maxDuplicates+2)
+	for i := range persons {
+		persons[i] = Person{ID: getPointerOnInt(i + 1), Name: getPointerOnString("Ivan Petrov")}
+	}
+
+	require.Len(t, findDuplicates(persons[0], persons, 0.8), maxDuplicates)
+}
+
+func Test_isDuplicateCandidate(t *testing.T) {
+	p := Person{Name: getPointerOnString("Ivan Petrov"), Email: getPointerOnString("ivan@example.com"), PhoneNumbers: pq.StringArray{"+79991234567"}}
+
+	require.True(t, isDuplicateCandidate(p, Person{Email: getPointerOnString("IVAN@example.com")}))
+	require.True(t, isDuplicateCandidate(p, Person{PhoneNumbers: pq.StringArray{"+79991234567"}}))
+	require.True(t, isDuplicateCandidate(p, Person{Name: getPointerOnString("petrov, Petr")}))
+	require.False(t, isDuplicateCandidate(p, Person{Name: getPointerOnString("Petr Ivanov")}))
+	require.False(t, isDuplicateCandidate(p, Person{}))
+}
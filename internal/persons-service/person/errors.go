@@ -0,0 +1,11 @@
+package person
+
+import (
+	"github.com/pkg/errors"
+)
+
+// ErrNotFound is returned by storages when the person to update or merge does not exist.
+var ErrNotFound = errors.New("person not found")
+
+// ErrRelationExists is returned by storages when a person already has the same relation.
+var ErrRelationExists = errors.New("relation already exists")
@@ -0,0 +1,123 @@
+package person
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	EventCreated = "CREATED"
+	EventUpdated = "UPDATED"
+	EventDeleted = "DELETED"
+)
+
+// eventBuffer is the number of events a subscriber may fall behind before it misses events.
+const eventBuffer = 64
+
+// Event is a change of a stored person. It is published after the change has been stored.
+type Event struct {
+	Type     string
+	PersonID int
+	At       time.Time
+}
+
+// Broker delivers the events of this instance to its subscribers. Changes made through other
+// instances sharing the storage are not seen.
+type Broker struct {
+	mu          sync.Mutex
+	subscribers map[chan Event]struct{}
+}
+
+func NewBroker() *Broker {
+	return &Broker{subscribers: make(map[chan Event]struct{})}
+}
+
+// Subscribe returns the events published from now on until ctx is done, when the channel is
+// closed. A subscriber that does not keep up misses events rather than slowing down writes.
+func (b *Broker) Subscribe(ctx context.Context) <-chan Event {
+	events := make(chan Event, eventBuffer)
+
+	b.mu.Lock()
+	b.subscribers[events] = struct{}{}
+	b.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		b.mu.Lock()
+		delete(b.subscribers, events)
+		b.mu.Unlock()
+		close(events)
+	}()
+
+	return events
+}
+
+func (b *Broker) Publish(e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for events := range b.subscribers {
+		select {
+		case events <- e:
+		default:
+		}
+	}
+}
+
+// eventStorage publishes the changes made through storage to a broker.
+type eventStorage struct {
+	storage
+	broker *Broker
+}
+
+func NewEventStorage(storage storage, broker *Broker) *eventStorage {
+	return &eventStorage{storage: storage, broker: broker}
+}
+
+func (s *eventStorage) publish(eventType string, id int) {
+	s.broker.Publish(Event{Type: eventType, PersonID: id, At: time.Now()})
+}
+
+func (s *eventStorage) CreatePerson(ctx context.Context, person Person) (int, error) {
+	id, err := s.storage.CreatePerson(ctx, person)
+	if err == nil {
+		s.publish(EventCreated, id)
+	}
+	return id, err
+}
+
+func (s *eventStorage) UpdatePerson(ctx context.Context, id int, person *Person) error {
+	err := s.storage.UpdatePerson(ctx, id, person)
+	if err == nil {
+		s.publish(EventUpdated, id)
+	}
+	return err
+}
+
+func (s *eventStorage) DeletePerson(ctx context.Context, id int) (bool, error) {
+	isDeleted, err := s.storage.DeletePerson(ctx, id)
+	if err == nil && isDeleted {
+		s.publish(EventDeleted, id)
+	}
+	return isDeleted, err
+}
+
+// MergePersons reports the merge as an update of the kept person and a deletion of the merged
+// one.
+func (s *eventStorage) MergePersons(ctx context.Context, id, sourceID int) (Person, error) {
+	p, err := s.storage.MergePersons(ctx, id, sourceID)
+	if err == nil {
+		s.publish(EventUpdated, id)
+		s.publish(EventDeleted, sourceID)
+	}
+	return p, err
+}
+
+// PersonsChanged reports the changes other storages made to the persons as updates.
+func (s *eventStorage) PersonsChanged(ctx context.Context, ids []int) {
+	s.storage.PersonsChanged(ctx, ids)
+	for _, id := range ids {
+		s.publish(EventUpdated, id)
+	}
+}
@@ -0,0 +1,147 @@
+package person
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// personFieldColumns maps the fields of the person representations to the columns they are
+// read from. id is read for every projection.
+var personFieldColumns = map[string][]string{
+	"id":            {"id"},
+	"name":          {"name"},
+	"age":           {"age", "birth_date"},
+	"birth_date":    {"birth_date"},
+	"email":         {"email"},
+	"phone_numbers": {"phone_numbers"},
+	"address":       {"address"},
+	"address_parts": {"address_street", "address_city", "address_postal_code", "address_country"},
+	"work":          {"work"},
+	"attributes":    {"attributes"},
+	"tags":          {"tags"},
+	"created_at":    {"created_at"},
+	"updated_at":    {"updated_at"},
+}
+
+// parseFields reads the fields query parameter, a comma separated list of the fields of the
+// person representation that may be repeated. An empty list means every field.
+func parseFields(values []string) ([]string, error) {
+	var fields []string
+	for _, value := range values {
+		for _, field := range strings.Split(value, ",") {
+			field = strings.TrimSpace(field)
+			if field == "" {
+				return nil, errors.New("fields must not be empty")
+			}
+			if _, ok := personFieldColumns[field]; !ok {
+				return nil, fmt.Errorf("unknown field %s", field)
+			}
+			if !slices.Contains(fields, field) {
+				fields = append(fields, field)
+			}
+		}
+	}
+	return fields, nil
+}
+
+// fieldColumns returns the columns fields are read from, in the order of personColumns, or
+// every column if fields is empty.
+func fieldColumns(fields []string) []string {
+	if len(fields) == 0 {
+		return personColumns
+	}
+
+	needed := map[string]bool{"id": true}
+	for _, field := range fields {
+		for _, column := range personFieldColumns[field] {
+			needed[column] = true
+		}
+	}
+	columns := make([]string, 0, len(needed))
+	for _, column := range personColumns {
+		if needed[column] {
+			columns = append(columns, column)
+		}
+	}
+	return columns
+}
+
+// projectPerson returns p with only the columns of fields set, like a select of them. Storages
+// that read whole persons use it.
+func projectPerson(p Person, fields []string) Person {
+	if len(fields) == 0 {
+		return p
+	}
+
+	columns := fieldColumns(fields)
+	has := func(column string) bool { return slices.Contains(columns, column) }
+	res := Person{ID: p.ID}
+	if has("name") {
+		res.Name = p.Name
+	}
+	if has("age") {
+		res.Age = p.Age
+	}
+	if has("birth_date") {
+		res.BirthDate = p.BirthDate
+	}
+	if has("email") {
+		res.Email = p.Email
+	}
+	if has("phone_numbers") {
+		res.PhoneNumbers = p.PhoneNumbers
+	}
+	if has("address") {
+		res.Address = p.Address
+	}
+	if has("address_street") {
+		res.Street, res.City, res.PostalCode, res.Country = p.Street, p.City, p.PostalCode, p.Country
+	}
+	if has("work") {
+		res.Work = p.Work
+	}
+	if has("attributes") {
+		res.Attributes = p.Attributes
+	}
+	if has("tags") {
+		res.Tags = p.Tags
+	}
+	if has("created_at") {
+		res.CreatedAt = p.CreatedAt
+	}
+	if has("updated_at") {
+		res.UpdatedAt = p.UpdatedAt
+	}
+	return res
+}
+
+// projectResponse returns resp, a person representation struct, as a struct of only its id and
+// the fields named by their json tags, so every codec writes just them. Fields without a json
+// name, like XMLName, are kept.
+func projectResponse(resp interface{}, fields []string) interface{} {
+	if len(fields) == 0 {
+		return resp
+	}
+
+	v := reflect.Indirect(reflect.ValueOf(resp))
+	t := v.Type()
+	var structFields []reflect.StructField
+	var values []reflect.Value
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" || name == "id" || slices.Contains(fields, name) {
+			structFields = append(structFields, field)
+			values = append(values, v.Field(i))
+		}
+	}
+
+	projected := reflect.New(reflect.StructOf(structFields)).Elem()
+	for i, value := range values {
+		projected.Field(i).Set(value)
+	}
+	return projected.Interface()
+}
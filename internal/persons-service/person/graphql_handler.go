@@ -0,0 +1,292 @@
+package person
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	api "github.com/Erlendum/rsoi-lab-01/api/persons-service"
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/config"
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/tracing"
+	"github.com/Erlendum/rsoi-lab-01/pkg/validation"
+	"github.com/go-playground/validator/v10"
+	"github.com/graph-gophers/graphql-go"
+	gqlerrors "github.com/graph-gophers/graphql-go/errors"
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog"
+	"mime"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"sync"
+)
+
+const (
+	graphqlCodeBadUserInput = "BAD_USER_INPUT"
+	graphqlCodeNotFound     = "NOT_FOUND"
+	graphqlCodeConflict     = "CONFLICT"
+	graphqlCodeInternal     = "INTERNAL_SERVER_ERROR"
+)
+
+// graphqlMaxDepth bounds the nesting of queries, e.g. of relations of related persons.
+const graphqlMaxDepth = 10
+
+// graphqlSubscriptionError is the error graphql-go returns for a subscription sent to Exec.
+const graphqlSubscriptionError = "graphql-ws protocol header is missing"
+
+// graphqlHandler serves the GraphQL API of the persons at /graphql. Queries and mutations
+// are answered with JSON. Requests accepting text/event-stream get the results as server-sent
+// events instead, which subscriptions require.
+type graphqlHandler struct {
+	handler      *handler
+	validator    *validation.CustomValidator
+	tenantHeader string
+	broker       *Broker
+	schema       *graphql.Schema
+
+	// shutdown is closed when the server shuts down, so the open streams end.
+	shutdown     chan struct{}
+	shutdownOnce sync.Once
+}
+
+func NewGraphQLHandler(storage storage, broker *Broker, cfg *config.Persons) (*graphqlHandler, error) {
+	h, err := NewHandler(storage, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	v := validation.MustRegisterCustomValidator(validator.New())
+	registerPersonValidation(v)
+
+	g := &graphqlHandler{
+		handler:      h,
+		validator:    v,
+		tenantHeader: cfg.Attributes.Header(),
+		broker:       broker,
+		shutdown:     make(chan struct{}),
+	}
+	g.schema, err = graphql.ParseSchema(api.GraphQLSchema, &graphqlResolver{g: g},
+		graphql.UseStringDescriptions(),
+		graphql.MaxDepth(graphqlMaxDepth),
+		// A whole page of persons is resolved at once, so its lookups fall into one batch.
+		graphql.MaxParallelism(maxPageLimit),
+		graphql.Logger(graphqlLogger{}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return g, nil
+}
+
+func (g *graphqlHandler) Register(echo *echo.Echo) {
+	echo.Server.RegisterOnShutdown(func() {
+		g.shutdownOnce.Do(func() { close(g.shutdown) })
+	})
+
+	echo.POST("/graphql", tracing.Handler("person.handler.GraphQL", g.GraphQL))
+}
+
+type graphqlRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+func (g *graphqlHandler) GraphQL(c echo.Context) error {
+	logger := zerolog.Ctx(c.Request().Context())
+
+	req := &graphqlRequest{}
+	if err := json.NewDecoder(c.Request().Body).Decode(req); err != nil {
+		logger.Warn().Err(err).Msg("unmarshalling error")
+		return c.JSON(http.StatusBadRequest, &graphql.Response{
+			Errors: []*gqlerrors.QueryError{gqlerrors.Errorf("unmarshalling error")},
+		})
+	}
+
+	if acceptsEventStream(c.Request().Header.Get(echo.HeaderAccept)) {
+		return g.stream(c, req)
+	}
+
+	ctx := g.newContext(c, true)
+	resp := g.schema.Exec(ctx, req.Query, req.OperationName, req.Variables)
+	if len(resp.Errors) == 1 && resp.Errors[0].Message == graphqlSubscriptionError {
+		resp.Errors[0].Message = "subscriptions are streamed as server-sent events, accepting text/event-stream"
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+// stream sends the results of the operation as next events followed by a complete event, as
+// in the distinct connections mode of the GraphQL over SSE protocol.
+func (g *graphqlHandler) stream(c echo.Context, req *graphqlRequest) error {
+	ctx, cancel := context.WithCancel(g.newContext(c, false))
+	defer cancel()
+	go func() {
+		select {
+		case <-g.shutdown:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	responses, err := g.schema.Subscribe(ctx, req.Query, req.OperationName, req.Variables)
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("subscribing error")
+		return c.JSON(http.StatusInternalServerError, &graphql.Response{
+			Errors: []*gqlerrors.QueryError{gqlerrors.Errorf("subscribing error")},
+		})
+	}
+
+	w := c.Response()
+	w.Header().Set(echo.HeaderContentType, "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	w.Flush()
+
+	for resp := range responses {
+		data, err := json.Marshal(resp)
+		if err != nil {
+			zerolog.Ctx(ctx).Error().Err(err).Msg("marshalling error")
+			continue
+		}
+		if _, err = fmt.Fprintf(w, "event: next\ndata: %s\n\n", data); err != nil {
+			return nil
+		}
+		w.Flush()
+	}
+
+	_, _ = fmt.Fprint(w, "event: complete\ndata:\n\n")
+	w.Flush()
+	return nil
+}
+
+func acceptsEventStream(accept string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(part)
+		if err == nil && mediaType == "text/event-stream" {
+			return true
+		}
+	}
+	return false
+}
+
+type graphqlContextKey struct{}
+
+// graphqlContext is what the resolvers of an operation need from its request.
+type graphqlContext struct {
+	language string
+	tenant   string
+	loaders  *graphqlLoaders
+}
+
+func (g *graphqlHandler) newContext(c echo.Context, cached bool) context.Context {
+	return context.WithValue(c.Request().Context(), graphqlContextKey{}, &graphqlContext{
+		language: c.Request().Header.Get("Accept-Language"),
+		tenant:   c.Request().Header.Get(g.tenantHeader),
+		loaders:  newGraphQLLoaders(g.handler.storage, cached),
+	})
+}
+
+func graphqlContextFrom(ctx context.Context) *graphqlContext {
+	return ctx.Value(graphqlContextKey{}).(*graphqlContext)
+}
+
+// validate converts in to a person with the field rules of the echo handler, reporting the
+// invalid fields in the language of the request.
+func (g *graphqlHandler) validate(ctx context.Context, in personInput) (Person, error) {
+	gctx := graphqlContextFrom(ctx)
+
+	req, err := in.newPersonRequest()
+	if err != nil {
+		return Person{}, err
+	}
+	if err = g.validator.Validate(req); err != nil {
+		var verr *validation.Error
+		if errors.As(err, &verr) {
+			return Person{}, graphqlValidationError(verr.Translate(gctx.language))
+		}
+		return Person{}, &graphqlError{message: err.Error(), code: graphqlCodeBadUserInput}
+	}
+
+	return req.toPerson(), nil
+}
+
+// graphqlError is a resolver error with a code and details in its extensions.
+type graphqlError struct {
+	message    string
+	code       string
+	extensions map[string]interface{}
+}
+
+func (e *graphqlError) Error() string {
+	return e.message
+}
+
+func (e *graphqlError) Extensions() map[string]interface{} {
+	res := map[string]interface{}{"code": e.code}
+	for key, value := range e.extensions {
+		res[key] = value
+	}
+	return res
+}
+
+// graphqlValidationError lists the messages of the invalid fields like the validation error
+// responses of the echo handler.
+func graphqlValidationError(fields map[string]string) error {
+	return &graphqlError{
+		message:    "validation error",
+		code:       graphqlCodeBadUserInput,
+		extensions: map[string]interface{}{"fields": fields},
+	}
+}
+
+// graphqlConflictError rejects a person that clashes with existing under a unique constraint.
+func graphqlConflictError(existing Person) error {
+	if existing.ID == nil {
+		return &graphqlError{message: "person already exists", code: graphqlCodeConflict}
+	}
+	return &graphqlError{
+		message:    "person already exists",
+		code:       graphqlCodeConflict,
+		extensions: map[string]interface{}{"existing_person_id": *existing.ID},
+	}
+}
+
+// graphqlPersonError maps the errors of the shared person operations to their codes, and any
+// other error to INTERNAL_SERVER_ERROR with msg.
+func graphqlPersonError(ctx context.Context, err error, msg string) error {
+	logger := zerolog.Ctx(ctx)
+
+	var attributesErr *AttributesError
+	if errors.As(err, &attributesErr) {
+		logger.Warn().Err(err).Msg("attributes validation error")
+		return graphqlValidationError(attributesErr.Fields)
+	}
+	if errors.Is(err, ErrNotFound) {
+		logger.Info().Msg("person not found")
+		return &graphqlError{message: "person not found", code: graphqlCodeNotFound}
+	}
+	var conflict *ConflictError
+	if errors.As(err, &conflict) {
+		logger.Info().Int("existing_person_id", valueOrZero(conflict.Existing.ID)).Msg("person already exists")
+		return graphqlConflictError(conflict.Existing)
+	}
+	return graphqlInternalError(ctx, err, msg)
+}
+
+// graphqlInternalError logs a storage error and hides it behind msg.
+func graphqlInternalError(ctx context.Context, err error, msg string) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	zerolog.Ctx(ctx).Error().Err(err).Msg(msg)
+	return &graphqlError{message: msg, code: graphqlCodeInternal}
+}
+
+// graphqlLogger logs the panics of resolvers, which graphql-go turns into errors of the
+// response.
+type graphqlLogger struct{}
+
+func (graphqlLogger) LogPanic(ctx context.Context, value interface{}) {
+	zerolog.Ctx(ctx).Error().Interface("panic", value).Bytes("stack", debug.Stack()).Msg("graphql resolver panicked")
+}
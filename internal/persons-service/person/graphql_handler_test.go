@@ -0,0 +1,332 @@
+package person
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/config"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// countingStorage counts the calls of the storage methods that load persons and relations.
+type countingStorage struct {
+	storage
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+func (s *countingStorage) count(method string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls[method]++
+}
+
+func (s *countingStorage) GetPerson(ctx context.Context, id int, fields ...string) (Person, error) {
+	s.count("GetPerson")
+	return s.storage.GetPerson(ctx, id, fields...)
+}
+
+func (s *countingStorage) GetPersonsByIDs(ctx context.Context, ids []int) ([]Person, error) {
+	s.count("GetPersonsByIDs")
+	return s.storage.GetPersonsByIDs(ctx, ids)
+}
+
+func (s *countingStorage) GetRelations(ctx context.Context, personID int) ([]Relation, error) {
+	s.count("GetRelations")
+	return s.storage.GetRelations(ctx, personID)
+}
+
+func (s *countingStorage) GetRelationsByPersonIDs(ctx context.Context, personIDs []int) ([]Relation, error) {
+	s.count("GetRelationsByPersonIDs")
+	return s.storage.GetRelationsByPersonIDs(ctx, personIDs)
+}
+
+type graphqlTestResponse struct {
+	Data   map[string]json.RawMessage `json:"data"`
+	Errors []struct {
+		Message    string                 `json:"message"`
+		Extensions map[string]interface{} `json:"extensions"`
+	} `json:"errors"`
+}
+
+func newGraphQLTestServer(t *testing.T, storage storage, broker *Broker) *echo.Echo {
+	h, err := NewGraphQLHandler(storage, broker, &config.Persons{
+		Attributes: config.Attributes{
+			TenantHeader: "X-Tenant-ID",
+			Schemas:      map[string]string{"default": "../../../configs/persons-service/attributes/default.schema.json"},
+		},
+	})
+	require.NoError(t, err)
+
+	e := echo.New()
+	h.Register(e)
+	return e
+}
+
+func execGraphQL(t *testing.T, e *echo.Echo, query string, variables map[string]interface{}, header http.Header) graphqlTestResponse {
+	body, err := json.Marshal(graphqlRequest{Query: query, Variables: variables})
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(string(body)))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	resp := graphqlTestResponse{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	return resp
+}
+
+func Test_GraphQLHandler(t *testing.T) {
+	e := newGraphQLTestServer(t, NewMemoryRepository(), NewBroker())
+
+	const personFields = `id name age email addressParts { city } attributes tags`
+
+	resp := execGraphQL(t, e, `mutation($input: PersonInput!) { createPerson(input: $input) { `+personFields+` } }`,
+		map[string]interface{}{"input": map[string]interface{}{
+			"name":         "Ivan",
+			"birthDate":    "1990-01-01",
+			"email":        "ivan@example.com",
+			"addressParts": map[string]interface{}{"city": "Moscow"},
+			"attributes":   map[string]interface{}{"region": "eu"},
+			"tags":         []string{"vip", "vip"},
+		}}, nil)
+	require.Empty(t, resp.Errors)
+	require.JSONEq(t, `{"id":"1","name":"Ivan","age":`+jsonAge(1990)+`,"email":"ivan@example.com",
+		"addressParts":{"city":"Moscow"},"attributes":{"region":"eu"},"tags":["vip"]}`, string(resp.Data["createPerson"]))
+
+	resp = execGraphQL(t, e, `mutation { createPerson(input: {name: "Petr", age: 40, attributes: {region: "us"}}) { id } }`, nil, nil)
+	require.Empty(t, resp.Errors)
+
+	resp = execGraphQL(t, e, `mutation { updatePerson(id: 2, input: {name: "Petr", work: "Acme"}) { age work } }`, nil, nil)
+	require.Empty(t, resp.Errors)
+	require.JSONEq(t, `{"age":40,"work":"Acme"}`, string(resp.Data["updatePerson"]))
+
+	resp = execGraphQL(t, e, `{ person(id: 1) { name email } missing: person(id: 100) { name } }`, nil, nil)
+	require.Empty(t, resp.Errors)
+	require.JSONEq(t, `{"name":"Ivan","email":"ivan@example.com"}`, string(resp.Data["person"]))
+	require.JSONEq(t, `null`, string(resp.Data["missing"]))
+
+	resp = execGraphQL(t, e, `{ persons(limit: 1, offset: 1) { totalCount items { name } } }`, nil, nil)
+	require.Empty(t, resp.Errors)
+	require.JSONEq(t, `{"totalCount":2,"items":[{"name":"Petr"}]}`, string(resp.Data["persons"]))
+
+	resp = execGraphQL(t, e, `{ persons(tags: ["vip"], attributes: [{name: "region", value: "eu"}]) { totalCount items { name } } }`, nil, nil)
+	require.Empty(t, resp.Errors)
+	require.JSONEq(t, `{"totalCount":1,"items":[{"name":"Ivan"}]}`, string(resp.Data["persons"]))
+
+	resp = execGraphQL(t, e, `mutation { deletePerson(id: 2) }`, nil, nil)
+	require.Empty(t, resp.Errors)
+	require.JSONEq(t, `"2"`, string(resp.Data["deletePerson"]))
+	resp = execGraphQL(t, e, `{ persons { totalCount } }`, nil, nil)
+	require.JSONEq(t, `{"totalCount":1}`, string(resp.Data["persons"]))
+}
+
+func jsonAge(birthYear int) string {
+	p := Person{BirthDate: ptrTo(time.Date(birthYear, time.January, 1, 0, 0, 0, 0, time.UTC))}
+	b, _ := json.Marshal(p.CurrentAge(time.Now()))
+	return string(b)
+}
+
+func Test_GraphQLHandler_Errors(t *testing.T) {
+	e := newGraphQLTestServer(t, newUniqueEmailRepository(t), NewBroker())
+
+	resp := execGraphQL(t, e, `mutation { createPerson(input: {name: "Ivan", email: "ivan@example.com"}) { id } }`, nil, nil)
+	require.Empty(t, resp.Errors)
+
+	tests := []struct {
+		name               string
+		query              string
+		header             http.Header
+		expectedMessage    string
+		expectedExtensions map[string]interface{}
+	}{
+		{
+			name:            "validation error",
+			query:           `mutation { createPerson(input: {age: 200}) { id } }`,
+			expectedMessage: "validation error",
+			expectedExtensions: map[string]interface{}{
+				"code": "BAD_USER_INPUT",
+				"fields": map[string]interface{}{
+					"name": "name is a required field",
+					"age":  "age must be between 0 and 150",
+				},
+			},
+		},
+		{
+			name:            "validation error in the language of the request",
+			query:           `mutation { updatePerson(id: 1, input: {name: "Ivan", age: -1}) { id } }`,
+			header:          http.Header{"Accept-Language": {"ru"}},
+			expectedMessage: "validation error",
+			expectedExtensions: map[string]interface{}{
+				"code":   "BAD_USER_INPUT",
+				"fields": map[string]interface{}{"age": "age должен быть от 0 до 150"},
+			},
+		},
+		{
+			name:            "attributes are not an object",
+			query:           `mutation { createPerson(input: {name: "Petr", attributes: [1]}) { id } }`,
+			expectedMessage: "validation error",
+			expectedExtensions: map[string]interface{}{
+				"code":   "BAD_USER_INPUT",
+				"fields": map[string]interface{}{"attributes": "attributes must be an object"},
+			},
+		},
+		{
+			name:               "conflict",
+			query:              `mutation { createPerson(input: {name: "Ivan", email: "ivan@example.com"}) { id } }`,
+			expectedMessage:    "person already exists",
+			expectedExtensions: map[string]interface{}{"code": "CONFLICT", "existing_person_id": float64(1)},
+		},
+		{
+			name:               "update of missing person",
+			query:              `mutation { updatePerson(id: 100, input: {name: "Petr"}) { id } }`,
+			expectedMessage:    "person not found",
+			expectedExtensions: map[string]interface{}{"code": "NOT_FOUND"},
+		},
+		{
+			name:               "delete of missing person",
+			query:              `mutation { deletePerson(id: 100) }`,
+			expectedMessage:    "person not found",
+			expectedExtensions: map[string]interface{}{"code": "NOT_FOUND"},
+		},
+		{
+			name:               "wrong id",
+			query:              `{ person(id: "test") { id } }`,
+			expectedMessage:    "wrong id",
+			expectedExtensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+		},
+		{
+			name:               "wrong page",
+			query:              `{ persons(offset: -1) { totalCount } }`,
+			expectedMessage:    "offset must not be negative",
+			expectedExtensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+		},
+		{
+			name:            "subscription without event stream",
+			query:           `subscription { personChanged { type } }`,
+			expectedMessage: "subscriptions are streamed as server-sent events, accepting text/event-stream",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := execGraphQL(t, e, tt.query, nil, tt.header)
+			require.Len(t, resp.Errors, 1)
+			require.Equal(t, tt.expectedMessage, resp.Errors[0].Message)
+			require.Equal(t, tt.expectedExtensions, resp.Errors[0].Extensions)
+		})
+	}
+}
+
+func Test_GraphQLHandler_Batching(t *testing.T) {
+	ctx := context.Background()
+	storage := &countingStorage{storage: NewMemoryRepository(), calls: map[string]int{}}
+
+	ids := make([]int, 0)
+	for _, name := range []string{"a", "b", "c", "d"} {
+		id, err := storage.CreatePerson(ctx, Person{Name: ptrTo(name)})
+		require.NoError(t, err)
+		ids = append(ids, id)
+	}
+	for i := 1; i < len(ids); i++ {
+		_, err := storage.CreateRelation(ctx, Relation{PersonID: ids[i-1], RelatedPersonID: ids[i], Type: "sibling", Bidirectional: true})
+		require.NoError(t, err)
+	}
+
+	e := newGraphQLTestServer(t, storage, NewBroker())
+	resp := execGraphQL(t, e, `{ persons(limit: 2) { items { name relations { relatedPerson { name relations { relatedPerson { name } } } } } } }`, nil, nil)
+	require.Empty(t, resp.Errors)
+
+	var page struct {
+		Items []struct {
+			Name      string
+			Relations []struct {
+				RelatedPerson struct {
+					Name      string
+					Relations []struct {
+						RelatedPerson struct{ Name string }
+					}
+				}
+			}
+		}
+	}
+	require.NoError(t, json.Unmarshal(resp.Data["persons"], &page))
+	require.Len(t, page.Items, 2)
+	require.Equal(t, "b", page.Items[0].Relations[0].RelatedPerson.Name)
+	require.Len(t, page.Items[1].Relations, 2)
+	require.Equal(t, "a", page.Items[1].Relations[0].RelatedPerson.Name)
+	c := page.Items[1].Relations[1].RelatedPerson
+	require.Equal(t, "c", c.Name)
+	require.Equal(t, "d", c.Relations[1].RelatedPerson.Name)
+
+	// Every level takes one call for the relations and one for the persons not loaded yet: the
+	// listed persons are known, c is loaded for the first level and d for the second.
+	require.Equal(t, map[string]int{"GetRelationsByPersonIDs": 2, "GetPersonsByIDs": 2}, storage.calls)
+}
+
+func Test_GraphQLHandler_Subscription(t *testing.T) {
+	ctx := context.Background()
+	broker := NewBroker()
+	storage := NewEventStorage(NewMemoryRepository(), broker)
+	server := httptest.NewServer(newGraphQLTestServer(t, storage, broker))
+	defer server.Close()
+
+	body := `{"query":"subscription { personChanged { type personId person { name } } }"}`
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+"/graphql", strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set(echo.HeaderAccept, "text/event-stream")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, "text/event-stream", resp.Header.Get(echo.HeaderContentType))
+
+	// The subscription is registered once the response has started.
+	require.Eventually(t, func() bool {
+		broker.mu.Lock()
+		defer broker.mu.Unlock()
+		return len(broker.subscribers) == 1
+	}, time.Second, time.Millisecond)
+
+	reader := bufio.NewReader(resp.Body)
+	nextEvent := func() string {
+		for {
+			line, err := reader.ReadString('\n')
+			require.NoError(t, err)
+			if data, ok := strings.CutPrefix(line, "data: "); ok {
+				return data
+			}
+		}
+	}
+
+	id, err := storage.CreatePerson(ctx, Person{Name: ptrTo("Ivan")})
+	require.NoError(t, err)
+	require.JSONEq(t, `{"data":{"personChanged":{"type":"CREATED","personId":"1","person":{"name":"Ivan"}}}}`, nextEvent())
+
+	_, err = storage.DeletePerson(ctx, id)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"data":{"personChanged":{"type":"DELETED","personId":"1","person":null}}}`, nextEvent())
+}
+
+func Test_GraphQLHandler_Stream(t *testing.T) {
+	e := newGraphQLTestServer(t, NewMemoryRepository(), NewBroker())
+
+	req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(`{"query":"{ persons { totalCount } }"}`))
+	req.Header.Set(echo.HeaderAccept, "text/event-stream")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "event: next\ndata: {\"data\":{\"persons\":{\"totalCount\":0}}}\n\nevent: complete\ndata:\n\n", rec.Body.String())
+}
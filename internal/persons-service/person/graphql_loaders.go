@@ -0,0 +1,136 @@
+package person
+
+import (
+	"context"
+	"github.com/graph-gophers/dataloader"
+	"strconv"
+	"time"
+)
+
+// loaderWait is how long a loader collects the keys requested by the resolvers of a level of
+// the query before it loads them in one storage call.
+const loaderWait = 2 * time.Millisecond
+
+// loaderKey is the id of a person as a dataloader key.
+type loaderKey int
+
+func (k loaderKey) String() string {
+	return strconv.Itoa(int(k))
+}
+
+func (k loaderKey) Raw() interface{} {
+	return int(k)
+}
+
+// graphqlLoaders batch the lookups of the resolvers of one GraphQL operation, so a list of n
+// persons with their relations and related persons takes three storage calls instead of 2n+1.
+type graphqlLoaders struct {
+	persons   *dataloader.Loader
+	relations *dataloader.Loader
+}
+
+// newGraphQLLoaders creates the loaders of an operation. Loaded values are kept for the whole
+// operation if cached is set, which subscriptions must not do as they outlive the changes.
+func newGraphQLLoaders(storage storage, cached bool) *graphqlLoaders {
+	opts := []dataloader.Option{
+		dataloader.WithWait(loaderWait),
+		dataloader.WithBatchCapacity(maxPageLimit),
+	}
+	if !cached {
+		opts = append(opts, dataloader.WithCache(&dataloader.NoCache{}))
+	}
+
+	return &graphqlLoaders{
+		persons:   dataloader.NewBatchedLoader(batchPersons(storage), opts...),
+		relations: dataloader.NewBatchedLoader(batchRelations(storage), opts...),
+	}
+}
+
+// person returns the stored person with id, or a person without an id if there is none.
+func (l *graphqlLoaders) person(ctx context.Context, id int) (Person, error) {
+	v, err := l.persons.Load(ctx, loaderKey(id))()
+	if err != nil {
+		return Person{}, err
+	}
+	return v.(Person), nil
+}
+
+func (l *graphqlLoaders) relationsOf(ctx context.Context, personID int) ([]Relation, error) {
+	v, err := l.relations.Load(ctx, loaderKey(personID))()
+	if err != nil {
+		return nil, err
+	}
+	return v.([]Relation), nil
+}
+
+// prime keeps persons that have been loaded otherwise, e.g. listed, for the operation.
+func (l *graphqlLoaders) prime(ctx context.Context, persons []Person) {
+	for _, p := range persons {
+		l.persons.Prime(ctx, loaderKey(*p.ID), p)
+	}
+}
+
+// forget drops what has been loaded for the person with id after a mutation changed it.
+func (l *graphqlLoaders) forget(ctx context.Context, id int) {
+	l.persons.Clear(ctx, loaderKey(id))
+	l.relations.ClearAll()
+}
+
+func loaderIDs(keys dataloader.Keys) []int {
+	ids := make([]int, len(keys))
+	for i, key := range keys {
+		ids[i] = key.Raw().(int)
+	}
+	return ids
+}
+
+func loaderErrors(n int, err error) []*dataloader.Result {
+	res := make([]*dataloader.Result, n)
+	for i := range res {
+		res[i] = &dataloader.Result{Error: err}
+	}
+	return res
+}
+
+func batchPersons(storage storage) dataloader.BatchFunc {
+	return func(ctx context.Context, keys dataloader.Keys) []*dataloader.Result {
+		ids := loaderIDs(keys)
+		persons, err := storage.GetPersonsByIDs(ctx, ids)
+		if err != nil {
+			return loaderErrors(len(keys), err)
+		}
+
+		byID := make(map[int]Person, len(persons))
+		for _, p := range persons {
+			byID[*p.ID] = p
+		}
+
+		res := make([]*dataloader.Result, len(ids))
+		for i, id := range ids {
+			res[i] = &dataloader.Result{Data: byID[id]}
+		}
+		return res
+	}
+}
+
+func batchRelations(storage storage) dataloader.BatchFunc {
+	return func(ctx context.Context, keys dataloader.Keys) []*dataloader.Result {
+		ids := loaderIDs(keys)
+		relations, err := storage.GetRelationsByPersonIDs(ctx, ids)
+		if err != nil {
+			return loaderErrors(len(keys), err)
+		}
+
+		res := make([]*dataloader.Result, len(ids))
+		for i, id := range ids {
+			personRelations := make([]Relation, 0)
+			for _, r := range relations {
+				if r.involves(id) {
+					personRelations = append(personRelations, r)
+				}
+			}
+			res[i] = &dataloader.Result{Data: personRelations}
+		}
+		return res
+	}
+}
@@ -0,0 +1,463 @@
+package person
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/graph-gophers/graphql-go"
+	"github.com/rs/zerolog"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// graphqlResolver is the root resolver of the GraphQL schema. It applies the rules of the echo
+// handler like the gRPC handler does.
+type graphqlResolver struct {
+	g *graphqlHandler
+}
+
+type attributeFilter struct {
+	Name  string
+	Value string
+}
+
+type personsArgs struct {
+	Tags       *[]string
+	Attributes *[]attributeFilter
+	Limit      *int32
+	Offset     *int32
+}
+
+type addressPartsInput struct {
+	Street     *string
+	City       *string
+	PostalCode *string
+	Country    *string
+}
+
+type personInput struct {
+	Name         *string
+	Age          *int32
+	BirthDate    *string
+	Email        *string
+	PhoneNumbers *[]string
+	Address      *string
+	AddressParts *addressPartsInput
+	Work         *string
+	Attributes   *jsonValue
+	Tags         *[]string
+}
+
+func (r *graphqlResolver) Person(ctx context.Context, args struct{ ID graphql.ID }) (*personResolver, error) {
+	id, err := parseGraphQLID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := r.g.handler.getPerson(ctx, id)
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, graphqlInternalError(ctx, err, "getting person error")
+	}
+	graphqlContextFrom(ctx).loaders.prime(ctx, []Person{p})
+
+	return newPersonResolver(p), nil
+}
+
+// Persons lists the persons like GetPersons does.
+func (r *graphqlResolver) Persons(ctx context.Context, args personsArgs) (*personPageResolver, error) {
+	logger := zerolog.Ctx(ctx)
+
+	params := url.Values{}
+	if args.Tags != nil {
+		params["tag"] = *args.Tags
+	}
+	if args.Attributes != nil {
+		for _, attribute := range *args.Attributes {
+			params.Add(attributeFilterPrefix+attribute.Name, attribute.Value)
+		}
+	}
+	if args.Limit != nil {
+		params.Set("limit", strconv.Itoa(int(*args.Limit)))
+	}
+	if args.Offset != nil {
+		params.Set("offset", strconv.Itoa(int(*args.Offset)))
+	}
+
+	match, filtered, err := parsePersonFilter(params)
+	if err != nil {
+		logger.Warn().Err(err).Msg("wrong filter")
+		return nil, &graphqlError{message: err.Error(), code: graphqlCodeBadUserInput}
+	}
+	page, err := parsePage(params)
+	if err != nil {
+		logger.Warn().Err(err).Msg("wrong page")
+		return nil, &graphqlError{message: err.Error(), code: graphqlCodeBadUserInput}
+	}
+
+	persons, total, err := listPersons(ctx, r.g.handler.storage, match, filtered, page, nil)
+	if err != nil {
+		return nil, graphqlInternalError(ctx, err, "getting persons error")
+	}
+
+	graphqlContextFrom(ctx).loaders.prime(ctx, persons)
+
+	items := make([]*personResolver, len(persons))
+	for i, p := range persons {
+		items[i] = newPersonResolver(p)
+	}
+	return &personPageResolver{totalCount: int32(total), items: items}, nil
+}
+
+func (r *graphqlResolver) CreatePerson(ctx context.Context, args struct{ Input personInput }) (*personResolver, error) {
+	logger := zerolog.Ctx(ctx)
+
+	p, err := r.g.validate(ctx, args.Input)
+	if err != nil {
+		logger.Warn().Err(err).Msg("validation error")
+		return nil, err
+	}
+
+	created, err := r.g.handler.createPerson(ctx, graphqlContextFrom(ctx).tenant, p)
+	if err != nil {
+		return nil, graphqlPersonError(ctx, err, "creating person error")
+	}
+
+	return newPersonResolver(created), nil
+}
+
+func (r *graphqlResolver) UpdatePerson(ctx context.Context, args struct {
+	ID    graphql.ID
+	Input personInput
+}) (*personResolver, error) {
+	id, err := parseGraphQLID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+	logger := zerolog.Ctx(ctx).With().Int("person_id", id).Logger()
+	ctx = logger.WithContext(ctx)
+
+	p, err := r.g.validate(ctx, args.Input)
+	if err != nil {
+		logger.Warn().Err(err).Msg("validation error")
+		return nil, err
+	}
+
+	p, err = r.g.handler.updatePerson(ctx, graphqlContextFrom(ctx).tenant, id, p)
+	if err != nil {
+		return nil, graphqlPersonError(ctx, err, "updating person error")
+	}
+	graphqlContextFrom(ctx).loaders.forget(ctx, id)
+
+	return newPersonResolver(p), nil
+}
+
+func (r *graphqlResolver) DeletePerson(ctx context.Context, args struct{ ID graphql.ID }) (graphql.ID, error) {
+	id, err := parseGraphQLID(args.ID)
+	if err != nil {
+		return "", err
+	}
+
+	isDeleted, err := r.g.handler.storage.DeletePerson(ctx, id)
+	if err != nil {
+		return "", graphqlInternalError(ctx, err, "deleting person error")
+	}
+	if !isDeleted {
+		zerolog.Ctx(ctx).Info().Int("person_id", id).Msg("person not found")
+		return "", &graphqlError{message: "person not found", code: graphqlCodeNotFound}
+	}
+	graphqlContextFrom(ctx).loaders.forget(ctx, id)
+
+	return args.ID, nil
+}
+
+// PersonChanged sends the events of the broker until the subscription ends.
+func (r *graphqlResolver) PersonChanged(ctx context.Context, args struct{ ID *graphql.ID }) (<-chan *personEventResolver, error) {
+	var id int
+	if args.ID != nil {
+		var err error
+		if id, err = parseGraphQLID(*args.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	events := r.g.broker.Subscribe(ctx)
+	res := make(chan *personEventResolver)
+	go func() {
+		defer close(res)
+		for e := range events {
+			if args.ID != nil && e.PersonID != id {
+				continue
+			}
+			select {
+			case res <- &personEventResolver{event: e}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return res, nil
+}
+
+func parseGraphQLID(id graphql.ID) (int, error) {
+	res, err := strconv.Atoi(string(id))
+	if err != nil {
+		return 0, &graphqlError{message: "wrong id", code: graphqlCodeBadUserInput}
+	}
+	return res, nil
+}
+
+// newPersonRequest converts the input of a mutation for the validation of the echo handler.
+func (in personInput) newPersonRequest() (personRequest, error) {
+	req := personRequest{
+		Name:      in.Name,
+		BirthDate: in.BirthDate,
+		Email:     in.Email,
+		Address:   in.Address,
+		Work:      in.Work,
+	}
+	if in.Age != nil {
+		age := int(*in.Age)
+		req.Age = &age
+	}
+	if in.PhoneNumbers != nil {
+		req.PhoneNumbers = *in.PhoneNumbers
+	}
+	if in.AddressParts != nil {
+		req.AddressParts = &addressParts{
+			Street:     in.AddressParts.Street,
+			City:       in.AddressParts.City,
+			PostalCode: in.AddressParts.PostalCode,
+			Country:    in.AddressParts.Country,
+		}
+	}
+	if in.Attributes != nil && in.Attributes.value != nil {
+		attributes, ok := in.Attributes.value.(map[string]interface{})
+		if !ok {
+			return personRequest{}, graphqlValidationError(map[string]string{"attributes": "attributes must be an object"})
+		}
+		req.Attributes = attributes
+	}
+	if in.Tags != nil {
+		req.Tags = *in.Tags
+	}
+	return req, nil
+}
+
+type personPageResolver struct {
+	totalCount int32
+	items      []*personResolver
+}
+
+func (r *personPageResolver) TotalCount() int32 {
+	return r.totalCount
+}
+
+func (r *personPageResolver) Items() []*personResolver {
+	return r.items
+}
+
+type personResolver struct {
+	p    Person
+	resp personResponse
+}
+
+func newPersonResolver(p Person) *personResolver {
+	return &personResolver{p: p, resp: newPersonResponse(p, time.Now())}
+}
+
+func (r *personResolver) ID() graphql.ID {
+	return graphql.ID(strconv.Itoa(r.resp.ID))
+}
+
+func (r *personResolver) Name() string {
+	return r.resp.Name
+}
+
+func (r *personResolver) Age() int32 {
+	return int32(r.resp.Age)
+}
+
+func (r *personResolver) Address() string {
+	return r.resp.Address
+}
+
+func (r *personResolver) Work() string {
+	return r.resp.Work
+}
+
+func (r *personResolver) BirthDate() *string {
+	return r.resp.BirthDate
+}
+
+func (r *personResolver) Email() *string {
+	return r.resp.Email
+}
+
+func (r *personResolver) PhoneNumbers() []string {
+	return nonNilStrings(r.resp.PhoneNumbers)
+}
+
+func (r *personResolver) AddressParts() *addressPartsResolver {
+	if r.resp.AddressParts == nil {
+		return nil
+	}
+	return &addressPartsResolver{parts: *r.resp.AddressParts}
+}
+
+func (r *personResolver) Attributes() *jsonValue {
+	if r.resp.Attributes == nil {
+		return nil
+	}
+	return &jsonValue{value: map[string]interface{}(r.resp.Attributes)}
+}
+
+func (r *personResolver) Tags() []string {
+	return nonNilStrings(r.resp.Tags)
+}
+
+func (r *personResolver) CreatedAt() *graphql.Time {
+	return graphqlTime(r.resp.CreatedAt)
+}
+
+func (r *personResolver) UpdatedAt() *graphql.Time {
+	return graphqlTime(r.resp.UpdatedAt)
+}
+
+func (r *personResolver) Relations(ctx context.Context) ([]*relationResolver, error) {
+	relations, err := graphqlContextFrom(ctx).loaders.relationsOf(ctx, r.resp.ID)
+	if err != nil {
+		return nil, graphqlInternalError(ctx, err, "getting relations error")
+	}
+
+	res := make([]*relationResolver, len(relations))
+	for i, relation := range relations {
+		res[i] = &relationResolver{relation: relation, personID: r.resp.ID}
+	}
+	return res, nil
+}
+
+type addressPartsResolver struct {
+	parts addressParts
+}
+
+func (r *addressPartsResolver) Street() *string {
+	return r.parts.Street
+}
+
+func (r *addressPartsResolver) City() *string {
+	return r.parts.City
+}
+
+func (r *addressPartsResolver) PostalCode() *string {
+	return r.parts.PostalCode
+}
+
+func (r *addressPartsResolver) Country() *string {
+	return r.parts.Country
+}
+
+// relationResolver is a relation as seen from the person with personID.
+type relationResolver struct {
+	relation Relation
+	personID int
+}
+
+func (r *relationResolver) ID() graphql.ID {
+	return graphql.ID(strconv.Itoa(valueOrZero(r.relation.ID)))
+}
+
+func (r *relationResolver) Type() string {
+	return r.relation.Type
+}
+
+func (r *relationResolver) Bidirectional() bool {
+	return r.relation.Bidirectional
+}
+
+func (r *relationResolver) CreatedAt() *graphql.Time {
+	return graphqlTime(r.relation.CreatedAt)
+}
+
+func (r *relationResolver) RelatedPerson(ctx context.Context) (*personResolver, error) {
+	id := r.relation.RelatedPersonID
+	if id == r.personID {
+		id = r.relation.PersonID
+	}
+
+	p, err := graphqlContextFrom(ctx).loaders.person(ctx, id)
+	if err != nil {
+		return nil, graphqlInternalError(ctx, err, "getting person error")
+	}
+	if p.ID == nil {
+		return nil, nil
+	}
+	return newPersonResolver(p), nil
+}
+
+type personEventResolver struct {
+	event Event
+}
+
+func (r *personEventResolver) Type() string {
+	return r.event.Type
+}
+
+func (r *personEventResolver) PersonID() graphql.ID {
+	return graphql.ID(strconv.Itoa(r.event.PersonID))
+}
+
+func (r *personEventResolver) At() graphql.Time {
+	return graphql.Time{Time: r.event.At}
+}
+
+func (r *personEventResolver) Person(ctx context.Context) (*personResolver, error) {
+	p, err := graphqlContextFrom(ctx).loaders.person(ctx, r.event.PersonID)
+	if err != nil {
+		return nil, graphqlInternalError(ctx, err, "getting person error")
+	}
+	if p.ID == nil {
+		return nil, nil
+	}
+	return newPersonResolver(p), nil
+}
+
+// jsonValue is the JSON scalar of the schema.
+type jsonValue struct {
+	value interface{}
+}
+
+func (jsonValue) ImplementsGraphQLType(name string) bool {
+	return name == "JSON"
+}
+
+// UnmarshalGraphQL takes the value through JSON, so literals of the query and variables both
+// give the types encoding/json decodes to.
+func (v *jsonValue) UnmarshalGraphQL(input interface{}) error {
+	b, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, &v.value)
+}
+
+func (v jsonValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(v.value)
+}
+
+func graphqlTime(t *time.Time) *graphql.Time {
+	if t == nil {
+		return nil
+	}
+	return &graphql.Time{Time: *t}
+}
+
+func nonNilStrings(v []string) []string {
+	if v == nil {
+		return []string{}
+	}
+	return v
+}
@@ -0,0 +1,369 @@
+package person
+
+import (
+	"context"
+	"errors"
+	personsv1 "github.com/Erlendum/rsoi-lab-01/api/persons-service/v1"
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/config"
+	"github.com/Erlendum/rsoi-lab-01/pkg/validation"
+	"github.com/go-playground/validator/v10"
+	"github.com/rs/zerolog"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// grpcHandler serves the persons over gRPC with the rules of the echo handler: the input is
+// validated as a personRequest, the attributes against the schema of the tenant, and persons
+// are checked against the unique constraints.
+type grpcHandler struct {
+	personsv1.UnimplementedPersonServiceServer
+	handler   *handler
+	validator *validation.CustomValidator
+	tenantKey string
+}
+
+func NewGRPCHandler(storage storage, cfg *config.Persons) (*grpcHandler, error) {
+	h, err := NewHandler(storage, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	v := validation.MustRegisterCustomValidator(validator.New())
+	registerPersonValidation(v)
+
+	return &grpcHandler{
+		handler:   h,
+		validator: v,
+		tenantKey: strings.ToLower(cfg.Attributes.Header()),
+	}, nil
+}
+
+func (g *grpcHandler) Register(s grpc.ServiceRegistrar) {
+	personsv1.RegisterPersonServiceServer(s, g)
+}
+
+func (g *grpcHandler) CreatePerson(ctx context.Context, req *personsv1.CreatePersonRequest) (*personsv1.Person, error) {
+	logger := zerolog.Ctx(ctx)
+
+	p, err := g.validate(ctx, req.GetPerson())
+	if err != nil {
+		logger.Warn().Err(err).Msg("validation error")
+		return nil, err
+	}
+
+	created, err := g.handler.createPerson(ctx, metadataValue(ctx, g.tenantKey), p)
+	if err != nil {
+		return nil, personError(ctx, err, "creating person error")
+	}
+
+	return newPersonMessage(newPersonResponse(created, time.Now()))
+}
+
+func (g *grpcHandler) GetPerson(ctx context.Context, req *personsv1.GetPersonRequest) (*personsv1.Person, error) {
+	ctx = zerolog.Ctx(ctx).With().Int32("person_id", req.GetId()).Logger().WithContext(ctx)
+
+	p, err := g.handler.getPerson(ctx, int(req.GetId()))
+	if err != nil {
+		return nil, personError(ctx, err, "getting person error")
+	}
+
+	return newPersonMessage(newPersonResponse(p, time.Now()))
+}
+
+// ListPersons streams the persons like GetPersons lists them. The count of all persons matching
+// the filters is sent in the x-total-count header.
+func (g *grpcHandler) ListPersons(req *personsv1.ListPersonsRequest, stream personsv1.PersonService_ListPersonsServer) error {
+	ctx := stream.Context()
+	logger := zerolog.Ctx(ctx)
+
+	params := url.Values{"tag": req.GetTags()}
+	for name, value := range req.GetAttributes() {
+		params.Set(attributeFilterPrefix+name, value)
+	}
+	if req.GetLimit() != 0 {
+		params.Set("limit", strconv.Itoa(int(req.GetLimit())))
+	}
+	if req.GetOffset() != 0 {
+		params.Set("offset", strconv.Itoa(int(req.GetOffset())))
+	}
+
+	match, filtered, err := parsePersonFilter(params)
+	if err != nil {
+		logger.Warn().Err(err).Msg("wrong filter")
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	page, err := parsePage(params)
+	if err != nil {
+		logger.Warn().Err(err).Msg("wrong page")
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	total, err := g.handler.storage.CountPersons(ctx, match)
+	if err != nil {
+		return internalError(ctx, err, "getting persons error")
+	}
+
+	if err = stream.SetHeader(metadata.Pairs("x-total-count", strconv.Itoa(total))); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	var sendErr error
+	err = streamPersons(ctx, g.handler.storage, match, filtered, page, func(persons []Person) error {
+		for _, p := range persons {
+			if sendErr = sendPerson(stream, newPersonResponse(p, now)); sendErr != nil {
+				return sendErr
+			}
+		}
+		return nil
+	})
+	if sendErr != nil {
+		return sendErr
+	}
+	if err != nil {
+		return internalError(ctx, err, "getting persons error")
+	}
+
+	return nil
+}
+
+func (g *grpcHandler) UpdatePerson(ctx context.Context, req *personsv1.UpdatePersonRequest) (*personsv1.Person, error) {
+	logger := zerolog.Ctx(ctx).With().Int32("person_id", req.GetId()).Logger()
+	ctx = logger.WithContext(ctx)
+
+	p, err := g.validate(ctx, req.GetPerson())
+	if err != nil {
+		logger.Warn().Err(err).Msg("validation error")
+		return nil, err
+	}
+
+	p, err = g.handler.updatePerson(ctx, metadataValue(ctx, g.tenantKey), int(req.GetId()), p)
+	if err != nil {
+		return nil, personError(ctx, err, "updating person error")
+	}
+
+	return newPersonMessage(newPersonResponse(p, time.Now()))
+}
+
+func (g *grpcHandler) DeletePerson(ctx context.Context, req *personsv1.DeletePersonRequest) (*emptypb.Empty, error) {
+	isDeleted, err := g.handler.storage.DeletePerson(ctx, int(req.GetId()))
+	if err != nil {
+		return nil, internalError(ctx, err, "deleting person error")
+	}
+	if !isDeleted {
+		zerolog.Ctx(ctx).Info().Int32("person_id", req.GetId()).Msg("person not found")
+		return nil, status.Error(codes.NotFound, "person not found")
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+// ExportPersons streams the persons as Export writes them, the age as stored rather than
+// computed from the birth date.
+func (g *grpcHandler) ExportPersons(_ *personsv1.ExportPersonsRequest, stream personsv1.PersonService_ExportPersonsServer) error {
+	ctx := stream.Context()
+
+	var sendErr error
+	err := streamPersons(ctx, g.handler.storage, Person{}, false, Page{}, func(persons []Person) error {
+		for _, p := range persons {
+			resp := newPersonResponse(p, time.Time{})
+			resp.Age = valueOrZero(p.Age)
+			if sendErr = sendPerson(stream, resp); sendErr != nil {
+				return sendErr
+			}
+		}
+		return nil
+	})
+	if sendErr != nil {
+		return sendErr
+	}
+	if err != nil {
+		return internalError(ctx, err, "exporting persons error")
+	}
+
+	return nil
+}
+
+// sendPerson sends resp on stream, which is one of the person streams.
+func sendPerson(stream interface{ Send(*personsv1.Person) error }, resp personResponse) error {
+	msg, err := newPersonMessage(resp)
+	if err != nil {
+		return err
+	}
+	return stream.Send(msg)
+}
+
+// validate converts in to a person with the field rules of the echo handler. It returns an
+// INVALID_ARGUMENT status listing the invalid fields in the language of the accept-language
+// metadata.
+func (g *grpcHandler) validate(ctx context.Context, in *personsv1.PersonInput) (Person, error) {
+	req := newPersonRequest(in)
+	if err := g.validator.Validate(req); err != nil {
+		var verr *validation.Error
+		if errors.As(err, &verr) {
+			return Person{}, invalidArgumentError(verr.Translate(metadataValue(ctx, "accept-language")))
+		}
+		return Person{}, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	return req.toPerson(), nil
+}
+
+func metadataValue(ctx context.Context, key string) string {
+	values := metadata.ValueFromIncomingContext(ctx, key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func newPersonRequest(in *personsv1.PersonInput) personRequest {
+	if in == nil {
+		in = &personsv1.PersonInput{}
+	}
+
+	req := personRequest{
+		Name:         in.Name,
+		BirthDate:    in.BirthDate,
+		Email:        in.Email,
+		PhoneNumbers: in.PhoneNumbers,
+		Address:      in.Address,
+		Work:         in.Work,
+		Tags:         in.Tags,
+	}
+	if in.Age != nil {
+		age := int(*in.Age)
+		req.Age = &age
+	}
+	if in.AddressParts != nil {
+		req.AddressParts = &addressParts{
+			Street:     in.AddressParts.Street,
+			City:       in.AddressParts.City,
+			PostalCode: in.AddressParts.PostalCode,
+			Country:    in.AddressParts.Country,
+		}
+	}
+	if in.Attributes != nil {
+		req.Attributes = in.Attributes.AsMap()
+	}
+	return req
+}
+
+func newPersonMessage(resp personResponse) (*personsv1.Person, error) {
+	msg := &personsv1.Person{
+		Id:           int32(resp.ID),
+		Name:         resp.Name,
+		Age:          int32(resp.Age),
+		Address:      resp.Address,
+		Work:         resp.Work,
+		BirthDate:    resp.BirthDate,
+		Email:        resp.Email,
+		PhoneNumbers: resp.PhoneNumbers,
+		Tags:         resp.Tags,
+	}
+	if resp.AddressParts != nil {
+		msg.AddressParts = &personsv1.AddressParts{
+			Street:     resp.AddressParts.Street,
+			City:       resp.AddressParts.City,
+			PostalCode: resp.AddressParts.PostalCode,
+			Country:    resp.AddressParts.Country,
+		}
+	}
+	if resp.Attributes != nil {
+		attributes, err := structpb.NewStruct(resp.Attributes)
+		if err != nil {
+			return nil, status.Error(codes.Internal, "encoding person attributes error")
+		}
+		msg.Attributes = attributes
+	}
+	if resp.CreatedAt != nil {
+		msg.CreatedAt = timestamppb.New(*resp.CreatedAt)
+	}
+	if resp.UpdatedAt != nil {
+		msg.UpdatedAt = timestamppb.New(*resp.UpdatedAt)
+	}
+	return msg, nil
+}
+
+// invalidArgumentError lists the messages of the invalid fields as a BadRequest detail.
+func invalidArgumentError(fields map[string]string) error {
+	names := make([]string, 0, len(fields))
+	for field := range fields {
+		names = append(names, field)
+	}
+	sort.Strings(names)
+
+	badRequest := &errdetails.BadRequest{}
+	for _, field := range names {
+		badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       field,
+			Description: fields[field],
+		})
+	}
+
+	st, err := status.New(codes.InvalidArgument, "validation error").WithDetails(badRequest)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, "validation error")
+	}
+	return st.Err()
+}
+
+// alreadyExistsError rejects a person that clashes with existing under a unique constraint,
+// naming existing in a ResourceInfo detail.
+func alreadyExistsError(existing Person) error {
+	if existing.ID == nil {
+		return status.Error(codes.AlreadyExists, "person already exists")
+	}
+	st, err := status.New(codes.AlreadyExists, "person already exists").WithDetails(&errdetails.ResourceInfo{
+		ResourceType: "persons.v1.Person",
+		ResourceName: "persons/" + strconv.Itoa(*existing.ID),
+		Description:  "the existing person with the same unique fields",
+	})
+	if err != nil {
+		return status.Error(codes.AlreadyExists, "person already exists")
+	}
+	return st.Err()
+}
+
+// personError maps the errors of the shared person operations to their statuses, and any
+// other error to INTERNAL with msg.
+func personError(ctx context.Context, err error, msg string) error {
+	logger := zerolog.Ctx(ctx)
+
+	var attributesErr *AttributesError
+	if errors.As(err, &attributesErr) {
+		logger.Warn().Err(err).Msg("attributes validation error")
+		return invalidArgumentError(attributesErr.Fields)
+	}
+	if errors.Is(err, ErrNotFound) {
+		logger.Info().Msg("person not found")
+		return status.Error(codes.NotFound, "person not found")
+	}
+	var conflict *ConflictError
+	if errors.As(err, &conflict) {
+		logger.Info().Int("existing_person_id", valueOrZero(conflict.Existing.ID)).Msg("person already exists")
+		return alreadyExistsError(conflict.Existing)
+	}
+	return internalError(ctx, err, msg)
+}
+
+// internalError logs a storage error and hides it behind msg. Errors caused by the end of the
+// call are returned as its CANCELED or DEADLINE_EXCEEDED status instead.
+func internalError(ctx context.Context, err error, msg string) error {
+	if ctx.Err() != nil {
+		return status.FromContextError(ctx.Err()).Err()
+	}
+	zerolog.Ctx(ctx).Error().Err(err).Msg(msg)
+	return status.Error(codes.Internal, msg)
+}
@@ -0,0 +1,252 @@
+package person
+
+import (
+	"context"
+	personsv1 "github.com/Erlendum/rsoi-lab-01/api/persons-service/v1"
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/config"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/structpb"
+	"io"
+	"net"
+	"testing"
+)
+
+// newGRPCTestClient serves a grpcHandler on storage over an in-memory connection.
+func newGRPCTestClient(t *testing.T, storage storage) personsv1.PersonServiceClient {
+	h, err := NewGRPCHandler(storage, &config.Persons{
+		Attributes: config.Attributes{
+			TenantHeader: "X-Tenant-ID",
+			Schemas:      map[string]string{"default": "../../../configs/persons-service/attributes/default.schema.json"},
+		},
+	})
+	require.NoError(t, err)
+
+	listener := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	h.Register(srv)
+	go func() {
+		_ = srv.Serve(listener)
+	}()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return personsv1.NewPersonServiceClient(conn)
+}
+
+func ptrTo[T any](v T) *T {
+	return &v
+}
+
+func receiveAll[T any](t *testing.T, recv func() (T, error)) ([]T, error) {
+	var res []T
+	for {
+		msg, err := recv()
+		if err == io.EOF {
+			return res, nil
+		}
+		if err != nil {
+			return res, err
+		}
+		res = append(res, msg)
+	}
+}
+
+func Test_GRPCHandler(t *testing.T) {
+	ctx := context.Background()
+	c := newGRPCTestClient(t, NewMemoryRepository())
+
+	attributes, err := structpb.NewStruct(map[string]interface{}{"region": "eu"})
+	require.NoError(t, err)
+	created, err := c.CreatePerson(ctx, &personsv1.CreatePersonRequest{Person: &personsv1.PersonInput{
+		Name:         ptrTo("Ivan"),
+		BirthDate:    ptrTo("1990-01-01"),
+		Email:        ptrTo("ivan@example.com"),
+		AddressParts: &personsv1.AddressParts{City: ptrTo("Moscow")},
+		Attributes:   attributes,
+		Tags:         []string{"vip", "vip"},
+	}})
+	require.NoError(t, err)
+	require.EqualValues(t, 1, created.Id)
+	require.Equal(t, "Ivan", created.Name)
+	require.Greater(t, created.Age, int32(30))
+	require.Equal(t, "Moscow", created.AddressParts.GetCity())
+	require.Equal(t, []string{"vip"}, created.Tags)
+	require.Equal(t, "eu", created.Attributes.AsMap()["region"])
+	require.NotNil(t, created.CreatedAt)
+
+	_, err = c.CreatePerson(ctx, &personsv1.CreatePersonRequest{Person: &personsv1.PersonInput{Name: ptrTo("Petr"), Age: ptrTo(int32(40))}})
+	require.NoError(t, err)
+
+	got, err := c.GetPerson(ctx, &personsv1.GetPersonRequest{Id: 1})
+	require.NoError(t, err)
+	require.Equal(t, created.Email, got.Email)
+
+	updated, err := c.UpdatePerson(ctx, &personsv1.UpdatePersonRequest{Id: 2, Person: &personsv1.PersonInput{Name: ptrTo("Petr"), Work: ptrTo("Acme")}})
+	require.NoError(t, err)
+	require.Equal(t, "Acme", updated.Work)
+	require.EqualValues(t, 40, updated.Age)
+
+	var header metadata.MD
+	stream, err := c.ListPersons(ctx, &personsv1.ListPersonsRequest{Limit: 1, Offset: 1}, grpc.Header(&header))
+	require.NoError(t, err)
+	listed, err := receiveAll(t, stream.Recv)
+	require.NoError(t, err)
+	require.Len(t, listed, 1)
+	require.Equal(t, "Petr", listed[0].Name)
+	require.Equal(t, []string{"2"}, header.Get("x-total-count"))
+
+	stream, err = c.ListPersons(ctx, &personsv1.ListPersonsRequest{Tags: []string{"vip"}, Attributes: map[string]string{"region": "eu"}})
+	require.NoError(t, err)
+	listed, err = receiveAll(t, stream.Recv)
+	require.NoError(t, err)
+	require.Len(t, listed, 1)
+	require.Equal(t, "Ivan", listed[0].Name)
+
+	export, err := c.ExportPersons(ctx, &personsv1.ExportPersonsRequest{})
+	require.NoError(t, err)
+	exported, err := receiveAll(t, export.Recv)
+	require.NoError(t, err)
+	require.Len(t, exported, 2)
+	require.EqualValues(t, 0, exported[0].Age)
+	require.Equal(t, "1990-01-01", exported[0].GetBirthDate())
+
+	_, err = c.DeletePerson(ctx, &personsv1.DeletePersonRequest{Id: 2})
+	require.NoError(t, err)
+	_, err = c.GetPerson(ctx, &personsv1.GetPersonRequest{Id: 2})
+	require.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func Test_GRPCHandler_Errors(t *testing.T) {
+	ctx := context.Background()
+	c := newGRPCTestClient(t, newUniqueEmailRepository(t))
+
+	_, err := c.CreatePerson(ctx, &personsv1.CreatePersonRequest{Person: &personsv1.PersonInput{Name: ptrTo("Ivan"), Email: ptrTo("ivan@example.com")}})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name               string
+		call               func(ctx context.Context) error
+		expectedCode       codes.Code
+		expectedViolations map[string]string
+		expectedResource   string
+	}{
+		{
+			name: "validation error",
+			call: func(ctx context.Context) error {
+				_, err := c.CreatePerson(ctx, &personsv1.CreatePersonRequest{Person: &personsv1.PersonInput{Age: ptrTo(int32(200))}})
+				return err
+			},
+			expectedCode: codes.InvalidArgument,
+			expectedViolations: map[string]string{
+				"name": "name is a required field",
+				"age":  "age must be between 0 and 150",
+			},
+		},
+		{
+			name: "validation error in the language of the call",
+			call: func(ctx context.Context) error {
+				ctx = metadata.AppendToOutgoingContext(ctx, "accept-language", "ru")
+				_, err := c.UpdatePerson(ctx, &personsv1.UpdatePersonRequest{Id: 1, Person: &personsv1.PersonInput{Name: ptrTo("Ivan"), Age: ptrTo(int32(-1))}})
+				return err
+			},
+			expectedCode:       codes.InvalidArgument,
+			expectedViolations: map[string]string{"age": "age должен быть от 0 до 150"},
+		},
+		{
+			name: "attributes validation error",
+			call: func(ctx context.Context) error {
+				attributes, _ := structpb.NewStruct(map[string]interface{}{"Region": "eu"})
+				_, err := c.CreatePerson(ctx, &personsv1.CreatePersonRequest{Person: &personsv1.PersonInput{Name: ptrTo("Petr"), Attributes: attributes}})
+				return err
+			},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name: "conflict",
+			call: func(ctx context.Context) error {
+				_, err := c.CreatePerson(ctx, &personsv1.CreatePersonRequest{Person: &personsv1.PersonInput{Name: ptrTo("Ivan"), Email: ptrTo("ivan@example.com")}})
+				return err
+			},
+			expectedCode:     codes.AlreadyExists,
+			expectedResource: "persons/1",
+		},
+		{
+			name: "update of missing person",
+			call: func(ctx context.Context) error {
+				_, err := c.UpdatePerson(ctx, &personsv1.UpdatePersonRequest{Id: 100, Person: &personsv1.PersonInput{Name: ptrTo("Petr")}})
+				return err
+			},
+			expectedCode: codes.NotFound,
+		},
+		{
+			name: "delete of missing person",
+			call: func(ctx context.Context) error {
+				_, err := c.DeletePerson(ctx, &personsv1.DeletePersonRequest{Id: 100})
+				return err
+			},
+			expectedCode: codes.NotFound,
+		},
+		{
+			name: "wrong page",
+			call: func(ctx context.Context) error {
+				stream, err := c.ListPersons(ctx, &personsv1.ListPersonsRequest{Offset: -1})
+				if err != nil {
+					return err
+				}
+				_, err = stream.Recv()
+				return err
+			},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name: "canceled call",
+			call: func(ctx context.Context) error {
+				ctx, cancel := context.WithCancel(ctx)
+				cancel()
+				_, err := c.GetPerson(ctx, &personsv1.GetPersonRequest{Id: 1})
+				return err
+			},
+			expectedCode: codes.Canceled,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st, ok := status.FromError(tt.call(ctx))
+			require.True(t, ok)
+			require.Equal(t, tt.expectedCode, st.Code(), st.Message())
+
+			violations := map[string]string{}
+			var resource string
+			for _, detail := range st.Details() {
+				switch d := detail.(type) {
+				case *errdetails.BadRequest:
+					for _, v := range d.FieldViolations {
+						violations[v.Field] = v.Description
+					}
+				case *errdetails.ResourceInfo:
+					resource = d.ResourceName
+				}
+			}
+			if tt.expectedViolations != nil {
+				require.Equal(t, tt.expectedViolations, violations)
+			}
+			require.Equal(t, tt.expectedResource, resource)
+		})
+	}
+}
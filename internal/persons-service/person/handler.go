@@ -1,13 +1,27 @@
 package person
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/config"
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/tracing"
+	"github.com/Erlendum/rsoi-lab-01/pkg/validation"
+	"github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v4"
-	"github.com/rs/zerolog/log"
+	"github.com/lib/pq"
+	"github.com/rs/zerolog"
 	"io"
+	"math"
 	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 )
 
 //go:generate mockgen -source=handler.go  -destination=handler_mocks.go -self_package=github.com/Erlendum/rsoi-lab-01/internal/persons-service/person -package=person
@@ -16,75 +30,338 @@ type storage interface {
 	CreatePerson(ctx context.Context, person Person) (int, error)
 	UpdatePerson(ctx context.Context, id int, person *Person) error
 	DeletePerson(ctx context.Context, id int) (bool, error)
-	GetPersons(ctx context.Context) ([]Person, error)
-	GetPerson(ctx context.Context, id int) (Person, error)
+	GetPersons(ctx context.Context, page Page, fields ...string) ([]Person, error)
+	GetPerson(ctx context.Context, id int, fields ...string) (Person, error)
+	GetPersonsByIDs(ctx context.Context, ids []int) ([]Person, error)
+	FindPersons(ctx context.Context, match Person, page Page, fields ...string) ([]Person, error)
+	CountPersons(ctx context.Context, match Person) (int, error)
+	FindDuplicateCandidates(ctx context.Context, p Person, limit int) ([]Person, error)
+	MergePersons(ctx context.Context, id, sourceID int) (Person, error)
+	PersonsChanged(ctx context.Context, ids []int)
+	GetPersonAlias(ctx context.Context, aliasID int) (int, error)
+	CreateRelation(ctx context.Context, relation Relation) (int, error)
+	GetRelations(ctx context.Context, personID int) ([]Relation, error)
+	GetRelationsByPersonIDs(ctx context.Context, personIDs []int) ([]Relation, error)
+	DeleteRelation(ctx context.Context, personID, relationID int) (bool, error)
+	GetRelatives(ctx context.Context, personID, maxDepth int, types []string) ([]Relative, error)
+}
+
+type addressParts struct {
+	Street     *string `json:"street,omitempty" xml:"street,omitempty" validate:"omitempty,max=255"`
+	City       *string `json:"city,omitempty" xml:"city,omitempty" validate:"omitempty,max=100"`
+	PostalCode *string `json:"postal_code,omitempty" xml:"postal_code,omitempty" validate:"omitempty,max=20"`
+	Country    *string `json:"country,omitempty" xml:"country,omitempty" validate:"omitempty,max=100"`
+}
+
+type personRequest struct {
+	Name         *string       `json:"name" xml:"name" validate:"required,max=255"`
+	Age          *int          `json:"age" xml:"age" validate:"omitempty,age"`
+	BirthDate    *string       `json:"birth_date" xml:"birth_date" validate:"omitempty,past_date"`
+	Email        *string       `json:"email" xml:"email" validate:"omitempty,email,max=255"`
+	PhoneNumbers []string      `json:"phone_numbers" xml:"phone_numbers>phone_number" validate:"omitempty,max=10,dive,phone"`
+	Address      *string       `json:"address" xml:"address" validate:"omitempty,max=255"`
+	AddressParts *addressParts `json:"address_parts" xml:"address_parts"`
+	Work         *string       `json:"work" xml:"work" validate:"omitempty,max=255"`
+	Attributes   Attributes    `json:"attributes" xml:"attributes"`
+	Tags         []string      `json:"tags" xml:"tags>tag" validate:"omitempty,max=20,dive,required,max=50"`
+}
+
+// toPerson converts a validated request, so the birth date is known to be well-formed.
+func (r *personRequest) toPerson() Person {
+	p := Person{
+		Name:         r.Name,
+		Age:          r.Age,
+		Email:        r.Email,
+		PhoneNumbers: r.PhoneNumbers,
+		Address:      r.Address,
+		Work:         r.Work,
+		Attributes:   r.Attributes,
+	}
+	if r.Tags != nil {
+		p.Tags = make(pq.StringArray, 0, len(r.Tags))
+		for _, tag := range r.Tags {
+			if !slices.Contains(p.Tags, tag) {
+				p.Tags = append(p.Tags, tag)
+			}
+		}
+	}
+	if r.BirthDate != nil {
+		birthDate, _ := time.Parse(validation.DateLayout, *r.BirthDate)
+		p.BirthDate = &birthDate
+	}
+	if r.AddressParts != nil {
+		p.Street = r.AddressParts.Street
+		p.City = r.AddressParts.City
+		p.PostalCode = r.AddressParts.PostalCode
+		p.Country = r.AddressParts.Country
+	}
+	return p
+}
+
+type personResponse struct {
+	XMLName      xml.Name      `json:"-" xml:"person"`
+	ID           int           `json:"id" xml:"id"`
+	Name         string        `json:"name" xml:"name"`
+	Age          int           `json:"age" xml:"age"`
+	Address      string        `json:"address" xml:"address"`
+	Work         string        `json:"work" xml:"work"`
+	BirthDate    *string       `json:"birth_date,omitempty" xml:"birth_date,omitempty"`
+	Email        *string       `json:"email,omitempty" xml:"email,omitempty"`
+	PhoneNumbers []string      `json:"phone_numbers,omitempty" xml:"phone_numbers>phone_number,omitempty"`
+	AddressParts *addressParts `json:"address_parts,omitempty" xml:"address_parts,omitempty"`
+	Attributes   Attributes    `json:"attributes,omitempty" xml:"attributes,omitempty"`
+	Tags         []string      `json:"tags,omitempty" xml:"tags>tag,omitempty"`
+	CreatedAt    *time.Time    `json:"created_at,omitempty" xml:"created_at,omitempty"`
+	UpdatedAt    *time.Time    `json:"updated_at,omitempty" xml:"updated_at,omitempty"`
+}
+
+// newPersonResponse builds the response for p with the age computed at now.
+func newPersonResponse(p Person, now time.Time) personResponse {
+	resp := personResponse{
+		ID:           valueOrZero(p.ID),
+		Name:         valueOrZero(p.Name),
+		Age:          valueOrZero(p.CurrentAge(now)),
+		Address:      valueOrZero(p.Address),
+		Work:         valueOrZero(p.Work),
+		Email:        p.Email,
+		PhoneNumbers: p.PhoneNumbers,
+		Attributes:   p.Attributes,
+		Tags:         p.Tags,
+		CreatedAt:    p.CreatedAt,
+		UpdatedAt:    p.UpdatedAt,
+	}
+	if p.BirthDate != nil {
+		birthDate := p.BirthDate.Format(validation.DateLayout)
+		resp.BirthDate = &birthDate
+	}
+	if p.Street != nil || p.City != nil || p.PostalCode != nil || p.Country != nil {
+		resp.AddressParts = &addressParts{
+			Street:     p.Street,
+			City:       p.City,
+			PostalCode: p.PostalCode,
+			Country:    p.Country,
+		}
+	}
+	return resp
+}
+
+// personValidator is the echo validator that person rules can be registered with.
+type personValidator interface {
+	RegisterStructValidation(fn validator.StructLevelFunc, types ...interface{})
+	RegisterTranslation(tag string, messages validation.Messages) error
+}
+
+// validatePersonRequest rejects an age that contradicts the birth date.
+func validatePersonRequest(sl validator.StructLevel) {
+	r := sl.Current().Interface().(personRequest)
+	if r.Age == nil || r.BirthDate == nil {
+		return
+	}
+
+	birthDate, err := time.Parse(validation.DateLayout, *r.BirthDate)
+	if err != nil {
+		return
+	}
+	if age := (Person{BirthDate: &birthDate}).CurrentAge(time.Now()); *age != *r.Age {
+		sl.ReportError(*r.Age, "age", "Age", "age_birth_date", "")
+	}
+}
+
+// attributeErrors checks the attributes of p against the schema of tenant. It returns a
+// message for each invalid attribute or nil if they conform.
+func (h *handler) attributeErrors(tenant string, p Person) map[string]string {
+	if h.attributeSchemas == nil || p.Attributes == nil {
+		return nil
+	}
+
+	err := h.attributeSchemas.Validate(tenant, map[string]interface{}(p.Attributes))
+	var serr *validation.SchemaError
+	if errors.As(err, &serr) {
+		errs := make(map[string]string, len(serr.Fields))
+		for path, message := range serr.Fields {
+			key := "attributes"
+			if path != "" {
+				key += "." + path
+			}
+			errs[key] = message
+		}
+		return errs
+	}
+	if err != nil {
+		return map[string]string{"attributes": err.Error()}
+	}
+	return nil
+}
+
+// conflictResponse rejects a person that clashes with existing under a unique constraint, and
+// points to existing if the storage knows it.
+func conflictResponse(c echo.Context, existing Person) error {
+	if existing.ID == nil {
+		return errorResponse(c, http.StatusConflict, "person already exists")
+	}
+	location := personPath(c, *existing.ID)
+	c.Response().Header().Set("Location", location)
+	return errorResponse(c, http.StatusConflict, "person already exists", echo.Map{"location": location})
+}
+
+// Getter reads single persons, for the handlers of the records that belong to a person.
+type Getter interface {
+	GetPerson(ctx context.Context, id int, fields ...string) (Person, error)
+}
+
+// Exists writes the error response and returns false if the person with id can not be read
+// from persons or does not exist. The response is in the representation of the API version of
+// the request, v1 on the routes that have no other.
+func Exists(c echo.Context, persons Getter, id int) (bool, error) {
+	logger := zerolog.Ctx(c.Request().Context()).With().Int("person_id", id).Logger()
+
+	p, err := persons.GetPerson(c.Request().Context(), id)
+	if err != nil {
+		logger.Error().Err(err).Msg("getting person error")
+		return false, errorResponse(c, http.StatusInternalServerError, "getting person error")
+	}
+
+	if p.ID == nil {
+		logger.Info().Msg("person not found")
+		return false, errorResponse(c, http.StatusNotFound, "person not found")
+	}
+
+	return true, nil
 }
 
 type handler struct {
-	storage storage
+	storage            storage
+	duplicateThreshold float64
+	attributeSchemas   *validation.SchemaRegistry
+	tenantHeader       string
+	// deprecations announce the retirement of the API versions they are set for.
+	deprecations map[*apiVersion]config.Deprecation
 }
 
-func NewHandler(storage storage) *handler {
-	return &handler{storage: storage}
+// NewHandler serves the persons in storage under the rules of cfg. The storage enforces the
+// unique constraints, so cfg must not set any.
+func NewHandler(storage storage, cfg *config.Persons) (*handler, error) {
+	if len(cfg.UniqueConstraints) > 0 {
+		return nil, errors.New("unique constraints must be set on the storage")
+	}
+
+	attributeSchemas, err := validation.NewSchemaRegistry(cfg.Attributes.Schemas)
+	if err != nil {
+		return nil, err
+	}
+
+	return &handler{
+		storage:            storage,
+		duplicateThreshold: cfg.DuplicateThreshold,
+		attributeSchemas:   attributeSchemas,
+		tenantHeader:       cfg.Attributes.Header(),
+		deprecations:       map[*apiVersion]config.Deprecation{apiV1: cfg.V1Deprecation},
+	}, nil
+}
+
+// registerPersonValidation adds the person rules to v.
+func registerPersonValidation(v personValidator) {
+	v.RegisterStructValidation(validatePersonRequest, personRequest{})
+	err := v.RegisterTranslation("age_birth_date", validation.Messages{
+		"en": "{0} does not match birth_date",
+		"ru": "{0} не соответствует birth_date",
+	})
+	if err != nil {
+		panic(err)
+	}
 }
 
 func (h *handler) Register(echo *echo.Echo) {
-	api := echo.Group("/api/v1")
+	if v, ok := echo.Validator.(personValidator); ok {
+		registerPersonValidation(v)
+	}
+
+	echo.Pre(routeCustomMethods)
 
-	api.GET("/persons/:id", h.GetPerson)
-	api.GET("/persons", h.GetPersons)
-	api.POST("/persons", h.CreatePerson)
-	api.PATCH("/persons/:id", h.UpdatePerson)
-	api.DELETE("/persons/:id", h.DeletePerson)
+	// Each version has its own prefix, and the unversioned routes serve the version the Accept
+	// header asks for.
+	h.registerRoutes(echo.Group("/api/v1"), h.useVersion(apiV1, "/api/v1"))
+	h.registerRoutes(echo.Group("/api/v2"), h.useVersion(apiV2, "/api/v2"))
+	h.registerRoutes(echo.Group("/api"), h.negotiateVersion("/api"))
+}
+
+// customMethod matches the paths of the custom methods on a person, like /persons/{id}:merge.
+var customMethod = regexp.MustCompile(`^(/api(?:/v[0-9]+)?/persons/[^/:]+):([a-z]+)$`)
+
+// routeCustomMethods routes the custom methods on /persons/:id/<method>, as echo reads a
+// parameter up to the next slash and can not tell the id from the method after it. Only the
+// raw path echo routes by is rewritten, the request keeps the path the client sent for the
+// spec validator and the logs.
+func routeCustomMethods(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := c.Request()
+		if m := customMethod.FindStringSubmatch(req.URL.Path); m != nil {
+			req.URL.RawPath = m[1] + "/" + m[2]
+		}
+		return next(c)
+	}
+}
+
+// registerRoutes adds the person routes to api behind version, which is set on each route as
+// group middlewares would route every other path under the prefix too.
+func (h *handler) registerRoutes(api *echo.Group, version echo.MiddlewareFunc) {
+	api.GET("/persons/:id", tracing.Handler("person.handler.GetPerson", h.GetPerson), version)
+	api.GET("/persons", tracing.Handler("person.handler.GetPersons", h.GetPersons), version)
+	api.POST("/persons", tracing.Handler("person.handler.CreatePerson", h.CreatePerson), version)
+	api.PATCH("/persons/:id", tracing.Handler("person.handler.UpdatePerson", h.UpdatePerson), version)
+	api.DELETE("/persons/:id", tracing.Handler("person.handler.DeletePerson", h.DeletePerson), version)
+	api.GET("/persons/:id/duplicates", tracing.Handler("person.handler.GetDuplicates", h.GetDuplicates), version)
+	api.GET("/persons/:id/relations", tracing.Handler("person.handler.GetRelations", h.GetRelations), version)
+	api.POST("/persons/:id/relations", tracing.Handler("person.handler.CreateRelation", h.CreateRelation), version)
+	api.DELETE("/persons/:id/relations/:relation_id", tracing.Handler("person.handler.DeleteRelation", h.DeleteRelation), version)
+	api.GET("/persons/:id/relatives", tracing.Handler("person.handler.GetRelatives", h.GetRelatives), version)
+	// Routed from /persons/{id}:merge by routeCustomMethods.
+	api.POST("/persons/:id/merge", tracing.Handler("person.handler.MergePerson", h.MergePerson), version)
 }
 
 func (h *handler) CreatePerson(c echo.Context) error {
-	type createPersonRequest struct {
-		Name    *string `json:"name" validate:"required"`
-		Age     *int    `json:"age"`
-		Address *string `json:"address"`
-		Work    *string `json:"work"`
+	logger := zerolog.Ctx(c.Request().Context())
+
+	dec, ok := requestDecoder(c)
+	if !ok {
+		logger.Warn().Str("content_type", c.Request().Header.Get(echo.HeaderContentType)).Msg("unsupported content type")
+		return unsupportedMediaTypeResponse(c)
 	}
 
-	req := &createPersonRequest{}
+	req := &personRequest{}
 	body, err := io.ReadAll(c.Request().Body)
 	if err != nil {
-		log.Error().Err(err).Msg("reading request body error")
-		return c.JSON(http.StatusBadRequest, echo.Map{
-			"errors": "unmarshalling error",
-		})
+		logger.Error().Err(err).Msg("reading request body error")
+		return errorResponse(c, http.StatusBadRequest, "unmarshalling error")
 	}
 
-	if err = json.Unmarshal(body, &req); err != nil {
-		log.Error().Err(err).Msg("unmarshalling error")
-		return c.JSON(http.StatusBadRequest, echo.Map{
-			"errors": "unmarshalling error",
-		})
+	if err = dec.Decode(bytes.NewReader(body), req); err != nil {
+		logger.Warn().Err(err).Msg("unmarshalling error")
+		return errorResponse(c, http.StatusBadRequest, "unmarshalling error")
 	}
 
 	if err = c.Validate(req); err != nil {
-		log.Error().Err(err).Msg("validation error")
-		return c.JSON(http.StatusBadRequest, echo.Map{
-			"errors": "validation error",
-		})
+		logger.Warn().Err(err).Msg("validation error")
+		return validationErrorResponse(c, err)
 	}
 
-	p := Person{
-		Name:    req.Name,
-		Address: req.Address,
-		Age:     req.Age,
-		Work:    req.Work,
+	created, err := h.createPerson(c.Request().Context(), c.Request().Header.Get(h.tenantHeader), req.toPerson())
+	var attributesErr *AttributesError
+	if errors.As(err, &attributesErr) {
+		logger.Warn().Msg("attributes validation error")
+		return fieldErrorsResponse(c, attributesErr.Fields)
+	}
+	var conflict *ConflictError
+	if errors.As(err, &conflict) {
+		logger.Info().Int("existing_person_id", valueOrZero(conflict.Existing.ID)).Msg("person already exists")
+		return conflictResponse(c, conflict.Existing)
 	}
-
-	id, err := h.storage.CreatePerson(c.Request().Context(), p)
 	if err != nil {
-		log.Error().Err(err).Msg("creating person error")
-		return c.JSON(http.StatusInternalServerError, echo.Map{
-			"errors": "creating person error",
-		})
+		logger.Error().Err(err).Msg("creating person error")
+		return errorResponse(c, http.StatusInternalServerError, "creating person error")
 	}
 
-	c.Response().Header().Set("Location", "/api/v1/persons/"+strconv.Itoa(id))
+	c.Response().Header().Set("Location", personPath(c, *created.ID))
 
 	return c.NoContent(http.StatusCreated)
 }
@@ -92,96 +369,83 @@ func (h *handler) CreatePerson(c echo.Context) error {
 func (h *handler) UpdatePerson(c echo.Context) error {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, echo.Map{
-			"errors": "wrong id",
-		})
+		zerolog.Ctx(c.Request().Context()).Warn().Str("id", c.Param("id")).Msg("wrong person id")
+		return errorResponse(c, http.StatusBadRequest, "wrong id")
 	}
 
-	type createPersonRequest struct {
-		Name    *string `json:"name" validate:"required"`
-		Age     *int    `json:"age"`
-		Address *string `json:"address"`
-		Work    *string `json:"work"`
+	logger := zerolog.Ctx(c.Request().Context()).With().Int("person_id", id).Logger()
+
+	enc, ok := acceptedEncoder(c, false)
+	if !ok {
+		logger.Warn().Str("accept", c.Request().Header.Get(echo.HeaderAccept)).Msg("not acceptable")
+		return notAcceptableResponse(c)
+	}
+	dec, ok := requestDecoder(c)
+	if !ok {
+		logger.Warn().Str("content_type", c.Request().Header.Get(echo.HeaderContentType)).Msg("unsupported content type")
+		return unsupportedMediaTypeResponse(c)
 	}
 
-	req := &createPersonRequest{}
+	req := &personRequest{}
 	body, err := io.ReadAll(c.Request().Body)
 	if err != nil {
-		log.Error().Err(err).Msg("reading request body error")
-		return c.JSON(http.StatusBadRequest, echo.Map{
-			"errors": "unmarshalling error",
-		})
+		logger.Error().Err(err).Msg("reading request body error")
+		return errorResponse(c, http.StatusBadRequest, "unmarshalling error")
 	}
 
-	if err = json.Unmarshal(body, &req); err != nil {
-		log.Error().Err(err).Msg("unmarshalling error")
-		return c.JSON(http.StatusBadRequest, echo.Map{
-			"errors": "unmarshalling error",
-		})
+	if err = dec.Decode(bytes.NewReader(body), req); err != nil {
+		logger.Warn().Err(err).Msg("unmarshalling error")
+		return errorResponse(c, http.StatusBadRequest, "unmarshalling error")
 	}
 
 	if err = c.Validate(req); err != nil {
-		log.Error().Err(err).Msg("validation error")
-		return c.JSON(http.StatusBadRequest, echo.Map{
-			"errors": "validation error",
-		})
+		logger.Warn().Err(err).Msg("validation error")
+		return validationErrorResponse(c, err)
 	}
 
-	p := Person{
-		Name:    req.Name,
-		Address: req.Address,
-		Age:     req.Age,
-		Work:    req.Work,
+	p, err := h.updatePerson(c.Request().Context(), c.Request().Header.Get(h.tenantHeader), id, req.toPerson())
+	var attributesErr *AttributesError
+	if errors.As(err, &attributesErr) {
+		logger.Warn().Msg("attributes validation error")
+		return fieldErrorsResponse(c, attributesErr.Fields)
 	}
-
-	err = h.storage.UpdatePerson(c.Request().Context(), id, &p)
-	if err != nil {
-		log.Error().Err(err).Msg("updating person error")
-		return c.JSON(http.StatusInternalServerError, echo.Map{
-			"errors": "updating person error",
-		})
+	if errors.Is(err, ErrNotFound) {
+		logger.Info().Msg("person not found")
+		return errorResponse(c, http.StatusNotFound, "person not found")
 	}
-
-	type updatePersonResponse struct {
-		ID      *int    `json:"id"`
-		Name    *string `json:"name"`
-		Age     *int    `json:"age"`
-		Address *string `json:"address"`
-		Work    *string `json:"work"`
+	var conflict *ConflictError
+	if errors.As(err, &conflict) {
+		logger.Info().Int("existing_person_id", valueOrZero(conflict.Existing.ID)).Msg("person already exists")
+		return conflictResponse(c, conflict.Existing)
 	}
-
-	resp := updatePersonResponse{
-		ID:      p.ID,
-		Name:    p.Name,
-		Age:     p.Age,
-		Address: p.Address,
-		Work:    p.Work,
+	if err != nil {
+		logger.Error().Err(err).Msg("updating person error")
+		return errorResponse(c, http.StatusInternalServerError, "updating person error")
 	}
 
-	return c.JSON(http.StatusOK, resp)
+	resp := versionOf(c).person(p, time.Now())
+
+	return respond(c, enc, http.StatusOK, resp)
 }
 
 func (h *handler) DeletePerson(c echo.Context) error {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, echo.Map{
-			"errors": "wrong id",
-		})
+		zerolog.Ctx(c.Request().Context()).Warn().Str("id", c.Param("id")).Msg("wrong person id")
+		return errorResponse(c, http.StatusBadRequest, "wrong id")
 	}
 
+	logger := zerolog.Ctx(c.Request().Context()).With().Int("person_id", id).Logger()
+
 	isDeleted, err := h.storage.DeletePerson(c.Request().Context(), id)
 	if err != nil {
-		log.Error().Err(err).Msg("deleting person error")
-		return c.JSON(http.StatusInternalServerError, echo.Map{
-			"errors": "deleting person error",
-		})
+		logger.Error().Err(err).Msg("deleting person error")
+		return errorResponse(c, http.StatusInternalServerError, "deleting person error")
 	}
 
 	if !isDeleted {
-		log.Error().Err(err).Msgf("person with id = %d not found", id)
-		return c.JSON(http.StatusNotFound, echo.Map{
-			"errors": "person not found",
-		})
+		logger.Info().Msg("person not found")
+		return errorResponse(c, http.StatusNotFound, "person not found")
 	}
 
 	return c.NoContent(http.StatusNoContent)
@@ -190,72 +454,318 @@ func (h *handler) DeletePerson(c echo.Context) error {
 func (h *handler) GetPerson(c echo.Context) error {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, echo.Map{
-			"errors": "wrong id",
-		})
+		zerolog.Ctx(c.Request().Context()).Warn().Str("id", c.Param("id")).Msg("wrong person id")
+		return errorResponse(c, http.StatusBadRequest, "wrong id")
 	}
 
-	p, err := h.storage.GetPerson(c.Request().Context(), id)
+	logger := zerolog.Ctx(c.Request().Context()).With().Int("person_id", id).Logger()
+
+	enc, ok := acceptedEncoder(c, false)
+	if !ok {
+		logger.Warn().Str("accept", c.Request().Header.Get(echo.HeaderAccept)).Msg("not acceptable")
+		return notAcceptableResponse(c)
+	}
+
+	fields, err := parseFields(c.QueryParams()["fields"])
 	if err != nil {
-		log.Error().Err(err).Msg("getting person error")
-		return c.JSON(http.StatusInternalServerError, echo.Map{
-			"errors": "getting person error",
-		})
+		logger.Warn().Err(err).Msg("wrong fields")
+		return errorResponse(c, http.StatusBadRequest, err.Error())
 	}
 
-	if p.ID == nil {
-		log.Error().Err(err).Msg("person with not found")
-		return c.JSON(http.StatusNotFound, echo.Map{
-			"errors": "person not found",
-		})
+	p, err := h.getPerson(c.Request().Context(), id, fields...)
+	if errors.Is(err, ErrNotFound) {
+		logger.Info().Msg("person not found")
+		return errorResponse(c, http.StatusNotFound, "person not found")
+	}
+	if err != nil {
+		logger.Error().Err(err).Msg("getting person error")
+		return errorResponse(c, http.StatusInternalServerError, "getting person error")
+	}
+
+	// A merged person redirects to the one it was merged into.
+	if *p.ID != id {
+		location := personPath(c, *p.ID)
+		if query := c.QueryString(); query != "" {
+			location += "?" + query
+		}
+		return c.Redirect(http.StatusMovedPermanently, location)
 	}
 
-	type getPersonResponse struct {
-		ID      int    `json:"id"`
-		Name    string `json:"name"`
-		Age     int    `json:"age"`
-		Address string `json:"address"`
-		Work    string `json:"work"`
+	resp := projectResponse(versionOf(c).person(p, time.Now()), fields)
+
+	return respond(c, enc, http.StatusOK, resp)
+}
+
+// attributeFilterPrefix starts the query parameters that filter persons by an attribute, e.g.
+// attr.team=core.
+const attributeFilterPrefix = "attr."
+
+// parsePersonFilter reads the tag and attribute filters of GetPersons. Attribute values are
+// JSON literals, e.g. attr.level=3 or attr.code="3", or plain strings. ok is false when there
+// are no filters.
+func parsePersonFilter(params url.Values) (match Person, ok bool, err error) {
+	for _, tag := range params["tag"] {
+		if tag == "" {
+			return Person{}, false, errors.New("tag must not be empty")
+		}
+		match.Tags = append(match.Tags, tag)
 	}
 
-	resp := getPersonResponse{
-		ID:      *p.ID,
-		Name:    *p.Name,
-		Age:     *p.Age,
-		Address: *p.Address,
-		Work:    *p.Work,
+	for param, values := range params {
+		key, found := strings.CutPrefix(param, attributeFilterPrefix)
+		if !found {
+			continue
+		}
+		if key == "" {
+			return Person{}, false, errors.New("attribute name must not be empty")
+		}
+		if match.Attributes == nil {
+			match.Attributes = Attributes{}
+		}
+		// Repeated values must all be contained, like in an array attribute.
+		parsed := make([]interface{}, len(values))
+		for i, value := range values {
+			var v interface{}
+			if json.Unmarshal([]byte(value), &v) != nil {
+				v = value
+			}
+			parsed[i] = v
+		}
+		if len(parsed) == 1 {
+			match.Attributes[key] = parsed[0]
+		} else {
+			match.Attributes[key] = parsed
+		}
 	}
 
-	return c.JSON(http.StatusOK, resp)
+	return match, match.Tags != nil || match.Attributes != nil, nil
+}
+
+// maxPageLimit bounds the limit query parameter of GetPersons.
+const maxPageLimit = 1000
+
+// parsePage reads the limit and offset query parameters of GetPersons. A zero limit lists
+// every person from the offset on.
+func parsePage(params url.Values) (page Page, err error) {
+	if param := params.Get("limit"); param != "" {
+		page.Limit, err = strconv.Atoi(param)
+		if err != nil || page.Limit < 1 || page.Limit > maxPageLimit {
+			return Page{}, errors.New("limit must be between 1 and " + strconv.Itoa(maxPageLimit))
+		}
+	}
+	if param := params.Get("offset"); param != "" {
+		page.Offset, err = strconv.Atoi(param)
+		if err != nil || page.Offset < 0 {
+			return Page{}, errors.New("offset must not be negative")
+		}
+	}
+
+	return page, nil
 }
 
+// listPersons returns the page of the persons matching match, or of every person if filtered
+// is false, and the count of all of them. The REST and GraphQL APIs list persons through it,
+// the gRPC streams through streamPersons.
+func listPersons(ctx context.Context, storage storage, match Person, filtered bool, page Page, fields []string) ([]Person, int, error) {
+	var persons []Person
+	var err error
+	if filtered {
+		persons, err = storage.FindPersons(ctx, match, page, fields...)
+	} else {
+		persons, err = storage.GetPersons(ctx, page, fields...)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if page == (Page{}) {
+		return persons, len(persons), nil
+	}
+	total, err := storage.CountPersons(ctx, match)
+	if err != nil {
+		return nil, 0, err
+	}
+	return persons, total, nil
+}
+
+// streamBatchSize is the number of persons streamPersons reads from the storage at once.
+const streamBatchSize = 500
+
+// streamPersons reads the page of the persons matching match, or of every person if filtered
+// is false, in batches of streamBatchSize and passes each batch to send as soon as it is read,
+// so a stream of all persons never holds more than a batch of them.
+func streamPersons(ctx context.Context, storage storage, match Person, filtered bool, page Page, send func([]Person) error) error {
+	for {
+		batch := Page{Limit: streamBatchSize, Offset: page.Offset}
+		if page.Limit != 0 && page.Limit < batch.Limit {
+			batch.Limit = page.Limit
+		}
+
+		var persons []Person
+		var err error
+		if filtered {
+			persons, err = storage.FindPersons(ctx, match, batch)
+		} else {
+			persons, err = storage.GetPersons(ctx, batch)
+		}
+		if err != nil {
+			return err
+		}
+		if len(persons) == 0 {
+			return nil
+		}
+		if err = send(persons); err != nil {
+			return err
+		}
+
+		if len(persons) < batch.Limit || len(persons) == page.Limit {
+			return nil
+		}
+		page.Offset += len(persons)
+		if page.Limit != 0 {
+			page.Limit -= len(persons)
+		}
+	}
+}
+
+// GetPersons lists the persons, only those with every tag query parameter and the attributes
+// of the attr.<name> parameters if any are given. The limit and offset query parameters select
+// a page of the persons ordered by ID; the X-Total-Count header has the count of all of them.
 func (h *handler) GetPersons(c echo.Context) error {
-	persons, err := h.storage.GetPersons(c.Request().Context())
+	logger := zerolog.Ctx(c.Request().Context())
+
+	enc, ok := acceptedEncoder(c, true)
+	if !ok {
+		logger.Warn().Str("accept", c.Request().Header.Get(echo.HeaderAccept)).Msg("not acceptable")
+		return notAcceptableResponse(c)
+	}
+
+	match, filtered, err := parsePersonFilter(c.QueryParams())
 	if err != nil {
-		log.Error().Err(err).Msg("getting persons error")
-		return c.JSON(http.StatusInternalServerError, echo.Map{
-			"errors": "getting persons error",
-		})
+		logger.Warn().Err(err).Msg("wrong filter")
+		return errorResponse(c, http.StatusBadRequest, err.Error())
 	}
 
-	type getPersonResponse struct {
-		ID      int    `json:"id"`
-		Name    string `json:"name"`
-		Age     int    `json:"age"`
-		Address string `json:"address"`
-		Work    string `json:"work"`
+	page, err := parsePage(c.QueryParams())
+	if err != nil {
+		logger.Warn().Err(err).Msg("wrong page")
+		return errorResponse(c, http.StatusBadRequest, err.Error())
 	}
 
-	personsResp := make([]getPersonResponse, len(persons), len(persons))
+	fields, err := parseFields(c.QueryParams()["fields"])
+	if err != nil {
+		logger.Warn().Err(err).Msg("wrong fields")
+		return errorResponse(c, http.StatusBadRequest, err.Error())
+	}
+
+	persons, total, err := listPersons(c.Request().Context(), h.storage, match, filtered, page, fields)
+	if err != nil {
+		logger.Error().Err(err).Msg("getting persons error")
+		return errorResponse(c, http.StatusInternalServerError, "getting persons error")
+	}
+
+	c.Response().Header().Set("X-Total-Count", strconv.Itoa(total))
+
+	now := time.Now()
+	v := versionOf(c)
+	personsResp := make([]interface{}, len(persons), len(persons))
 	for i, p := range persons {
-		personsResp[i] = getPersonResponse{
-			ID:      *p.ID,
-			Name:    *p.Name,
-			Age:     *p.Age,
-			Address: *p.Address,
-			Work:    *p.Work,
+		personsResp[i] = projectResponse(v.person(p, now), fields)
+	}
+
+	return respond(c, enc, http.StatusOK, personsResp)
+}
+
+func (h *handler) GetDuplicates(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		zerolog.Ctx(c.Request().Context()).Warn().Str("id", c.Param("id")).Msg("wrong person id")
+		return errorResponse(c, http.StatusBadRequest, "wrong id")
+	}
+
+	logger := zerolog.Ctx(c.Request().Context()).With().Int("person_id", id).Logger()
+
+	p, err := h.storage.GetPerson(c.Request().Context(), id)
+	if err != nil {
+		logger.Error().Err(err).Msg("getting person error")
+		return errorResponse(c, http.StatusInternalServerError, "getting duplicates error")
+	}
+	if p.ID == nil {
+		logger.Info().Msg("person not found")
+		return errorResponse(c, http.StatusNotFound, "person not found")
+	}
+
+	candidates, err := h.storage.FindDuplicateCandidates(c.Request().Context(), p, maxDuplicateCandidates)
+	if err != nil {
+		logger.Error().Err(err).Msg("finding duplicate candidates error")
+		return errorResponse(c, http.StatusInternalServerError, "getting duplicates error")
+	}
+
+	threshold := h.duplicateThreshold
+	if threshold == 0 {
+		threshold = defaultDuplicateThreshold
+	}
+
+	type duplicateResponse struct {
+		Person interface{} `json:"person"`
+		Score  float64     `json:"score"`
+	}
+
+	now := time.Now()
+	v := versionOf(c)
+	duplicates := findDuplicates(p, candidates, threshold)
+	resp := make([]duplicateResponse, len(duplicates), len(duplicates))
+	for i, d := range duplicates {
+		resp[i] = duplicateResponse{
+			Person: v.person(d.person, now),
+			Score:  math.Round(d.score*100) / 100,
 		}
 	}
 
-	return c.JSON(http.StatusOK, personsResp)
+	return c.JSON(http.StatusOK, resp)
+}
+
+// MergePerson merges the person given in the body into the one in the path. The merged id
+// stays an alias of the remaining person.
+func (h *handler) MergePerson(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		zerolog.Ctx(c.Request().Context()).Warn().Str("id", c.Param("id")).Msg("wrong person id")
+		return errorResponse(c, http.StatusBadRequest, "wrong id")
+	}
+
+	logger := zerolog.Ctx(c.Request().Context()).With().Int("person_id", id).Logger()
+
+	type mergePersonRequest struct {
+		SourceID *int `json:"source_id" validate:"required"`
+	}
+
+	req := &mergePersonRequest{}
+	if err = json.NewDecoder(c.Request().Body).Decode(req); err != nil {
+		logger.Warn().Err(err).Msg("unmarshalling error")
+		return errorResponse(c, http.StatusBadRequest, "unmarshalling error")
+	}
+
+	if err = c.Validate(req); err != nil {
+		logger.Warn().Err(err).Msg("validation error")
+		return validationErrorResponse(c, err)
+	}
+
+	if *req.SourceID == id {
+		logger.Warn().Msg("merging person into itself")
+		return errorResponse(c, http.StatusBadRequest, "person can not be merged into itself")
+	}
+
+	p, err := h.storage.MergePersons(c.Request().Context(), id, *req.SourceID)
+	if errors.Is(err, ErrNotFound) {
+		logger.Info().Int("source_person_id", *req.SourceID).Msg("person not found")
+		return errorResponse(c, http.StatusNotFound, "person not found")
+	}
+	if err != nil {
+		logger.Error().Err(err).Msg("merging persons error")
+		return errorResponse(c, http.StatusInternalServerError, "merging persons error")
+	}
+	logger.Info().Int("source_person_id", *req.SourceID).Msg("persons merged")
+
+	return c.JSON(http.StatusOK, versionOf(c).person(p, time.Now()))
 }
@@ -8,6 +8,8 @@ import (
 	context "context"
 	reflect "reflect"
 
+	validation "github.com/Erlendum/rsoi-lab-01/pkg/validation"
+	v10 "github.com/go-playground/validator/v10"
 	gomock "github.com/golang/mock/gomock"
 )
 
@@ -34,6 +36,21 @@ func (m *Mockstorage) EXPECT() *MockstorageMockRecorder {
 	return m.recorder
 }
 
+// CountPersons mocks base method.
+func (m *Mockstorage) CountPersons(ctx context.Context, match Person) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountPersons", ctx, match)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountPersons indicates an expected call of CountPersons.
+func (mr *MockstorageMockRecorder) CountPersons(ctx, match interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountPersons", reflect.TypeOf((*Mockstorage)(nil).CountPersons), ctx, match)
+}
+
 // CreatePerson mocks base method.
 func (m *Mockstorage) CreatePerson(ctx context.Context, person Person) (int, error) {
 	m.ctrl.T.Helper()
@@ -49,6 +66,21 @@ func (mr *MockstorageMockRecorder) CreatePerson(ctx, person interface{}) *gomock
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePerson", reflect.TypeOf((*Mockstorage)(nil).CreatePerson), ctx, person)
 }
 
+// CreateRelation mocks base method.
+func (m *Mockstorage) CreateRelation(ctx context.Context, relation Relation) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateRelation", ctx, relation)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateRelation indicates an expected call of CreateRelation.
+func (mr *MockstorageMockRecorder) CreateRelation(ctx, relation interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateRelation", reflect.TypeOf((*Mockstorage)(nil).CreateRelation), ctx, relation)
+}
+
 // DeletePerson mocks base method.
 func (m *Mockstorage) DeletePerson(ctx context.Context, id int) (bool, error) {
 	m.ctrl.T.Helper()
@@ -64,34 +96,196 @@ func (mr *MockstorageMockRecorder) DeletePerson(ctx, id interface{}) *gomock.Cal
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeletePerson", reflect.TypeOf((*Mockstorage)(nil).DeletePerson), ctx, id)
 }
 
+// DeleteRelation mocks base method.
+func (m *Mockstorage) DeleteRelation(ctx context.Context, personID, relationID int) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteRelation", ctx, personID, relationID)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteRelation indicates an expected call of DeleteRelation.
+func (mr *MockstorageMockRecorder) DeleteRelation(ctx, personID, relationID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteRelation", reflect.TypeOf((*Mockstorage)(nil).DeleteRelation), ctx, personID, relationID)
+}
+
+// FindDuplicateCandidates mocks base method.
+func (m *Mockstorage) FindDuplicateCandidates(ctx context.Context, p Person, limit int) ([]Person, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindDuplicateCandidates", ctx, p, limit)
+	ret0, _ := ret[0].([]Person)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindDuplicateCandidates indicates an expected call of FindDuplicateCandidates.
+func (mr *MockstorageMockRecorder) FindDuplicateCandidates(ctx, p, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindDuplicateCandidates", reflect.TypeOf((*Mockstorage)(nil).FindDuplicateCandidates), ctx, p, limit)
+}
+
+// FindPersons mocks base method.
+func (m *Mockstorage) FindPersons(ctx context.Context, match Person, page Page, fields ...string) ([]Person, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx, match, page}
+	for _, a := range fields {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "FindPersons", varargs...)
+	ret0, _ := ret[0].([]Person)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindPersons indicates an expected call of FindPersons.
+func (mr *MockstorageMockRecorder) FindPersons(ctx, match, page interface{}, fields ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx, match, page}, fields...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindPersons", reflect.TypeOf((*Mockstorage)(nil).FindPersons), varargs...)
+}
+
 // GetPerson mocks base method.
-func (m *Mockstorage) GetPerson(ctx context.Context, id int) (Person, error) {
+func (m *Mockstorage) GetPerson(ctx context.Context, id int, fields ...string) (Person, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetPerson", ctx, id)
+	varargs := []interface{}{ctx, id}
+	for _, a := range fields {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetPerson", varargs...)
 	ret0, _ := ret[0].(Person)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetPerson indicates an expected call of GetPerson.
-func (mr *MockstorageMockRecorder) GetPerson(ctx, id interface{}) *gomock.Call {
+func (mr *MockstorageMockRecorder) GetPerson(ctx, id interface{}, fields ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx, id}, fields...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPerson", reflect.TypeOf((*Mockstorage)(nil).GetPerson), varargs...)
+}
+
+// GetPersonAlias mocks base method.
+func (m *Mockstorage) GetPersonAlias(ctx context.Context, aliasID int) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPersonAlias", ctx, aliasID)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPersonAlias indicates an expected call of GetPersonAlias.
+func (mr *MockstorageMockRecorder) GetPersonAlias(ctx, aliasID interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPerson", reflect.TypeOf((*Mockstorage)(nil).GetPerson), ctx, id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPersonAlias", reflect.TypeOf((*Mockstorage)(nil).GetPersonAlias), ctx, aliasID)
 }
 
 // GetPersons mocks base method.
-func (m *Mockstorage) GetPersons(ctx context.Context) ([]Person, error) {
+func (m *Mockstorage) GetPersons(ctx context.Context, page Page, fields ...string) ([]Person, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetPersons", ctx)
+	varargs := []interface{}{ctx, page}
+	for _, a := range fields {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetPersons", varargs...)
 	ret0, _ := ret[0].([]Person)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetPersons indicates an expected call of GetPersons.
-func (mr *MockstorageMockRecorder) GetPersons(ctx interface{}) *gomock.Call {
+func (mr *MockstorageMockRecorder) GetPersons(ctx, page interface{}, fields ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx, page}, fields...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPersons", reflect.TypeOf((*Mockstorage)(nil).GetPersons), varargs...)
+}
+
+// GetPersonsByIDs mocks base method.
+func (m *Mockstorage) GetPersonsByIDs(ctx context.Context, ids []int) ([]Person, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPersonsByIDs", ctx, ids)
+	ret0, _ := ret[0].([]Person)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPersonsByIDs indicates an expected call of GetPersonsByIDs.
+func (mr *MockstorageMockRecorder) GetPersonsByIDs(ctx, ids interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPersonsByIDs", reflect.TypeOf((*Mockstorage)(nil).GetPersonsByIDs), ctx, ids)
+}
+
+// GetRelations mocks base method.
+func (m *Mockstorage) GetRelations(ctx context.Context, personID int) ([]Relation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRelations", ctx, personID)
+	ret0, _ := ret[0].([]Relation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRelations indicates an expected call of GetRelations.
+func (mr *MockstorageMockRecorder) GetRelations(ctx, personID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRelations", reflect.TypeOf((*Mockstorage)(nil).GetRelations), ctx, personID)
+}
+
+// GetRelationsByPersonIDs mocks base method.
+func (m *Mockstorage) GetRelationsByPersonIDs(ctx context.Context, personIDs []int) ([]Relation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRelationsByPersonIDs", ctx, personIDs)
+	ret0, _ := ret[0].([]Relation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRelationsByPersonIDs indicates an expected call of GetRelationsByPersonIDs.
+func (mr *MockstorageMockRecorder) GetRelationsByPersonIDs(ctx, personIDs interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRelationsByPersonIDs", reflect.TypeOf((*Mockstorage)(nil).GetRelationsByPersonIDs), ctx, personIDs)
+}
+
+// GetRelatives mocks base method.
+func (m *Mockstorage) GetRelatives(ctx context.Context, personID, maxDepth int, types []string) ([]Relative, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRelatives", ctx, personID, maxDepth, types)
+	ret0, _ := ret[0].([]Relative)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRelatives indicates an expected call of GetRelatives.
+func (mr *MockstorageMockRecorder) GetRelatives(ctx, personID, maxDepth, types interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRelatives", reflect.TypeOf((*Mockstorage)(nil).GetRelatives), ctx, personID, maxDepth, types)
+}
+
+// MergePersons mocks base method.
+func (m *Mockstorage) MergePersons(ctx context.Context, id, sourceID int) (Person, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MergePersons", ctx, id, sourceID)
+	ret0, _ := ret[0].(Person)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// MergePersons indicates an expected call of MergePersons.
+func (mr *MockstorageMockRecorder) MergePersons(ctx, id, sourceID interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPersons", reflect.TypeOf((*Mockstorage)(nil).GetPersons), ctx)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MergePersons", reflect.TypeOf((*Mockstorage)(nil).MergePersons), ctx, id, sourceID)
+}
+
+// PersonsChanged mocks base method.
+func (m *Mockstorage) PersonsChanged(ctx context.Context, ids []int) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "PersonsChanged", ctx, ids)
+}
+
+// PersonsChanged indicates an expected call of PersonsChanged.
+func (mr *MockstorageMockRecorder) PersonsChanged(ctx, ids interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PersonsChanged", reflect.TypeOf((*Mockstorage)(nil).PersonsChanged), ctx, ids)
 }
 
 // UpdatePerson mocks base method.
@@ -107,3 +301,57 @@ func (mr *MockstorageMockRecorder) UpdatePerson(ctx, id, person interface{}) *go
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePerson", reflect.TypeOf((*Mockstorage)(nil).UpdatePerson), ctx, id, person)
 }
+
+// MockpersonValidator is a mock of personValidator interface.
+type MockpersonValidator struct {
+	ctrl     *gomock.Controller
+	recorder *MockpersonValidatorMockRecorder
+}
+
+// MockpersonValidatorMockRecorder is the mock recorder for MockpersonValidator.
+type MockpersonValidatorMockRecorder struct {
+	mock *MockpersonValidator
+}
+
+// NewMockpersonValidator creates a new mock instance.
+func NewMockpersonValidator(ctrl *gomock.Controller) *MockpersonValidator {
+	mock := &MockpersonValidator{ctrl: ctrl}
+	mock.recorder = &MockpersonValidatorMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockpersonValidator) EXPECT() *MockpersonValidatorMockRecorder {
+	return m.recorder
+}
+
+// RegisterStructValidation mocks base method.
+func (m *MockpersonValidator) RegisterStructValidation(fn v10.StructLevelFunc, types ...interface{}) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{fn}
+	for _, a := range types {
+		varargs = append(varargs, a)
+	}
+	m.ctrl.Call(m, "RegisterStructValidation", varargs...)
+}
+
+// RegisterStructValidation indicates an expected call of RegisterStructValidation.
+func (mr *MockpersonValidatorMockRecorder) RegisterStructValidation(fn interface{}, types ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{fn}, types...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RegisterStructValidation", reflect.TypeOf((*MockpersonValidator)(nil).RegisterStructValidation), varargs...)
+}
+
+// RegisterTranslation mocks base method.
+func (m *MockpersonValidator) RegisterTranslation(tag string, messages validation.Messages) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RegisterTranslation", tag, messages)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RegisterTranslation indicates an expected call of RegisterTranslation.
+func (mr *MockpersonValidatorMockRecorder) RegisterTranslation(tag, messages interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RegisterTranslation", reflect.TypeOf((*MockpersonValidator)(nil).RegisterTranslation), tag, messages)
+}
@@ -1,19 +1,30 @@
 package person
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"github.com/Erlendum/rsoi-lab-01/pkg/validation"
 	"github.com/go-playground/validator/v10"
 	"github.com/golang/mock/gomock"
 	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/require"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 )
 
+// newUniqueEmailRepository returns a memory storage that keeps the emails unique.
+func newUniqueEmailRepository(t *testing.T) *memoryRepository {
+	r := NewMemoryRepository()
+	require.NoError(t, r.SetUniqueConstraints([][]string{{"email"}}))
+	return r
+}
+
 type handlerTestFields struct {
 	storage *Mockstorage
 }
@@ -69,6 +80,60 @@ func Test_CreatePerson(t *testing.T) {
 			Prepare: func(fields *handlerTestFields) {
 			},
 		},
+		{
+			name: "http-code 400: invalid phone number",
+			fields: fields{
+				expectedHTTPCode:       http.StatusBadRequest,
+				reqBody:                `{"name": "test", "phone_numbers": ["89991234567"]}`,
+				expectedLocationHeader: ``,
+			},
+
+			Prepare: func(fields *handlerTestFields) {
+			},
+		},
+		{
+			name: "http-code 400: invalid email",
+			fields: fields{
+				expectedHTTPCode:       http.StatusBadRequest,
+				reqBody:                `{"name": "test", "email": "test"}`,
+				expectedLocationHeader: ``,
+			},
+
+			Prepare: func(fields *handlerTestFields) {
+			},
+		},
+		{
+			name: "http-code 400: negative age",
+			fields: fields{
+				expectedHTTPCode:       http.StatusBadRequest,
+				reqBody:                `{"name": "test", "age": -1}`,
+				expectedLocationHeader: ``,
+			},
+
+			Prepare: func(fields *handlerTestFields) {
+			},
+		},
+		{
+			name: "http-code 201: extended fields",
+			fields: fields{
+				expectedHTTPCode:       http.StatusCreated,
+				reqBody:                `{"name": "test", "birth_date": "1990-01-31", "email": "test@example.com", "phone_numbers": ["+79991234567"], "address_parts": {"city": "Moscow", "country": "RU"}}`,
+				expectedLocationHeader: `/api/v1/persons/2`,
+			},
+
+			Prepare: func(fields *handlerTestFields) {
+				birthDate := time.Date(1990, time.January, 31, 0, 0, 0, 0, time.UTC)
+				fields.storage.EXPECT().CreatePerson(gomock.Any(), Person{
+					Name:         getPointerOnString("test"),
+					BirthDate:    &birthDate,
+					Email:        getPointerOnString("test@example.com"),
+					PhoneNumbers: pq.StringArray{"+79991234567"},
+					City:         getPointerOnString("Moscow"),
+					Country:      getPointerOnString("RU"),
+				}).Return(2, nil)
+				fields.storage.EXPECT().GetPerson(gomock.Any(), 2).Return(Person{ID: getPointerOnInt(2), Name: getPointerOnString("test")}, nil)
+			},
+		},
 		{
 			name: "http-code 500: storage error",
 			fields: fields{
@@ -91,6 +156,7 @@ func Test_CreatePerson(t *testing.T) {
 
 			Prepare: func(fields *handlerTestFields) {
 				fields.storage.EXPECT().CreatePerson(gomock.Any(), gomock.Any()).Return(1, nil)
+				fields.storage.EXPECT().GetPerson(gomock.Any(), 1).Return(Person{ID: getPointerOnInt(1), Name: getPointerOnString("test")}, nil)
 			},
 		},
 	}
@@ -177,6 +243,18 @@ func Test_UpdatePerson(t *testing.T) {
 				fields.storage.EXPECT().UpdatePerson(gomock.Any(), 1, gomock.Any()).Return(errors.New(""))
 			},
 		},
+		{
+			name: "http-code 404: person not found",
+			fields: fields{
+				expectedHTTPCode: http.StatusNotFound,
+				id:               "1",
+				reqBody:          `{"name": "test", "age": 1, "address": "test", "work": "test"}`,
+			},
+
+			Prepare: func(fields *handlerTestFields) {
+				fields.storage.EXPECT().UpdatePerson(gomock.Any(), 1, gomock.Any()).Return(ErrNotFound)
+			},
+		},
 		{
 			name: "http-code 200",
 			fields: fields{
@@ -299,6 +377,7 @@ func Test_DeletePerson(t *testing.T) {
 func Test_GetPerson(t *testing.T) {
 	type fields struct {
 		id                   string
+		query                string
 		expectedHTTPCode     int
 		expectedResponseBody string
 	}
@@ -344,6 +423,21 @@ func Test_GetPerson(t *testing.T) {
 
 			Prepare: func(fields *handlerTestFields) {
 				fields.storage.EXPECT().GetPerson(gomock.Any(), 1).Return(Person{}, nil)
+				fields.storage.EXPECT().GetPersonAlias(gomock.Any(), 1).Return(0, nil)
+			},
+		},
+		{
+			name: "http-code 301: merged person",
+			fields: fields{
+				expectedHTTPCode:     http.StatusMovedPermanently,
+				id:                   "1",
+				expectedResponseBody: ``,
+			},
+
+			Prepare: func(fields *handlerTestFields) {
+				fields.storage.EXPECT().GetPerson(gomock.Any(), 1).Return(Person{}, nil)
+				fields.storage.EXPECT().GetPersonAlias(gomock.Any(), 1).Return(2, nil)
+				fields.storage.EXPECT().GetPerson(gomock.Any(), 2).Return(Person{ID: getPointerOnInt(2)}, nil)
 			},
 		},
 		{
@@ -365,6 +459,35 @@ func Test_GetPerson(t *testing.T) {
 				}, nil)
 			},
 		},
+		{
+			name: "http-code 200: fields",
+			fields: fields{
+				expectedHTTPCode: http.StatusOK,
+				id:               "1",
+				query:            "fields=name,%20age&fields=name",
+				expectedResponseBody: `{"id":1,"name":"test","age":2}
+`,
+			},
+
+			Prepare: func(fields *handlerTestFields) {
+				fields.storage.EXPECT().GetPerson(gomock.Any(), 1, "name", "age").Return(Person{
+					ID:   getPointerOnInt(1),
+					Name: getPointerOnString("test"),
+					Age:  getPointerOnInt(2),
+				}, nil)
+			},
+		},
+		{
+			name: "http-code 400: unknown field",
+			fields: fields{
+				expectedHTTPCode: http.StatusBadRequest,
+				id:               "1",
+				query:            "fields=name,password",
+			},
+
+			Prepare: func(fields *handlerTestFields) {
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -376,7 +499,7 @@ func Test_GetPerson(t *testing.T) {
 
 			h := &handler{storage: testFields.storage}
 
-			req := httptest.NewRequest(http.MethodGet, "/test", nil)
+			req := httptest.NewRequest(http.MethodGet, "/test?"+tt.fields.query, nil)
 			rec := httptest.NewRecorder()
 			c := e.NewContext(req, rec)
 			c.SetParamNames("id")
@@ -395,10 +518,32 @@ func Test_GetPerson(t *testing.T) {
 	}
 }
 
+func Test_streamPersons(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	testFields := createHandlerTestFields(ctrl)
+
+	batch := make([]Person, streamBatchSize)
+	match := Person{Tags: []string{"vip"}}
+	gomock.InOrder(
+		testFields.storage.EXPECT().FindPersons(gomock.Any(), match, Page{Limit: streamBatchSize, Offset: 10}).Return(batch, nil),
+		testFields.storage.EXPECT().FindPersons(gomock.Any(), match, Page{Limit: 100, Offset: 10 + streamBatchSize}).Return(batch[:100], nil),
+	)
+
+	var sent []int
+	err := streamPersons(context.Background(), testFields.storage, match, true, Page{Limit: streamBatchSize + 100, Offset: 10}, func(persons []Person) error {
+		sent = append(sent, len(persons))
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []int{streamBatchSize, 100}, sent)
+}
+
 func Test_GetPersons(t *testing.T) {
 	type fields struct {
+		query                string
 		expectedHTTPCode     int
 		expectedResponseBody string
+		expectedTotalCount   string
 	}
 
 	e := echo.New()
@@ -417,7 +562,7 @@ func Test_GetPersons(t *testing.T) {
 			},
 
 			Prepare: func(fields *handlerTestFields) {
-				fields.storage.EXPECT().GetPersons(gomock.Any()).Return([]Person{{}}, errors.New(""))
+				fields.storage.EXPECT().GetPersons(gomock.Any(), Page{}).Return([]Person{{}}, errors.New(""))
 			},
 		},
 		{
@@ -426,10 +571,11 @@ func Test_GetPersons(t *testing.T) {
 				expectedHTTPCode: http.StatusOK,
 				expectedResponseBody: `[{"id":1,"name":"test","age":2,"address":"testaddress","work":"testwork"}]
 `,
+				expectedTotalCount: "1",
 			},
 
 			Prepare: func(fields *handlerTestFields) {
-				fields.storage.EXPECT().GetPersons(gomock.Any()).Return([]Person{{
+				fields.storage.EXPECT().GetPersons(gomock.Any(), Page{}).Return([]Person{{
 					ID:      getPointerOnInt(1),
 					Name:    getPointerOnString("test"),
 					Address: getPointerOnString("testaddress"),
@@ -438,6 +584,137 @@ func Test_GetPersons(t *testing.T) {
 				}}, nil)
 			},
 		},
+		{
+			name: "http-code 400: empty tag",
+			fields: fields{
+				query:            "tag=",
+				expectedHTTPCode: http.StatusBadRequest,
+			},
+
+			Prepare: func(fields *handlerTestFields) {
+			},
+		},
+		{
+			name: "http-code 200: filtered by tags and attributes",
+			fields: fields{
+				query:            "tag=vip&tag=beta&attr.team=core&attr.level=3&attr.code=%2242%22",
+				expectedHTTPCode: http.StatusOK,
+				expectedResponseBody: `[{"id":1,"name":"test","age":0,"address":"","work":"","attributes":{"level":3,"team":"core"},"tags":["vip","beta"]}]
+`,
+			},
+
+			Prepare: func(fields *handlerTestFields) {
+				fields.storage.EXPECT().FindPersons(gomock.Any(), Person{
+					Tags:       pq.StringArray{"vip", "beta"},
+					Attributes: Attributes{"team": "core", "level": float64(3), "code": "42"},
+				}, Page{}).Return([]Person{{
+					ID:         getPointerOnInt(1),
+					Name:       getPointerOnString("test"),
+					Attributes: Attributes{"team": "core", "level": float64(3)},
+					Tags:       pq.StringArray{"vip", "beta"},
+				}}, nil)
+			},
+		},
+		{
+			name: "http-code 400: wrong limit",
+			fields: fields{
+				query:            "limit=0",
+				expectedHTTPCode: http.StatusBadRequest,
+			},
+
+			Prepare: func(fields *handlerTestFields) {
+			},
+		},
+		{
+			name: "http-code 200: page",
+			fields: fields{
+				query:            "limit=1&offset=1",
+				expectedHTTPCode: http.StatusOK,
+				expectedResponseBody: `[{"id":2,"name":"test2","age":0,"address":"","work":""}]
+`,
+				expectedTotalCount: "3",
+			},
+
+			Prepare: func(fields *handlerTestFields) {
+				fields.storage.EXPECT().GetPersons(gomock.Any(), Page{Limit: 1, Offset: 1}).Return([]Person{
+					{ID: getPointerOnInt(2), Name: getPointerOnString("test2")},
+				}, nil)
+				fields.storage.EXPECT().CountPersons(gomock.Any(), Person{}).Return(3, nil)
+			},
+		},
+		{
+			name: "http-code 200: filtered page",
+			fields: fields{
+				query:            "tag=vip&limit=1",
+				expectedHTTPCode: http.StatusOK,
+				expectedResponseBody: `[{"id":1,"name":"test1","age":0,"address":"","work":"","tags":["vip"]}]
+`,
+				expectedTotalCount: "2",
+			},
+
+			Prepare: func(fields *handlerTestFields) {
+				match := Person{Tags: pq.StringArray{"vip"}}
+				fields.storage.EXPECT().FindPersons(gomock.Any(), match, Page{Limit: 1}).Return([]Person{
+					{ID: getPointerOnInt(1), Name: getPointerOnString("test1"), Tags: pq.StringArray{"vip"}},
+				}, nil)
+				fields.storage.EXPECT().CountPersons(gomock.Any(), match).Return(2, nil)
+			},
+		},
+		{
+			name: "http-code 200: offset past the end",
+			fields: fields{
+				query:            "offset=5",
+				expectedHTTPCode: http.StatusOK,
+				expectedResponseBody: `[]
+`,
+				expectedTotalCount: "1",
+			},
+
+			Prepare: func(fields *handlerTestFields) {
+				fields.storage.EXPECT().GetPersons(gomock.Any(), Page{Offset: 5}).Return([]Person{}, nil)
+				fields.storage.EXPECT().CountPersons(gomock.Any(), Person{}).Return(1, nil)
+			},
+		},
+		{
+			name: "http-code 200: fields",
+			fields: fields{
+				query:            "fields=name",
+				expectedHTTPCode: http.StatusOK,
+				expectedResponseBody: `[{"id":1,"name":"test1"}]
+`,
+			},
+
+			Prepare: func(fields *handlerTestFields) {
+				fields.storage.EXPECT().GetPersons(gomock.Any(), Page{}, "name").Return([]Person{
+					{ID: getPointerOnInt(1), Name: getPointerOnString("test1")},
+				}, nil)
+			},
+		},
+		{
+			name: "http-code 200: filtered fields",
+			fields: fields{
+				query:            "tag=vip&fields=tags",
+				expectedHTTPCode: http.StatusOK,
+				expectedResponseBody: `[{"id":1,"tags":["vip"]}]
+`,
+			},
+
+			Prepare: func(fields *handlerTestFields) {
+				fields.storage.EXPECT().FindPersons(gomock.Any(), Person{Tags: pq.StringArray{"vip"}}, Page{}, "tags").Return([]Person{
+					{ID: getPointerOnInt(1), Tags: pq.StringArray{"vip"}},
+				}, nil)
+			},
+		},
+		{
+			name: "http-code 400: empty field",
+			fields: fields{
+				query:            "fields=name,",
+				expectedHTTPCode: http.StatusBadRequest,
+			},
+
+			Prepare: func(fields *handlerTestFields) {
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -449,7 +726,7 @@ func Test_GetPersons(t *testing.T) {
 
 			h := &handler{storage: testFields.storage}
 
-			req := httptest.NewRequest(http.MethodGet, "/test", nil)
+			req := httptest.NewRequest(http.MethodGet, "/test?"+tt.fields.query, nil)
 			rec := httptest.NewRecorder()
 			c := e.NewContext(req, rec)
 
@@ -462,6 +739,270 @@ func Test_GetPersons(t *testing.T) {
 				require.NoError(t, err)
 				require.Equal(t, tt.fields.expectedResponseBody, string(body))
 			}
+			if tt.fields.expectedTotalCount != "" {
+				require.Equal(t, tt.fields.expectedTotalCount, rec.Header().Get("X-Total-Count"))
+			}
+		})
+	}
+}
+
+func Test_Person_CurrentAge(t *testing.T) {
+	now := time.Date(2026, time.October, 19, 12, 0, 0, 0, time.UTC)
+	date := func(year int, month time.Month, day int) *time.Time {
+		d := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+		return &d
+	}
+
+	require.Nil(t, Person{}.CurrentAge(now))
+	require.Equal(t, 7, *Person{Age: getPointerOnInt(7)}.CurrentAge(now))
+	require.Equal(t, 36, *Person{BirthDate: date(1990, time.October, 19)}.CurrentAge(now))
+	require.Equal(t, 35, *Person{BirthDate: date(1990, time.October, 20)}.CurrentAge(now))
+	require.Equal(t, 36, *Person{Age: getPointerOnInt(7), BirthDate: date(1990, time.January, 1)}.CurrentAge(now))
+}
+
+func Test_CreatePerson_ValidationMessages(t *testing.T) {
+	e := echo.New()
+	e.Validator = validation.MustRegisterCustomValidator(validator.New())
+
+	ctrl := gomock.NewController(t)
+	h := &handler{storage: createHandlerTestFields(ctrl).storage}
+	h.Register(e)
+
+	tests := []struct {
+		name                 string
+		reqBody              string
+		acceptLanguage       string
+		expectedResponseBody string
+	}{
+		{
+			name:           "field rules",
+			reqBody:        `{"age": 1, "phone_numbers": ["+7999", "abc"]}`,
+			acceptLanguage: "en",
+			expectedResponseBody: `{"errors":"validation error","fields":{"name":"name is a required field","phone_numbers[1]":"phone_numbers[1] must be a phone number in E.164 format"}}
+`,
+		},
+		{
+			name:           "struct rule",
+			reqBody:        `{"name": "test", "age": 1, "birth_date": "1990-01-31"}`,
+			acceptLanguage: "ru-RU,ru;q=0.9",
+			expectedResponseBody: `{"errors":"validation error","fields":{"age":"age не соответствует birth_date"}}
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/persons", strings.NewReader(tt.reqBody))
+			req.Header.Set("Accept-Language", tt.acceptLanguage)
+			rec := httptest.NewRecorder()
+
+			e.ServeHTTP(rec, req)
+
+			require.Equal(t, http.StatusBadRequest, rec.Code)
+			require.Equal(t, tt.expectedResponseBody, rec.Body.String())
+		})
+	}
+}
+
+func Test_CreatePerson_UniqueConstraints(t *testing.T) {
+	e := echo.New()
+	e.Validator = validation.MustRegisterCustomValidator(validator.New())
+
+	tests := []struct {
+		name                   string
+		reqBody                string
+		expectedHTTPCode       int
+		expectedLocationHeader string
+		Prepare                func(fields *handlerTestFields)
+	}{
+		{
+			name:                   "http-code 409",
+			reqBody:                `{"name": "test", "address": "test"}`,
+			expectedHTTPCode:       http.StatusConflict,
+			expectedLocationHeader: `/api/v1/persons/5`,
+			Prepare: func(fields *handlerTestFields) {
+				fields.storage.EXPECT().CreatePerson(gomock.Any(), gomock.Any()).
+					Return(0, &ConflictError{Existing: Person{ID: getPointerOnInt(5)}})
+			},
+		},
+		{
+			name:             "http-code 409: existing person unknown",
+			reqBody:          `{"name": "test", "address": "test"}`,
+			expectedHTTPCode: http.StatusConflict,
+			Prepare: func(fields *handlerTestFields) {
+				fields.storage.EXPECT().CreatePerson(gomock.Any(), gomock.Any()).
+					Return(0, fmt.Errorf("failed to create person: %w", &ConflictError{}))
+			},
+		},
+		{
+			name:                   "http-code 201",
+			reqBody:                `{"name": "test", "address": "test", "email": "test@example.com"}`,
+			expectedHTTPCode:       http.StatusCreated,
+			expectedLocationHeader: `/api/v1/persons/1`,
+			Prepare: func(fields *handlerTestFields) {
+				fields.storage.EXPECT().CreatePerson(gomock.Any(), gomock.Any()).Return(1, nil)
+				fields.storage.EXPECT().GetPerson(gomock.Any(), 1).Return(Person{ID: getPointerOnInt(1), Name: getPointerOnString("test")}, nil)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			testFields := createHandlerTestFields(ctrl)
+			tt.Prepare(testFields)
+
+			h := &handler{storage: testFields.storage}
+
+			req := httptest.NewRequest(http.MethodPost, "/test", strings.NewReader(tt.reqBody))
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			err := h.CreatePerson(c)
+
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedHTTPCode, rec.Code)
+			require.Equal(t, tt.expectedLocationHeader, rec.Header().Get("Location"))
+		})
+	}
+}
+
+func Test_MergePerson(t *testing.T) {
+	type fields struct {
+		id               string
+		reqBody          string
+		expectedHTTPCode int
+	}
+
+	e := echo.New()
+	e.Validator = validation.MustRegisterCustomValidator(validator.New())
+
+	tests := []struct {
+		name    string
+		fields  fields
+		Prepare func(fields *handlerTestFields)
+	}{
+		{
+			name: "http-code 400: wrong id",
+			fields: fields{
+				expectedHTTPCode: http.StatusBadRequest,
+				id:               "test",
+				reqBody:          `{"source_id": 2}`,
+			},
+			Prepare: func(fields *handlerTestFields) {},
+		},
+		{
+			name: "http-code 400: no source",
+			fields: fields{
+				expectedHTTPCode: http.StatusBadRequest,
+				id:               "1",
+				reqBody:          `{}`,
+			},
+			Prepare: func(fields *handlerTestFields) {},
+		},
+		{
+			name: "http-code 400: merge into itself",
+			fields: fields{
+				expectedHTTPCode: http.StatusBadRequest,
+				id:               "1",
+				reqBody:          `{"source_id": 1}`,
+			},
+			Prepare: func(fields *handlerTestFields) {},
+		},
+		{
+			name: "http-code 404",
+			fields: fields{
+				expectedHTTPCode: http.StatusNotFound,
+				id:               "1",
+				reqBody:          `{"source_id": 2}`,
+			},
+			Prepare: func(fields *handlerTestFields) {
+				fields.storage.EXPECT().MergePersons(gomock.Any(), 1, 2).Return(Person{}, ErrNotFound)
+			},
+		},
+		{
+			name: "http-code 500",
+			fields: fields{
+				expectedHTTPCode: http.StatusInternalServerError,
+				id:               "1",
+				reqBody:          `{"source_id": 2}`,
+			},
+			Prepare: func(fields *handlerTestFields) {
+				fields.storage.EXPECT().MergePersons(gomock.Any(), 1, 2).Return(Person{}, errors.New(""))
+			},
+		},
+		{
+			name: "http-code 200",
+			fields: fields{
+				expectedHTTPCode: http.StatusOK,
+				id:               "1",
+				reqBody:          `{"source_id": 2}`,
+			},
+			Prepare: func(fields *handlerTestFields) {
+				fields.storage.EXPECT().MergePersons(gomock.Any(), 1, 2).Return(Person{
+					ID:   getPointerOnInt(1),
+					Name: getPointerOnString("test"),
+				}, nil)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			testFields := createHandlerTestFields(ctrl)
+			tt.Prepare(testFields)
+
+			h := &handler{storage: testFields.storage}
+
+			req := httptest.NewRequest(http.MethodPost, "/test", strings.NewReader(tt.fields.reqBody))
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetParamNames("id")
+			c.SetParamValues(tt.fields.id)
+
+			err := h.MergePerson(c)
+
+			require.NoError(t, err)
+			require.Equal(t, tt.fields.expectedHTTPCode, rec.Code)
+		})
+	}
+}
+
+func Test_MergePerson_Routes(t *testing.T) {
+	e := echo.New()
+	e.Validator = validation.MustRegisterCustomValidator(validator.New())
+
+	ctrl := gomock.NewController(t)
+	testFields := createHandlerTestFields(ctrl)
+	testFields.storage.EXPECT().MergePersons(gomock.Any(), 1, 2).Return(Person{ID: getPointerOnInt(1), Name: getPointerOnString("test")}, nil).Times(3)
+
+	h := &handler{storage: testFields.storage}
+	h.Register(e)
+
+	tests := []struct {
+		name             string
+		path             string
+		expectedHTTPCode int
+	}{
+		{name: "v1", path: "/api/v1/persons/1:merge", expectedHTTPCode: http.StatusOK},
+		{name: "v2", path: "/api/v2/persons/1:merge", expectedHTTPCode: http.StatusOK},
+		{name: "unversioned", path: "/api/persons/1:merge", expectedHTTPCode: http.StatusOK},
+		{name: "unknown method", path: "/api/v1/persons/1:copy", expectedHTTPCode: http.StatusNotFound},
+		{name: "no method", path: "/api/v1/persons/1", expectedHTTPCode: http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(`{"source_id": 2}`))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+
+			e.ServeHTTP(rec, req)
+
+			require.Equal(t, tt.expectedHTTPCode, rec.Code)
 		})
 	}
 }
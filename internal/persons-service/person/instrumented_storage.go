@@ -0,0 +1,148 @@
+package person
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"time"
+)
+
+// instrumentedStorage records the duration and outcome of every storage call.
+type instrumentedStorage struct {
+	storage  storage
+	duration *prometheus.HistogramVec
+}
+
+func NewInstrumentedStorage(storage storage, reg prometheus.Registerer) *instrumentedStorage {
+	duration := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "person_repository_query_duration_seconds",
+		Help:    "Duration of person repository queries by method and result.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "result"})
+	reg.MustRegister(duration)
+
+	return &instrumentedStorage{storage: storage, duration: duration}
+}
+
+func (s *instrumentedStorage) observe(method string, start time.Time, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	s.duration.WithLabelValues(method, result).Observe(time.Since(start).Seconds())
+}
+
+func (s *instrumentedStorage) CreatePerson(ctx context.Context, person Person) (int, error) {
+	start := time.Now()
+	id, err := s.storage.CreatePerson(ctx, person)
+	s.observe("CreatePerson", start, err)
+	return id, err
+}
+
+func (s *instrumentedStorage) UpdatePerson(ctx context.Context, id int, person *Person) error {
+	start := time.Now()
+	err := s.storage.UpdatePerson(ctx, id, person)
+	s.observe("UpdatePerson", start, err)
+	return err
+}
+
+func (s *instrumentedStorage) DeletePerson(ctx context.Context, id int) (bool, error) {
+	start := time.Now()
+	isDeleted, err := s.storage.DeletePerson(ctx, id)
+	s.observe("DeletePerson", start, err)
+	return isDeleted, err
+}
+
+func (s *instrumentedStorage) GetPersons(ctx context.Context, page Page, fields ...string) ([]Person, error) {
+	start := time.Now()
+	persons, err := s.storage.GetPersons(ctx, page, fields...)
+	s.observe("GetPersons", start, err)
+	return persons, err
+}
+
+func (s *instrumentedStorage) GetPerson(ctx context.Context, id int, fields ...string) (Person, error) {
+	start := time.Now()
+	p, err := s.storage.GetPerson(ctx, id, fields...)
+	s.observe("GetPerson", start, err)
+	return p, err
+}
+
+func (s *instrumentedStorage) GetPersonsByIDs(ctx context.Context, ids []int) ([]Person, error) {
+	start := time.Now()
+	persons, err := s.storage.GetPersonsByIDs(ctx, ids)
+	s.observe("GetPersonsByIDs", start, err)
+	return persons, err
+}
+
+func (s *instrumentedStorage) FindPersons(ctx context.Context, match Person, page Page, fields ...string) ([]Person, error) {
+	start := time.Now()
+	persons, err := s.storage.FindPersons(ctx, match, page, fields...)
+	s.observe("FindPersons", start, err)
+	return persons, err
+}
+
+func (s *instrumentedStorage) CountPersons(ctx context.Context, match Person) (int, error) {
+	start := time.Now()
+	count, err := s.storage.CountPersons(ctx, match)
+	s.observe("CountPersons", start, err)
+	return count, err
+}
+
+func (s *instrumentedStorage) FindDuplicateCandidates(ctx context.Context, p Person, limit int) ([]Person, error) {
+	start := time.Now()
+	persons, err := s.storage.FindDuplicateCandidates(ctx, p, limit)
+	s.observe("FindDuplicateCandidates", start, err)
+	return persons, err
+}
+
+func (s *instrumentedStorage) MergePersons(ctx context.Context, id, sourceID int) (Person, error) {
+	start := time.Now()
+	p, err := s.storage.MergePersons(ctx, id, sourceID)
+	s.observe("MergePersons", start, err)
+	return p, err
+}
+
+func (s *instrumentedStorage) GetPersonAlias(ctx context.Context, aliasID int) (int, error) {
+	start := time.Now()
+	id, err := s.storage.GetPersonAlias(ctx, aliasID)
+	s.observe("GetPersonAlias", start, err)
+	return id, err
+}
+
+func (s *instrumentedStorage) CreateRelation(ctx context.Context, relation Relation) (int, error) {
+	start := time.Now()
+	id, err := s.storage.CreateRelation(ctx, relation)
+	s.observe("CreateRelation", start, err)
+	return id, err
+}
+
+func (s *instrumentedStorage) GetRelations(ctx context.Context, personID int) ([]Relation, error) {
+	start := time.Now()
+	relations, err := s.storage.GetRelations(ctx, personID)
+	s.observe("GetRelations", start, err)
+	return relations, err
+}
+
+func (s *instrumentedStorage) GetRelationsByPersonIDs(ctx context.Context, personIDs []int) ([]Relation, error) {
+	start := time.Now()
+	relations, err := s.storage.GetRelationsByPersonIDs(ctx, personIDs)
+	s.observe("GetRelationsByPersonIDs", start, err)
+	return relations, err
+}
+
+func (s *instrumentedStorage) DeleteRelation(ctx context.Context, personID, relationID int) (bool, error) {
+	start := time.Now()
+	isDeleted, err := s.storage.DeleteRelation(ctx, personID, relationID)
+	s.observe("DeleteRelation", start, err)
+	return isDeleted, err
+}
+
+func (s *instrumentedStorage) PersonsChanged(ctx context.Context, ids []int) {
+	s.storage.PersonsChanged(ctx, ids)
+}
+
+func (s *instrumentedStorage) GetRelatives(ctx context.Context, personID, maxDepth int, types []string) ([]Relative, error) {
+	start := time.Now()
+	relatives, err := s.storage.GetRelatives(ctx, personID, maxDepth, types)
+	s.observe("GetRelatives", start, err)
+	return relatives, err
+}
@@ -0,0 +1,421 @@
+package person
+
+import (
+	"context"
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/pgutil"
+	"github.com/pkg/errors"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// memoryRepository keeps persons in process memory. It is meant for tests and local runs
+// without a database; the data is lost on restart.
+type memoryRepository struct {
+	mu      sync.RWMutex
+	persons map[int]Person
+	// aliases maps the ids of merged persons to the person they were merged into.
+	aliases        map[int]int
+	relations      map[int]Relation
+	lastID         int
+	lastRelationID int
+	unique         []uniqueConstraint
+}
+
+func NewMemoryRepository() *memoryRepository {
+	return &memoryRepository{
+		persons:   make(map[int]Person),
+		aliases:   make(map[int]int),
+		relations: make(map[int]Relation),
+	}
+}
+
+// SetUniqueConstraints checks the constraints under the lock of r.mu.
+func (r *memoryRepository) SetUniqueConstraints(fields [][]string) error {
+	constraints, err := newUniqueConstraints(fields)
+	if err != nil {
+		return err
+	}
+	r.unique = constraints
+	return nil
+}
+
+// findConflict checks the constraints for p under the lock it is written in. r.mu must be held.
+func (r *memoryRepository) findConflict(p Person, excludeID int) error {
+	return findConflict(r.unique, p, excludeID, func(match Person) ([]Person, error) {
+		return r.matchingPersons(match), nil
+	})
+}
+
+func (r *memoryRepository) CreatePerson(ctx context.Context, person Person) (int, error) {
+	if err := pgutil.CheckContext(ctx); err != nil {
+		return 0, err
+	}
+	if person.Name == nil {
+		return 0, errors.New("name is required")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.findConflict(person, 0); err != nil {
+		return 0, err
+	}
+
+	r.lastID++
+	id := r.lastID
+	now := time.Now().UTC()
+	person = clonePerson(person)
+	person.ID = &id
+	person.CreatedAt, person.UpdatedAt = &now, &now
+	r.persons[id] = person
+
+	return id, nil
+}
+
+func (r *memoryRepository) UpdatePerson(ctx context.Context, id int, person *Person) error {
+	if err := pgutil.CheckContext(ctx); err != nil {
+		return err
+	}
+	if isEmptyUpdate(*person) {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.persons[id]
+	if !ok {
+		return ErrNotFound
+	}
+	stored = applyUpdate(stored, *person)
+	if err := r.findConflict(stored, id); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	stored.UpdatedAt = &now
+	r.persons[id] = stored
+	*person = clonePerson(stored)
+
+	return nil
+}
+
+func (r *memoryRepository) DeletePerson(ctx context.Context, id int) (bool, error) {
+	if err := pgutil.CheckContext(ctx); err != nil {
+		return false, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.persons[id]; !ok {
+		return false, nil
+	}
+	delete(r.persons, id)
+	for aliasID, personID := range r.aliases {
+		if personID == id {
+			delete(r.aliases, aliasID)
+		}
+	}
+	for relationID, relation := range r.relations {
+		if relation.PersonID == id || relation.RelatedPersonID == id {
+			delete(r.relations, relationID)
+		}
+	}
+
+	return true, nil
+}
+
+func (r *memoryRepository) GetPersons(ctx context.Context, page Page, fields ...string) ([]Person, error) {
+	return r.findPersons(ctx, Person{}, page, fields)
+}
+
+func (r *memoryRepository) GetPerson(ctx context.Context, id int, fields ...string) (Person, error) {
+	if err := pgutil.CheckContext(ctx); err != nil {
+		return Person{}, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	p, ok := r.persons[id]
+	if !ok {
+		return Person{}, nil
+	}
+
+	return projectPerson(clonePerson(p), fields), nil
+}
+
+func (r *memoryRepository) GetPersonsByIDs(ctx context.Context, ids []int) ([]Person, error) {
+	if err := pgutil.CheckContext(ctx); err != nil {
+		return []Person{}, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	res := make([]Person, 0, len(ids))
+	for _, id := range distinctIDs(ids) {
+		if p, ok := r.persons[id]; ok {
+			res = append(res, clonePerson(p))
+		}
+	}
+
+	return res, nil
+}
+
+func (r *memoryRepository) FindPersons(ctx context.Context, match Person, page Page, fields ...string) ([]Person, error) {
+	return r.findPersons(ctx, match, page, fields)
+}
+
+func (r *memoryRepository) CountPersons(ctx context.Context, match Person) (int, error) {
+	persons, err := r.findPersons(ctx, match, Page{}, nil)
+	return len(persons), err
+}
+
+func (r *memoryRepository) FindDuplicateCandidates(ctx context.Context, p Person, limit int) ([]Person, error) {
+	if err := pgutil.CheckContext(ctx); err != nil {
+		return []Person{}, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	res := make([]Person, 0)
+	for _, candidate := range r.matchingPersons(Person{}) {
+		if len(res) == limit {
+			break
+		}
+		if *candidate.ID != *p.ID && isDuplicateCandidate(p, candidate) {
+			res = append(res, clonePerson(candidate))
+		}
+	}
+	return res, nil
+}
+
+// findPersons returns the page of the persons matching match with the columns of fields.
+func (r *memoryRepository) findPersons(ctx context.Context, match Person, page Page, fields []string) ([]Person, error) {
+	if err := pgutil.CheckContext(ctx); err != nil {
+		return []Person{}, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	res := page.apply(r.matchingPersons(match))
+	for i := range res {
+		res[i] = projectPerson(clonePerson(res[i]), fields)
+	}
+	return res, nil
+}
+
+// matchingPersons returns the persons matching match sorted by ID. r.mu must be held.
+func (r *memoryRepository) matchingPersons(match Person) []Person {
+	res := make([]Person, 0)
+	for _, p := range r.persons {
+		if matchesPerson(p, match) {
+			res = append(res, p)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return *res[i].ID < *res[j].ID
+	})
+	return res
+}
+
+func (r *memoryRepository) MergePersons(ctx context.Context, id, sourceID int) (Person, error) {
+	if err := pgutil.CheckContext(ctx); err != nil {
+		return Person{}, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	target, ok := r.persons[id]
+	if !ok {
+		return Person{}, ErrNotFound
+	}
+	source, ok := r.persons[sourceID]
+	if !ok {
+		return Person{}, ErrNotFound
+	}
+
+	now := time.Now().UTC()
+	merged := mergePersons(target, source)
+	merged.UpdatedAt = &now
+	r.persons[id] = merged
+
+	delete(r.persons, sourceID)
+	for aliasID, personID := range r.aliases {
+		if personID == sourceID {
+			r.aliases[aliasID] = id
+		}
+	}
+	r.aliases[sourceID] = id
+
+	relations := make([]Relation, 0, len(r.relations))
+	for _, relation := range r.relations {
+		relations = append(relations, relation)
+	}
+	r.relations = make(map[int]Relation, len(relations))
+	for _, relation := range mergeRelations(relations, id, sourceID) {
+		r.relations[*relation.ID] = relation
+	}
+
+	return clonePerson(merged), nil
+}
+
+// PersonsChanged has nothing to do, the storages changing persons behind it store the changes
+// themselves.
+func (r *memoryRepository) PersonsChanged(ctx context.Context, ids []int) {}
+
+// SetWork sets the work of the person, or clears it for nil, and reports whether it changed.
+// The memory organization storage, sharing no database with the persons, keeps the work in
+// step with the employments through it. A missing person is ignored.
+func (r *memoryRepository) SetWork(ctx context.Context, id int, work *string) (bool, error) {
+	if err := pgutil.CheckContext(ctx); err != nil {
+		return false, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.persons[id]
+	if !ok || equalPointers(stored.Work, work) {
+		return false, nil
+	}
+
+	now := time.Now().UTC()
+	stored.Work = clonePointer(work)
+	stored.UpdatedAt = &now
+	r.persons[id] = stored
+
+	return true, nil
+}
+
+func (r *memoryRepository) GetPersonAlias(ctx context.Context, aliasID int) (int, error) {
+	if err := pgutil.CheckContext(ctx); err != nil {
+		return 0, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.aliases[aliasID], nil
+}
+
+func (r *memoryRepository) CreateRelation(ctx context.Context, relation Relation) (int, error) {
+	if err := pgutil.CheckContext(ctx); err != nil {
+		return 0, err
+	}
+	if relation.PersonID == relation.RelatedPersonID {
+		return 0, errors.New("person can not be related to itself")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, ok := r.persons[relation.PersonID]
+	_, relatedOK := r.persons[relation.RelatedPersonID]
+	if !ok || !relatedOK {
+		return 0, ErrNotFound
+	}
+	for _, stored := range r.relations {
+		if stored.PersonID == relation.PersonID && stored.RelatedPersonID == relation.RelatedPersonID && stored.Type == relation.Type {
+			return 0, ErrRelationExists
+		}
+	}
+
+	r.lastRelationID++
+	id := r.lastRelationID
+	now := time.Now().UTC()
+	relation.ID, relation.CreatedAt = &id, &now
+	r.relations[id] = relation
+
+	return id, nil
+}
+
+func (r *memoryRepository) GetRelations(ctx context.Context, personID int) ([]Relation, error) {
+	if err := pgutil.CheckContext(ctx); err != nil {
+		return []Relation{}, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	res := make([]Relation, 0)
+	for _, relation := range r.relations {
+		if relation.involves(personID) {
+			relation.ID, relation.CreatedAt = clonePointer(relation.ID), clonePointer(relation.CreatedAt)
+			res = append(res, relation)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return *res[i].ID < *res[j].ID
+	})
+
+	return res, nil
+}
+
+func (r *memoryRepository) GetRelationsByPersonIDs(ctx context.Context, personIDs []int) ([]Relation, error) {
+	if err := pgutil.CheckContext(ctx); err != nil {
+		return []Relation{}, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	res := make([]Relation, 0)
+	for _, relation := range r.relations {
+		if slices.ContainsFunc(personIDs, relation.involves) {
+			relation.ID, relation.CreatedAt = clonePointer(relation.ID), clonePointer(relation.CreatedAt)
+			res = append(res, relation)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return *res[i].ID < *res[j].ID
+	})
+
+	return res, nil
+}
+
+func (r *memoryRepository) DeleteRelation(ctx context.Context, personID, relationID int) (bool, error) {
+	if err := pgutil.CheckContext(ctx); err != nil {
+		return false, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	relation, ok := r.relations[relationID]
+	if !ok || !relation.involves(personID) {
+		return false, nil
+	}
+	delete(r.relations, relationID)
+
+	return true, nil
+}
+
+func (r *memoryRepository) GetRelatives(ctx context.Context, personID, maxDepth int, types []string) ([]Relative, error) {
+	if err := pgutil.CheckContext(ctx); err != nil {
+		return []Relative{}, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	relations := make([]Relation, 0, len(r.relations))
+	for _, relation := range r.relations {
+		relations = append(relations, relation)
+	}
+
+	res := make([]Relative, 0)
+	for id, depth := range walkRelations(relations, personID, maxDepth, types) {
+		res = append(res, Relative{Person: clonePerson(r.persons[id]), Depth: depth})
+	}
+	sortRelatives(res)
+
+	return res, nil
+}
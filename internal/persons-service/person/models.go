@@ -1,9 +1,241 @@
 package person
 
+import (
+	"github.com/lib/pq"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Person is a stored person. Nil fields are NULL in the storage or, in partial updates,
+// fields that are left unchanged. The json form is used for export, caches and file storages.
 type Person struct {
-	ID      *int    `db:"id"`
-	Name    *string `db:"name"`
-	Age     *int    `db:"age"`
-	Address *string `db:"address"`
-	Work    *string `db:"work"`
+	ID           *int           `db:"id" json:"id,omitempty"`
+	Name         *string        `db:"name" json:"name"`
+	Age          *int           `db:"age" json:"age,omitempty"`
+	BirthDate    *time.Time     `db:"birth_date" json:"birth_date,omitempty"`
+	Email        *string        `db:"email" json:"email,omitempty"`
+	PhoneNumbers pq.StringArray `db:"phone_numbers" json:"phone_numbers,omitempty"`
+	Address      *string        `db:"address" json:"address,omitempty"`
+	Street       *string        `db:"address_street" json:"address_street,omitempty"`
+	City         *string        `db:"address_city" json:"address_city,omitempty"`
+	PostalCode   *string        `db:"address_postal_code" json:"address_postal_code,omitempty"`
+	Country      *string        `db:"address_country" json:"address_country,omitempty"`
+	Work         *string        `db:"work" json:"work,omitempty"`
+	Attributes   Attributes     `db:"attributes" json:"attributes,omitempty"`
+	Tags         pq.StringArray `db:"tags" json:"tags,omitempty"`
+	CreatedAt    *time.Time     `db:"created_at" json:"created_at,omitempty"`
+	UpdatedAt    *time.Time     `db:"updated_at" json:"updated_at,omitempty"`
+}
+
+// CurrentAge returns the age at now computed from the birth date, or the stored age for
+// persons created without one.
+func (p Person) CurrentAge(now time.Time) *int {
+	if p.BirthDate == nil {
+		return p.Age
+	}
+
+	birth := p.BirthDate.UTC()
+	now = now.UTC()
+	age := now.Year() - birth.Year()
+	if now.Month() < birth.Month() || (now.Month() == birth.Month() && now.Day() < birth.Day()) {
+		age--
+	}
+	return &age
+}
+
+// Page selects a part of a list of persons ordered by ID. A zero Limit selects every person
+// from Offset on.
+type Page struct {
+	Limit  int
+	Offset int
+}
+
+// apply returns the part of persons, ordered by ID, that the page selects.
+func (p Page) apply(persons []Person) []Person {
+	persons = persons[min(p.Offset, len(persons)):]
+	if p.Limit > 0 && p.Limit < len(persons) {
+		persons = persons[:p.Limit]
+	}
+	return persons
+}
+
+// clonePerson returns a copy of p that shares no pointers with it.
+func clonePerson(p Person) Person {
+	return Person{
+		ID:           clonePointer(p.ID),
+		Name:         clonePointer(p.Name),
+		Age:          clonePointer(p.Age),
+		BirthDate:    clonePointer(p.BirthDate),
+		Email:        clonePointer(p.Email),
+		PhoneNumbers: cloneSlice(p.PhoneNumbers),
+		Address:      clonePointer(p.Address),
+		Street:       clonePointer(p.Street),
+		City:         clonePointer(p.City),
+		PostalCode:   clonePointer(p.PostalCode),
+		Country:      clonePointer(p.Country),
+		Work:         clonePointer(p.Work),
+		Attributes:   cloneAttributes(p.Attributes),
+		Tags:         cloneSlice(p.Tags),
+		CreatedAt:    clonePointer(p.CreatedAt),
+		UpdatedAt:    clonePointer(p.UpdatedAt),
+	}
+}
+
+// distinctIDs returns the ids sorted and without repeats, leaving ids unchanged.
+func distinctIDs(ids []int) []int {
+	res := slices.Clone(ids)
+	slices.Sort(res)
+	return slices.Compact(res)
+}
+
+func clonePointer[T any](v *T) *T {
+	if v == nil {
+		return nil
+	}
+	c := *v
+	return &c
+}
+
+// equalPointers reports whether a and b are both nil or point to equal values.
+func equalPointers[T comparable](a, b *T) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func cloneSlice[S ~[]T, T any](v S) S {
+	if v == nil {
+		return nil
+	}
+	return append(make(S, 0, len(v)), v...)
+}
+
+// valueOrZero returns the value v points to, or the zero value for nullable columns.
+func valueOrZero[T any](v *T) T {
+	if v == nil {
+		var zero T
+		return zero
+	}
+	return *v
+}
+
+// isEmptyUpdate reports whether a partial update sets no fields.
+func isEmptyUpdate(p Person) bool {
+	return p.Name == nil && p.Age == nil && p.BirthDate == nil && p.Email == nil && p.PhoneNumbers == nil &&
+		p.Address == nil && p.Street == nil && p.City == nil && p.PostalCode == nil && p.Country == nil &&
+		p.Work == nil && p.Attributes == nil && p.Tags == nil
+}
+
+// applyUpdate returns stored with the fields set in update replaced.
+func applyUpdate(stored, update Person) Person {
+	res := clonePerson(stored)
+	if update.Name != nil {
+		res.Name = clonePointer(update.Name)
+	}
+	if update.Age != nil {
+		res.Age = clonePointer(update.Age)
+	}
+	if update.BirthDate != nil {
+		res.BirthDate = clonePointer(update.BirthDate)
+	}
+	if update.Email != nil {
+		res.Email = clonePointer(update.Email)
+	}
+	if update.PhoneNumbers != nil {
+		res.PhoneNumbers = cloneSlice(update.PhoneNumbers)
+	}
+	if update.Address != nil {
+		res.Address = clonePointer(update.Address)
+	}
+	if update.Street != nil {
+		res.Street = clonePointer(update.Street)
+	}
+	if update.City != nil {
+		res.City = clonePointer(update.City)
+	}
+	if update.PostalCode != nil {
+		res.PostalCode = clonePointer(update.PostalCode)
+	}
+	if update.Country != nil {
+		res.Country = clonePointer(update.Country)
+	}
+	if update.Work != nil {
+		res.Work = clonePointer(update.Work)
+	}
+	if update.Attributes != nil {
+		res.Attributes = cloneAttributes(update.Attributes)
+	}
+	if update.Tags != nil {
+		res.Tags = cloneSlice(update.Tags)
+	}
+	return res
+}
+
+// matchesPerson reports whether p has every field set in match. Strings are compared
+// case-insensitively, p must have all tags of match and contain its attributes; phone
+// numbers are not compared.
+func matchesPerson(p, match Person) bool {
+	equalFold := func(v, m *string) bool {
+		return m == nil || (v != nil && strings.EqualFold(*v, *m))
+	}
+	if match.Age != nil && (p.Age == nil || *p.Age != *match.Age) {
+		return false
+	}
+	if match.BirthDate != nil && (p.BirthDate == nil || !p.BirthDate.Equal(*match.BirthDate)) {
+		return false
+	}
+	for _, tag := range match.Tags {
+		if !slices.Contains(p.Tags, tag) {
+			return false
+		}
+	}
+	if match.Attributes != nil && !containsAttributes(p.Attributes, match.Attributes) {
+		return false
+	}
+	return equalFold(p.Name, match.Name) && equalFold(p.Email, match.Email) &&
+		equalFold(p.Address, match.Address) && equalFold(p.Street, match.Street) &&
+		equalFold(p.City, match.City) && equalFold(p.PostalCode, match.PostalCode) &&
+		equalFold(p.Country, match.Country) && equalFold(p.Work, match.Work)
+}
+
+// mergePersons returns target with the fields and attributes it lacks taken from source, the
+// phone numbers and tags of both and the earlier creation time.
+func mergePersons(target, source Person) Person {
+	res := applyUpdate(source, target)
+	res.ID = clonePointer(target.ID)
+
+	phoneNumbers := cloneSlice(target.PhoneNumbers)
+	for _, phone := range source.PhoneNumbers {
+		if !slices.Contains(phoneNumbers, phone) {
+			phoneNumbers = append(phoneNumbers, phone)
+		}
+	}
+	res.PhoneNumbers = phoneNumbers
+
+	tags := cloneSlice(target.Tags)
+	for _, tag := range source.Tags {
+		if !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	res.Tags = tags
+
+	if target.Attributes != nil || source.Attributes != nil {
+		res.Attributes = cloneAttributes(source.Attributes)
+		if res.Attributes == nil {
+			res.Attributes = Attributes{}
+		}
+		for k, v := range cloneAttributes(target.Attributes) {
+			res.Attributes[k] = v
+		}
+	}
+
+	res.CreatedAt = clonePointer(target.CreatedAt)
+	if source.CreatedAt != nil && (target.CreatedAt == nil || source.CreatedAt.Before(*target.CreatedAt)) {
+		res.CreatedAt = clonePointer(source.CreatedAt)
+	}
+	res.UpdatedAt = clonePointer(target.UpdatedAt)
+	return res
 }
@@ -0,0 +1,121 @@
+package person
+
+import (
+	"slices"
+	"sort"
+	"time"
+)
+
+const (
+	defaultRelativesDepth = 1
+	maxRelativesDepth     = 5
+)
+
+// relationTypes lists the kinds of relations, familyRelationTypes the ones that make persons
+// relatives.
+var (
+	relationTypes       = []string{"parent", "child", "spouse", "sibling", "relative", "employer", "employee", "colleague", "contact"}
+	familyRelationTypes = []string{"parent", "child", "spouse", "sibling", "relative"}
+)
+
+// Relation is a typed link from a person to a related one. One-way relations belong to the
+// person only; bidirectional ones are shared by both persons.
+type Relation struct {
+	ID              *int       `db:"id" json:"id,omitempty"`
+	PersonID        int        `db:"person_id" json:"person_id"`
+	RelatedPersonID int        `db:"related_person_id" json:"related_person_id"`
+	Type            string     `db:"type" json:"type"`
+	Bidirectional   bool       `db:"bidirectional" json:"bidirectional"`
+	CreatedAt       *time.Time `db:"created_at" json:"created_at,omitempty"`
+}
+
+// involves reports whether r is one of the relations of the person with id.
+func (r Relation) involves(id int) bool {
+	return r.PersonID == id || (r.Bidirectional && r.RelatedPersonID == id)
+}
+
+// Relative is a person reachable through relations in Depth hops.
+type Relative struct {
+	Person
+	Depth int `db:"depth" json:"depth"`
+}
+
+// walkRelations returns the depth of every person reachable from id in at most maxDepth hops
+// over the relations of the given types. One-way relations are followed from the person to
+// the related person only.
+func walkRelations(relations []Relation, id, maxDepth int, types []string) map[int]int {
+	next := make(map[int][]int)
+	for _, r := range relations {
+		if !slices.Contains(types, r.Type) {
+			continue
+		}
+		next[r.PersonID] = append(next[r.PersonID], r.RelatedPersonID)
+		if r.Bidirectional {
+			next[r.RelatedPersonID] = append(next[r.RelatedPersonID], r.PersonID)
+		}
+	}
+
+	depths := map[int]int{id: 0}
+	queue := []int{id}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if depths[current] == maxDepth {
+			continue
+		}
+		for _, related := range next[current] {
+			if _, ok := depths[related]; !ok {
+				depths[related] = depths[current] + 1
+				queue = append(queue, related)
+			}
+		}
+	}
+	delete(depths, id)
+	return depths
+}
+
+// sortRelatives orders relatives by depth and then by id, like the database does.
+func sortRelatives(relatives []Relative) {
+	sort.Slice(relatives, func(i, j int) bool {
+		if relatives[i].Depth != relatives[j].Depth {
+			return relatives[i].Depth < relatives[j].Depth
+		}
+		return *relatives[i].ID < *relatives[j].ID
+	})
+}
+
+// mergeRelations moves the relations of sourceID to id, dropping the ones that would relate
+// id to itself or repeat a relation id already has. It returns the relations to keep.
+func mergeRelations(relations []Relation, id, sourceID int) []Relation {
+	type key struct {
+		personID, relatedPersonID int
+		relationType              string
+	}
+
+	existing := make(map[key]bool)
+	for _, r := range relations {
+		if r.PersonID != sourceID && r.RelatedPersonID != sourceID {
+			existing[key{r.PersonID, r.RelatedPersonID, r.Type}] = true
+		}
+	}
+
+	res := make([]Relation, 0, len(relations))
+	for _, r := range relations {
+		if r.PersonID == sourceID {
+			r.PersonID = id
+		} else if r.RelatedPersonID == sourceID {
+			r.RelatedPersonID = id
+		} else {
+			res = append(res, r)
+			continue
+		}
+
+		k := key{r.PersonID, r.RelatedPersonID, r.Type}
+		if r.PersonID == r.RelatedPersonID || existing[k] {
+			continue
+		}
+		existing[k] = true
+		res = append(res, r)
+	}
+	return res
+}
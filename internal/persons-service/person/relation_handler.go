@@ -0,0 +1,198 @@
+package person
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+type relationRequest struct {
+	RelatedPersonID *int    `json:"related_person_id" validate:"required"`
+	Type            *string `json:"type" validate:"required,oneof=parent child spouse sibling relative employer employee colleague contact"`
+	Bidirectional   bool    `json:"bidirectional"`
+}
+
+type relationResponse struct {
+	ID              int        `json:"id"`
+	PersonID        int        `json:"person_id"`
+	RelatedPersonID int        `json:"related_person_id"`
+	Type            string     `json:"type"`
+	Bidirectional   bool       `json:"bidirectional"`
+	CreatedAt       *time.Time `json:"created_at,omitempty"`
+}
+
+func (h *handler) CreateRelation(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		zerolog.Ctx(c.Request().Context()).Warn().Str("id", c.Param("id")).Msg("wrong person id")
+		return errorResponse(c, http.StatusBadRequest, "wrong id")
+	}
+
+	logger := zerolog.Ctx(c.Request().Context()).With().Int("person_id", id).Logger()
+
+	req := &relationRequest{}
+	if err = json.NewDecoder(c.Request().Body).Decode(req); err != nil {
+		logger.Warn().Err(err).Msg("unmarshalling error")
+		return errorResponse(c, http.StatusBadRequest, "unmarshalling error")
+	}
+
+	if err = c.Validate(req); err != nil {
+		logger.Warn().Err(err).Msg("validation error")
+		return validationErrorResponse(c, err)
+	}
+
+	if *req.RelatedPersonID == id {
+		logger.Warn().Msg("relating person to itself")
+		return errorResponse(c, http.StatusBadRequest, "person can not be related to itself")
+	}
+
+	relationID, err := h.storage.CreateRelation(c.Request().Context(), Relation{
+		PersonID:        id,
+		RelatedPersonID: *req.RelatedPersonID,
+		Type:            *req.Type,
+		Bidirectional:   req.Bidirectional,
+	})
+	if errors.Is(err, ErrNotFound) {
+		logger.Info().Int("related_person_id", *req.RelatedPersonID).Msg("person not found")
+		return errorResponse(c, http.StatusNotFound, "person not found")
+	}
+	if errors.Is(err, ErrRelationExists) {
+		logger.Info().Int("related_person_id", *req.RelatedPersonID).Msg("relation already exists")
+		return errorResponse(c, http.StatusConflict, "relation already exists")
+	}
+	if err != nil {
+		logger.Error().Err(err).Msg("creating relation error")
+		return errorResponse(c, http.StatusInternalServerError, "creating relation error")
+	}
+
+	c.Response().Header().Set("Location", personPath(c, id)+"/relations/"+strconv.Itoa(relationID))
+
+	return c.NoContent(http.StatusCreated)
+}
+
+func (h *handler) GetRelations(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		zerolog.Ctx(c.Request().Context()).Warn().Str("id", c.Param("id")).Msg("wrong person id")
+		return errorResponse(c, http.StatusBadRequest, "wrong id")
+	}
+
+	logger := zerolog.Ctx(c.Request().Context()).With().Int("person_id", id).Logger()
+
+	if found, err := Exists(c, h.storage, id); err != nil || !found {
+		return err
+	}
+
+	relations, err := h.storage.GetRelations(c.Request().Context(), id)
+	if err != nil {
+		logger.Error().Err(err).Msg("getting relations error")
+		return errorResponse(c, http.StatusInternalServerError, "getting relations error")
+	}
+
+	resp := make([]relationResponse, len(relations), len(relations))
+	for i, r := range relations {
+		resp[i] = relationResponse{
+			ID:              *r.ID,
+			PersonID:        r.PersonID,
+			RelatedPersonID: r.RelatedPersonID,
+			Type:            r.Type,
+			Bidirectional:   r.Bidirectional,
+			CreatedAt:       r.CreatedAt,
+		}
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (h *handler) DeleteRelation(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		zerolog.Ctx(c.Request().Context()).Warn().Str("id", c.Param("id")).Msg("wrong person id")
+		return errorResponse(c, http.StatusBadRequest, "wrong id")
+	}
+
+	relationID, err := strconv.Atoi(c.Param("relation_id"))
+	if err != nil {
+		zerolog.Ctx(c.Request().Context()).Warn().Str("relation_id", c.Param("relation_id")).Msg("wrong relation id")
+		return errorResponse(c, http.StatusBadRequest, "wrong relation id")
+	}
+
+	logger := zerolog.Ctx(c.Request().Context()).With().Int("person_id", id).Int("relation_id", relationID).Logger()
+
+	isDeleted, err := h.storage.DeleteRelation(c.Request().Context(), id, relationID)
+	if err != nil {
+		logger.Error().Err(err).Msg("deleting relation error")
+		return errorResponse(c, http.StatusInternalServerError, "deleting relation error")
+	}
+
+	if !isDeleted {
+		logger.Info().Msg("relation not found")
+		return errorResponse(c, http.StatusNotFound, "relation not found")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// GetRelatives lists the persons within the depth query parameter hops over the relations of
+// the type parameters, by default the family ones.
+func (h *handler) GetRelatives(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		zerolog.Ctx(c.Request().Context()).Warn().Str("id", c.Param("id")).Msg("wrong person id")
+		return errorResponse(c, http.StatusBadRequest, "wrong id")
+	}
+
+	logger := zerolog.Ctx(c.Request().Context()).With().Int("person_id", id).Logger()
+
+	depth := defaultRelativesDepth
+	if param := c.QueryParam("depth"); param != "" {
+		depth, err = strconv.Atoi(param)
+		if err != nil || depth < 1 || depth > maxRelativesDepth {
+			logger.Warn().Str("depth", param).Msg("wrong depth")
+			return errorResponse(c, http.StatusBadRequest, "depth must be between 1 and "+strconv.Itoa(maxRelativesDepth))
+		}
+	}
+
+	types := c.QueryParams()["type"]
+	for _, t := range types {
+		if !slices.Contains(relationTypes, t) {
+			logger.Warn().Str("type", t).Msg("wrong relation type")
+			return errorResponse(c, http.StatusBadRequest, "unknown relation type "+t)
+		}
+	}
+	if len(types) == 0 {
+		types = familyRelationTypes
+	}
+
+	if found, err := Exists(c, h.storage, id); err != nil || !found {
+		return err
+	}
+
+	relatives, err := h.storage.GetRelatives(c.Request().Context(), id, depth, types)
+	if err != nil {
+		logger.Error().Err(err).Msg("getting relatives error")
+		return errorResponse(c, http.StatusInternalServerError, "getting relatives error")
+	}
+
+	type relativeResponse struct {
+		Person interface{} `json:"person"`
+		Depth  int         `json:"depth"`
+	}
+
+	now := time.Now()
+	v := versionOf(c)
+	resp := make([]relativeResponse, len(relatives), len(relatives))
+	for i, r := range relatives {
+		resp[i] = relativeResponse{
+			Person: v.person(r.Person, now),
+			Depth:  r.Depth,
+		}
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,235 @@
+package person
+
+import (
+	"errors"
+	"github.com/Erlendum/rsoi-lab-01/pkg/validation"
+	"github.com/go-playground/validator/v10"
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_relationRequest_Types(t *testing.T) {
+	field, ok := reflect.TypeOf(relationRequest{}).FieldByName("Type")
+	require.True(t, ok)
+	require.Contains(t, field.Tag.Get("validate"), "oneof="+strings.Join(relationTypes, " "))
+	require.Subset(t, relationTypes, familyRelationTypes)
+}
+
+func Test_CreateRelation(t *testing.T) {
+	type fields struct {
+		id                     string
+		reqBody                string
+		expectedHTTPCode       int
+		expectedLocationHeader string
+	}
+
+	e := echo.New()
+	e.Validator = validation.MustRegisterCustomValidator(validator.New())
+
+	tests := []struct {
+		name    string
+		fields  fields
+		Prepare func(fields *handlerTestFields)
+	}{
+		{
+			name: "http-code 400: wrong id",
+			fields: fields{
+				expectedHTTPCode: http.StatusBadRequest,
+				id:               "test",
+				reqBody:          `{"related_person_id": 2, "type": "parent"}`,
+			},
+			Prepare: func(fields *handlerTestFields) {},
+		},
+		{
+			name: "http-code 400: unknown type",
+			fields: fields{
+				expectedHTTPCode: http.StatusBadRequest,
+				id:               "1",
+				reqBody:          `{"related_person_id": 2, "type": "friend"}`,
+			},
+			Prepare: func(fields *handlerTestFields) {},
+		},
+		{
+			name: "http-code 400: related to itself",
+			fields: fields{
+				expectedHTTPCode: http.StatusBadRequest,
+				id:               "1",
+				reqBody:          `{"related_person_id": 1, "type": "parent"}`,
+			},
+			Prepare: func(fields *handlerTestFields) {},
+		},
+		{
+			name: "http-code 404",
+			fields: fields{
+				expectedHTTPCode: http.StatusNotFound,
+				id:               "1",
+				reqBody:          `{"related_person_id": 2, "type": "parent"}`,
+			},
+			Prepare: func(fields *handlerTestFields) {
+				fields.storage.EXPECT().CreateRelation(gomock.Any(), gomock.Any()).Return(0, ErrNotFound)
+			},
+		},
+		{
+			name: "http-code 409",
+			fields: fields{
+				expectedHTTPCode: http.StatusConflict,
+				id:               "1",
+				reqBody:          `{"related_person_id": 2, "type": "parent"}`,
+			},
+			Prepare: func(fields *handlerTestFields) {
+				fields.storage.EXPECT().CreateRelation(gomock.Any(), gomock.Any()).Return(0, ErrRelationExists)
+			},
+		},
+		{
+			name: "http-code 500",
+			fields: fields{
+				expectedHTTPCode: http.StatusInternalServerError,
+				id:               "1",
+				reqBody:          `{"related_person_id": 2, "type": "parent"}`,
+			},
+			Prepare: func(fields *handlerTestFields) {
+				fields.storage.EXPECT().CreateRelation(gomock.Any(), gomock.Any()).Return(0, errors.New(""))
+			},
+		},
+		{
+			name: "http-code 201",
+			fields: fields{
+				expectedHTTPCode:       http.StatusCreated,
+				id:                     "1",
+				reqBody:                `{"related_person_id": 2, "type": "spouse", "bidirectional": true}`,
+				expectedLocationHeader: `/api/v1/persons/1/relations/3`,
+			},
+			Prepare: func(fields *handlerTestFields) {
+				fields.storage.EXPECT().CreateRelation(gomock.Any(), Relation{
+					PersonID:        1,
+					RelatedPersonID: 2,
+					Type:            "spouse",
+					Bidirectional:   true,
+				}).Return(3, nil)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			testFields := createHandlerTestFields(ctrl)
+			tt.Prepare(testFields)
+
+			h := &handler{storage: testFields.storage}
+
+			req := httptest.NewRequest(http.MethodPost, "/test", strings.NewReader(tt.fields.reqBody))
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetParamNames("id")
+			c.SetParamValues(tt.fields.id)
+
+			err := h.CreateRelation(c)
+
+			require.NoError(t, err)
+			require.Equal(t, tt.fields.expectedHTTPCode, rec.Code)
+			require.Equal(t, tt.fields.expectedLocationHeader, rec.Header().Get("Location"))
+		})
+	}
+}
+
+func Test_GetRelatives(t *testing.T) {
+	type fields struct {
+		query                string
+		expectedHTTPCode     int
+		expectedResponseBody string
+	}
+
+	e := echo.New()
+
+	tests := []struct {
+		name    string
+		fields  fields
+		Prepare func(fields *handlerTestFields)
+	}{
+		{
+			name: "http-code 400: wrong depth",
+			fields: fields{
+				expectedHTTPCode: http.StatusBadRequest,
+				query:            "?depth=10",
+			},
+			Prepare: func(fields *handlerTestFields) {},
+		},
+		{
+			name: "http-code 400: unknown type",
+			fields: fields{
+				expectedHTTPCode: http.StatusBadRequest,
+				query:            "?type=friend",
+			},
+			Prepare: func(fields *handlerTestFields) {},
+		},
+		{
+			name: "http-code 404",
+			fields: fields{
+				expectedHTTPCode: http.StatusNotFound,
+			},
+			Prepare: func(fields *handlerTestFields) {
+				fields.storage.EXPECT().GetPerson(gomock.Any(), 1).Return(Person{}, nil)
+			},
+		},
+		{
+			name: "http-code 200: family relations by default",
+			fields: fields{
+				expectedHTTPCode: http.StatusOK,
+				expectedResponseBody: `[{"person":{"id":2,"name":"test","age":0,"address":"","work":""},"depth":1}]
+`,
+			},
+			Prepare: func(fields *handlerTestFields) {
+				fields.storage.EXPECT().GetPerson(gomock.Any(), 1).Return(Person{ID: getPointerOnInt(1)}, nil)
+				fields.storage.EXPECT().GetRelatives(gomock.Any(), 1, 1, familyRelationTypes).Return([]Relative{
+					{Person: Person{ID: getPointerOnInt(2), Name: getPointerOnString("test")}, Depth: 1},
+				}, nil)
+			},
+		},
+		{
+			name: "http-code 200",
+			fields: fields{
+				expectedHTTPCode: http.StatusOK,
+				query:            "?depth=3&type=colleague&type=employer",
+				expectedResponseBody: `[]
+`,
+			},
+			Prepare: func(fields *handlerTestFields) {
+				fields.storage.EXPECT().GetPerson(gomock.Any(), 1).Return(Person{ID: getPointerOnInt(1)}, nil)
+				fields.storage.EXPECT().GetRelatives(gomock.Any(), 1, 3, []string{"colleague", "employer"}).Return([]Relative{}, nil)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			testFields := createHandlerTestFields(ctrl)
+			tt.Prepare(testFields)
+
+			h := &handler{storage: testFields.storage}
+
+			req := httptest.NewRequest(http.MethodGet, "/test"+tt.fields.query, nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetParamNames("id")
+			c.SetParamValues("1")
+
+			err := h.GetRelatives(c)
+
+			require.NoError(t, err)
+			require.Equal(t, tt.fields.expectedHTTPCode, rec.Code)
+			if tt.fields.expectedHTTPCode == http.StatusOK {
+				require.Equal(t, tt.fields.expectedResponseBody, rec.Body.String())
+			}
+		})
+	}
+}
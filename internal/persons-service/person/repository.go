@@ -2,9 +2,13 @@ package person
 
 import (
 	"context"
+	"database/sql"
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/pgutil"
 	sq "github.com/Masterminds/squirrel"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"github.com/pkg/errors"
+	"strings"
 	"time"
 )
 
@@ -12,17 +16,99 @@ const (
 	defaultTimeout = 5 * time.Second
 )
 
+var relationColumns = []string{"id", "person_id", "related_person_id", "type", "bidirectional", "created_at"}
+
+var personColumns = []string{
+	"id", "name", "age", "birth_date", "email", "phone_numbers",
+	"address", "address_street", "address_city", "address_postal_code", "address_country",
+	"work", "attributes", "tags", "created_at", "updated_at",
+}
+
+var queryTracer = pgutil.NewQueryTracer("github.com/Erlendum/rsoi-lab-01/internal/persons-service/person", "person.repository")
+
 type repository struct {
-	conn *sqlx.DB
+	conns  pgutil.ConnRouter
+	unique []uniqueConstraint
 }
 
 func NewRepository(conn *sqlx.DB) *repository {
-	return &repository{conn: conn}
+	return &repository{conns: pgutil.SingleConn{Conn: conn}}
+}
+
+// NewReplicatedRepository creates a repository that reads and writes through conns.
+func NewReplicatedRepository(conns pgutil.ConnRouter) *repository {
+	return &repository{conns: conns}
+}
+
+// SetUniqueConstraints checks the constraints under advisory locks, see checkUnique.
+func (r *repository) SetUniqueConstraints(fields [][]string) error {
+	constraints, err := newUniqueConstraints(fields)
+	if err != nil {
+		return err
+	}
+	r.unique = constraints
+	return nil
 }
 
+// mapWriteError translates a unique_violation of a person write, e.g. of a unique index an
+// operator added, to a ConflictError without the existing person.
+func mapWriteError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code.Name() == "unique_violation" {
+		return &ConflictError{}
+	}
+	return errors.Wrap(err, "failed to execute query")
+}
+
+// checkUnique takes an advisory lock for the values of every unique constraint set in p, held
+// until tx ends, and then looks for another person with them. Writers of the same values wait
+// for each other, so two concurrent writes can not both miss the other. The locks are taken in
+// key order, so writers of several values do not deadlock.
+func (r *repository) checkUnique(ctx context.Context, tx *sqlx.Tx, p Person, excludeID int) error {
+	keys, err := lockKeys(r.unique, p)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		const query = "SELECT pg_advisory_xact_lock(hashtext($1))"
+		spanCtx, span := queryTracer.StartQuerySpan(ctx, "LockUnique", query)
+		_, err = tx.ExecContext(spanCtx, query, key)
+		queryTracer.EndQuerySpan(span, err)
+		if err != nil {
+			return errors.Wrap(err, "failed to lock unique values")
+		}
+	}
+
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+	return findConflict(r.unique, p, excludeID, func(match Person) ([]Person, error) {
+		// Two rows are enough to find one other than the person itself.
+		builder := whereMatches(psql.Select(personColumns...).From("persons"), match).OrderBy("id").Limit(2)
+		query, args, err := builder.ToSql()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to build query")
+		}
+
+		res := make([]Person, 0, 2)
+		spanCtx, span := queryTracer.StartQuerySpan(ctx, "FindConflict", query)
+		err = tx.SelectContext(spanCtx, &res, query, args...)
+		queryTracer.EndQuerySpan(span, err)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to execute query")
+		}
+		return res, nil
+	})
+}
+
+// CreatePerson checks the unique constraints and inserts the person in one transaction.
 func (r *repository) CreatePerson(ctx context.Context, person Person) (int, error) {
 	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
-	builder := psql.Insert("persons").Columns("name", "age", "address", "work").Values(person.Name, person.Age, person.Address, person.Work)
+	builder := psql.Insert("persons").
+		Columns("name", "age", "birth_date", "email", "phone_numbers",
+			"address", "address_street", "address_city", "address_postal_code", "address_country", "work",
+			"attributes", "tags").
+		Values(person.Name, person.Age, person.BirthDate, person.Email, person.PhoneNumbers,
+			person.Address, person.Street, person.City, person.PostalCode, person.Country, person.Work,
+			person.Attributes, person.Tags)
 	query, args, err := builder.Suffix("RETURNING id").ToSql()
 	if err != nil {
 		return 0, errors.Wrap(err, "failed to build query")
@@ -31,12 +117,29 @@ func (r *repository) CreatePerson(ctx context.Context, person Person) (int, erro
 	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
 	defer cancel()
 
+	tx, err := r.conns.Writer(ctx).BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to begin transaction")
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err = r.checkUnique(ctx, tx, person, 0); err != nil {
+		return 0, err
+	}
+
+	spanCtx, span := queryTracer.StartQuerySpan(ctx, "CreatePerson", query)
 	var id int
-	err = r.conn.QueryRowContext(ctx, query, args...).Scan(&id)
+	err = tx.QueryRowContext(spanCtx, query, args...).Scan(&id)
+	queryTracer.EndQuerySpan(span, err)
 	if err != nil {
-		return 0, errors.Wrap(err, "failed to execute query")
+		return 0, mapWriteError(err)
 	}
 
+	if err = tx.Commit(); err != nil {
+		return 0, errors.Wrap(err, "failed to commit transaction")
+	}
+	r.conns.MarkWrite(ctx)
+
 	return id, nil
 }
 
@@ -52,6 +155,18 @@ func (r *repository) createUpdateBuilderForPerson(id int, person Person) (sq.Upd
 		updateBuilder = updateBuilder.Set("age", person.Age)
 		isEmpty = false
 	}
+	if person.BirthDate != nil {
+		updateBuilder = updateBuilder.Set("birth_date", person.BirthDate)
+		isEmpty = false
+	}
+	if person.Email != nil {
+		updateBuilder = updateBuilder.Set("email", person.Email)
+		isEmpty = false
+	}
+	if person.PhoneNumbers != nil {
+		updateBuilder = updateBuilder.Set("phone_numbers", person.PhoneNumbers)
+		isEmpty = false
+	}
 	if person.Work != nil {
 		updateBuilder = updateBuilder.Set("work", person.Work)
 		isEmpty = false
@@ -60,17 +175,44 @@ func (r *repository) createUpdateBuilderForPerson(id int, person Person) (sq.Upd
 		updateBuilder = updateBuilder.Set("address", person.Address)
 		isEmpty = false
 	}
+	if person.Street != nil {
+		updateBuilder = updateBuilder.Set("address_street", person.Street)
+		isEmpty = false
+	}
+	if person.City != nil {
+		updateBuilder = updateBuilder.Set("address_city", person.City)
+		isEmpty = false
+	}
+	if person.PostalCode != nil {
+		updateBuilder = updateBuilder.Set("address_postal_code", person.PostalCode)
+		isEmpty = false
+	}
+	if person.Country != nil {
+		updateBuilder = updateBuilder.Set("address_country", person.Country)
+		isEmpty = false
+	}
+	if person.Attributes != nil {
+		updateBuilder = updateBuilder.Set("attributes", person.Attributes)
+		isEmpty = false
+	}
+	if person.Tags != nil {
+		updateBuilder = updateBuilder.Set("tags", person.Tags)
+		isEmpty = false
+	}
+	updateBuilder = updateBuilder.Set("updated_at", sq.Expr("now()"))
 
 	return updateBuilder, isEmpty
 }
 
+// UpdatePerson checks the unique constraints for the updated person and updates it in one
+// transaction.
 func (r *repository) UpdatePerson(ctx context.Context, id int, person *Person) error {
 	builder, isEmpty := r.createUpdateBuilderForPerson(id, *person)
 	if isEmpty {
 		return nil
 	}
 
-	builder = builder.Suffix("RETURNING id, name, age, address, work")
+	builder = builder.Suffix("RETURNING " + strings.Join(personColumns, ", "))
 
 	query, args, err := builder.ToSql()
 	if err != nil {
@@ -80,14 +222,65 @@ func (r *repository) UpdatePerson(ctx context.Context, id int, person *Person) e
 	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
 	defer cancel()
 
-	err = r.conn.QueryRowContext(ctx, query, args...).Scan(&person.ID, &person.Name, &person.Age, &person.Address, &person.Work)
+	tx, err := r.conns.Writer(ctx).BeginTxx(ctx, nil)
 	if err != nil {
-		return errors.Wrap(err, "failed to execute query")
+		return errors.Wrap(err, "failed to begin transaction")
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if len(r.unique) > 0 {
+		stored, err := r.lockPerson(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if err = r.checkUnique(ctx, tx, applyUpdate(stored, *person), id); err != nil {
+			return err
+		}
 	}
 
+	spanCtx, span := queryTracer.StartQuerySpan(ctx, "UpdatePerson", query)
+	updated := Person{}
+	err = tx.QueryRowxContext(spanCtx, query, args...).StructScan(&updated)
+	queryTracer.EndQuerySpan(span, err)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return mapWriteError(err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return errors.Wrap(err, "failed to commit transaction")
+	}
+	r.conns.MarkWrite(ctx)
+	*person = updated
+
 	return nil
 }
 
+// lockPerson reads the person with id for an update in tx, or fails with ErrNotFound.
+func (r *repository) lockPerson(ctx context.Context, tx *sqlx.Tx, id int) (Person, error) {
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+	query, args, err := psql.Select(personColumns...).From("persons").
+		Where(sq.Eq{"id": id}).Suffix("FOR UPDATE").ToSql()
+	if err != nil {
+		return Person{}, errors.Wrap(err, "failed to build query")
+	}
+
+	stored := Person{}
+	spanCtx, span := queryTracer.StartQuerySpan(ctx, "UpdatePerson", query)
+	err = tx.GetContext(spanCtx, &stored, query, args...)
+	queryTracer.EndQuerySpan(span, err)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Person{}, ErrNotFound
+	}
+	if err != nil {
+		return Person{}, errors.Wrap(err, "failed to execute query")
+	}
+
+	return stored, nil
+}
+
 func (r *repository) DeletePerson(ctx context.Context, id int) (bool, error) {
 	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
 	builder := psql.Delete("persons").Where(sq.Eq{"id": id})
@@ -99,7 +292,9 @@ func (r *repository) DeletePerson(ctx context.Context, id int) (bool, error) {
 	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
 	defer cancel()
 
-	res, err := r.conn.ExecContext(ctx, query, args...)
+	ctx, span := queryTracer.StartQuerySpan(ctx, "DeletePerson", query)
+	res, err := r.conns.Writer(ctx).ExecContext(ctx, query, args...)
+	queryTracer.EndQuerySpan(span, err)
 	if res == nil || err != nil {
 		return false, errors.Wrap(err, "failed to execute query")
 	}
@@ -108,14 +303,15 @@ func (r *repository) DeletePerson(ctx context.Context, id int) (bool, error) {
 	if err != nil {
 		return false, errors.Wrap(err, "failed to get count of affected rows")
 	}
+	r.conns.MarkWrite(ctx)
 
 	return countAffectedRows == 1, nil
 }
 
-func (r *repository) GetPersons(ctx context.Context) ([]Person, error) {
+func (r *repository) GetPersons(ctx context.Context, page Page, fields ...string) ([]Person, error) {
 	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
 
-	builder := psql.Select("id", "name", "age", "address", "work").From("persons")
+	builder := withPage(psql.Select(fieldColumns(fields)...).From("persons").OrderBy("id"), page)
 
 	query, args, err := builder.ToSql()
 	if err != nil {
@@ -127,7 +323,9 @@ func (r *repository) GetPersons(ctx context.Context) ([]Person, error) {
 
 	res := make([]Person, 0)
 
-	err = r.conn.SelectContext(ctx, &res, query, args...)
+	ctx, span := queryTracer.StartQuerySpan(ctx, "GetPersons", query)
+	err = r.conns.Reader(ctx).SelectContext(ctx, &res, query, args...)
+	queryTracer.EndQuerySpan(span, err)
 	if err != nil {
 		return []Person{}, errors.Wrap(err, "failed to execute query")
 	}
@@ -135,10 +333,10 @@ func (r *repository) GetPersons(ctx context.Context) ([]Person, error) {
 	return res, nil
 }
 
-func (r *repository) GetPerson(ctx context.Context, id int) (Person, error) {
+func (r *repository) GetPerson(ctx context.Context, id int, fields ...string) (Person, error) {
 	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
 
-	builder := psql.Select("id", "name", "age", "address", "work").From("persons").Where(sq.Eq{"id": id})
+	builder := psql.Select(fieldColumns(fields)...).From("persons").Where(sq.Eq{"id": id})
 
 	query, args, err := builder.ToSql()
 	if err != nil {
@@ -150,10 +348,485 @@ func (r *repository) GetPerson(ctx context.Context, id int) (Person, error) {
 
 	res := Person{}
 
-	err = r.conn.GetContext(ctx, &res, query, args...)
+	ctx, span := queryTracer.StartQuerySpan(ctx, "GetPerson", query)
+	err = r.conns.Reader(ctx).GetContext(ctx, &res, query, args...)
+	queryTracer.EndQuerySpan(span, err)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Person{}, nil
+	}
 	if err != nil {
 		return Person{}, errors.Wrap(err, "failed to execute query")
 	}
 
 	return res, nil
 }
+
+// GetPersonsByIDs loads the persons with the given ids in one query, for batched lookups.
+// Missing ids are left out of the result.
+func (r *repository) GetPersonsByIDs(ctx context.Context, ids []int) ([]Person, error) {
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	builder := psql.Select(personColumns...).From("persons").Where(sq.Eq{"id": ids}).OrderBy("id")
+
+	query, args, err := builder.ToSql()
+	if err != nil {
+		return []Person{}, errors.Wrap(err, "failed to build query")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	res := make([]Person, 0, len(ids))
+
+	ctx, span := queryTracer.StartQuerySpan(ctx, "GetPersonsByIDs", query)
+	err = r.conns.Reader(ctx).SelectContext(ctx, &res, query, args...)
+	queryTracer.EndQuerySpan(span, err)
+	if err != nil {
+		return []Person{}, errors.Wrap(err, "failed to execute query")
+	}
+
+	return res, nil
+}
+
+// withPage limits builder, ordered by ID, to the persons of page.
+func withPage(builder sq.SelectBuilder, page Page) sq.SelectBuilder {
+	if page.Limit > 0 {
+		builder = builder.Limit(uint64(page.Limit))
+	}
+	if page.Offset > 0 {
+		builder = builder.Offset(uint64(page.Offset))
+	}
+	return builder
+}
+
+// whereMatches adds the conditions of FindPersons and CountPersons for match to builder.
+func whereMatches(builder sq.SelectBuilder, match Person) sq.SelectBuilder {
+	for _, field := range []struct {
+		column string
+		value  *string
+	}{
+		{"name", match.Name}, {"email", match.Email}, {"address", match.Address},
+		{"address_street", match.Street}, {"address_city", match.City},
+		{"address_postal_code", match.PostalCode}, {"address_country", match.Country}, {"work", match.Work},
+	} {
+		if field.value != nil {
+			builder = builder.Where(sq.Expr("lower("+field.column+") = lower(?)", *field.value))
+		}
+	}
+	if match.Age != nil {
+		builder = builder.Where(sq.Eq{"age": *match.Age})
+	}
+	if match.BirthDate != nil {
+		builder = builder.Where(sq.Eq{"birth_date": *match.BirthDate})
+	}
+	// The containment operators are served by the GIN indexes on tags and attributes.
+	if len(match.Tags) > 0 {
+		builder = builder.Where(sq.Expr("tags @> ?", match.Tags))
+	}
+	if match.Attributes != nil {
+		builder = builder.Where(sq.Expr("attributes @> ?::jsonb", match.Attributes))
+	}
+	return builder
+}
+
+func (r *repository) FindPersons(ctx context.Context, match Person, page Page, fields ...string) ([]Person, error) {
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	builder := withPage(whereMatches(psql.Select(fieldColumns(fields)...).From("persons"), match).OrderBy("id"), page)
+
+	query, args, err := builder.ToSql()
+	if err != nil {
+		return []Person{}, errors.Wrap(err, "failed to build query")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	res := make([]Person, 0)
+
+	ctx, span := queryTracer.StartQuerySpan(ctx, "FindPersons", query)
+	err = r.conns.Reader(ctx).SelectContext(ctx, &res, query, args...)
+	queryTracer.EndQuerySpan(span, err)
+	if err != nil {
+		return []Person{}, errors.Wrap(err, "failed to execute query")
+	}
+
+	return res, nil
+}
+
+// CountPersons counts the persons FindPersons finds for match, every person for a zero match.
+func (r *repository) CountPersons(ctx context.Context, match Person) (int, error) {
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	builder := whereMatches(psql.Select("count(*)").From("persons"), match)
+
+	query, args, err := builder.ToSql()
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to build query")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	var count int
+
+	ctx, span := queryTracer.StartQuerySpan(ctx, "CountPersons", query)
+	err = r.conns.Reader(ctx).GetContext(ctx, &count, query, args...)
+	queryTracer.EndQuerySpan(span, err)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to execute query")
+	}
+
+	return count, nil
+}
+
+// nameWordsExpr is the expression of the persons_name_words_idx index, the words of the name
+// as nameWords splits them.
+const nameWordsExpr = `regexp_split_to_array(lower(name), '[ \t\n.,;:_''"()/-]+')`
+
+// FindDuplicateCandidates returns the persons other than p sharing a blocking key with it, as
+// isDuplicateCandidate does, ordered by ID and at most limit of them. Each key is served by an
+// index of the add_person_duplicate_keys migration.
+func (r *repository) FindDuplicateCandidates(ctx context.Context, p Person, limit int) ([]Person, error) {
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	keys := sq.Or{}
+	if p.Email != nil {
+		keys = append(keys, sq.Expr("lower(email) = lower(?)", *p.Email))
+	}
+	if len(p.PhoneNumbers) > 0 {
+		keys = append(keys, sq.Expr("phone_numbers && ?", p.PhoneNumbers))
+	}
+	if p.Name != nil {
+		if words := nameWords(*p.Name); len(words) > 0 {
+			keys = append(keys, sq.Expr(nameWordsExpr+" && ?", pq.StringArray(words)))
+		}
+	}
+	if len(keys) == 0 {
+		return []Person{}, nil
+	}
+
+	builder := psql.Select(personColumns...).From("persons").
+		Where(sq.NotEq{"id": *p.ID}).Where(keys).OrderBy("id").Limit(uint64(limit))
+
+	query, args, err := builder.ToSql()
+	if err != nil {
+		return []Person{}, errors.Wrap(err, "failed to build query")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	res := make([]Person, 0)
+
+	ctx, span := queryTracer.StartQuerySpan(ctx, "FindDuplicateCandidates", query)
+	err = r.conns.Reader(ctx).SelectContext(ctx, &res, query, args...)
+	queryTracer.EndQuerySpan(span, err)
+	if err != nil {
+		return []Person{}, errors.Wrap(err, "failed to execute query")
+	}
+
+	return res, nil
+}
+
+func (r *repository) MergePersons(ctx context.Context, id, sourceID int) (Person, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	tx, err := r.conns.Writer(ctx).BeginTxx(ctx, nil)
+	if err != nil {
+		return Person{}, errors.Wrap(err, "failed to begin transaction")
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	merged, err := r.mergePersons(ctx, tx, id, sourceID)
+	if err != nil {
+		return Person{}, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return Person{}, errors.Wrap(err, "failed to commit transaction")
+	}
+	r.conns.MarkWrite(ctx)
+
+	return merged, nil
+}
+
+func (r *repository) mergePersons(ctx context.Context, tx *sqlx.Tx, id, sourceID int) (Person, error) {
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	query, args, err := psql.Select(personColumns...).From("persons").
+		Where(sq.Eq{"id": []int{id, sourceID}}).Suffix("FOR UPDATE").ToSql()
+	if err != nil {
+		return Person{}, errors.Wrap(err, "failed to build query")
+	}
+
+	persons := make([]Person, 0, 2)
+	spanCtx, span := queryTracer.StartQuerySpan(ctx, "MergePersons", query)
+	err = tx.SelectContext(spanCtx, &persons, query, args...)
+	queryTracer.EndQuerySpan(span, err)
+	if err != nil {
+		return Person{}, errors.Wrap(err, "failed to execute query")
+	}
+
+	var target, source *Person
+	for i := range persons {
+		switch *persons[i].ID {
+		case id:
+			target = &persons[i]
+		case sourceID:
+			source = &persons[i]
+		}
+	}
+	if target == nil || source == nil {
+		return Person{}, ErrNotFound
+	}
+
+	merged := mergePersons(*target, *source)
+	builder, _ := r.createUpdateBuilderForPerson(id, merged)
+	builder = builder.Set("created_at", merged.CreatedAt).Suffix("RETURNING " + strings.Join(personColumns, ", "))
+
+	// Relations of the source move to the target unless the target already has them; the
+	// rest is removed with the source. Its employments and attachments move along.
+	statements := []sq.Sqlizer{
+		psql.Update("person_aliases").Set("person_id", id).Where(sq.Eq{"person_id": sourceID}),
+		psql.Update("person_relations r").Set("person_id", id).
+			Where(sq.Eq{"r.person_id": sourceID}).Where(sq.NotEq{"r.related_person_id": id}).
+			Where(sq.Expr(`NOT EXISTS (SELECT 1 FROM person_relations o
+				WHERE o.person_id = ? AND o.related_person_id = r.related_person_id AND o.type = r.type)`, id)),
+		psql.Update("person_relations r").Set("related_person_id", id).
+			Where(sq.Eq{"r.related_person_id": sourceID}).Where(sq.NotEq{"r.person_id": id}).
+			Where(sq.Expr(`NOT EXISTS (SELECT 1 FROM person_relations o
+				WHERE o.person_id = r.person_id AND o.related_person_id = ? AND o.type = r.type)`, id)),
+		psql.Update("employments").Set("person_id", id).Where(sq.Eq{"person_id": sourceID}),
+		psql.Update("attachments").Set("person_id", id).Where(sq.Eq{"person_id": sourceID}),
+		psql.Delete("persons").Where(sq.Eq{"id": sourceID}),
+		psql.Insert("person_aliases").Columns("alias_id", "person_id").Values(sourceID, id),
+	}
+
+	query, args, err = builder.ToSql()
+	if err != nil {
+		return Person{}, errors.Wrap(err, "failed to build query")
+	}
+
+	spanCtx, span = queryTracer.StartQuerySpan(ctx, "MergePersons", query)
+	err = tx.QueryRowxContext(spanCtx, query, args...).StructScan(&merged)
+	queryTracer.EndQuerySpan(span, err)
+	if err != nil {
+		return Person{}, errors.Wrap(err, "failed to execute query")
+	}
+
+	for _, statement := range statements {
+		query, args, err = statement.ToSql()
+		if err != nil {
+			return Person{}, errors.Wrap(err, "failed to build query")
+		}
+
+		spanCtx, span = queryTracer.StartQuerySpan(ctx, "MergePersons", query)
+		_, err = tx.ExecContext(spanCtx, query, args...)
+		queryTracer.EndQuerySpan(span, err)
+		if err != nil {
+			return Person{}, errors.Wrap(err, "failed to execute query")
+		}
+	}
+
+	return merged, nil
+}
+
+// PersonsChanged has nothing to do, the storages changing persons behind it store the changes
+// themselves.
+func (r *repository) PersonsChanged(ctx context.Context, ids []int) {}
+
+func (r *repository) GetPersonAlias(ctx context.Context, aliasID int) (int, error) {
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	builder := psql.Select("person_id").From("person_aliases").Where(sq.Eq{"alias_id": aliasID})
+
+	query, args, err := builder.ToSql()
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to build query")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	id := 0
+
+	ctx, span := queryTracer.StartQuerySpan(ctx, "GetPersonAlias", query)
+	err = r.conns.Reader(ctx).GetContext(ctx, &id, query, args...)
+	queryTracer.EndQuerySpan(span, err)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to execute query")
+	}
+
+	return id, nil
+}
+
+func (r *repository) CreateRelation(ctx context.Context, relation Relation) (int, error) {
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+	builder := psql.Insert("person_relations").
+		Columns("person_id", "related_person_id", "type", "bidirectional").
+		Values(relation.PersonID, relation.RelatedPersonID, relation.Type, relation.Bidirectional)
+	query, args, err := builder.Suffix("RETURNING id").ToSql()
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to build query")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	ctx, span := queryTracer.StartQuerySpan(ctx, "CreateRelation", query)
+	var id int
+	err = r.conns.Writer(ctx).QueryRowContext(ctx, query, args...).Scan(&id)
+	queryTracer.EndQuerySpan(span, err)
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code.Name() {
+		case "foreign_key_violation":
+			return 0, ErrNotFound
+		case "unique_violation":
+			return 0, ErrRelationExists
+		}
+	}
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to execute query")
+	}
+	r.conns.MarkWrite(ctx)
+
+	return id, nil
+}
+
+func (r *repository) GetRelations(ctx context.Context, personID int) ([]Relation, error) {
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	builder := psql.Select(relationColumns...).From("person_relations").
+		Where(sq.Or{
+			sq.Eq{"person_id": personID},
+			sq.And{sq.Eq{"related_person_id": personID}, sq.Eq{"bidirectional": true}},
+		}).
+		OrderBy("id")
+
+	query, args, err := builder.ToSql()
+	if err != nil {
+		return []Relation{}, errors.Wrap(err, "failed to build query")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	res := make([]Relation, 0)
+
+	ctx, span := queryTracer.StartQuerySpan(ctx, "GetRelations", query)
+	err = r.conns.Reader(ctx).SelectContext(ctx, &res, query, args...)
+	queryTracer.EndQuerySpan(span, err)
+	if err != nil {
+		return []Relation{}, errors.Wrap(err, "failed to execute query")
+	}
+
+	return res, nil
+}
+
+// GetRelationsByPersonIDs loads the relations of all the given persons in one query. A
+// relation shared by two of them is returned once.
+func (r *repository) GetRelationsByPersonIDs(ctx context.Context, personIDs []int) ([]Relation, error) {
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	builder := psql.Select(relationColumns...).From("person_relations").
+		Where(sq.Or{
+			sq.Eq{"person_id": personIDs},
+			sq.And{sq.Eq{"related_person_id": personIDs}, sq.Eq{"bidirectional": true}},
+		}).
+		OrderBy("id")
+
+	query, args, err := builder.ToSql()
+	if err != nil {
+		return []Relation{}, errors.Wrap(err, "failed to build query")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	res := make([]Relation, 0)
+
+	ctx, span := queryTracer.StartQuerySpan(ctx, "GetRelationsByPersonIDs", query)
+	err = r.conns.Reader(ctx).SelectContext(ctx, &res, query, args...)
+	queryTracer.EndQuerySpan(span, err)
+	if err != nil {
+		return []Relation{}, errors.Wrap(err, "failed to execute query")
+	}
+
+	return res, nil
+}
+
+func (r *repository) DeleteRelation(ctx context.Context, personID, relationID int) (bool, error) {
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+	builder := psql.Delete("person_relations").
+		Where(sq.Eq{"id": relationID}).
+		Where(sq.Or{
+			sq.Eq{"person_id": personID},
+			sq.And{sq.Eq{"related_person_id": personID}, sq.Eq{"bidirectional": true}},
+		})
+	query, args, err := builder.ToSql()
+	if err != nil {
+		return false, errors.Wrap(err, "failed to build query")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	ctx, span := queryTracer.StartQuerySpan(ctx, "DeleteRelation", query)
+	res, err := r.conns.Writer(ctx).ExecContext(ctx, query, args...)
+	queryTracer.EndQuerySpan(span, err)
+	if res == nil || err != nil {
+		return false, errors.Wrap(err, "failed to execute query")
+	}
+
+	countAffectedRows, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get count of affected rows")
+	}
+	r.conns.MarkWrite(ctx)
+
+	return countAffectedRows == 1, nil
+}
+
+// relativesQuery walks the relations of the given types from a person breadth-first. UNION
+// keeps one row per person and depth, so each step only grows the distinct persons reached
+// rather than every path to them, and the depth bound ends cycles; min(depth) then picks the
+// shortest way to every relative.
+var relativesQuery = `WITH RECURSIVE edges(from_id, to_id) AS (
+	SELECT person_id, related_person_id FROM person_relations WHERE type = ANY($3)
+	UNION ALL
+	SELECT related_person_id, person_id FROM person_relations WHERE bidirectional AND type = ANY($3)
+), walk(id, depth) AS (
+	SELECT $1::int, 0
+	UNION
+	SELECT e.to_id, w.depth + 1
+	FROM walk w JOIN edges e ON e.from_id = w.id
+	WHERE w.depth < $2
+)
+SELECT ` + "p." + strings.Join(personColumns, ", p.") + `, min(w.depth) AS depth
+FROM walk w JOIN persons p ON p.id = w.id
+WHERE w.id <> $1
+GROUP BY p.id
+ORDER BY depth, p.id`
+
+func (r *repository) GetRelatives(ctx context.Context, personID, maxDepth int, types []string) ([]Relative, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	res := make([]Relative, 0)
+
+	ctx, span := queryTracer.StartQuerySpan(ctx, "GetRelatives", relativesQuery)
+	err := r.conns.Reader(ctx).SelectContext(ctx, &res, relativesQuery, personID, maxDepth, pq.StringArray(types))
+	queryTracer.EndQuerySpan(span, err)
+	if err != nil {
+		return []Relative{}, errors.Wrap(err, "failed to execute query")
+	}
+
+	return res, nil
+}
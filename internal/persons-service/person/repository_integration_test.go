@@ -0,0 +1,158 @@
+//go:build integration
+
+package person
+
+import (
+	"context"
+	"fmt"
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/pgtest"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testDB is shared by the integration tests. It points to POSTGRESQL_TEST_DSN if set, or
+// to an ephemeral PostgreSQL started for the test run.
+var testDB *sqlx.DB
+
+func TestMain(m *testing.M) {
+	os.Exit(pgtest.Run(m, func(db *sqlx.DB) {
+		testDB = db
+	}))
+}
+
+func newTestRepository(t *testing.T) *repository {
+	_, err := testDB.Exec("TRUNCATE persons RESTART IDENTITY CASCADE")
+	require.NoError(t, err)
+	return NewRepository(testDB)
+}
+
+func Test_Repository(t *testing.T) {
+	testStorageConformance(t, func(t *testing.T) storage {
+		return newTestRepository(t)
+	})
+	testUniqueConstraints(t, func(t *testing.T) uniqueStorage {
+		return newTestRepository(t)
+	})
+}
+
+func Test_Repository_NotNullColumns(t *testing.T) {
+	r := newTestRepository(t)
+
+	_, err := r.CreatePerson(context.Background(), Person{Age: getPointerOnInt(1)})
+	require.Error(t, err)
+
+	_, err = r.CreatePerson(context.Background(), Person{Name: getPointerOnString("test"), Age: getPointerOnInt(-1)})
+	require.Error(t, err)
+}
+
+func Test_Repository_NullableColumnsUpdate(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRepository(t)
+
+	id, err := r.CreatePerson(ctx, Person{Name: getPointerOnString("test"), Age: getPointerOnInt(1)})
+	require.NoError(t, err)
+
+	update := Person{Address: getPointerOnString("address")}
+	require.NoError(t, r.UpdatePerson(ctx, id, &update))
+	require.Equal(t, "address", *update.Address)
+	require.Nil(t, update.Work)
+}
+
+func Test_Repository_ConcurrentUpdates(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRepository(t)
+
+	id, err := r.CreatePerson(ctx, Person{Name: getPointerOnString("test"), Age: getPointerOnInt(1)})
+	require.NoError(t, err)
+
+	const workers = 20
+	wg := sync.WaitGroup{}
+	errs := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var update Person
+			if i%2 == 0 {
+				update = Person{Age: getPointerOnInt(i)}
+			} else {
+				update = Person{Work: getPointerOnString(fmt.Sprintf("work %d", i))}
+			}
+			errs <- r.UpdatePerson(ctx, id, &update)
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	got, err := r.GetPerson(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, "test", *got.Name)
+	require.Equal(t, 0, *got.Age%2)
+	require.NotNil(t, got.Work)
+}
+
+func Test_Repository_Timeout(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRepository(t)
+
+	id, err := r.CreatePerson(ctx, Person{Name: getPointerOnString("test"), Age: getPointerOnInt(1)})
+	require.NoError(t, err)
+
+	tx, err := testDB.BeginTxx(ctx, nil)
+	require.NoError(t, err)
+	defer tx.Rollback()
+	_, err = tx.ExecContext(ctx, "SELECT id FROM persons WHERE id = $1 FOR UPDATE", id)
+	require.NoError(t, err)
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	update := Person{Age: getPointerOnInt(2)}
+	err = r.UpdatePerson(timeoutCtx, id, &update)
+	require.Error(t, err)
+	require.Less(t, time.Since(start), defaultTimeout)
+
+	require.NoError(t, tx.Rollback())
+
+	got, err := r.GetPerson(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, 1, *got.Age)
+}
+
+func Test_Repository_MergeMovesEmploymentsAndAttachments(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRepository(t)
+
+	id, err := r.CreatePerson(ctx, Person{Name: getPointerOnString("kept")})
+	require.NoError(t, err)
+	sourceID, err := r.CreatePerson(ctx, Person{Name: getPointerOnString("merged")})
+	require.NoError(t, err)
+
+	var organizationID int
+	err = testDB.QueryRow("INSERT INTO organizations (name) VALUES ('merge test') RETURNING id").Scan(&organizationID)
+	require.NoError(t, err)
+	t.Cleanup(func() { _, _ = testDB.Exec("DELETE FROM organizations WHERE id = $1", organizationID) })
+	_, err = testDB.Exec("INSERT INTO employments (person_id, organization_id) VALUES ($1, $2)", sourceID, organizationID)
+	require.NoError(t, err)
+	_, err = testDB.Exec(`INSERT INTO attachments (person_id, kind, file_name, content_type, size, blob_key)
+		VALUES ($1, 'document', 'test.txt', 'text/plain', 4, 'persons/merge/attachments/test')`, sourceID)
+	require.NoError(t, err)
+
+	_, err = r.MergePersons(ctx, id, sourceID)
+	require.NoError(t, err)
+
+	var personIDs []int
+	require.NoError(t, testDB.Select(&personIDs, "SELECT person_id FROM employments WHERE organization_id = $1", organizationID))
+	require.Equal(t, []int{id}, personIDs)
+	require.NoError(t, testDB.Select(&personIDs, "SELECT person_id FROM attachments"))
+	require.Equal(t, []int{id}, personIDs)
+}
@@ -0,0 +1,53 @@
+package person
+
+import (
+	"bytes"
+	apihttp "github.com/Erlendum/rsoi-lab-01/internal/persons-service/http"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"slices"
+)
+
+// acceptedEncoder returns the encoder of the representation of a person, or of a list of
+// persons if list is set, that the Accept header of the request prefers. ok is false if the
+// API has none of the accepted ones.
+func acceptedEncoder(c echo.Context, list bool) (apihttp.Encoder, bool) {
+	varyAccept(c)
+
+	accept := c.Request().Header.Get(echo.HeaderAccept)
+	if list {
+		return apihttp.DefaultCodecs.ListEncoder(accept)
+	}
+	return apihttp.DefaultCodecs.Encoder(accept)
+}
+
+func notAcceptableResponse(c echo.Context) error {
+	return errorResponse(c, http.StatusNotAcceptable, "unsupported media type in Accept")
+}
+
+// requestDecoder returns the codec of the representation the body of the request is in, JSON
+// if it has no Content-Type. ok is false if the API does not read it.
+func requestDecoder(c echo.Context) (apihttp.Codec, bool) {
+	return apihttp.DefaultCodecs.Decoder(c.Request().Header.Get(echo.HeaderContentType))
+}
+
+func unsupportedMediaTypeResponse(c echo.Context) error {
+	return errorResponse(c, http.StatusUnsupportedMediaType, "unsupported Content-Type")
+}
+
+// respond answers the request with v in the representation of enc.
+func respond(c echo.Context, enc apihttp.Encoder, status int, v interface{}) error {
+	body := &bytes.Buffer{}
+	if err := enc.Encode(body, v); err != nil {
+		return err
+	}
+	return c.Blob(status, enc.MediaType(), body.Bytes())
+}
+
+// varyAccept tells caches that the response depends on the Accept header of the request.
+func varyAccept(c echo.Context) {
+	header := c.Response().Header()
+	if !slices.Contains(header.Values(echo.HeaderVary), echo.HeaderAccept) {
+		header.Add(echo.HeaderVary, echo.HeaderAccept)
+	}
+}
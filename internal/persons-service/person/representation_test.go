@@ -0,0 +1,210 @@
+package person
+
+import (
+	"bytes"
+	"github.com/Erlendum/rsoi-lab-01/pkg/validation"
+	"github.com/go-playground/validator/v10"
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_Representations(t *testing.T) {
+	ivan := Person{
+		ID:           getPointerOnInt(1),
+		Name:         getPointerOnString("Ivan"),
+		Address:      getPointerOnString("Moscow"),
+		PhoneNumbers: []string{"+79991234567"},
+		Attributes:   Attributes{"level": float64(3)},
+		Tags:         pq.StringArray{"vip"},
+	}
+
+	tests := []struct {
+		name                 string
+		method               string
+		path                 string
+		accept               string
+		contentType          string
+		reqBody              []byte
+		expectedHTTPCode     int
+		expectedContentType  string
+		expectedResponseBody string
+		Prepare              func(storage *Mockstorage)
+	}{
+		{
+			name:                "xml person",
+			method:              http.MethodGet,
+			path:                "/api/v1/persons/1",
+			accept:              "application/xml",
+			expectedHTTPCode:    http.StatusOK,
+			expectedContentType: echo.MIMEApplicationXML,
+			expectedResponseBody: `<?xml version="1.0" encoding="UTF-8"?>` + "\n" +
+				`<person><id>1</id><name>Ivan</name><age>0</age><address>Moscow</address><work></work>` +
+				`<phone_numbers><phone_number>+79991234567</phone_number></phone_numbers>` +
+				`<attributes>{&#34;level&#34;:3}</attributes><tags><tag>vip</tag></tags></person>`,
+			Prepare: func(storage *Mockstorage) {
+				storage.EXPECT().GetPerson(gomock.Any(), 1).Return(ivan, nil)
+			},
+		},
+		{
+			name:                "csv persons",
+			method:              http.MethodGet,
+			path:                "/api/v2/persons",
+			accept:              "text/csv, application/json;q=0.5",
+			expectedHTTPCode:    http.StatusOK,
+			expectedContentType: "text/csv",
+			expectedResponseBody: "id,name,age,address,work,birth_date,email,phone_numbers,address_parts,attributes,tags,created_at,updated_at\n" +
+				`1,Ivan,,Moscow,,,,"[""+79991234567""]",,"{""level"":3}","[""vip""]",,` + "\n",
+			Prepare: func(storage *Mockstorage) {
+				storage.EXPECT().GetPersons(gomock.Any(), Page{}).Return([]Person{ivan}, nil)
+			},
+		},
+		{
+			name:                "xml person fields",
+			method:              http.MethodGet,
+			path:                "/api/v2/persons/1?fields=name,tags",
+			accept:              "application/xml",
+			expectedHTTPCode:    http.StatusOK,
+			expectedContentType: echo.MIMEApplicationXML,
+			expectedResponseBody: `<?xml version="1.0" encoding="UTF-8"?>` + "\n" +
+				`<person><id>1</id><name>Ivan</name><tags><tag>vip</tag></tags></person>`,
+			Prepare: func(storage *Mockstorage) {
+				storage.EXPECT().GetPerson(gomock.Any(), 1, "name", "tags").Return(ivan, nil)
+			},
+		},
+		{
+			name:                 "csv persons fields",
+			method:               http.MethodGet,
+			path:                 "/api/v1/persons?fields=name",
+			accept:               "text/csv",
+			expectedHTTPCode:     http.StatusOK,
+			expectedContentType:  "text/csv",
+			expectedResponseBody: "id,name\n1,Ivan\n",
+			Prepare: func(storage *Mockstorage) {
+				storage.EXPECT().GetPersons(gomock.Any(), Page{}, "name").Return([]Person{ivan}, nil)
+			},
+		},
+		{
+			name:                 "csv person",
+			method:               http.MethodGet,
+			path:                 "/api/v1/persons/1",
+			accept:               "text/csv",
+			expectedHTTPCode:     http.StatusNotAcceptable,
+			expectedResponseBody: `{"errors":"unsupported media type in Accept"}` + "\n",
+			Prepare:              func(storage *Mockstorage) {},
+		},
+		{
+			name:             "xml body",
+			method:           http.MethodPost,
+			path:             "/api/v1/persons",
+			contentType:      "application/xml; charset=utf-8",
+			reqBody:          []byte(`<person><name>Ivan</name><phone_numbers><phone_number>+79991234567</phone_number></phone_numbers><attributes>{"level": 3}</attributes><tags><tag>vip</tag></tags><address>Moscow</address></person>`),
+			expectedHTTPCode: http.StatusCreated,
+			Prepare: func(storage *Mockstorage) {
+				storage.EXPECT().CreatePerson(gomock.Any(), Person{
+					Name:         ivan.Name,
+					Address:      ivan.Address,
+					PhoneNumbers: ivan.PhoneNumbers,
+					Attributes:   ivan.Attributes,
+					Tags:         ivan.Tags,
+				}).Return(1, nil)
+				storage.EXPECT().GetPerson(gomock.Any(), 1).Return(Person{ID: getPointerOnInt(1), Name: getPointerOnString("test")}, nil)
+			},
+		},
+		{
+			name:                 "invalid xml body",
+			method:               http.MethodPost,
+			path:                 "/api/v1/persons",
+			contentType:          echo.MIMEApplicationXML,
+			reqBody:              []byte(`<person><name>Ivan</name><age>old</age></person>`),
+			expectedHTTPCode:     http.StatusBadRequest,
+			expectedResponseBody: `{"errors":"unmarshalling error"}` + "\n",
+			Prepare:              func(storage *Mockstorage) {},
+		},
+		{
+			name:                 "unsupported body",
+			method:               http.MethodPost,
+			path:                 "/api/v2/persons",
+			contentType:          "text/plain",
+			reqBody:              []byte(`Ivan`),
+			expectedHTTPCode:     http.StatusUnsupportedMediaType,
+			expectedContentType:  mimeApplicationProblemJSON,
+			expectedResponseBody: `{"detail":"unsupported Content-Type","status":415,"title":"Unsupported Media Type","type":"about:blank"}` + "\n",
+			Prepare:              func(storage *Mockstorage) {},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			storage := NewMockstorage(ctrl)
+			tt.Prepare(storage)
+
+			e := echo.New()
+			e.Validator = validation.MustRegisterCustomValidator(validator.New())
+			h := &handler{storage: storage}
+			h.Register(e)
+
+			req := httptest.NewRequest(tt.method, tt.path, bytes.NewReader(tt.reqBody))
+			if tt.accept != "" {
+				req.Header.Set(echo.HeaderAccept, tt.accept)
+			}
+			if tt.contentType != "" {
+				req.Header.Set(echo.HeaderContentType, tt.contentType)
+			}
+			rec := httptest.NewRecorder()
+
+			e.ServeHTTP(rec, req)
+
+			require.Equal(t, tt.expectedHTTPCode, rec.Code)
+			if tt.expectedContentType != "" {
+				require.Equal(t, tt.expectedContentType, rec.Header().Get(echo.HeaderContentType))
+			}
+			if tt.expectedResponseBody != "" {
+				require.Equal(t, tt.expectedResponseBody, rec.Body.String())
+			}
+		})
+	}
+}
+
+func Test_Representations_Msgpack(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	storage := NewMockstorage(ctrl)
+	storage.EXPECT().GetPerson(gomock.Any(), 1).Return(Person{ID: getPointerOnInt(1), Name: getPointerOnString("Ivan")}, nil)
+	storage.EXPECT().UpdatePerson(gomock.Any(), 1, &Person{Name: getPointerOnString("Petr")}).Return(nil)
+
+	e := echo.New()
+	e.Validator = validation.MustRegisterCustomValidator(validator.New())
+	h := &handler{storage: storage}
+	h.Register(e)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/persons/1", nil)
+	req.Header.Set(echo.HeaderAccept, echo.MIMEApplicationMsgpack)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, echo.MIMEApplicationMsgpack, rec.Header().Get(echo.HeaderContentType))
+	require.Equal(t, echo.HeaderAccept, rec.Header().Get(echo.HeaderVary))
+	var resp map[string]interface{}
+	require.NoError(t, msgpack.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Equal(t, map[string]interface{}{"id": int8(1), "name": "Ivan", "age": int8(0), "address": "", "work": ""}, resp)
+
+	body, err := msgpack.Marshal(map[string]interface{}{"name": "Petr"})
+	require.NoError(t, err)
+	req = httptest.NewRequest(http.MethodPatch, "/api/v1/persons/1", bytes.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationMsgpack)
+	req.Header.Set(echo.HeaderAccept, echo.MIMEApplicationMsgpack)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	resp = nil
+	require.NoError(t, msgpack.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Equal(t, "Petr", resp["name"])
+}
@@ -0,0 +1,88 @@
+package person
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	"sort"
+	"strings"
+)
+
+// The person operations the REST, gRPC and GraphQL handlers share once they have read and
+// validated their input. They return domain errors, which each handler maps to its responses:
+// ErrNotFound, *AttributesError, *ConflictError, or a storage error for anything else.
+
+// AttributesError is returned for a person whose attributes do not conform to the schema of
+// the tenant. Fields holds a message for each invalid attribute.
+type AttributesError struct {
+	Fields map[string]string
+}
+
+func (e *AttributesError) Error() string {
+	names := make([]string, 0, len(e.Fields))
+	for name := range e.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return fmt.Sprintf("invalid attributes: %s", strings.Join(names, ", "))
+}
+
+// createPerson stores p for tenant and returns it as stored.
+func (h *handler) createPerson(ctx context.Context, tenant string, p Person) (Person, error) {
+	if errs := h.attributeErrors(tenant, p); errs != nil {
+		return Person{}, &AttributesError{Fields: errs}
+	}
+
+	id, err := h.storage.CreatePerson(ctx, p)
+	if err != nil {
+		return Person{}, err
+	}
+
+	created, err := h.storage.GetPerson(ctx, id)
+	if err != nil {
+		return Person{}, err
+	}
+	if created.ID == nil {
+		return Person{}, errors.Wrapf(ErrNotFound, "created person %d", id)
+	}
+	return created, nil
+}
+
+// updatePerson changes the fields set in p of the person with id for tenant and returns the
+// person as updated.
+func (h *handler) updatePerson(ctx context.Context, tenant string, id int, p Person) (Person, error) {
+	if errs := h.attributeErrors(tenant, p); errs != nil {
+		return Person{}, &AttributesError{Fields: errs}
+	}
+
+	if err := h.storage.UpdatePerson(ctx, id, &p); err != nil {
+		return Person{}, err
+	}
+	return p, nil
+}
+
+// getPerson returns the person with id, or the one it was merged into, with the columns of
+// fields. The ID of the result tells which one it is.
+func (h *handler) getPerson(ctx context.Context, id int, fields ...string) (Person, error) {
+	p, err := h.storage.GetPerson(ctx, id, fields...)
+	if err != nil || p.ID != nil {
+		return p, err
+	}
+
+	mergedID, err := h.storage.GetPersonAlias(ctx, id)
+	if err != nil {
+		return Person{}, err
+	}
+	if mergedID == 0 {
+		return Person{}, ErrNotFound
+	}
+
+	p, err = h.storage.GetPerson(ctx, mergedID, fields...)
+	if err != nil {
+		return Person{}, err
+	}
+	if p.ID == nil {
+		return Person{}, ErrNotFound
+	}
+	return p, nil
+}
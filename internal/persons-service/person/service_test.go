@@ -0,0 +1,74 @@
+package person
+
+import (
+	"context"
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/config"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_handler_getPerson(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryRepository()
+	h := &handler{storage: storage}
+
+	id, err := storage.CreatePerson(ctx, Person{Name: getPointerOnString("target")})
+	require.NoError(t, err)
+	sourceID, err := storage.CreatePerson(ctx, Person{Name: getPointerOnString("source")})
+	require.NoError(t, err)
+	_, err = storage.MergePersons(ctx, id, sourceID)
+	require.NoError(t, err)
+
+	p, err := h.getPerson(ctx, id, "name")
+	require.NoError(t, err)
+	require.Equal(t, id, *p.ID)
+
+	p, err = h.getPerson(ctx, sourceID)
+	require.NoError(t, err)
+	require.Equal(t, id, *p.ID)
+
+	_, err = h.getPerson(ctx, 100)
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func Test_handler_createAndUpdatePerson(t *testing.T) {
+	ctx := context.Background()
+	schema := filepath.Join(t.TempDir(), "sales.schema.json")
+	require.NoError(t, os.WriteFile(schema, []byte(`{"properties": {"region": {"enum": ["EU", "US"]}}}`), 0o600))
+	h, err := NewHandler(newUniqueEmailRepository(t), &config.Persons{
+		Attributes: config.Attributes{
+			Schemas: map[string]string{"sales": schema},
+		},
+	})
+	require.NoError(t, err)
+
+	created, err := h.createPerson(ctx, "", Person{Name: getPointerOnString("test"), Email: getPointerOnString("test@example.com")})
+	require.NoError(t, err)
+	require.NotNil(t, created.ID)
+	require.NotNil(t, created.CreatedAt)
+
+	_, err = h.createPerson(ctx, "", Person{Name: getPointerOnString("other"), Email: getPointerOnString("TEST@example.com")})
+	var conflict *ConflictError
+	require.ErrorAs(t, err, &conflict)
+	require.Equal(t, created.ID, conflict.Existing.ID)
+
+	_, err = h.createPerson(ctx, "sales", Person{Name: getPointerOnString("test"), Attributes: Attributes{"region": "MARS"}})
+	var attributesErr *AttributesError
+	require.ErrorAs(t, err, &attributesErr)
+	require.Contains(t, attributesErr.Fields, "attributes.region")
+
+	updated, err := h.updatePerson(ctx, "", *created.ID, Person{Work: getPointerOnString("work")})
+	require.NoError(t, err)
+	require.Equal(t, "test", *updated.Name)
+	require.Equal(t, "work", *updated.Work)
+
+	_, err = h.updatePerson(ctx, "", 100, Person{Work: getPointerOnString("work")})
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func Test_NewHandler_UniqueConstraints(t *testing.T) {
+	_, err := NewHandler(NewMemoryRepository(), &config.Persons{UniqueConstraints: [][]string{{"email"}}})
+	require.Error(t, err)
+}
@@ -0,0 +1,677 @@
+package person
+
+import (
+	"context"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testStorageConformance checks the behaviour every storage backend must share. newStorage
+// must return an empty storage.
+func testStorageConformance(t *testing.T, newStorage func(t *testing.T) storage) {
+	ctx := context.Background()
+
+	newPerson := func(name string, age int) Person {
+		return Person{
+			Name:    getPointerOnString(name),
+			Age:     getPointerOnInt(age),
+			Address: getPointerOnString(name + " address"),
+			Work:    getPointerOnString(name + " work"),
+		}
+	}
+
+	t.Run("create and get", func(t *testing.T) {
+		s := newStorage(t)
+
+		p := Person{Name: getPointerOnString("test"), Age: getPointerOnInt(1)}
+		id, err := s.CreatePerson(ctx, p)
+		require.NoError(t, err)
+
+		got, err := s.GetPerson(ctx, id)
+		require.NoError(t, err)
+		require.Equal(t, id, *got.ID)
+		require.Equal(t, "test", *got.Name)
+		require.Equal(t, 1, *got.Age)
+		require.Nil(t, got.Address)
+		require.Nil(t, got.Work)
+	})
+
+	t.Run("extended fields", func(t *testing.T) {
+		s := newStorage(t)
+
+		birthDate := time.Date(1990, time.January, 31, 0, 0, 0, 0, time.UTC)
+		p := Person{
+			Name:         getPointerOnString("test"),
+			BirthDate:    &birthDate,
+			Email:        getPointerOnString("test@example.com"),
+			PhoneNumbers: pq.StringArray{"+79991234567", "+79997654321"},
+			Street:       getPointerOnString("Baumanskaya 2"),
+			City:         getPointerOnString("Moscow"),
+			PostalCode:   getPointerOnString("105005"),
+			Country:      getPointerOnString("RU"),
+		}
+		id, err := s.CreatePerson(ctx, p)
+		require.NoError(t, err)
+
+		got, err := s.GetPerson(ctx, id)
+		require.NoError(t, err)
+		require.Nil(t, got.Age)
+		require.True(t, birthDate.Equal(*got.BirthDate))
+		require.Equal(t, "test@example.com", *got.Email)
+		require.Equal(t, p.PhoneNumbers, got.PhoneNumbers)
+		require.Equal(t, "Baumanskaya 2", *got.Street)
+		require.Equal(t, "Moscow", *got.City)
+		require.Equal(t, "105005", *got.PostalCode)
+		require.Equal(t, "RU", *got.Country)
+		require.NotNil(t, got.CreatedAt)
+		require.NotNil(t, got.UpdatedAt)
+
+		update := Person{PhoneNumbers: pq.StringArray{"+79990000000"}}
+		require.NoError(t, s.UpdatePerson(ctx, id, &update))
+		require.Equal(t, pq.StringArray{"+79990000000"}, update.PhoneNumbers)
+		require.Equal(t, "test@example.com", *update.Email)
+		require.True(t, got.CreatedAt.Equal(*update.CreatedAt))
+		require.False(t, update.UpdatedAt.Before(*got.UpdatedAt))
+	})
+
+	t.Run("get missing person", func(t *testing.T) {
+		s := newStorage(t)
+
+		got, err := s.GetPerson(ctx, 100)
+		require.NoError(t, err)
+		require.Nil(t, got.ID)
+	})
+
+	t.Run("get persons ordered by id", func(t *testing.T) {
+		s := newStorage(t)
+
+		persons, err := s.GetPersons(ctx, Page{})
+		require.NoError(t, err)
+		require.Empty(t, persons)
+
+		ids := make([]int, 0)
+		for _, name := range []string{"a", "b", "c"} {
+			id, err := s.CreatePerson(ctx, newPerson(name, 1))
+			require.NoError(t, err)
+			ids = append(ids, id)
+		}
+
+		persons, err = s.GetPersons(ctx, Page{})
+		require.NoError(t, err)
+		require.Len(t, persons, 3)
+		for i, p := range persons {
+			require.Equal(t, ids[i], *p.ID)
+		}
+		require.Less(t, ids[0], ids[1])
+		require.Less(t, ids[1], ids[2])
+
+		persons, err = s.GetPersonsByIDs(ctx, []int{ids[2], 100, ids[0], ids[2]})
+		require.NoError(t, err)
+		require.Len(t, persons, 2)
+		require.Equal(t, ids[0], *persons[0].ID)
+		require.Equal(t, ids[2], *persons[1].ID)
+		require.Equal(t, "c", *persons[1].Name)
+
+		persons, err = s.GetPersonsByIDs(ctx, nil)
+		require.NoError(t, err)
+		require.Empty(t, persons)
+	})
+
+	t.Run("pages and counts", func(t *testing.T) {
+		s := newStorage(t)
+
+		ids := make([]int, 0)
+		for _, name := range []string{"a", "b", "c", "d"} {
+			p := newPerson(name, 1)
+			if name != "b" {
+				p.Tags = pq.StringArray{"vip"}
+			}
+			id, err := s.CreatePerson(ctx, p)
+			require.NoError(t, err)
+			ids = append(ids, id)
+		}
+		pageIDs := func(persons []Person, err error) []int {
+			require.NoError(t, err)
+			res := make([]int, len(persons))
+			for i, p := range persons {
+				res[i] = *p.ID
+			}
+			return res
+		}
+
+		require.Equal(t, ids[1:3], pageIDs(s.GetPersons(ctx, Page{Limit: 2, Offset: 1})))
+		require.Equal(t, ids[3:], pageIDs(s.GetPersons(ctx, Page{Offset: 3})))
+		require.Empty(t, pageIDs(s.GetPersons(ctx, Page{Limit: 2, Offset: 4})))
+
+		vip := Person{Tags: pq.StringArray{"vip"}}
+		require.Equal(t, []int{ids[2]}, pageIDs(s.FindPersons(ctx, vip, Page{Limit: 1, Offset: 1})))
+		require.Equal(t, []int{ids[2], ids[3]}, pageIDs(s.FindPersons(ctx, vip, Page{Offset: 1})))
+
+		count, err := s.CountPersons(ctx, Person{})
+		require.NoError(t, err)
+		require.Equal(t, 4, count)
+		count, err = s.CountPersons(ctx, vip)
+		require.NoError(t, err)
+		require.Equal(t, 3, count)
+	})
+
+	t.Run("projected fields", func(t *testing.T) {
+		s := newStorage(t)
+
+		birthDate := time.Date(1990, time.January, 31, 0, 0, 0, 0, time.UTC)
+		p := newPerson("test", 1)
+		p.BirthDate = &birthDate
+		p.City = getPointerOnString("Moscow")
+		id, err := s.CreatePerson(ctx, p)
+		require.NoError(t, err)
+
+		got, err := s.GetPerson(ctx, id, "name", "age", "address_parts")
+		require.NoError(t, err)
+		require.Equal(t, id, *got.ID)
+		require.Equal(t, "test", *got.Name)
+		require.Equal(t, 1, *got.Age)
+		require.True(t, birthDate.Equal(*got.BirthDate))
+		require.Equal(t, "Moscow", *got.City)
+		require.Nil(t, got.Address)
+		require.Nil(t, got.Work)
+		require.Nil(t, got.CreatedAt)
+
+		persons, err := s.GetPersons(ctx, Page{}, "name")
+		require.NoError(t, err)
+		require.Len(t, persons, 1)
+		require.Equal(t, Person{ID: &id, Name: getPointerOnString("test")}, persons[0])
+
+		persons, err = s.FindPersons(ctx, Person{City: getPointerOnString("moscow")}, Page{}, "work")
+		require.NoError(t, err)
+		require.Len(t, persons, 1)
+		require.Equal(t, Person{ID: &id, Work: getPointerOnString("test work")}, persons[0])
+
+		got, err = s.GetPerson(ctx, 100, "name")
+		require.NoError(t, err)
+		require.Nil(t, got.ID)
+	})
+
+	t.Run("partial update", func(t *testing.T) {
+		s := newStorage(t)
+
+		id, err := s.CreatePerson(ctx, newPerson("test", 1))
+		require.NoError(t, err)
+
+		update := Person{Age: getPointerOnInt(2)}
+		require.NoError(t, s.UpdatePerson(ctx, id, &update))
+		require.Equal(t, id, *update.ID)
+		require.Equal(t, "test", *update.Name)
+		require.Equal(t, 2, *update.Age)
+		require.Equal(t, "test address", *update.Address)
+
+		got, err := s.GetPerson(ctx, id)
+		require.NoError(t, err)
+		require.Equal(t, update, got)
+	})
+
+	t.Run("empty update", func(t *testing.T) {
+		s := newStorage(t)
+
+		id, err := s.CreatePerson(ctx, newPerson("test", 1))
+		require.NoError(t, err)
+
+		update := Person{}
+		require.NoError(t, s.UpdatePerson(ctx, id, &update))
+
+		got, err := s.GetPerson(ctx, id)
+		require.NoError(t, err)
+		require.Equal(t, 1, *got.Age)
+	})
+
+	t.Run("update missing person", func(t *testing.T) {
+		s := newStorage(t)
+
+		update := Person{Age: getPointerOnInt(2)}
+		require.ErrorIs(t, s.UpdatePerson(ctx, 100, &update), ErrNotFound)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		s := newStorage(t)
+
+		id, err := s.CreatePerson(ctx, newPerson("test", 1))
+		require.NoError(t, err)
+
+		isDeleted, err := s.DeletePerson(ctx, id)
+		require.NoError(t, err)
+		require.True(t, isDeleted)
+
+		isDeleted, err = s.DeletePerson(ctx, id)
+		require.NoError(t, err)
+		require.False(t, isDeleted)
+
+		got, err := s.GetPerson(ctx, id)
+		require.NoError(t, err)
+		require.Nil(t, got.ID)
+	})
+
+	t.Run("find persons", func(t *testing.T) {
+		s := newStorage(t)
+
+		id, err := s.CreatePerson(ctx, newPerson("Test", 1))
+		require.NoError(t, err)
+		_, err = s.CreatePerson(ctx, newPerson("other", 1))
+		require.NoError(t, err)
+
+		persons, err := s.FindPersons(ctx, Person{Name: getPointerOnString("TEST"), Address: getPointerOnString("test ADDRESS")}, Page{})
+		require.NoError(t, err)
+		require.Len(t, persons, 1)
+		require.Equal(t, id, *persons[0].ID)
+
+		persons, err = s.FindPersons(ctx, Person{Name: getPointerOnString("test"), Work: getPointerOnString("other work")}, Page{})
+		require.NoError(t, err)
+		require.Empty(t, persons)
+	})
+
+	t.Run("duplicate candidates", func(t *testing.T) {
+		s := newStorage(t)
+
+		create := func(p Person) int {
+			id, err := s.CreatePerson(ctx, p)
+			require.NoError(t, err)
+			return id
+		}
+		id := create(Person{Name: getPointerOnString("Ivan Petrov"), Email: getPointerOnString("ivan@example.com"), PhoneNumbers: pq.StringArray{"+79991234567"}})
+		sameEmail := create(Person{Name: getPointerOnString("I. P."), Email: getPointerOnString("IVAN@example.com")})
+		samePhone := create(Person{Name: getPointerOnString("Somebody"), PhoneNumbers: pq.StringArray{"+70000000000", "+79991234567"}})
+		sameWord := create(Person{Name: getPointerOnString("petrov, Petr")})
+		create(Person{Name: getPointerOnString("Petr Ivanov")})
+
+		p, err := s.GetPerson(ctx, id)
+		require.NoError(t, err)
+
+		candidates, err := s.FindDuplicateCandidates(ctx, p, 10)
+		require.NoError(t, err)
+		ids := make([]int, len(candidates))
+		for i, candidate := range candidates {
+			ids[i] = *candidate.ID
+		}
+		require.Equal(t, []int{sameEmail, samePhone, sameWord}, ids)
+
+		candidates, err = s.FindDuplicateCandidates(ctx, p, 2)
+		require.NoError(t, err)
+		require.Len(t, candidates, 2)
+	})
+
+	t.Run("attributes and tags", func(t *testing.T) {
+		s := newStorage(t)
+
+		id, err := s.CreatePerson(ctx, Person{
+			Name:       getPointerOnString("test"),
+			Attributes: Attributes{"team": "core", "level": float64(3), "skills": []interface{}{"go", "sql"}},
+			Tags:       pq.StringArray{"vip", "beta"},
+		})
+		require.NoError(t, err)
+		otherID, err := s.CreatePerson(ctx, Person{
+			Name:       getPointerOnString("other"),
+			Attributes: Attributes{"team": "sales"},
+			Tags:       pq.StringArray{"vip"},
+		})
+		require.NoError(t, err)
+		_, err = s.CreatePerson(ctx, newPerson("plain", 1))
+		require.NoError(t, err)
+
+		got, err := s.GetPerson(ctx, id)
+		require.NoError(t, err)
+		require.Equal(t, Attributes{"team": "core", "level": float64(3), "skills": []interface{}{"go", "sql"}}, got.Attributes)
+		require.Equal(t, pq.StringArray{"vip", "beta"}, got.Tags)
+
+		findIDs := func(match Person) []int {
+			found, err := s.FindPersons(ctx, match, Page{})
+			require.NoError(t, err)
+			ids := make([]int, len(found))
+			for i, p := range found {
+				ids[i] = *p.ID
+			}
+			return ids
+		}
+		require.Equal(t, []int{id, otherID}, findIDs(Person{Tags: pq.StringArray{"vip"}}))
+		require.Equal(t, []int{id}, findIDs(Person{Tags: pq.StringArray{"vip", "beta"}}))
+		require.Equal(t, []int{id}, findIDs(Person{Attributes: Attributes{"skills": []interface{}{"sql"}}}))
+		require.Equal(t, []int{otherID}, findIDs(Person{Attributes: Attributes{"team": "sales"}, Tags: pq.StringArray{"vip"}}))
+		require.Empty(t, findIDs(Person{Attributes: Attributes{"level": "3"}}))
+
+		update := Person{Attributes: Attributes{"team": "platform"}, Tags: pq.StringArray{}}
+		require.NoError(t, s.UpdatePerson(ctx, id, &update))
+		require.Equal(t, Attributes{"team": "platform"}, update.Attributes)
+		require.Empty(t, update.Tags)
+		require.Equal(t, "test", *update.Name)
+	})
+
+	t.Run("merge persons", func(t *testing.T) {
+		s := newStorage(t)
+
+		id, err := s.CreatePerson(ctx, Person{Name: getPointerOnString("test"), PhoneNumbers: pq.StringArray{"+79991234567"}})
+		require.NoError(t, err)
+		sourceID, err := s.CreatePerson(ctx, Person{
+			Name:         getPointerOnString("source"),
+			Work:         getPointerOnString("source work"),
+			PhoneNumbers: pq.StringArray{"+79991234567", "+79997654321"},
+		})
+		require.NoError(t, err)
+		olderSourceID, err := s.CreatePerson(ctx, newPerson("older source", 1))
+		require.NoError(t, err)
+
+		_, err = s.MergePersons(ctx, sourceID, olderSourceID)
+		require.NoError(t, err)
+		merged, err := s.MergePersons(ctx, id, sourceID)
+		require.NoError(t, err)
+		require.Equal(t, id, *merged.ID)
+		require.Equal(t, "test", *merged.Name)
+		require.Equal(t, "source work", *merged.Work)
+		require.Equal(t, "older source address", *merged.Address)
+		require.Equal(t, pq.StringArray{"+79991234567", "+79997654321"}, merged.PhoneNumbers)
+
+		got, err := s.GetPerson(ctx, id)
+		require.NoError(t, err)
+		require.Equal(t, merged, got)
+
+		got, err = s.GetPerson(ctx, sourceID)
+		require.NoError(t, err)
+		require.Nil(t, got.ID)
+
+		for _, aliasID := range []int{sourceID, olderSourceID} {
+			mergedID, err := s.GetPersonAlias(ctx, aliasID)
+			require.NoError(t, err)
+			require.Equal(t, id, mergedID)
+		}
+
+		_, err = s.MergePersons(ctx, id, sourceID)
+		require.ErrorIs(t, err, ErrNotFound)
+
+		isDeleted, err := s.DeletePerson(ctx, id)
+		require.NoError(t, err)
+		require.True(t, isDeleted)
+
+		mergedID, err := s.GetPersonAlias(ctx, sourceID)
+		require.NoError(t, err)
+		require.Zero(t, mergedID)
+	})
+
+	t.Run("relations", func(t *testing.T) {
+		s := newStorage(t)
+
+		ids := make([]int, 0)
+		for _, name := range []string{"a", "b", "c"} {
+			id, err := s.CreatePerson(ctx, newPerson(name, 1))
+			require.NoError(t, err)
+			ids = append(ids, id)
+		}
+
+		parentID, err := s.CreateRelation(ctx, Relation{PersonID: ids[0], RelatedPersonID: ids[1], Type: "parent"})
+		require.NoError(t, err)
+		siblingID, err := s.CreateRelation(ctx, Relation{PersonID: ids[2], RelatedPersonID: ids[0], Type: "sibling", Bidirectional: true})
+		require.NoError(t, err)
+
+		_, err = s.CreateRelation(ctx, Relation{PersonID: ids[0], RelatedPersonID: ids[1], Type: "parent"})
+		require.ErrorIs(t, err, ErrRelationExists)
+		_, err = s.CreateRelation(ctx, Relation{PersonID: ids[0], RelatedPersonID: 100, Type: "parent"})
+		require.ErrorIs(t, err, ErrNotFound)
+
+		relations, err := s.GetRelations(ctx, ids[0])
+		require.NoError(t, err)
+		require.Len(t, relations, 2)
+		require.Equal(t, parentID, *relations[0].ID)
+		require.Equal(t, Relation{
+			ID: relations[1].ID, PersonID: ids[2], RelatedPersonID: ids[0], Type: "sibling", Bidirectional: true,
+			CreatedAt: relations[1].CreatedAt,
+		}, relations[1])
+		require.NotNil(t, relations[1].CreatedAt)
+
+		relations, err = s.GetRelations(ctx, ids[1])
+		require.NoError(t, err)
+		require.Empty(t, relations)
+
+		relations, err = s.GetRelationsByPersonIDs(ctx, []int{ids[0], ids[2]})
+		require.NoError(t, err)
+		require.Len(t, relations, 2)
+		require.Equal(t, parentID, *relations[0].ID)
+		require.Equal(t, siblingID, *relations[1].ID)
+		relations, err = s.GetRelationsByPersonIDs(ctx, []int{ids[1]})
+		require.NoError(t, err)
+		require.Empty(t, relations)
+
+		isDeleted, err := s.DeleteRelation(ctx, ids[1], parentID)
+		require.NoError(t, err)
+		require.False(t, isDeleted)
+		isDeleted, err = s.DeleteRelation(ctx, ids[0], siblingID)
+		require.NoError(t, err)
+		require.True(t, isDeleted)
+
+		isDeleted, err = s.DeletePerson(ctx, ids[1])
+		require.NoError(t, err)
+		require.True(t, isDeleted)
+		relations, err = s.GetRelations(ctx, ids[0])
+		require.NoError(t, err)
+		require.Empty(t, relations)
+	})
+
+	t.Run("relatives", func(t *testing.T) {
+		s := newStorage(t)
+
+		ids := make([]int, 0)
+		for _, name := range []string{"a", "b", "c", "d", "e"} {
+			id, err := s.CreatePerson(ctx, newPerson(name, 1))
+			require.NoError(t, err)
+			ids = append(ids, id)
+		}
+		for _, r := range []Relation{
+			{PersonID: ids[0], RelatedPersonID: ids[1], Type: "parent"},
+			{PersonID: ids[1], RelatedPersonID: ids[2], Type: "spouse", Bidirectional: true},
+			{PersonID: ids[2], RelatedPersonID: ids[0], Type: "child"},
+			{PersonID: ids[3], RelatedPersonID: ids[0], Type: "parent"},
+			{PersonID: ids[0], RelatedPersonID: ids[4], Type: "colleague"},
+		} {
+			_, err := s.CreateRelation(ctx, r)
+			require.NoError(t, err)
+		}
+
+		relativeIDs := func(relatives []Relative) map[int]int {
+			res := make(map[int]int)
+			for _, r := range relatives {
+				res[*r.ID] = r.Depth
+			}
+			return res
+		}
+
+		relatives, err := s.GetRelatives(ctx, ids[0], 1, familyRelationTypes)
+		require.NoError(t, err)
+		require.Equal(t, map[int]int{ids[1]: 1}, relativeIDs(relatives))
+		require.Equal(t, "b", *relatives[0].Name)
+
+		relatives, err = s.GetRelatives(ctx, ids[0], 3, familyRelationTypes)
+		require.NoError(t, err)
+		require.Equal(t, map[int]int{ids[1]: 1, ids[2]: 2}, relativeIDs(relatives))
+		require.Equal(t, ids[1], *relatives[0].ID)
+
+		relatives, err = s.GetRelatives(ctx, ids[2], 5, familyRelationTypes)
+		require.NoError(t, err)
+		require.Equal(t, map[int]int{ids[0]: 1, ids[1]: 1}, relativeIDs(relatives))
+
+		relatives, err = s.GetRelatives(ctx, ids[0], 5, []string{"colleague"})
+		require.NoError(t, err)
+		require.Equal(t, map[int]int{ids[4]: 1}, relativeIDs(relatives))
+	})
+
+	t.Run("merge persons with relations", func(t *testing.T) {
+		s := newStorage(t)
+
+		ids := make([]int, 0)
+		for _, name := range []string{"target", "source", "c"} {
+			id, err := s.CreatePerson(ctx, newPerson(name, 1))
+			require.NoError(t, err)
+			ids = append(ids, id)
+		}
+		for _, r := range []Relation{
+			{PersonID: ids[0], RelatedPersonID: ids[2], Type: "sibling"},
+			{PersonID: ids[1], RelatedPersonID: ids[2], Type: "sibling"},
+			{PersonID: ids[1], RelatedPersonID: ids[2], Type: "colleague"},
+			{PersonID: ids[2], RelatedPersonID: ids[1], Type: "spouse", Bidirectional: true},
+			{PersonID: ids[1], RelatedPersonID: ids[0], Type: "relative"},
+		} {
+			_, err := s.CreateRelation(ctx, r)
+			require.NoError(t, err)
+		}
+
+		_, err := s.MergePersons(ctx, ids[0], ids[1])
+		require.NoError(t, err)
+
+		relations, err := s.GetRelations(ctx, ids[0])
+		require.NoError(t, err)
+		got := make([]Relation, 0, len(relations))
+		for _, r := range relations {
+			got = append(got, Relation{PersonID: r.PersonID, RelatedPersonID: r.RelatedPersonID, Type: r.Type, Bidirectional: r.Bidirectional})
+		}
+		require.ElementsMatch(t, []Relation{
+			{PersonID: ids[0], RelatedPersonID: ids[2], Type: "sibling"},
+			{PersonID: ids[0], RelatedPersonID: ids[2], Type: "colleague"},
+			{PersonID: ids[2], RelatedPersonID: ids[0], Type: "spouse", Bidirectional: true},
+		}, got)
+	})
+
+	t.Run("stored person is not aliased", func(t *testing.T) {
+		s := newStorage(t)
+
+		p := newPerson("test", 1)
+		id, err := s.CreatePerson(ctx, p)
+		require.NoError(t, err)
+		*p.Name = "changed"
+
+		got, err := s.GetPerson(ctx, id)
+		require.NoError(t, err)
+		require.Equal(t, "test", *got.Name)
+	})
+
+	t.Run("cancelled context", func(t *testing.T) {
+		s := newStorage(t)
+
+		cancelledCtx, cancel := context.WithCancel(ctx)
+		cancel()
+
+		_, err := s.CreatePerson(cancelledCtx, newPerson("test", 1))
+		require.Error(t, err)
+		_, err = s.GetPersons(cancelledCtx, Page{})
+		require.Error(t, err)
+	})
+}
+
+// uniqueStorage is a storage backend, which checks the unique constraints itself.
+type uniqueStorage interface {
+	storage
+	SetUniqueConstraints(fields [][]string) error
+}
+
+// testUniqueConstraints checks the unique constraints every storage backend enforces.
+// newStorage must return an empty storage.
+func testUniqueConstraints(t *testing.T, newStorage func(t *testing.T) uniqueStorage) {
+	ctx := context.Background()
+
+	newUniqueStorage := func(t *testing.T) uniqueStorage {
+		s := newStorage(t)
+		require.NoError(t, s.SetUniqueConstraints([][]string{{"name", "address"}, {"email"}}))
+		return s
+	}
+
+	t.Run("unknown field", func(t *testing.T) {
+		s := newStorage(t)
+		require.Error(t, s.SetUniqueConstraints([][]string{{"email"}, {"name", "unknown"}}))
+
+		// None of the rejected constraints applies.
+		_, err := s.CreatePerson(ctx, Person{Name: getPointerOnString("test"), Email: getPointerOnString("test@example.com")})
+		require.NoError(t, err)
+		_, err = s.CreatePerson(ctx, Person{Name: getPointerOnString("test"), Email: getPointerOnString("test@example.com")})
+		require.NoError(t, err)
+	})
+
+	t.Run("create and update", func(t *testing.T) {
+		s := newUniqueStorage(t)
+
+		id, err := s.CreatePerson(ctx, Person{
+			Name:    getPointerOnString("test"),
+			Address: getPointerOnString("address"),
+			Email:   getPointerOnString("test@example.com"),
+		})
+		require.NoError(t, err)
+
+		var conflict *ConflictError
+		_, err = s.CreatePerson(ctx, Person{Name: getPointerOnString("TEST"), Address: getPointerOnString("Address")})
+		require.ErrorAs(t, err, &conflict)
+		require.Equal(t, id, *conflict.Existing.ID)
+
+		otherID, err := s.CreatePerson(ctx, Person{Name: getPointerOnString("test")})
+		require.NoError(t, err)
+
+		err = s.UpdatePerson(ctx, otherID, &Person{Address: getPointerOnString("address")})
+		require.ErrorAs(t, err, &conflict)
+		require.Equal(t, id, *conflict.Existing.ID)
+
+		require.NoError(t, s.UpdatePerson(ctx, id, &Person{Email: getPointerOnString("TEST@example.com")}))
+
+		err = s.UpdatePerson(ctx, otherID+1, &Person{Email: getPointerOnString("other@example.com")})
+		require.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("concurrent creates", func(t *testing.T) {
+		s := newUniqueStorage(t)
+
+		const writers = 10
+		errs := make(chan error, writers)
+		wg := sync.WaitGroup{}
+		for i := 0; i < writers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := s.CreatePerson(ctx, Person{Name: getPointerOnString("test"), Email: getPointerOnString("test@example.com")})
+				errs <- err
+			}()
+		}
+		wg.Wait()
+		close(errs)
+
+		created := 0
+		for err := range errs {
+			var conflict *ConflictError
+			if err == nil {
+				created++
+				continue
+			}
+			require.ErrorAs(t, err, &conflict)
+		}
+		require.Equal(t, 1, created)
+	})
+}
+
+func Test_MemoryRepository(t *testing.T) {
+	testStorageConformance(t, func(t *testing.T) storage {
+		return NewMemoryRepository()
+	})
+	testUniqueConstraints(t, func(t *testing.T) uniqueStorage {
+		return NewMemoryRepository()
+	})
+}
+
+func newTestBoltRepository(t *testing.T) *boltRepository {
+	r, err := NewBoltRepository(filepath.Join(t.TempDir(), "persons.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, r.Close())
+	})
+	return r
+}
+
+func Test_BoltRepository(t *testing.T) {
+	testStorageConformance(t, func(t *testing.T) storage {
+		return newTestBoltRepository(t)
+	})
+	testUniqueConstraints(t, func(t *testing.T) uniqueStorage {
+		return newTestBoltRepository(t)
+	})
+}
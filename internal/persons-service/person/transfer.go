@@ -0,0 +1,90 @@
+package person
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"io"
+)
+
+// Export writes all persons to w as JSON lines, one person per line. It reads the persons from
+// storage a batch at a time.
+func Export(ctx context.Context, storage storage, w io.Writer) (int, error) {
+	enc := json.NewEncoder(w)
+	count := 0
+	var encodeErr error
+	err := streamPersons(ctx, storage, Person{}, false, Page{}, func(persons []Person) error {
+		for _, p := range persons {
+			if encodeErr = enc.Encode(p); encodeErr != nil {
+				return encodeErr
+			}
+			count++
+		}
+		return nil
+	})
+	if encodeErr != nil {
+		return count, errors.Wrap(encodeErr, "failed to encode person")
+	}
+	if err != nil {
+		return count, errors.Wrap(err, "failed to get persons")
+	}
+
+	return count, nil
+}
+
+// Import reads JSON lines in the Export format and creates a person for each of them.
+// Ids from the input are ignored, persons always get new ids.
+func Import(ctx context.Context, storage storage, r io.Reader) (int, error) {
+	scanner := bufio.NewScanner(r)
+	count := 0
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		p := Person{}
+		if err := json.Unmarshal(scanner.Bytes(), &p); err != nil {
+			return count, errors.Wrapf(err, "failed to decode person on line %d", line)
+		}
+		if p.Name == nil {
+			return count, errors.Errorf("person on line %d has no name", line)
+		}
+
+		p.ID = nil
+		p.CreatedAt = nil
+		p.UpdatedAt = nil
+		if _, err := storage.CreatePerson(ctx, p); err != nil {
+			return count, errors.Wrapf(err, "failed to create person on line %d", line)
+		}
+		count++
+	}
+	if err := scanner.Err(); err != nil {
+		return count, errors.Wrap(err, "failed to read input")
+	}
+
+	return count, nil
+}
+
+// Seed creates n persons with generated data for local development.
+func Seed(ctx context.Context, storage storage, n int) (int, error) {
+	for i := 1; i <= n; i++ {
+		name := fmt.Sprintf("Seed Person %d", i)
+		age := 18 + i%60
+		address := fmt.Sprintf("Seed Street %d", i)
+		work := fmt.Sprintf("Seed Company %d", i%10)
+
+		p := Person{
+			Name:    &name,
+			Age:     &age,
+			Address: &address,
+			Work:    &work,
+		}
+		if _, err := storage.CreatePerson(ctx, p); err != nil {
+			return i - 1, errors.Wrap(err, "failed to create person")
+		}
+	}
+
+	return n, nil
+}
@@ -0,0 +1,120 @@
+package person
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func Test_ExportImport(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	testFields := createHandlerTestFields(ctrl)
+
+	persons := []Person{
+		{ID: getPointerOnInt(1), Name: getPointerOnString("a"), Age: getPointerOnInt(1), Address: getPointerOnString("a"), Work: getPointerOnString("a")},
+		{ID: getPointerOnInt(2), Name: getPointerOnString("b"), Age: getPointerOnInt(2), Address: nil, Work: nil},
+	}
+	testFields.storage.EXPECT().GetPersons(gomock.Any(), Page{Limit: streamBatchSize}).Return(persons, nil)
+
+	buf := &bytes.Buffer{}
+	n, err := Export(context.Background(), testFields.storage, buf)
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+
+	created := make([]Person, 0)
+	testFields.storage.EXPECT().CreatePerson(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, p Person) (int, error) {
+		created = append(created, p)
+		return len(created), nil
+	}).Times(2)
+
+	n, err = Import(context.Background(), testFields.storage, buf)
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	require.Nil(t, created[0].ID)
+	require.Equal(t, "a", *created[0].Name)
+	require.Equal(t, "b", *created[1].Name)
+	require.Nil(t, created[1].Address)
+}
+
+func Test_Export_Batches(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	testFields := createHandlerTestFields(ctrl)
+
+	batch := make([]Person, streamBatchSize)
+	for i := range batch {
+		batch[i] = Person{ID: getPointerOnInt(i + 1), Name: getPointerOnString("a")}
+	}
+	// No read without a limit is expected.
+	gomock.InOrder(
+		testFields.storage.EXPECT().GetPersons(gomock.Any(), Page{Limit: streamBatchSize}).Return(batch, nil),
+		testFields.storage.EXPECT().GetPersons(gomock.Any(), Page{Limit: streamBatchSize, Offset: streamBatchSize}).Return(batch, nil),
+		testFields.storage.EXPECT().GetPersons(gomock.Any(), Page{Limit: streamBatchSize, Offset: 2 * streamBatchSize}).Return(batch[:1], nil),
+	)
+
+	buf := &bytes.Buffer{}
+	n, err := Export(context.Background(), testFields.storage, buf)
+	require.NoError(t, err)
+	require.Equal(t, 2*streamBatchSize+1, n)
+	require.Equal(t, 2*streamBatchSize+1, strings.Count(buf.String(), "\n"))
+}
+
+func Test_Import(t *testing.T) {
+	tests := []struct {
+		name          string
+		input         string
+		expectedCount int
+		expectedError bool
+		Prepare       func(fields *handlerTestFields)
+	}{
+		{
+			name:          "wrong json",
+			input:         `{"name": `,
+			expectedError: true,
+			Prepare:       func(fields *handlerTestFields) {},
+		},
+		{
+			name:          "no name",
+			input:         `{"age": 1}`,
+			expectedError: true,
+			Prepare:       func(fields *handlerTestFields) {},
+		},
+		{
+			name:          "storage error",
+			input:         "{\"name\": \"a\"}\n{\"name\": \"b\"}",
+			expectedCount: 1,
+			expectedError: true,
+			Prepare: func(fields *handlerTestFields) {
+				gomock.InOrder(
+					fields.storage.EXPECT().CreatePerson(gomock.Any(), gomock.Any()).Return(1, nil),
+					fields.storage.EXPECT().CreatePerson(gomock.Any(), gomock.Any()).Return(0, errors.New("")),
+				)
+			},
+		},
+		{
+			name:          "empty lines are skipped",
+			input:         "\n{\"name\": \"a\"}\n\n",
+			expectedCount: 1,
+			Prepare: func(fields *handlerTestFields) {
+				fields.storage.EXPECT().CreatePerson(gomock.Any(), gomock.Any()).Return(1, nil)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			testFields := createHandlerTestFields(ctrl)
+			tt.Prepare(testFields)
+
+			n, err := Import(context.Background(), testFields.storage, strings.NewReader(tt.input))
+
+			require.Equal(t, tt.expectedError, err != nil)
+			require.Equal(t, tt.expectedCount, n)
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package person
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"slices"
+	"strings"
+)
+
+// uniqueFields copies the field with the json name from src to dst and reports whether it is
+// set in src.
+var uniqueFields = map[string]func(src Person, dst *Person) bool{
+	"name": func(src Person, dst *Person) bool {
+		dst.Name = src.Name
+		return src.Name != nil
+	},
+	"age": func(src Person, dst *Person) bool {
+		dst.Age = src.Age
+		return src.Age != nil
+	},
+	"birth_date": func(src Person, dst *Person) bool {
+		dst.BirthDate = src.BirthDate
+		return src.BirthDate != nil
+	},
+	"email": func(src Person, dst *Person) bool {
+		dst.Email = src.Email
+		return src.Email != nil
+	},
+	"address": func(src Person, dst *Person) bool {
+		dst.Address = src.Address
+		return src.Address != nil
+	},
+	"address_street": func(src Person, dst *Person) bool {
+		dst.Street = src.Street
+		return src.Street != nil
+	},
+	"address_city": func(src Person, dst *Person) bool {
+		dst.City = src.City
+		return src.City != nil
+	},
+	"address_postal_code": func(src Person, dst *Person) bool {
+		dst.PostalCode = src.PostalCode
+		return src.PostalCode != nil
+	},
+	"address_country": func(src Person, dst *Person) bool {
+		dst.Country = src.Country
+		return src.Country != nil
+	},
+	"work": func(src Person, dst *Person) bool {
+		dst.Work = src.Work
+		return src.Work != nil
+	},
+}
+
+// uniqueConstraint is a set of fields whose values may belong to one person only.
+type uniqueConstraint []string
+
+func newUniqueConstraints(fields [][]string) ([]uniqueConstraint, error) {
+	constraints := make([]uniqueConstraint, 0, len(fields))
+	for _, constraint := range fields {
+		for _, field := range constraint {
+			if _, ok := uniqueFields[field]; !ok {
+				return nil, errors.Errorf("unknown unique constraint field %q", field)
+			}
+		}
+		constraints = append(constraints, constraint)
+	}
+	return constraints, nil
+}
+
+// match returns the fields of the constraint taken from p, or false if one of them is not set,
+// as persons without a value can not clash.
+func (c uniqueConstraint) match(p Person) (Person, bool) {
+	match := Person{}
+	for _, field := range c {
+		if !uniqueFields[field](p, &match) {
+			return Person{}, false
+		}
+	}
+	return match, true
+}
+
+// ConflictError is returned by storages when a created or updated person would share the
+// fields of a unique constraint with the existing person.
+type ConflictError struct {
+	Existing Person
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("person already exists: %d", valueOrZero(e.Existing.ID))
+}
+
+// lockKeys returns a key for the fields of every constraint set in p, sorted, for storages that
+// serialize the writers of the same values. The values are compared case-insensitively like
+// FindPersons does, so the keys are too.
+func lockKeys(constraints []uniqueConstraint, p Person) ([]string, error) {
+	keys := make([]string, 0, len(constraints))
+	for _, constraint := range constraints {
+		match, ok := constraint.match(p)
+		if !ok {
+			continue
+		}
+		key, err := json.Marshal(match)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to encode lock key")
+		}
+		keys = append(keys, strings.ToLower(string(key)))
+	}
+	slices.Sort(keys)
+	return slices.Compact(keys), nil
+}
+
+// findConflict returns a ConflictError for the person other than the one with excludeID that
+// shares the fields of any constraint with p, or nil. find lists the persons matching the
+// fields of a constraint, like FindPersons; storages call it in the transaction or under the
+// lock they write p in.
+func findConflict(constraints []uniqueConstraint, p Person, excludeID int, find func(match Person) ([]Person, error)) error {
+	for _, constraint := range constraints {
+		match, ok := constraint.match(p)
+		if !ok {
+			continue
+		}
+
+		persons, err := find(match)
+		if err != nil {
+			return err
+		}
+		for _, existing := range persons {
+			if *existing.ID != excludeID {
+				return &ConflictError{Existing: existing}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,274 @@
+package person
+
+import (
+	"encoding/xml"
+	"errors"
+	"github.com/Erlendum/rsoi-lab-01/pkg/validation"
+	"github.com/labstack/echo/v4"
+	"maps"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// apiVersion is a version of the REST API of the persons. The versions share the handlers and
+// the domain logic and differ in how persons and errors are represented.
+type apiVersion struct {
+	// name is the value of the version parameter of the Accept media type, e.g.
+	// application/json; version=2.
+	name string
+	// person converts p to its representation with the age computed at now.
+	person func(p Person, now time.Time) interface{}
+	// problemDetails is set if errors are answered as RFC 9457 problem details.
+	problemDetails bool
+}
+
+var (
+	// apiV1 leaves out unknown values and answers errors as {"errors": "..."}.
+	apiV1 = &apiVersion{
+		name: "1",
+		person: func(p Person, now time.Time) interface{} {
+			return newPersonResponse(p, now)
+		},
+	}
+	// apiV2 answers every field, null if it is unknown, and errors as problem details.
+	apiV2 = &apiVersion{
+		name: "2",
+		person: func(p Person, now time.Time) interface{} {
+			return newPersonResponseV2(p, now)
+		},
+		problemDetails: true,
+	}
+
+	apiVersions      = []*apiVersion{apiV1, apiV2}
+	latestAPIVersion = apiV2
+)
+
+const (
+	apiVersionKey = "person.api_version"
+	apiPrefixKey  = "person.api_prefix"
+)
+
+// mimeApplicationProblemJSON is the media type of problem details.
+const mimeApplicationProblemJSON = "application/problem+json"
+
+// versionOf returns the API version the request is served in. Requests that did not pass the
+// version middlewares, e.g. in tests, are served in v1.
+func versionOf(c echo.Context) *apiVersion {
+	if v, ok := c.Get(apiVersionKey).(*apiVersion); ok {
+		return v
+	}
+	return apiV1
+}
+
+// personPath returns the path of the person with id under the prefix the request was routed
+// by, so negotiated requests are pointed at negotiated routes again.
+func personPath(c echo.Context, id int) string {
+	prefix, ok := c.Get(apiPrefixKey).(string)
+	if !ok {
+		prefix = "/api/v1"
+	}
+	return prefix + "/persons/" + strconv.Itoa(id)
+}
+
+// successorPath returns the path of the requested route under the prefix of the latest version.
+func successorPath(c echo.Context) string {
+	prefix, _ := c.Get(apiPrefixKey).(string)
+	return "/api/v" + latestAPIVersion.name + strings.TrimPrefix(c.Request().URL.Path, prefix)
+}
+
+// useVersion serves the routes registered under prefix in v.
+func (h *handler) useVersion(v *apiVersion, prefix string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set(apiVersionKey, v)
+			c.Set(apiPrefixKey, prefix)
+			h.announceDeprecation(c, v)
+			return next(c)
+		}
+	}
+}
+
+// negotiateVersion serves the routes registered under prefix in the version asked for by the
+// version parameter of the Accept header, by default the latest one.
+func (h *handler) negotiateVersion(prefix string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			varyAccept(c)
+			c.Set(apiPrefixKey, prefix)
+
+			v, ok := acceptedVersion(c.Request().Header.Get(echo.HeaderAccept))
+			if !ok {
+				c.Set(apiVersionKey, latestAPIVersion)
+				return errorResponse(c, http.StatusNotAcceptable, "unknown api version")
+			}
+
+			c.Set(apiVersionKey, v)
+			h.announceDeprecation(c, v)
+			return next(c)
+		}
+	}
+}
+
+// acceptedVersion returns the version named by the first media range of accept with a version
+// parameter, or the latest one if there is none. ok is false if that version is unknown.
+func acceptedVersion(accept string) (v *apiVersion, ok bool) {
+	for _, part := range strings.Split(accept, ",") {
+		_, params, err := mime.ParseMediaType(part)
+		if err != nil {
+			continue
+		}
+		name, found := params["version"]
+		if !found {
+			continue
+		}
+		for _, v := range apiVersions {
+			if v.name == name {
+				return v, true
+			}
+		}
+		return nil, false
+	}
+	return latestAPIVersion, true
+}
+
+// announceDeprecation adds the Deprecation and Sunset headers to the responses of a deprecated
+// version, with a link to the same route in the latest one.
+func (h *handler) announceDeprecation(c echo.Context, v *apiVersion) {
+	d, ok := h.deprecations[v]
+	if !ok {
+		return
+	}
+
+	header := c.Response().Header()
+	if !d.Deprecated.IsZero() {
+		// RFC 9745 dates the deprecation as a structured field date.
+		header.Set("Deprecation", "@"+strconv.FormatInt(d.Deprecated.Unix(), 10))
+		header.Add("Link", "<"+successorPath(c)+">; rel=\"successor-version\"")
+	}
+	if !d.Sunset.IsZero() {
+		header.Set("Sunset", d.Sunset.UTC().Format(http.TimeFormat))
+	}
+}
+
+// errorResponse answers the request with the error message in the representation of its API
+// version. members are added to the body, e.g. the location of a conflicting person.
+func errorResponse(c echo.Context, status int, message string, members ...echo.Map) error {
+	var body echo.Map
+	if versionOf(c).problemDetails {
+		body = problem(status, message)
+		c.Response().Header().Set(echo.HeaderContentType, mimeApplicationProblemJSON)
+	} else {
+		body = echo.Map{"errors": message}
+	}
+	for _, m := range members {
+		maps.Copy(body, m)
+	}
+	return c.JSON(status, body)
+}
+
+// problem returns the RFC 9457 problem details of an error without a type of its own.
+func problem(status int, detail string) echo.Map {
+	return echo.Map{
+		"type":   "about:blank",
+		"title":  http.StatusText(status),
+		"status": status,
+		"detail": detail,
+	}
+}
+
+// validationErrorResponse lists the messages of a validation error in the language asked for
+// by the client.
+func validationErrorResponse(c echo.Context, err error) error {
+	var fields map[string]string
+	var verr *validation.Error
+	if errors.As(err, &verr) {
+		fields = verr.Translate(c.Request().Header.Get("Accept-Language"))
+	}
+	return fieldErrorsResponse(c, fields)
+}
+
+// fieldErrorsResponse answers a request with invalid fields, with a message for each of them.
+// v1 keeps its {"errors": "validation error"} body and lists the messages under fields.
+func fieldErrorsResponse(c echo.Context, fields map[string]string) error {
+	if versionOf(c).problemDetails {
+		body := problem(http.StatusBadRequest, "validation error")
+		if fields != nil {
+			body["errors"] = fields
+		}
+		c.Response().Header().Set(echo.HeaderContentType, mimeApplicationProblemJSON)
+		return c.JSON(http.StatusBadRequest, body)
+	}
+
+	resp := echo.Map{"errors": "validation error"}
+	if fields != nil {
+		resp["fields"] = fields
+	}
+	return c.JSON(http.StatusBadRequest, resp)
+}
+
+// personResponseV2 is a person in v2, where every field is present: unknown values are null
+// and missing lists empty.
+type personResponseV2 struct {
+	XMLName      xml.Name        `json:"-" xml:"person"`
+	ID           int             `json:"id" xml:"id"`
+	Name         string          `json:"name" xml:"name"`
+	Age          *int            `json:"age" xml:"age"`
+	Address      *string         `json:"address" xml:"address"`
+	Work         *string         `json:"work" xml:"work"`
+	BirthDate    *string         `json:"birth_date" xml:"birth_date"`
+	Email        *string         `json:"email" xml:"email"`
+	PhoneNumbers []string        `json:"phone_numbers" xml:"phone_numbers>phone_number"`
+	AddressParts *addressPartsV2 `json:"address_parts" xml:"address_parts"`
+	Attributes   Attributes      `json:"attributes" xml:"attributes"`
+	Tags         []string        `json:"tags" xml:"tags>tag"`
+	CreatedAt    *time.Time      `json:"created_at" xml:"created_at"`
+	UpdatedAt    *time.Time      `json:"updated_at" xml:"updated_at"`
+}
+
+type addressPartsV2 struct {
+	Street     *string `json:"street" xml:"street"`
+	City       *string `json:"city" xml:"city"`
+	PostalCode *string `json:"postal_code" xml:"postal_code"`
+	Country    *string `json:"country" xml:"country"`
+}
+
+func newPersonResponseV2(p Person, now time.Time) personResponseV2 {
+	resp := personResponseV2{
+		ID:           valueOrZero(p.ID),
+		Name:         valueOrZero(p.Name),
+		Age:          p.CurrentAge(now),
+		Address:      p.Address,
+		Work:         p.Work,
+		Email:        p.Email,
+		PhoneNumbers: p.PhoneNumbers,
+		Attributes:   p.Attributes,
+		Tags:         p.Tags,
+		CreatedAt:    p.CreatedAt,
+		UpdatedAt:    p.UpdatedAt,
+	}
+	if resp.PhoneNumbers == nil {
+		resp.PhoneNumbers = []string{}
+	}
+	if resp.Tags == nil {
+		resp.Tags = []string{}
+	}
+	if resp.Attributes == nil {
+		resp.Attributes = Attributes{}
+	}
+	if p.BirthDate != nil {
+		birthDate := p.BirthDate.Format(validation.DateLayout)
+		resp.BirthDate = &birthDate
+	}
+	if p.Street != nil || p.City != nil || p.PostalCode != nil || p.Country != nil {
+		resp.AddressParts = &addressPartsV2{
+			Street:     p.Street,
+			City:       p.City,
+			PostalCode: p.PostalCode,
+			Country:    p.Country,
+		}
+	}
+	return resp
+}
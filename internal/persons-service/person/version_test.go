@@ -0,0 +1,184 @@
+package person
+
+import (
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/config"
+	"github.com/Erlendum/rsoi-lab-01/pkg/validation"
+	"github.com/go-playground/validator/v10"
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_APIVersions(t *testing.T) {
+	const (
+		v1Person = `{"id":1,"name":"Ivan","age":0,"address":"","work":""}`
+		v2Person = `{"id":1,"name":"Ivan","age":null,"address":null,"work":null,"birth_date":null,"email":null,
+			"phone_numbers":[],"address_parts":null,"attributes":{},"tags":[],"created_at":null,"updated_at":null}`
+	)
+
+	tests := []struct {
+		name                     string
+		method                   string
+		path                     string
+		accept                   string
+		reqBody                  string
+		expectedHTTPCode         int
+		expectedContentType      string
+		expectedResponseBody     string
+		expectedLocationHeader   string
+		expectedDeprecatedHeader bool
+		expectedSuccessorLink    string
+	}{
+		{
+			name:                     "v1",
+			method:                   http.MethodGet,
+			path:                     "/api/v1/persons/1",
+			expectedHTTPCode:         http.StatusOK,
+			expectedContentType:      echo.MIMEApplicationJSON,
+			expectedResponseBody:     v1Person,
+			expectedDeprecatedHeader: true,
+			expectedSuccessorLink:    `</api/v2/persons/1>; rel="successor-version"`,
+		},
+		{
+			name:                     "v1 custom method",
+			method:                   http.MethodPost,
+			path:                     "/api/v1/persons/1:merge",
+			reqBody:                  `{"source_id": 1}`,
+			expectedHTTPCode:         http.StatusBadRequest,
+			expectedDeprecatedHeader: true,
+			expectedSuccessorLink:    `</api/v2/persons/1:merge>; rel="successor-version"`,
+		},
+		{
+			name:                 "v2",
+			method:               http.MethodGet,
+			path:                 "/api/v2/persons/1",
+			expectedHTTPCode:     http.StatusOK,
+			expectedContentType:  echo.MIMEApplicationJSON,
+			expectedResponseBody: v2Person,
+		},
+		{
+			name:                 "v2 error",
+			method:               http.MethodGet,
+			path:                 "/api/v2/persons/100",
+			expectedHTTPCode:     http.StatusNotFound,
+			expectedContentType:  mimeApplicationProblemJSON,
+			expectedResponseBody: `{"type":"about:blank","title":"Not Found","status":404,"detail":"person not found"}`,
+		},
+		{
+			// v1 keeps the errors string of its first release, the messages are added beside it.
+			name:                     "v1 validation error",
+			method:                   http.MethodPost,
+			path:                     "/api/v1/persons",
+			reqBody:                  `{"age": 1}`,
+			expectedHTTPCode:         http.StatusBadRequest,
+			expectedContentType:      echo.MIMEApplicationJSON,
+			expectedResponseBody:     `{"errors":"validation error","fields":{"name":"name is a required field"}}`,
+			expectedDeprecatedHeader: true,
+			expectedSuccessorLink:    `</api/v2/persons>; rel="successor-version"`,
+		},
+		{
+			name:                 "v2 validation error",
+			method:               http.MethodPost,
+			path:                 "/api/v2/persons",
+			reqBody:              `{"age": 1}`,
+			expectedHTTPCode:     http.StatusBadRequest,
+			expectedContentType:  mimeApplicationProblemJSON,
+			expectedResponseBody: `{"type":"about:blank","title":"Bad Request","status":400,"detail":"validation error","errors":{"name":"name is a required field"}}`,
+		},
+		{
+			name:                 "negotiated latest version",
+			method:               http.MethodGet,
+			path:                 "/api/persons/1",
+			accept:               "application/json",
+			expectedHTTPCode:     http.StatusOK,
+			expectedContentType:  echo.MIMEApplicationJSON,
+			expectedResponseBody: v2Person,
+		},
+		{
+			name:                     "negotiated v1",
+			method:                   http.MethodGet,
+			path:                     "/api/persons/1",
+			accept:                   "text/html, application/json; version=1",
+			expectedHTTPCode:         http.StatusOK,
+			expectedContentType:      echo.MIMEApplicationJSON,
+			expectedResponseBody:     v1Person,
+			expectedDeprecatedHeader: true,
+			expectedSuccessorLink:    `</api/v2/persons/1>; rel="successor-version"`,
+		},
+		{
+			name:                   "negotiated location",
+			method:                 http.MethodPost,
+			path:                   "/api/persons",
+			accept:                 "application/json; version=2",
+			reqBody:                `{"name": "Ivan"}`,
+			expectedHTTPCode:       http.StatusCreated,
+			expectedLocationHeader: "/api/persons/2",
+		},
+		{
+			name:                 "negotiated unknown version",
+			method:               http.MethodGet,
+			path:                 "/api/persons/1",
+			accept:               "application/json; version=3",
+			expectedHTTPCode:     http.StatusNotAcceptable,
+			expectedContentType:  mimeApplicationProblemJSON,
+			expectedResponseBody: `{"type":"about:blank","title":"Not Acceptable","status":406,"detail":"unknown api version"}`,
+		},
+	}
+
+	deprecated := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+	sunset := time.Date(2027, 10, 19, 0, 0, 0, 0, time.UTC)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			storage := NewMockstorage(ctrl)
+			storage.EXPECT().GetPerson(gomock.Any(), 1).Return(Person{ID: getPointerOnInt(1), Name: getPointerOnString("Ivan")}, nil).AnyTimes()
+			storage.EXPECT().GetPerson(gomock.Any(), 2).Return(Person{ID: getPointerOnInt(2), Name: getPointerOnString("Ivan")}, nil).AnyTimes()
+			storage.EXPECT().GetPerson(gomock.Any(), 100).Return(Person{}, nil).AnyTimes()
+			storage.EXPECT().GetPersonAlias(gomock.Any(), 100).Return(0, nil).AnyTimes()
+			storage.EXPECT().CreatePerson(gomock.Any(), gomock.Any()).Return(2, nil).AnyTimes()
+
+			h, err := NewHandler(storage, &config.Persons{
+				V1Deprecation: config.Deprecation{Deprecated: deprecated, Sunset: sunset},
+			})
+			require.NoError(t, err)
+
+			e := echo.New()
+			e.Validator = validation.MustRegisterCustomValidator(validator.New())
+			h.Register(e)
+
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.reqBody))
+			if tt.accept != "" {
+				req.Header.Set(echo.HeaderAccept, tt.accept)
+			}
+			rec := httptest.NewRecorder()
+
+			e.ServeHTTP(rec, req)
+
+			require.Equal(t, tt.expectedHTTPCode, rec.Code)
+			if tt.expectedContentType != "" {
+				require.True(t, strings.HasPrefix(rec.Header().Get(echo.HeaderContentType), tt.expectedContentType),
+					rec.Header().Get(echo.HeaderContentType))
+			}
+			if tt.expectedResponseBody != "" {
+				require.JSONEq(t, tt.expectedResponseBody, rec.Body.String())
+			}
+			require.Equal(t, tt.expectedLocationHeader, rec.Header().Get("Location"))
+			if tt.expectedDeprecatedHeader {
+				require.Equal(t, "@1792368000", rec.Header().Get("Deprecation"))
+				require.Equal(t, "Tue, 19 Oct 2027 00:00:00 GMT", rec.Header().Get("Sunset"))
+				require.Equal(t, tt.expectedSuccessorLink, rec.Header().Get("Link"))
+			} else {
+				require.Empty(t, rec.Header().Get("Deprecation"))
+			}
+			if strings.HasPrefix(tt.path, "/api/persons") {
+				require.Equal(t, echo.HeaderAccept, rec.Header().Get(echo.HeaderVary))
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package pgtest
+
+import (
+	"context"
+	"fmt"
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/migrator"
+	embeddedpostgres "github.com/fergusstrange/embedded-postgres"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+	"net"
+	"os"
+	"testing"
+)
+
+// Run runs the tests of m on a migrated database and returns their exit code, for a TestMain.
+// The database is the one POSTGRESQL_TEST_DSN points to if set, or an ephemeral PostgreSQL
+// started for the test run. use gets the connection before the tests run.
+func Run(m *testing.M, use func(db *sqlx.DB)) int {
+	dsn := os.Getenv("POSTGRESQL_TEST_DSN")
+	if dsn == "" {
+		pg, pgDSN, err := startEmbeddedPostgres()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "failed to start embedded postgresql:", err)
+			return 1
+		}
+		defer pg.Stop()
+		dsn = pgDSN
+	}
+
+	db, err := sqlx.Connect("postgres", dsn)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "failed to connect to postgresql:", err)
+		return 1
+	}
+	defer db.Close()
+
+	mig, err := migrator.NewMigrator(db.DB)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "failed to create migrator:", err)
+		return 1
+	}
+	if err = mig.Up(context.Background()); err != nil {
+		fmt.Fprintln(os.Stderr, "failed to apply migrations:", err)
+		return 1
+	}
+
+	use(db)
+	return m.Run()
+}
+
+// startEmbeddedPostgres starts PostgreSQL on a free port. EMBEDDED_POSTGRES_CACHE keeps the
+// downloaded binaries between runs.
+func startEmbeddedPostgres() (*embeddedpostgres.EmbeddedPostgres, string, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, "", err
+	}
+	port := uint32(l.Addr().(*net.TCPAddr).Port)
+	_ = l.Close()
+
+	dir, err := os.MkdirTemp("", "persons-postgres")
+	if err != nil {
+		return nil, "", err
+	}
+
+	cfg := embeddedpostgres.DefaultConfig().
+		Port(port).
+		RuntimePath(dir).
+		Logger(nil)
+	if cache := os.Getenv("EMBEDDED_POSTGRES_CACHE"); cache != "" {
+		cfg = cfg.CachePath(cache)
+	}
+
+	pg := embeddedpostgres.NewDatabase(cfg)
+	if err = pg.Start(); err != nil {
+		return nil, "", err
+	}
+
+	return pg, fmt.Sprintf("host=127.0.0.1 port=%d user=postgres password=postgres dbname=postgres sslmode=disable", port), nil
+}
@@ -0,0 +1,22 @@
+package pgutil
+
+import (
+	"context"
+	"github.com/jmoiron/sqlx"
+)
+
+// ConnRouter picks the connection for a query, e.g. a replica for reads.
+type ConnRouter interface {
+	Writer(ctx context.Context) *sqlx.DB
+	Reader(ctx context.Context) *sqlx.DB
+	MarkWrite(ctx context.Context)
+}
+
+// SingleConn routes every query to Conn.
+type SingleConn struct {
+	Conn *sqlx.DB
+}
+
+func (s SingleConn) Writer(ctx context.Context) *sqlx.DB { return s.Conn }
+func (s SingleConn) Reader(ctx context.Context) *sqlx.DB { return s.Conn }
+func (s SingleConn) MarkWrite(ctx context.Context)       {}
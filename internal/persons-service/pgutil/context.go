@@ -0,0 +1,14 @@
+package pgutil
+
+import (
+	"context"
+	"github.com/pkg/errors"
+)
+
+// CheckContext fails fast if ctx is already done, for storages whose calls are not cancellable.
+func CheckContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return errors.Wrap(err, "context is done")
+	}
+	return nil
+}
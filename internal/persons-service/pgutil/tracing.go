@@ -0,0 +1,30 @@
+package pgutil
+
+import (
+	"context"
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// QueryTracer traces the queries of a repository.
+type QueryTracer struct {
+	tracer     trace.Tracer
+	repository string
+}
+
+// NewQueryTracer returns a QueryTracer with the tracer of the package with path pkg, naming
+// the spans after repository, e.g. "person.repository".
+func NewQueryTracer(pkg, repository string) QueryTracer {
+	return QueryTracer{tracer: otel.Tracer(pkg), repository: repository}
+}
+
+// StartQuerySpan starts the span of query, run by method of the repository.
+func (t QueryTracer) StartQuerySpan(ctx context.Context, method, query string) (context.Context, trace.Span) {
+	return tracing.StartQuerySpan(ctx, t.tracer, t.repository, method, query)
+}
+
+// EndQuerySpan records err on span, if any, and ends it.
+func (t QueryTracer) EndQuerySpan(span trace.Span, err error) {
+	tracing.EndSpan(span, err)
+}
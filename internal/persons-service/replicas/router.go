@@ -0,0 +1,159 @@
+package replicas
+
+import (
+	"context"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"sync/atomic"
+	"time"
+)
+
+const defaultCheckTimeout = 2 * time.Second
+
+var errReplicaLag = errors.New("replica lag is too high")
+
+type replica struct {
+	name    string
+	db      *sqlx.DB
+	healthy atomic.Bool
+}
+
+// Router sends writes to the primary and spreads reads over the healthy replicas. Reads fall
+// back to the primary when no replica is healthy or the caller has written recently.
+type Router struct {
+	primary       *sqlx.DB
+	replicas      []*replica
+	next          atomic.Uint64
+	checkInterval time.Duration
+	maxLag        time.Duration
+	stickyWindow  time.Duration
+	started       atomic.Bool
+	stop          chan struct{}
+	stopped       chan struct{}
+}
+
+// NewRouter creates a router. Replicas start as unhealthy until Run has checked them and are
+// re-checked every checkInterval; a replica lagging more than maxLag behind is unhealthy, zero disables the
+// lag check. After a write the session reads from the primary for stickyWindow.
+func NewRouter(primary *sqlx.DB, replicaDBs map[string]*sqlx.DB, checkInterval, maxLag, stickyWindow time.Duration) *Router {
+	r := &Router{
+		primary:       primary,
+		checkInterval: checkInterval,
+		maxLag:        maxLag,
+		stickyWindow:  stickyWindow,
+		stop:          make(chan struct{}),
+		stopped:       make(chan struct{}),
+	}
+	for name, db := range replicaDBs {
+		rep := &replica{name: name, db: db}
+		r.replicas = append(r.replicas, rep)
+	}
+	return r
+}
+
+func (r *Router) Writer(ctx context.Context) *sqlx.DB {
+	return r.primary
+}
+
+func (r *Router) Reader(ctx context.Context) *sqlx.DB {
+	if len(r.replicas) == 0 {
+		return r.primary
+	}
+	if r.Sticky(ctx) {
+		return r.primary
+	}
+
+	start := r.next.Add(1)
+	for i := 0; i < len(r.replicas); i++ {
+		rep := r.replicas[(start+uint64(i))%uint64(len(r.replicas))]
+		if rep.healthy.Load() {
+			return rep.db
+		}
+	}
+
+	return r.primary
+}
+
+// Sticky reports whether the session in ctx has written recently and reads from the primary
+// until the replicas have caught up.
+func (r *Router) Sticky(ctx context.Context) bool {
+	s := sessionFromContext(ctx)
+	return len(r.replicas) > 0 && s != nil && s.wroteWithin(r.stickyWindow)
+}
+
+// MarkWrite records a write of the session in ctx, so its next reads see it.
+func (r *Router) MarkWrite(ctx context.Context) {
+	if s := sessionFromContext(ctx); s != nil {
+		s.markWrite()
+	}
+}
+
+// Run checks the replicas until Stop is called.
+func (r *Router) Run() error {
+	r.started.Store(true)
+	defer close(r.stopped)
+	if len(r.replicas) == 0 {
+		<-r.stop
+		return nil
+	}
+
+	r.checkReplicas()
+	ticker := time.NewTicker(r.checkInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			r.checkReplicas()
+		case <-r.stop:
+			return nil
+		}
+	}
+}
+
+func (r *Router) Stop(ctx context.Context) error {
+	close(r.stop)
+	if !r.started.Load() {
+		return nil
+	}
+	select {
+	case <-r.stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *Router) checkReplicas() {
+	for _, rep := range r.replicas {
+		err := r.checkReplica(rep)
+		healthy := err == nil
+		if rep.healthy.Swap(healthy) != healthy {
+			if healthy {
+				log.Info().Str("replica", rep.name).Msg("replica is healthy again")
+			} else {
+				log.Warn().Err(err).Str("replica", rep.name).Msg("replica is unhealthy, reads fall back")
+			}
+		}
+	}
+}
+
+func (r *Router) checkReplica(rep *replica) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultCheckTimeout)
+	defer cancel()
+
+	if r.maxLag <= 0 {
+		return rep.db.PingContext(ctx)
+	}
+
+	var lag float64
+	err := rep.db.QueryRowContext(ctx,
+		"SELECT COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)").Scan(&lag)
+	if err != nil {
+		return err
+	}
+	if time.Duration(lag*float64(time.Second)) > r.maxLag {
+		return errReplicaLag
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package replicas
+
+import (
+	"context"
+	"github.com/jmoiron/sqlx"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func Test_RouterReader(t *testing.T) {
+	primary, first, second := &sqlx.DB{}, &sqlx.DB{}, &sqlx.DB{}
+
+	t.Run("no replicas", func(t *testing.T) {
+		r := NewRouter(primary, nil, time.Second, 0, time.Second)
+		require.Same(t, primary, r.Reader(context.Background()))
+		require.Same(t, primary, r.Writer(context.Background()))
+	})
+
+	t.Run("unhealthy replicas fall back to primary", func(t *testing.T) {
+		r := NewRouter(primary, map[string]*sqlx.DB{"first": first}, time.Second, 0, time.Second)
+		require.Same(t, primary, r.Reader(context.Background()))
+	})
+
+	t.Run("healthy replicas are used in turn", func(t *testing.T) {
+		r := NewRouter(primary, map[string]*sqlx.DB{"first": first, "second": second}, time.Second, 0, time.Second)
+		for _, rep := range r.replicas {
+			rep.healthy.Store(true)
+		}
+
+		seen := map[*sqlx.DB]int{}
+		for i := 0; i < 4; i++ {
+			seen[r.Reader(context.Background())]++
+		}
+		require.Equal(t, map[*sqlx.DB]int{first: 2, second: 2}, seen)
+
+		r.replicas[0].healthy.Store(false)
+		require.Same(t, r.replicas[1].db, r.Reader(context.Background()))
+	})
+
+	t.Run("session reads its writes from primary", func(t *testing.T) {
+		r := NewRouter(primary, map[string]*sqlx.DB{"first": first}, time.Second, 0, time.Minute)
+		r.replicas[0].healthy.Store(true)
+
+		ctx := WithSession(context.Background())
+		require.Same(t, first, r.Reader(ctx))
+
+		require.False(t, r.Sticky(ctx))
+
+		r.MarkWrite(ctx)
+		require.Same(t, primary, r.Reader(ctx))
+		require.True(t, r.Sticky(ctx))
+		require.Same(t, first, r.Reader(context.Background()))
+		require.False(t, r.Sticky(context.Background()))
+	})
+}
+
+func Test_RouterMiddleware(t *testing.T) {
+	primary, first := &sqlx.DB{}, &sqlx.DB{}
+	r := NewRouter(primary, map[string]*sqlx.DB{"first": first}, time.Second, 0, time.Minute)
+	r.replicas[0].healthy.Store(true)
+
+	var used *sqlx.DB
+	e := echo.New()
+	e.Use(r.NewHTTPMiddleware())
+	e.POST("/", func(c echo.Context) error {
+		r.MarkWrite(c.Request().Context())
+		return c.NoContent(http.StatusCreated)
+	})
+	e.GET("/", func(c echo.Context) error {
+		used = r.Reader(c.Request().Context())
+		return c.NoContent(http.StatusOK)
+	})
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Same(t, first, used)
+	require.Empty(t, rec.Result().Cookies())
+
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", nil))
+	cookies := rec.Result().Cookies()
+	require.Len(t, cookies, 1)
+	require.Equal(t, SessionCookie, cookies[0].Name)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(cookies[0])
+	e.ServeHTTP(httptest.NewRecorder(), req)
+	require.Same(t, primary, used)
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: SessionCookie, Value: strconv.FormatInt(time.Now().Add(-time.Hour).UnixNano(), 10)})
+	e.ServeHTTP(httptest.NewRecorder(), req)
+	require.Same(t, first, used)
+}
@@ -0,0 +1,78 @@
+package replicas
+
+import (
+	"context"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// SessionCookie carries the time of the client's last write between requests, so a client
+// keeps reading from the primary until replicas have caught up with its own writes.
+const SessionCookie = "persons_last_write"
+
+type sessionKey struct{}
+
+type session struct {
+	mu        sync.Mutex
+	lastWrite time.Time
+	wrote     bool
+}
+
+func (s *session) markWrite() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastWrite = time.Now()
+	s.wrote = true
+}
+
+func (s *session) wroteWithin(window time.Duration) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return !s.lastWrite.IsZero() && time.Since(s.lastWrite) < window
+}
+
+func sessionFromContext(ctx context.Context) *session {
+	s, _ := ctx.Value(sessionKey{}).(*session)
+	return s
+}
+
+// WithSession returns a context carrying a fresh read-your-writes session.
+func WithSession(ctx context.Context) context.Context {
+	return context.WithValue(ctx, sessionKey{}, &session{})
+}
+
+// NewHTTPMiddleware restores the session of the client from SessionCookie and sets the cookie
+// when the request wrote something.
+func (r *Router) NewHTTPMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			s := &session{}
+			if cookie, err := c.Cookie(SessionCookie); err == nil {
+				if nanos, err := strconv.ParseInt(cookie.Value, 10, 64); err == nil {
+					s.lastWrite = time.Unix(0, nanos)
+				}
+			}
+			c.SetRequest(c.Request().WithContext(context.WithValue(c.Request().Context(), sessionKey{}, s)))
+
+			c.Response().Before(func() {
+				s.mu.Lock()
+				defer s.mu.Unlock()
+				if !s.wrote {
+					return
+				}
+				c.SetCookie(&http.Cookie{
+					Name:     SessionCookie,
+					Value:    strconv.FormatInt(s.lastWrite.UnixNano(), 10),
+					Path:     "/",
+					MaxAge:   int(r.stickyWindow.Seconds()) + 1,
+					HttpOnly: true,
+				})
+			})
+
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package tracing
+
+import (
+	"errors"
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+)
+
+const instrumentationName = "github.com/Erlendum/rsoi-lab-01/internal/persons-service/tracing"
+
+// NewHTTPMiddleware returns an echo middleware that continues the trace from the incoming
+// W3C traceparent header and wraps the request in a server span.
+func NewHTTPMiddleware() echo.MiddlewareFunc {
+	tracer := otel.Tracer(instrumentationName)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			ctx := otel.GetTextMapPropagator().Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+
+			route := c.Path()
+			if route == "" {
+				route = req.URL.Path
+			}
+
+			ctx, span := tracer.Start(ctx, req.Method+" "+route,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(
+					semconv.HTTPRequestMethodKey.String(req.Method),
+					semconv.HTTPRoute(route),
+					semconv.URLPath(req.URL.Path),
+				),
+			)
+			defer span.End()
+
+			c.SetRequest(req.WithContext(ctx))
+
+			err := next(c)
+
+			status := c.Response().Status
+			if err != nil {
+				var httpErr *echo.HTTPError
+				if errors.As(err, &httpErr) {
+					status = httpErr.Code
+				} else {
+					status = http.StatusInternalServerError
+				}
+				span.RecordError(err)
+			}
+			span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+			if status >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(status))
+			}
+
+			return err
+		}
+	}
+}
+
+// Handler wraps an echo handler in an internal span named after it, so the handler work is
+// visible separately from the middleware chain.
+func Handler(name string, next echo.HandlerFunc) echo.HandlerFunc {
+	tracer := otel.Tracer(instrumentationName)
+
+	return func(c echo.Context) error {
+		ctx, span := tracer.Start(c.Request().Context(), name, trace.WithAttributes(attribute.String("handler", name)))
+		defer span.End()
+
+		c.SetRequest(c.Request().WithContext(ctx))
+
+		err := next(c)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		} else if c.Response().Status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(c.Response().Status))
+		}
+		return err
+	}
+}
@@ -0,0 +1,54 @@
+package tracing
+
+import (
+	"context"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"regexp"
+	"strings"
+)
+
+var (
+	sqlStringLiteral  = regexp.MustCompile(`'(?:[^']|'')*'`)
+	sqlNumericLiteral = regexp.MustCompile(`\$?\b\d+(?:\.\d+)?\b`)
+	sqlWhitespace     = regexp.MustCompile(`\s+`)
+)
+
+// sanitizeSQL masks literals, so values never leak into span attributes even if a query is
+// built without placeholders.
+func sanitizeSQL(query string) string {
+	query = sqlStringLiteral.ReplaceAllString(query, "?")
+	query = sqlNumericLiteral.ReplaceAllStringFunc(query, func(s string) string {
+		if strings.HasPrefix(s, "$") {
+			return s
+		}
+		return "?"
+	})
+	return strings.TrimSpace(sqlWhitespace.ReplaceAllString(query, " "))
+}
+
+// StartQuerySpan starts the span of a repository query with tracer and logs the query with
+// the request-scoped logger from ctx. The span is named "<repository>.<method>".
+func StartQuerySpan(ctx context.Context, tracer trace.Tracer, repository, method, query string) (context.Context, trace.Span) {
+	query = sanitizeSQL(query)
+	zerolog.Ctx(ctx).Debug().Str("repository_method", method).Str("query", query).Msg("executing query")
+
+	return tracer.Start(ctx, repository+"."+method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBQueryText(query),
+		),
+	)
+}
+
+// EndSpan records err on span, if any, and ends it.
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
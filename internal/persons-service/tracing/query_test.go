@@ -0,0 +1,36 @@
+package tracing
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func Test_sanitizeSQL(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		expected string
+	}{
+		{
+			name:     "placeholders are kept",
+			query:    "SELECT id, name FROM persons WHERE id = $1",
+			expected: "SELECT id, name FROM persons WHERE id = $1",
+		},
+		{
+			name:     "literals are masked",
+			query:    "UPDATE persons SET name = 'O''Brien', age = 42 WHERE id = 7",
+			expected: "UPDATE persons SET name = ?, age = ? WHERE id = ?",
+		},
+		{
+			name:     "whitespace is collapsed",
+			query:    "SELECT id\n\tFROM persons ",
+			expected: "SELECT id FROM persons",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, sanitizeSQL(tt.query))
+		})
+	}
+}
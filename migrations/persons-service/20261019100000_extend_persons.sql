@@ -0,0 +1,32 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE persons
+    ALTER COLUMN age DROP NOT NULL,
+    ADD CONSTRAINT persons_age_non_negative CHECK (age >= 0),
+    ADD COLUMN birth_date date,
+    ADD COLUMN email text,
+    ADD COLUMN phone_numbers text[],
+    ADD COLUMN address_street text,
+    ADD COLUMN address_city text,
+    ADD COLUMN address_postal_code text,
+    ADD COLUMN address_country text,
+    ADD COLUMN created_at timestamptz not null default now(),
+    ADD COLUMN updated_at timestamptz not null default now();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+UPDATE persons SET age = 0 WHERE age IS NULL;
+ALTER TABLE persons
+    DROP COLUMN updated_at,
+    DROP COLUMN created_at,
+    DROP COLUMN address_country,
+    DROP COLUMN address_postal_code,
+    DROP COLUMN address_city,
+    DROP COLUMN address_street,
+    DROP COLUMN phone_numbers,
+    DROP COLUMN email,
+    DROP COLUMN birth_date,
+    DROP CONSTRAINT persons_age_non_negative,
+    ALTER COLUMN age SET NOT NULL;
+-- +goose StatementEnd
@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS person_aliases(
+    alias_id int primary key,
+    person_id int not null references persons(id) on delete cascade
+);
+CREATE INDEX IF NOT EXISTS person_aliases_person_id_idx ON person_aliases (person_id);
+CREATE INDEX IF NOT EXISTS persons_lower_name_idx ON persons (lower(name));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS persons_lower_name_idx;
+DROP TABLE IF EXISTS person_aliases;
+-- +goose StatementEnd
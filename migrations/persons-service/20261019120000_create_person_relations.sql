@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS person_relations(
+    id serial primary key,
+    person_id int not null references persons(id) on delete cascade,
+    related_person_id int not null references persons(id) on delete cascade,
+    type text not null,
+    bidirectional boolean not null default false,
+    created_at timestamptz not null default now(),
+    CONSTRAINT person_relations_not_self CHECK (person_id <> related_person_id),
+    CONSTRAINT person_relations_unique UNIQUE (person_id, related_person_id, type)
+);
+CREATE INDEX IF NOT EXISTS person_relations_related_person_id_idx ON person_relations (related_person_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS person_relations;
+-- +goose StatementEnd
@@ -0,0 +1,37 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS organizations(
+    id serial primary key,
+    name text not null,
+    created_at timestamptz not null default now(),
+    updated_at timestamptz not null default now(),
+    CONSTRAINT organizations_name_unique UNIQUE (name)
+);
+CREATE TABLE IF NOT EXISTS employments(
+    id serial primary key,
+    person_id int not null,
+    organization_id int not null,
+    role text,
+    start_date date,
+    end_date date,
+    created_at timestamptz not null default now(),
+    CONSTRAINT employments_person_id_fkey FOREIGN KEY (person_id) REFERENCES persons(id) ON DELETE CASCADE,
+    CONSTRAINT employments_organization_id_fkey FOREIGN KEY (organization_id) REFERENCES organizations(id) ON DELETE CASCADE,
+    CONSTRAINT employments_dates_check CHECK (end_date IS NULL OR start_date IS NULL OR end_date >= start_date)
+);
+CREATE INDEX IF NOT EXISTS employments_person_id_idx ON employments (person_id);
+CREATE INDEX IF NOT EXISTS employments_organization_id_idx ON employments (organization_id);
+
+-- persons.work stays the denormalized name of the current employer; seed the history from it.
+INSERT INTO organizations (name)
+SELECT DISTINCT work FROM persons WHERE work IS NOT NULL AND work <> ''
+ON CONFLICT (name) DO NOTHING;
+INSERT INTO employments (person_id, organization_id)
+SELECT p.id, o.id FROM persons p JOIN organizations o ON o.name = p.work;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS employments;
+DROP TABLE IF EXISTS organizations;
+-- +goose StatementEnd
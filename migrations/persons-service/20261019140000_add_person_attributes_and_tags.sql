@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE persons
+    ADD COLUMN attributes jsonb,
+    ADD COLUMN tags text[],
+    ADD CONSTRAINT persons_attributes_object CHECK (jsonb_typeof(attributes) = 'object');
+-- jsonb_path_ops only supports @>, the operator of the attribute filters, and is smaller and
+-- faster than the default jsonb_ops.
+CREATE INDEX IF NOT EXISTS persons_attributes_idx ON persons USING GIN (attributes jsonb_path_ops);
+CREATE INDEX IF NOT EXISTS persons_tags_idx ON persons USING GIN (tags);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS persons_tags_idx;
+DROP INDEX IF EXISTS persons_attributes_idx;
+ALTER TABLE persons
+    DROP CONSTRAINT persons_attributes_object,
+    DROP COLUMN tags,
+    DROP COLUMN attributes;
+-- +goose StatementEnd
@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS attachments(
+    id serial primary key,
+    person_id int not null,
+    kind text not null,
+    file_name text not null,
+    content_type text not null,
+    size bigint not null,
+    blob_key text not null,
+    thumbnail_key text,
+    created_at timestamptz not null default now(),
+    CONSTRAINT attachments_person_id_fkey FOREIGN KEY (person_id) REFERENCES persons(id) ON DELETE CASCADE,
+    CONSTRAINT attachments_kind_check CHECK (kind IN ('document', 'photo')),
+    CONSTRAINT attachments_size_check CHECK (size >= 0),
+    CONSTRAINT attachments_blob_key_unique UNIQUE (blob_key)
+);
+CREATE INDEX IF NOT EXISTS attachments_person_id_idx ON attachments (person_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS attachments;
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+-- The blocking keys of the duplicate search: only the persons sharing the email, a phone number
+-- or a word of the name with a person are scored against it. The words are split at the
+-- separators of normalizeText in person/duplicates.go.
+CREATE INDEX IF NOT EXISTS persons_email_lower_idx ON persons (lower(email));
+CREATE INDEX IF NOT EXISTS persons_phone_numbers_idx ON persons USING GIN (phone_numbers);
+CREATE INDEX IF NOT EXISTS persons_name_words_idx ON persons USING GIN (regexp_split_to_array(lower(name), '[ \t\n.,;:_''"()/-]+'));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS persons_name_words_idx;
+DROP INDEX IF EXISTS persons_phone_numbers_idx;
+DROP INDEX IF EXISTS persons_email_lower_idx;
+-- +goose StatementEnd
@@ -0,0 +1,8 @@
+package migrations
+
+import "embed"
+
+// FS holds the SQL migrations of the persons service, so the binary can apply them without goose installed.
+//
+//go:embed *.sql
+var FS embed.FS
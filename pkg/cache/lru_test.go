@@ -0,0 +1,74 @@
+package cache
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func Test_LRU(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("evicts least recently used", func(t *testing.T) {
+		c := NewLRU(2)
+		require.NoError(t, c.Set(ctx, "a", []byte("a"), 0))
+		require.NoError(t, c.Set(ctx, "b", []byte("b"), 0))
+
+		_, ok, _ := c.Get(ctx, "a")
+		require.True(t, ok)
+
+		require.NoError(t, c.Set(ctx, "c", []byte("c"), 0))
+		require.Equal(t, 2, c.Len())
+
+		_, ok, _ = c.Get(ctx, "b")
+		require.False(t, ok)
+		_, ok, _ = c.Get(ctx, "a")
+		require.True(t, ok)
+	})
+
+	t.Run("expires entries", func(t *testing.T) {
+		now := time.Now()
+		c := NewLRU(2)
+		c.now = func() time.Time { return now }
+
+		require.NoError(t, c.Set(ctx, "a", []byte("a"), time.Second))
+
+		_, ok, _ := c.Get(ctx, "a")
+		require.True(t, ok)
+
+		now = now.Add(time.Second)
+		_, ok, _ = c.Get(ctx, "a")
+		require.False(t, ok)
+		require.Equal(t, 0, c.Len())
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		c := NewLRU(2)
+		require.NoError(t, c.Set(ctx, "a", []byte("a"), 0))
+		require.NoError(t, c.Delete(ctx, "a", "missing"))
+
+		_, ok, _ := c.Get(ctx, "a")
+		require.False(t, ok)
+	})
+}
+
+func Test_Tiered(t *testing.T) {
+	ctx := context.Background()
+	local, remote := NewLRU(10), NewLRU(10)
+	c := NewTiered(time.Minute, local, remote)
+
+	require.NoError(t, remote.Set(ctx, "a", []byte("a"), 0))
+
+	value, ok, err := c.Get(ctx, "a")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, []byte("a"), value)
+
+	_, ok, _ = local.Get(ctx, "a")
+	require.True(t, ok)
+
+	require.NoError(t, c.Delete(ctx, "a"))
+	_, ok, _ = remote.Get(ctx, "a")
+	require.False(t, ok)
+}
@@ -0,0 +1,60 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Tiered looks caches up in order, e.g. an in-process LRU before a shared remote cache, and
+// fills the faster tiers for fillTTL on a hit in a slower one.
+type Tiered struct {
+	caches  []Cache
+	fillTTL time.Duration
+}
+
+func NewTiered(fillTTL time.Duration, caches ...Cache) *Tiered {
+	return &Tiered{caches: caches, fillTTL: fillTTL}
+}
+
+func (t *Tiered) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	var errs []error
+	for i, c := range t.caches {
+		value, ok, err := c.Get(ctx, key)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		for _, faster := range t.caches[:i] {
+			if err = faster.Set(ctx, key, value, t.fillTTL); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return value, true, errors.Join(errs...)
+	}
+
+	return nil, false, errors.Join(errs...)
+}
+
+func (t *Tiered) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	var errs []error
+	for _, c := range t.caches {
+		if err := c.Set(ctx, key, value, ttl); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (t *Tiered) Delete(ctx context.Context, keys ...string) error {
+	var errs []error
+	for _, c := range t.caches {
+		if err := c.Delete(ctx, keys...); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
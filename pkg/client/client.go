@@ -0,0 +1,281 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultTimeout = 10 * time.Second
+	// maxResponseSize bounds the bodies read from the service.
+	maxResponseSize = 32 << 20
+)
+
+// RequestEditorFn changes a request before it is sent, e.g. to authenticate it. It is called
+// again for every retry of the request.
+type RequestEditorFn func(ctx context.Context, req *http.Request) error
+
+// BearerToken authenticates the requests with token in the Authorization header.
+func BearerToken(token string) RequestEditorFn {
+	return func(_ context.Context, req *http.Request) error {
+		req.Header.Set("Authorization", "Bearer "+token)
+		return nil
+	}
+}
+
+// Tenant sets the tenant whose schema the attributes of created and updated persons are
+// validated against.
+func Tenant(tenant string) RequestEditorFn {
+	return func(_ context.Context, req *http.Request) error {
+		req.Header.Set("X-Tenant-ID", tenant)
+		return nil
+	}
+}
+
+// RetryPolicy tells how requests that failed on the way or with a status that may pass later
+// (429, 502, 503 and 504) are repeated. Only requests that can be repeated safely are retried,
+// creating a person is not.
+type RetryPolicy struct {
+	// MaxAttempts counts the first attempt too; 1 disables retries.
+	MaxAttempts int
+	// MinBackoff is the longest wait before the first retry, the waits are random and double
+	// with every retry up to MaxBackoff. A Retry-After header of the response is honoured up to
+	// MaxBackoff.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// DefaultRetryPolicy is used unless WithRetryPolicy sets another one.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	MinBackoff:  100 * time.Millisecond,
+	MaxBackoff:  2 * time.Second,
+}
+
+// Client calls the persons API of the persons service. It is safe for concurrent use.
+type Client struct {
+	baseURL    *url.URL
+	httpClient *http.Client
+	timeout    time.Duration
+	retry      RetryPolicy
+	editors    []RequestEditorFn
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithHTTPClient sends the requests with httpClient instead of http.DefaultClient.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithTimeout bounds every attempt of a request, 10 seconds by default. The context passed to
+// the methods bounds the request with all of its retries.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = timeout
+	}
+}
+
+// WithRetryPolicy replaces DefaultRetryPolicy.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *Client) {
+		c.retry = policy
+	}
+}
+
+// WithRequestEditor applies editor to every request of the client, before the editors passed to
+// a method.
+func WithRequestEditor(editor RequestEditorFn) Option {
+	return func(c *Client) {
+		c.editors = append(c.editors, editor)
+	}
+}
+
+// New creates a client of the service at baseURL, e.g. http://localhost:8018.
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse base url")
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, errors.Errorf("base url %q must be absolute", baseURL)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+
+	c := &Client{
+		baseURL:    u,
+		httpClient: http.DefaultClient,
+		timeout:    defaultTimeout,
+		retry:      DefaultRetryPolicy,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.retry.MaxAttempts < 1 {
+		c.retry.MaxAttempts = 1
+	}
+
+	return c, nil
+}
+
+// response is a response read in full, so the attempt that got it can be finished.
+type response struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// do sends a request with body encoded as JSON unless it is nil, retrying it as the policy
+// allows. Responses with an error status are returned as *Error.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body interface{}, editors []RequestEditorFn) (*response, error) {
+	var payload []byte
+	if body != nil {
+		var err error
+		payload, err = json.Marshal(body)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to marshal request")
+		}
+	}
+
+	u := *c.baseURL
+	u.Path += path
+	u.RawQuery = query.Encode()
+
+	editors = append(append([]RequestEditorFn{}, c.editors...), editors...)
+	retryable := method != http.MethodPost
+	for attempt := 1; ; attempt++ {
+		resp, err := c.send(ctx, method, u.String(), payload, editors)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		var retryAfter time.Duration
+		switch {
+		case err != nil:
+			var editorErr *editorError
+			if errors.As(err, &editorErr) {
+				return nil, editorErr.err
+			}
+		case isRetryableStatus(resp.status):
+			retryAfter = parseRetryAfter(resp.header.Get("Retry-After"))
+		default:
+			if resp.status >= http.StatusBadRequest {
+				return nil, decodeError(resp)
+			}
+			return resp, nil
+		}
+
+		if !retryable || attempt >= c.retry.MaxAttempts {
+			if err != nil {
+				return nil, err
+			}
+			return nil, decodeError(resp)
+		}
+
+		timer := time.NewTimer(c.backoff(attempt, retryAfter))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// editorError marks the errors of request editors, which are not retried.
+type editorError struct {
+	err error
+}
+
+func (e *editorError) Error() string {
+	return e.err.Error()
+}
+
+// send makes one attempt of a request.
+func (c *Client) send(ctx context.Context, method, u string, payload []byte, editors []RequestEditorFn) (*response, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create request")
+	}
+	req.Header.Set("Accept", "application/json")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for _, editor := range editors {
+		if err = editor(ctx, req); err != nil {
+			return nil, &editorError{err: errors.Wrap(err, "failed to edit request")}
+		}
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to send %s request", method)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read response")
+	}
+
+	return &response{status: resp.StatusCode, header: resp.Header, body: respBody}, nil
+}
+
+func isRetryableStatus(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// parseRetryAfter reads a Retry-After header in seconds or as a date; it is 0 if there is none.
+func parseRetryAfter(header string) time.Duration {
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(header); err == nil {
+		return max(time.Until(date), 0)
+	}
+	return 0
+}
+
+// backoff is the wait before the retry following attempt: the retry after time of the server if
+// it gave one, a random wait of up to MinBackoff doubled with every attempt otherwise. Both are
+// capped at MaxBackoff.
+func (c *Client) backoff(attempt int, retryAfter time.Duration) time.Duration {
+	if retryAfter > 0 {
+		return min(retryAfter, c.retry.MaxBackoff)
+	}
+
+	ceiling := c.retry.MinBackoff << (attempt - 1)
+	if ceiling <= 0 || ceiling > c.retry.MaxBackoff {
+		ceiling = c.retry.MaxBackoff
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(ceiling) + 1))
+}
@@ -0,0 +1,276 @@
+package client
+
+import (
+	"context"
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/config"
+	"github.com/Erlendum/rsoi-lab-01/internal/persons-service/person"
+	"github.com/Erlendum/rsoi-lab-01/pkg/validation"
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestServer serves the persons API of the service on a memory storage, behind middlewares
+// that let the tests interfere with the requests.
+func newTestServer(t *testing.T, middlewares ...echo.MiddlewareFunc) *httptest.Server {
+	e := echo.New()
+	e.Validator = validation.MustRegisterCustomValidator(validator.New())
+	e.Use(middlewares...)
+
+	storage := person.NewMemoryRepository()
+	require.NoError(t, storage.SetUniqueConstraints([][]string{{"email"}}))
+	h, err := person.NewHandler(storage, &config.Persons{})
+	require.NoError(t, err)
+	h.Register(e)
+
+	srv := httptest.NewServer(e)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newTestClient(t *testing.T, srv *httptest.Server, opts ...Option) *Client {
+	opts = append([]Option{WithRetryPolicy(RetryPolicy{MaxAttempts: 3, MinBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond})}, opts...)
+	c, err := New(srv.URL, opts...)
+	require.NoError(t, err)
+	return c
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func Test_Client_CRUD(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t, newTestServer(t))
+
+	id, err := c.CreatePerson(ctx, PersonRequest{
+		Name:         ptr("Ivan"),
+		Age:          ptr(30),
+		Email:        ptr("ivan@example.com"),
+		AddressParts: &AddressParts{City: ptr("Moscow")},
+		Tags:         []string{"vip"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, id)
+
+	p, err := c.GetPerson(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, "Ivan", p.Name)
+	require.Equal(t, 30, p.Age)
+	require.Equal(t, "ivan@example.com", *p.Email)
+	require.Equal(t, []string{"vip"}, p.Tags)
+
+	p, err = c.UpdatePerson(ctx, id, PersonRequest{Name: ptr("Ivan Petrov"), Work: ptr("Acme")})
+	require.NoError(t, err)
+	require.Equal(t, "Ivan Petrov", p.Name)
+	require.Equal(t, "Acme", p.Work)
+	require.Equal(t, 30, p.Age)
+
+	page, err := c.ListPersons(ctx, ListOptions{Tags: []string{"vip"}})
+	require.NoError(t, err)
+	require.Equal(t, 1, page.Total)
+	require.Len(t, page.Persons, 1)
+	require.Equal(t, "Ivan Petrov", page.Persons[0].Name)
+
+	require.NoError(t, c.DeletePerson(ctx, id))
+
+	_, err = c.GetPerson(ctx, id)
+	require.True(t, IsNotFound(err))
+	require.True(t, IsNotFound(c.DeletePerson(ctx, id)))
+}
+
+func Test_Client_Errors(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t, newTestServer(t))
+
+	_, err := c.CreatePerson(ctx, PersonRequest{Age: ptr(200)})
+	var apiErr *Error
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+	require.Equal(t, "validation error", apiErr.Message)
+	require.Contains(t, apiErr.Fields, "name")
+	require.Contains(t, apiErr.Fields, "age")
+
+	id, err := c.CreatePerson(ctx, PersonRequest{Name: ptr("Ivan"), Email: ptr("ivan@example.com")})
+	require.NoError(t, err)
+	_, err = c.CreatePerson(ctx, PersonRequest{Name: ptr("Ivan"), Email: ptr("ivan@example.com")})
+	require.True(t, IsConflict(err))
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, "person already exists", apiErr.Message)
+	require.Equal(t, "/api/v1/persons/1", apiErr.Location)
+
+	_, err = c.UpdatePerson(ctx, 100, PersonRequest{Name: ptr("Petr")})
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+	require.Equal(t, "person not found", apiErr.Message)
+
+	_, err = c.ListPersons(ctx, ListOptions{Tags: []string{""}})
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+	require.Equal(t, "tag must not be empty", apiErr.Message)
+
+	require.NoError(t, c.DeletePerson(ctx, id))
+}
+
+func Test_decodeError(t *testing.T) {
+	tests := []struct {
+		name     string
+		resp     *response
+		expected *Error
+	}{
+		{
+			name: "problem details",
+			resp: &response{
+				status: http.StatusTooManyRequests,
+				header: http.Header{"Content-Type": {"application/problem+json"}},
+				body:   []byte(`{"type": "about:blank", "title": "Too Many Requests", "detail": "rate limit exceeded"}`),
+			},
+			expected: &Error{StatusCode: http.StatusTooManyRequests, Message: "rate limit exceeded"},
+		},
+		{
+			name: "not json",
+			resp: &response{
+				status: http.StatusBadGateway,
+				header: http.Header{"Content-Type": {"text/html"}},
+				body:   []byte(`<html>bad gateway</html>`),
+			},
+			expected: &Error{StatusCode: http.StatusBadGateway, Message: "Bad Gateway"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, decodeError(tt.resp))
+		})
+	}
+}
+
+func Test_Client_IteratePersons(t *testing.T) {
+	ctx := context.Background()
+	var requests atomic.Int32
+	c := newTestClient(t, newTestServer(t, func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if c.Request().Method == http.MethodGet {
+				requests.Add(1)
+			}
+			return next(c)
+		}
+	}))
+
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		_, err := c.CreatePerson(ctx, PersonRequest{Name: ptr(name)})
+		require.NoError(t, err)
+	}
+
+	var names []string
+	it := c.IteratePersons(ListOptions{Limit: 2, Offset: 1})
+	for it.Next(ctx) {
+		names = append(names, it.Person().Name)
+	}
+	require.NoError(t, it.Err())
+	require.Equal(t, []string{"b", "c", "d", "e"}, names)
+	require.EqualValues(t, 2, requests.Load())
+
+	it = c.IteratePersons(ListOptions{Tags: []string{""}})
+	require.False(t, it.Next(ctx))
+	require.True(t, it.Err() != nil)
+}
+
+func Test_Client_Retry(t *testing.T) {
+	ctx := context.Background()
+	var attempts atomic.Int32
+	failing := 2
+	srv := newTestServer(t, func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if int(attempts.Add(1)) <= failing {
+				c.Response().Header().Set("Retry-After", "0")
+				return c.JSON(http.StatusServiceUnavailable, echo.Map{"errors": "unavailable"})
+			}
+			return next(c)
+		}
+	})
+	c := newTestClient(t, srv)
+
+	_, err := c.ListPersons(ctx, ListOptions{})
+	require.NoError(t, err)
+	require.EqualValues(t, 3, attempts.Load())
+
+	attempts.Store(0)
+	failing = 3
+	_, err = c.ListPersons(ctx, ListOptions{})
+	var apiErr *Error
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, http.StatusServiceUnavailable, apiErr.StatusCode)
+	require.Equal(t, "unavailable", apiErr.Message)
+	require.EqualValues(t, 3, attempts.Load())
+
+	// Creating a person is not repeated, the first attempt may have created it.
+	attempts.Store(0)
+	failing = 1
+	_, err = c.CreatePerson(ctx, PersonRequest{Name: ptr("Ivan")})
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, http.StatusServiceUnavailable, apiErr.StatusCode)
+	require.EqualValues(t, 1, attempts.Load())
+}
+
+func Test_Client_Timeout(t *testing.T) {
+	ctx := context.Background()
+	var attempts atomic.Int32
+	srv := newTestServer(t, func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if attempts.Add(1) == 1 {
+				select {
+				case <-c.Request().Context().Done():
+				case <-time.After(time.Second):
+				}
+			}
+			return next(c)
+		}
+	})
+	c := newTestClient(t, srv, WithTimeout(50*time.Millisecond))
+
+	_, err := c.ListPersons(ctx, ListOptions{})
+	require.NoError(t, err)
+	require.EqualValues(t, 2, attempts.Load())
+
+	attempts.Store(0)
+	ctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	_, err = c.ListPersons(ctx, ListOptions{})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func Test_Client_RequestEditors(t *testing.T) {
+	ctx := context.Background()
+	srv := newTestServer(t, func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if c.Request().Header.Get("Authorization") != "Bearer secret" {
+				return c.JSON(http.StatusUnauthorized, echo.Map{"errors": "unauthorized"})
+			}
+			return next(c)
+		}
+	})
+
+	c := newTestClient(t, srv)
+	_, err := c.ListPersons(ctx, ListOptions{})
+	var apiErr *Error
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, http.StatusUnauthorized, apiErr.StatusCode)
+
+	_, err = c.ListPersons(ctx, ListOptions{}, BearerToken("secret"))
+	require.NoError(t, err)
+
+	c = newTestClient(t, srv, WithRequestEditor(BearerToken("secret")))
+	_, err = c.ListPersons(ctx, ListOptions{})
+	require.NoError(t, err)
+
+	_, err = c.ListPersons(ctx, ListOptions{}, func(context.Context, *http.Request) error {
+		return context.Canceled
+	})
+	require.ErrorIs(t, err, context.Canceled)
+}
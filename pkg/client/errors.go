@@ -0,0 +1,103 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Error is a response of the service with an error status.
+type Error struct {
+	StatusCode int
+	// Message describes the error, e.g. "person not found".
+	Message string
+	// Fields has a message for each invalid field of a validation error.
+	Fields map[string]string
+	// Location is the path of the existing person a created or updated one conflicts with.
+	Location string
+}
+
+func (e *Error) Error() string {
+	msg := "persons service: " + strconv.Itoa(e.StatusCode) + " " + e.Message
+	if len(e.Fields) == 0 {
+		return msg
+	}
+
+	fields := make([]string, 0, len(e.Fields))
+	for field, fieldMsg := range e.Fields {
+		fields = append(fields, field+": "+fieldMsg)
+	}
+	sort.Strings(fields)
+	return msg + " (" + strings.Join(fields, ", ") + ")"
+}
+
+// IsNotFound reports whether err is a 404 response of the service.
+func IsNotFound(err error) bool {
+	return hasStatus(err, http.StatusNotFound)
+}
+
+// IsConflict reports whether err is a 409 response of the service, e.g. to a person that clashes
+// with an existing one under a unique constraint.
+func IsConflict(err error) bool {
+	return hasStatus(err, http.StatusConflict)
+}
+
+func hasStatus(err error, status int) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.StatusCode == status
+}
+
+// errorBody covers the error bodies of the service: {"errors": "..."}, validation errors with
+// {"fields": {field: message}} besides, and RFC 7807 problem details, which list the invalid
+// fields under errors.
+type errorBody struct {
+	Errors   json.RawMessage   `json:"errors"`
+	Fields   map[string]string `json:"fields"`
+	Location string            `json:"location"`
+	Title    string            `json:"title"`
+	Detail   string            `json:"detail"`
+}
+
+// decodeError builds the *Error of resp. Bodies that can not be decoded leave the status text
+// as the message.
+func decodeError(resp *response) *Error {
+	apiErr := &Error{
+		StatusCode: resp.status,
+		Message:    http.StatusText(resp.status),
+		Location:   resp.header.Get("Location"),
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.header.Get("Content-Type"))
+	if mediaType != "application/json" && mediaType != "application/problem+json" {
+		return apiErr
+	}
+
+	var body errorBody
+	if json.Unmarshal(resp.body, &body) != nil {
+		return apiErr
+	}
+
+	var message string
+	if json.Unmarshal(body.Errors, &message) == nil && message != "" {
+		apiErr.Message = message
+	} else {
+		_ = json.Unmarshal(body.Errors, &apiErr.Fields)
+	}
+	if body.Fields != nil {
+		apiErr.Fields = body.Fields
+	}
+	if body.Detail != "" {
+		apiErr.Message = body.Detail
+	} else if body.Title != "" {
+		apiErr.Message = body.Title
+	}
+	if body.Location != "" {
+		apiErr.Location = body.Location
+	}
+
+	return apiErr
+}
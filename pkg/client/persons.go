@@ -0,0 +1,233 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"time"
+)
+
+const personsPath = "/api/v1/persons"
+
+// AddressParts is the structured address of a person.
+type AddressParts struct {
+	Street     *string `json:"street,omitempty"`
+	City       *string `json:"city,omitempty"`
+	PostalCode *string `json:"postal_code,omitempty"`
+	Country    *string `json:"country,omitempty"`
+}
+
+// Person is a person as the service returns it. The age is computed from the birth date if the
+// person has one.
+type Person struct {
+	ID           int                    `json:"id"`
+	Name         string                 `json:"name"`
+	Age          int                    `json:"age"`
+	Address      string                 `json:"address"`
+	Work         string                 `json:"work"`
+	BirthDate    *string                `json:"birth_date,omitempty"`
+	Email        *string                `json:"email,omitempty"`
+	PhoneNumbers []string               `json:"phone_numbers,omitempty"`
+	AddressParts *AddressParts          `json:"address_parts,omitempty"`
+	Attributes   map[string]interface{} `json:"attributes,omitempty"`
+	Tags         []string               `json:"tags,omitempty"`
+	CreatedAt    *time.Time             `json:"created_at,omitempty"`
+	UpdatedAt    *time.Time             `json:"updated_at,omitempty"`
+}
+
+// PersonRequest creates a person or updates the fields it sets of one. The name is required
+// in both cases.
+type PersonRequest struct {
+	Name         *string                `json:"name,omitempty"`
+	Age          *int                   `json:"age,omitempty"`
+	BirthDate    *string                `json:"birth_date,omitempty"`
+	Email        *string                `json:"email,omitempty"`
+	PhoneNumbers []string               `json:"phone_numbers,omitempty"`
+	Address      *string                `json:"address,omitempty"`
+	AddressParts *AddressParts          `json:"address_parts,omitempty"`
+	Work         *string                `json:"work,omitempty"`
+	Attributes   map[string]interface{} `json:"attributes,omitempty"`
+	Tags         []string               `json:"tags,omitempty"`
+}
+
+// ListOptions filter and page the listed persons.
+type ListOptions struct {
+	// Tags the persons must all have.
+	Tags []string
+	// Attributes the persons must have. Values are JSON literals, e.g. 3 or "3", or plain
+	// strings.
+	Attributes map[string]string
+	// Limit is the maximum number of persons to return, all of them if 0.
+	Limit int
+	// Offset is the number of persons ordered by ID to skip.
+	Offset int
+}
+
+func (o ListOptions) query() url.Values {
+	query := url.Values{}
+	for _, tag := range o.Tags {
+		query.Add("tag", tag)
+	}
+	for name, value := range o.Attributes {
+		query.Add("attr."+name, value)
+	}
+	if o.Limit > 0 {
+		query.Set("limit", strconv.Itoa(o.Limit))
+	}
+	if o.Offset > 0 {
+		query.Set("offset", strconv.Itoa(o.Offset))
+	}
+	return query
+}
+
+// Page is a page of the listed persons.
+type Page struct {
+	Persons []Person
+	// Total is the number of persons matching the filters regardless of the page.
+	Total int
+}
+
+func personPath(id int) string {
+	return personsPath + "/" + strconv.Itoa(id)
+}
+
+// CreatePerson creates a person and returns its ID. A person that clashes with an existing one
+// under a unique constraint fails with a conflict *Error whose location is the existing one.
+func (c *Client) CreatePerson(ctx context.Context, req PersonRequest, editors ...RequestEditorFn) (int, error) {
+	resp, err := c.do(ctx, http.MethodPost, personsPath, nil, req, editors)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := strconv.Atoi(path.Base(resp.header.Get("Location")))
+	if err != nil {
+		return 0, errors.Errorf("unexpected location %q of created person", resp.header.Get("Location"))
+	}
+	return id, nil
+}
+
+// GetPerson returns the person with id. The person a merged one was merged into is returned for
+// its ID.
+func (c *Client) GetPerson(ctx context.Context, id int, editors ...RequestEditorFn) (Person, error) {
+	resp, err := c.do(ctx, http.MethodGet, personPath(id), nil, nil, editors)
+	if err != nil {
+		return Person{}, err
+	}
+
+	var p Person
+	if err = json.Unmarshal(resp.body, &p); err != nil {
+		return Person{}, errors.Wrap(err, "failed to unmarshal person")
+	}
+	return p, nil
+}
+
+// ListPersons returns the page of persons opts select.
+func (c *Client) ListPersons(ctx context.Context, opts ListOptions, editors ...RequestEditorFn) (Page, error) {
+	resp, err := c.do(ctx, http.MethodGet, personsPath, opts.query(), nil, editors)
+	if err != nil {
+		return Page{}, err
+	}
+
+	page := Page{}
+	if err = json.Unmarshal(resp.body, &page.Persons); err != nil {
+		return Page{}, errors.Wrap(err, "failed to unmarshal persons")
+	}
+	page.Total, err = strconv.Atoi(resp.header.Get("X-Total-Count"))
+	if err != nil {
+		page.Total = opts.Offset + len(page.Persons)
+	}
+	return page, nil
+}
+
+// UpdatePerson sets the fields req sets of the person with id and returns the updated person.
+func (c *Client) UpdatePerson(ctx context.Context, id int, req PersonRequest, editors ...RequestEditorFn) (Person, error) {
+	resp, err := c.do(ctx, http.MethodPatch, personPath(id), nil, req, editors)
+	if err != nil {
+		return Person{}, err
+	}
+
+	var p Person
+	if err = json.Unmarshal(resp.body, &p); err != nil {
+		return Person{}, errors.Wrap(err, "failed to unmarshal person")
+	}
+	return p, nil
+}
+
+// DeletePerson removes the person with id.
+func (c *Client) DeletePerson(ctx context.Context, id int, editors ...RequestEditorFn) error {
+	_, err := c.do(ctx, http.MethodDelete, personPath(id), nil, nil, editors)
+	return err
+}
+
+// defaultPageSize is the page size of iterators whose options set no limit.
+const defaultPageSize = 100
+
+// PersonIterator walks the persons matching the filters of its options page by page:
+//
+//	it := c.IteratePersons(client.ListOptions{Tags: []string{"vip"}})
+//	for it.Next(ctx) {
+//		p := it.Person()
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+//
+// Pages are selected by offset, so persons created or removed while iterating may be skipped or
+// returned twice.
+type PersonIterator struct {
+	client  *Client
+	opts    ListOptions
+	editors []RequestEditorFn
+	page    []Person
+	current Person
+	done    bool
+	err     error
+}
+
+// IteratePersons returns an iterator over the persons matching the filters of opts, starting at
+// its offset and fetching pages of its limit, 100 if it sets none.
+func (c *Client) IteratePersons(opts ListOptions, editors ...RequestEditorFn) *PersonIterator {
+	if opts.Limit <= 0 {
+		opts.Limit = defaultPageSize
+	}
+	return &PersonIterator{client: c, opts: opts, editors: editors}
+}
+
+// Next advances to the next person, fetching the next page when the current one is used up. It
+// returns false when there are no more persons or fetching a page failed.
+func (it *PersonIterator) Next(ctx context.Context) bool {
+	if it.err != nil {
+		return false
+	}
+
+	if len(it.page) == 0 && !it.done {
+		page, err := it.client.ListPersons(ctx, it.opts, it.editors...)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		it.page = page.Persons
+		it.opts.Offset += len(page.Persons)
+		it.done = len(page.Persons) < it.opts.Limit || it.opts.Offset >= page.Total
+	}
+	if len(it.page) == 0 {
+		return false
+	}
+
+	it.current, it.page = it.page[0], it.page[1:]
+	return true
+}
+
+// Person is the person Next advanced to.
+func (it *PersonIterator) Person() Person {
+	return it.current
+}
+
+// Err is the error that stopped the iteration, if any.
+func (it *PersonIterator) Err() error {
+	return it.err
+}
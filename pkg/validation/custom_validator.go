@@ -1,17 +1,137 @@
 package validation
 
 import (
+	"errors"
+	ut "github.com/go-playground/universal-translator"
 	"github.com/go-playground/validator/v10"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
 )
 
+const (
+	DateLayout = "2006-01-02"
+
+	maxAge = 150
+)
+
+var e164Regex = regexp.MustCompile(`^\+[1-9]\d{1,14}$`)
+
 type CustomValidator struct {
-	validator *validator.Validate
+	validator  *validator.Validate
+	translator *ut.UniversalTranslator
 }
 
+// MustRegisterCustomValidator registers the json field names, the default translations and
+// the custom tags on v and panics if one of them cannot be registered:
+//
+//	phone     - phone number in E.164 format, e.g. +79991234567
+//	age       - age between 0 and 150
+//	past_date - date in the 2006-01-02 layout that is not in the future
 func MustRegisterCustomValidator(v *validator.Validate) *CustomValidator {
-	return &CustomValidator{validator: v}
+	v.RegisterTagNameFunc(jsonFieldName)
+
+	translator, err := newTranslator(v)
+	if err != nil {
+		panic(err)
+	}
+
+	cv := &CustomValidator{validator: v, translator: translator}
+	mustRegister(cv, "phone", validatePhone, Messages{
+		"en": "{0} must be a phone number in E.164 format",
+		"ru": "{0} должен быть номером телефона в формате E.164",
+	})
+	mustRegister(cv, "age", validateAge, Messages{
+		"en": "{0} must be between 0 and 150",
+		"ru": "{0} должен быть от 0 до 150",
+	})
+	mustRegister(cv, "past_date", validatePastDate, Messages{
+		"en": "{0} must be a date in the YYYY-MM-DD format that is not in the future",
+		"ru": "{0} должен быть датой в формате ГГГГ-ММ-ДД не позже сегодняшней",
+	})
+	return cv
 }
 
+func mustRegister(cv *CustomValidator, tag string, fn validator.Func, messages Messages) {
+	if err := cv.RegisterValidation(tag, fn, messages); err != nil {
+		panic(err)
+	}
+}
+
+// RegisterValidation adds the tag validated by fn together with its messages.
+func (cv *CustomValidator) RegisterValidation(tag string, fn validator.Func, messages Messages) error {
+	if err := cv.validator.RegisterValidation(tag, fn); err != nil {
+		return err
+	}
+	return cv.RegisterTranslation(tag, messages)
+}
+
+// RegisterStructValidation adds a struct-level rule for types. The tags fn reports must have
+// their messages registered with RegisterTranslation.
+func (cv *CustomValidator) RegisterStructValidation(fn validator.StructLevelFunc, types ...interface{}) {
+	cv.validator.RegisterStructValidation(fn, types...)
+}
+
+// RegisterTranslation sets the messages of tag, overriding the default ones. Locales missing
+// in messages get the english message.
+func (cv *CustomValidator) RegisterTranslation(tag string, messages Messages) error {
+	if _, ok := messages[fallbackLocale]; !ok {
+		return errors.New("validation: no " + fallbackLocale + " message for tag " + tag)
+	}
+
+	for _, locale := range supportedLocales {
+		message, ok := messages[locale]
+		if !ok {
+			message = messages[fallbackLocale]
+		}
+
+		trans, _ := cv.translator.GetTranslator(locale)
+		err := cv.validator.RegisterTranslation(tag, trans, func(trans ut.Translator) error {
+			return trans.Add(tag, message, true)
+		}, translateField)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Validate returns an *Error when i breaks any of the rules.
 func (cv *CustomValidator) Validate(i interface{}) error {
-	return cv.validator.Struct(i)
+	err := cv.validator.Struct(i)
+
+	var errs validator.ValidationErrors
+	if errors.As(err, &errs) {
+		return &Error{errs: errs, translator: cv.translator}
+	}
+	return err
+}
+
+func jsonFieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return field.Name
+	}
+	return name
+}
+
+func validatePhone(fl validator.FieldLevel) bool {
+	return e164Regex.MatchString(fl.Field().String())
+}
+
+func validateAge(fl validator.FieldLevel) bool {
+	age := fl.Field().Int()
+	return age >= 0 && age <= maxAge
+}
+
+func validatePastDate(fl validator.FieldLevel) bool {
+	date, err := time.Parse(DateLayout, fl.Field().String())
+	if err != nil {
+		return false
+	}
+	return !date.After(time.Now().UTC())
 }
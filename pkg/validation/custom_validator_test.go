@@ -0,0 +1,125 @@
+package validation
+
+import (
+	"github.com/go-playground/validator/v10"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func Test_CustomValidator(t *testing.T) {
+	type request struct {
+		Phones    []string `validate:"omitempty,dive,phone"`
+		Age       *int     `validate:"omitempty,age"`
+		BirthDate *string  `validate:"omitempty,past_date"`
+	}
+
+	getPointerOnInt := func(i int) *int { return &i }
+	getPointerOnString := func(s string) *string { return &s }
+
+	cv := MustRegisterCustomValidator(validator.New())
+
+	tests := []struct {
+		name    string
+		req     request
+		isValid bool
+	}{
+		{name: "empty", req: request{}, isValid: true},
+		{name: "valid phone", req: request{Phones: []string{"+79991234567"}}, isValid: true},
+		{name: "phone without plus", req: request{Phones: []string{"79991234567"}}, isValid: false},
+		{name: "phone with letters", req: request{Phones: []string{"+7999abc"}}, isValid: false},
+		{name: "zero age", req: request{Age: getPointerOnInt(0)}, isValid: true},
+		{name: "negative age", req: request{Age: getPointerOnInt(-1)}, isValid: false},
+		{name: "too old", req: request{Age: getPointerOnInt(200)}, isValid: false},
+		{name: "past date", req: request{BirthDate: getPointerOnString("1990-01-31")}, isValid: true},
+		{name: "future date", req: request{BirthDate: getPointerOnString(time.Now().AddDate(1, 0, 0).Format(DateLayout))}, isValid: false},
+		{name: "wrong date", req: request{BirthDate: getPointerOnString("31.01.1990")}, isValid: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := cv.Validate(tt.req)
+			require.Equal(t, tt.isValid, err == nil, err)
+		})
+	}
+}
+
+func Test_Error_Translate(t *testing.T) {
+	type address struct {
+		City string `json:"city" validate:"max=3"`
+	}
+	type request struct {
+		Name    string   `json:"name" validate:"required"`
+		Phones  []string `json:"phone_numbers" validate:"dive,phone"`
+		Address address  `json:"address_parts"`
+	}
+
+	cv := MustRegisterCustomValidator(validator.New())
+
+	err := cv.Validate(request{Phones: []string{"123"}, Address: address{City: "Moscow"}})
+	var verr *Error
+	require.ErrorAs(t, err, &verr)
+
+	tests := []struct {
+		name           string
+		acceptLanguage string
+		expected       map[string]string
+	}{
+		{
+			name:           "default language",
+			acceptLanguage: "",
+			expected: map[string]string{
+				"name":               "name is a required field",
+				"phone_numbers[0]":   "phone_numbers[0] must be a phone number in E.164 format",
+				"address_parts.city": "city must be a maximum of 3 characters in length",
+			},
+		},
+		{
+			name:           "preferred russian",
+			acceptLanguage: "de;q=0.9, ru-RU, en;q=0.8",
+			expected: map[string]string{
+				"name":               "name обязательное поле",
+				"phone_numbers[0]":   "phone_numbers[0] должен быть номером телефона в формате E.164",
+				"address_parts.city": "city должен содержать максимум 3 символа",
+			},
+		},
+		{
+			name:           "unsupported language",
+			acceptLanguage: "de",
+			expected: map[string]string{
+				"name":               "name is a required field",
+				"phone_numbers[0]":   "phone_numbers[0] must be a phone number in E.164 format",
+				"address_parts.city": "city must be a maximum of 3 characters in length",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, verr.Translate(tt.acceptLanguage))
+		})
+	}
+}
+
+func Test_CustomValidator_RegisterStructValidation(t *testing.T) {
+	type request struct {
+		From int `json:"from"`
+		To   int `json:"to"`
+	}
+
+	cv := MustRegisterCustomValidator(validator.New())
+	cv.RegisterStructValidation(func(sl validator.StructLevel) {
+		r := sl.Current().Interface().(request)
+		if r.From > r.To {
+			sl.ReportError(r.To, "to", "To", "gtefield_from", "")
+		}
+	}, request{})
+	require.NoError(t, cv.RegisterTranslation("gtefield_from", Messages{"en": "{0} must not be less than from"}))
+	require.Error(t, cv.RegisterTranslation("gtefield_from", Messages{"ru": "{0} должен быть не меньше from"}))
+
+	require.NoError(t, cv.Validate(request{From: 1, To: 2}))
+
+	var verr *Error
+	require.ErrorAs(t, cv.Validate(request{From: 2, To: 1}), &verr)
+	require.Equal(t, map[string]string{"to": "to must not be less than from"}, verr.Translate("ru"))
+}
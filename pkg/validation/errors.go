@@ -0,0 +1,43 @@
+package validation
+
+import (
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	"strings"
+)
+
+// Error is returned by Validate for the values that break validation rules.
+type Error struct {
+	errs       validator.ValidationErrors
+	translator *ut.UniversalTranslator
+}
+
+func (e *Error) Error() string {
+	return e.errs.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.errs
+}
+
+// Translate returns the messages keyed by the json path of the field, e.g.
+// "address_parts.city" or "phone_numbers[0]", in the language chosen by an Accept-Language
+// header value.
+func (e *Error) Translate(acceptLanguage string) map[string]string {
+	trans := findTranslator(e.translator, acceptLanguage)
+
+	messages := make(map[string]string, len(e.errs))
+	for _, fe := range e.errs {
+		messages[fieldPath(fe)] = fe.Translate(trans)
+	}
+	return messages
+}
+
+// fieldPath drops the struct name from the namespace of fe.
+func fieldPath(fe validator.FieldError) string {
+	_, path, ok := strings.Cut(fe.Namespace(), ".")
+	if !ok {
+		return fe.Field()
+	}
+	return path
+}
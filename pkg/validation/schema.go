@@ -0,0 +1,108 @@
+package validation
+
+import (
+	"github.com/pkg/errors"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DefaultTenant is the tenant whose schema applies when the tenant of a document has none.
+const DefaultTenant = "default"
+
+// SchemaRegistry validates JSON documents against the JSON Schema of their tenant.
+type SchemaRegistry struct {
+	schemas map[string]*jsonschema.Schema
+}
+
+// NewSchemaRegistry compiles the schema files keyed by tenant.
+func NewSchemaRegistry(files map[string]string) (*SchemaRegistry, error) {
+	r := &SchemaRegistry{schemas: make(map[string]*jsonschema.Schema, len(files))}
+	for tenant, file := range files {
+		path, err := filepath.Abs(file)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to resolve schema of tenant %q", tenant)
+		}
+		schema, err := jsonschema.Compile(path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to compile schema of tenant %q", tenant)
+		}
+		r.schemas[tenant] = schema
+	}
+	return r, nil
+}
+
+// Validate checks doc, a value decoded from JSON, against the schema of tenant or, if the
+// tenant has none, the default one. Documents of tenants without any schema are valid. It
+// returns a *SchemaError if doc does not conform.
+func (r *SchemaRegistry) Validate(tenant string, doc interface{}) error {
+	schema, ok := r.schemas[tenant]
+	if !ok {
+		schema, ok = r.schemas[DefaultTenant]
+	}
+	if !ok {
+		return nil
+	}
+
+	err := schema.Validate(doc)
+	var verr *jsonschema.ValidationError
+	if errors.As(err, &verr) {
+		serr := &SchemaError{Fields: make(map[string]string)}
+		collectSchemaErrors(verr, serr.Fields)
+		return serr
+	}
+	return err
+}
+
+// collectSchemaErrors keeps the innermost causes of verr, the ones that name the failed rule.
+func collectSchemaErrors(verr *jsonschema.ValidationError, fields map[string]string) {
+	if len(verr.Causes) == 0 {
+		path := instancePath(verr.InstanceLocation)
+		if _, ok := fields[path]; !ok {
+			fields[path] = verr.Message
+		}
+		return
+	}
+	for _, cause := range verr.Causes {
+		collectSchemaErrors(cause, fields)
+	}
+}
+
+// instancePath converts a JSON pointer like "/phones/0" to "phones[0]", the form of Error
+// field paths.
+func instancePath(pointer string) string {
+	var b strings.Builder
+	for _, token := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		if token == "" {
+			continue
+		}
+		token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+		if strings.Trim(token, "0123456789") == "" {
+			b.WriteString("[" + token + "]")
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(token)
+	}
+	return b.String()
+}
+
+// SchemaError is returned by SchemaRegistry.Validate for a document that does not conform to
+// its schema.
+type SchemaError struct {
+	// Fields holds the messages keyed by the path of the value in the document; the document
+	// itself has the empty path.
+	Fields map[string]string
+}
+
+func (e *SchemaError) Error() string {
+	messages := make([]string, 0, len(e.Fields))
+	for path, message := range e.Fields {
+		messages = append(messages, path+": "+message)
+	}
+	sort.Strings(messages)
+	return "schema validation failed: " + strings.Join(messages, "; ")
+}
@@ -0,0 +1,86 @@
+package validation
+
+import (
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_SchemaRegistry(t *testing.T) {
+	dir := t.TempDir()
+	writeSchema := func(name, schema string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(schema), 0o600))
+		return path
+	}
+
+	r, err := NewSchemaRegistry(map[string]string{
+		DefaultTenant: writeSchema("default.json", `{"type": "object", "maxProperties": 1}`),
+		"sales": writeSchema("sales.json", `{
+			"type": "object",
+			"properties": {
+				"region": {"type": "string"},
+				"quotas": {"type": "array", "items": {"type": "integer"}}
+			},
+			"required": ["region"]
+		}`),
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		tenant   string
+		doc      interface{}
+		expected map[string]string
+	}{
+		{name: "valid", tenant: "sales", doc: map[string]interface{}{"region": "EU", "quotas": []interface{}{1.0}}},
+		{
+			name:     "wrong nested type",
+			tenant:   "sales",
+			doc:      map[string]interface{}{"region": "EU", "quotas": []interface{}{"one"}},
+			expected: map[string]string{"quotas[0]": "expected integer, but got string"},
+		},
+		{
+			name:     "missing property",
+			tenant:   "sales",
+			doc:      map[string]interface{}{},
+			expected: map[string]string{"": "missing properties: 'region'"},
+		},
+		{name: "unknown tenant uses default", tenant: "hr", doc: map[string]interface{}{"a": 1.0}},
+		{
+			name:     "default schema",
+			tenant:   "",
+			doc:      map[string]interface{}{"a": 1.0, "b": 2.0},
+			expected: map[string]string{"": "maximum 1 properties allowed, but found 2 properties"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := r.Validate(tt.tenant, tt.doc)
+			if tt.expected == nil {
+				require.NoError(t, err)
+				return
+			}
+
+			var serr *SchemaError
+			require.ErrorAs(t, err, &serr)
+			require.Equal(t, tt.expected, serr.Fields)
+		})
+	}
+}
+
+func Test_SchemaRegistry_WithoutSchemas(t *testing.T) {
+	r, err := NewSchemaRegistry(nil)
+	require.NoError(t, err)
+	require.NoError(t, r.Validate("sales", map[string]interface{}{"anything": true}))
+}
+
+func Test_NewSchemaRegistry_InvalidSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "invalid.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"type": 1}`), 0o600))
+
+	_, err := NewSchemaRegistry(map[string]string{DefaultTenant: path})
+	require.Error(t, err)
+}
@@ -0,0 +1,84 @@
+package validation
+
+import (
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/ru"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	en_translations "github.com/go-playground/validator/v10/translations/en"
+	ru_translations "github.com/go-playground/validator/v10/translations/ru"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const fallbackLocale = "en"
+
+var supportedLocales = []string{"en", "ru"}
+
+// Messages maps a locale to the message template of a tag. {0} is replaced with the field
+// name and {1} with the tag parameter.
+type Messages map[string]string
+
+func newTranslator(v *validator.Validate) (*ut.UniversalTranslator, error) {
+	translator := ut.New(en.New(), en.New(), ru.New())
+
+	enTrans, _ := translator.GetTranslator("en")
+	if err := en_translations.RegisterDefaultTranslations(v, enTrans); err != nil {
+		return nil, err
+	}
+
+	ruTrans, _ := translator.GetTranslator("ru")
+	if err := ru_translations.RegisterDefaultTranslations(v, ruTrans); err != nil {
+		return nil, err
+	}
+	return translator, nil
+}
+
+func translateField(trans ut.Translator, fe validator.FieldError) string {
+	message, err := trans.T(fe.Tag(), fe.Field(), fe.Param())
+	if err != nil {
+		return fe.Error()
+	}
+	return message
+}
+
+// findTranslator picks the translator of the most preferred language in an Accept-Language
+// header value, e.g. "ru-RU,ru;q=0.9,en;q=0.8", or the english one.
+func findTranslator(translator *ut.UniversalTranslator, acceptLanguage string) ut.Translator {
+	type language struct {
+		tag     string
+		quality float64
+	}
+
+	languages := make([]language, 0)
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		quality := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(q, 64)
+			if err != nil || parsed <= 0 {
+				continue
+			}
+			quality = parsed
+		}
+		languages = append(languages, language{tag: tag, quality: quality})
+	}
+	sort.SliceStable(languages, func(i, j int) bool {
+		return languages[i].quality > languages[j].quality
+	})
+
+	locales := make([]string, 0, len(languages)*2)
+	for _, l := range languages {
+		locale := strings.ReplaceAll(l.tag, "-", "_")
+		base, _, _ := strings.Cut(locale, "_")
+		locales = append(locales, locale, base)
+	}
+
+	trans, _ := translator.FindTranslator(locales...)
+	return trans
+}